@@ -1,9 +1,12 @@
 package api
 
 import (
+	"errors"
 	"net/http"
+	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/shopspring/decimal"
@@ -12,37 +15,11 @@ import (
 func (h *Handler) GetAlerts(c *gin.Context) {
 	userID := c.MustGet("userID").(int64)
 
-	rows, err := h.db.Query(c.Request.Context(),
-		"SELECT id, symbol, condition_type, target_value, is_active, created_at FROM alerts WHERE user_id = $1",
-		userID)
+	alerts, err := h.store.Alerts.ListAlerts(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch alerts"})
 		return
 	}
-	defer rows.Close()
-
-	alerts := make([]map[string]interface{}, 0)
-	for rows.Next() {
-		var (
-			id            int64
-			symbol        string
-			conditionType string
-			targetValue   decimal.Decimal
-			isActive      bool
-			createdAt     time.Time
-		)
-		if err := rows.Scan(&id, &symbol, &conditionType, &targetValue, &isActive, &createdAt); err != nil {
-			continue
-		}
-		alerts = append(alerts, map[string]interface{}{
-			"id":             id,
-			"symbol":         symbol,
-			"condition_type": conditionType,
-			"target_value":   targetValue,
-			"is_active":      isActive,
-			"created_at":     createdAt,
-		})
-	}
 
 	c.JSON(http.StatusOK, alerts)
 }
@@ -60,11 +37,12 @@ func (h *Handler) CreateAlert(c *gin.Context) {
 		return
 	}
 
-	var alertID int64
-	err := h.db.QueryRow(c.Request.Context(),
-		"INSERT INTO alerts (user_id, symbol, condition_type, target_value) VALUES ($1, $2, $3, $4) RETURNING id",
-		userID, strings.ToUpper(req.Symbol), req.ConditionType, req.TargetValue).Scan(&alertID)
-
+	alertID, err := h.store.Alerts.CreateAlert(c.Request.Context(), model.Alert{
+		UserID:        userID,
+		Symbol:        strings.ToUpper(req.Symbol),
+		ConditionType: req.ConditionType,
+		TargetValue:   req.TargetValue,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create alert"})
 		return
@@ -75,17 +53,18 @@ func (h *Handler) CreateAlert(c *gin.Context) {
 
 func (h *Handler) DeleteAlert(c *gin.Context) {
 	userID := c.MustGet("userID").(int64)
-	alertID := c.Param("id")
-
-	result, err := h.db.Exec(c.Request.Context(),
-		"DELETE FROM alerts WHERE id = $1 AND user_id = $2", alertID, userID)
+	alertID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete alert"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alert id"})
 		return
 	}
 
-	if result.RowsAffected() == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "alert not found"})
+	if err := h.store.Alerts.DeleteAlert(c.Request.Context(), userID, alertID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "alert not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete alert"})
 		return
 	}
 
@@ -3,6 +3,7 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"quant-trader/internal/model"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,34 +13,11 @@ import (
 func (h *Handler) ListAPIKeys(c *gin.Context) {
 	userID := c.MustGet("userID").(int64)
 
-	rows, err := h.db.Query(c.Request.Context(),
-		"SELECT id, key_id, name, is_active, created_at FROM api_keys WHERE user_id = $1", userID)
+	keys, err := h.store.Users.ListAPIKeys(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch API keys"})
 		return
 	}
-	defer rows.Close()
-
-	var keys []map[string]interface{}
-	for rows.Next() {
-		var (
-			id       int64
-			keyID    string
-			name     string
-			isActive bool
-			cat      time.Time
-		)
-		if err := rows.Scan(&id, &keyID, &name, &isActive, &cat); err != nil {
-			continue
-		}
-		keys = append(keys, map[string]interface{}{
-			"id":         id,
-			"key_id":     keyID,
-			"name":       name,
-			"is_active":  isActive,
-			"created_at": cat,
-		})
-	}
 
 	c.JSON(http.StatusOK, keys)
 }
@@ -59,10 +37,12 @@ func (h *Handler) CreateAPIKey(c *gin.Context) {
 	rawSecret := fmt.Sprintf("sec_%d_%d", userID, time.Now().UnixNano())
 	hash, _ := bcrypt.GenerateFromPassword([]byte(rawSecret), bcrypt.DefaultCost)
 
-	_, err := h.db.Exec(c.Request.Context(),
-		"INSERT INTO api_keys (user_id, key_id, key_secret, name) VALUES ($1, $2, $3, $4)",
-		userID, keyID, string(hash), req.Name)
-
+	err := h.store.Users.CreateAPIKey(c.Request.Context(), model.APIKey{
+		UserID:    userID,
+		KeyID:     keyID,
+		KeySecret: string(hash),
+		Name:      req.Name,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create API key"})
 		return
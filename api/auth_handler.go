@@ -1,8 +1,10 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"quant-trader/api/middleware"
+	"quant-trader/internal/storage"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -26,14 +28,14 @@ func (h *Handler) Register(c *gin.Context) {
 		return
 	}
 
-	var userID int64
-	err = h.db.QueryRow(c.Request.Context(),
-		"INSERT INTO users (email, password_hash) VALUES ($1, $2) RETURNING id",
-		req.Email, string(hash)).Scan(&userID)
-
+	userID, err := h.store.Users.CreateUser(c.Request.Context(), req.Email, string(hash))
 	if err != nil {
+		if errors.Is(err, storage.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "email already exists"})
+			return
+		}
 		h.logger.Error("failed to register user", zap.Error(err))
-		c.JSON(http.StatusConflict, gin.H{"error": "email already exists"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register user"})
 		return
 	}
 
@@ -51,22 +53,18 @@ func (h *Handler) Login(c *gin.Context) {
 		return
 	}
 
-	var userID int64
-	var hash string
-	err := h.db.QueryRow(c.Request.Context(),
-		"SELECT id, password_hash FROM users WHERE email = $1", req.Email).Scan(&userID, &hash)
-
+	user, err := h.store.Users.GetUserByEmail(c.Request.Context(), req.Email)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(req.Password)); err != nil {
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
 		return
 	}
 
-	token, err := middleware.GenerateToken(userID)
+	token, err := middleware.GenerateToken(user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
 		return
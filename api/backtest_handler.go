@@ -0,0 +1,211 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"quant-trader/internal/analytics"
+	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxCompareRuns bounds how many runs one comparison loads with their reports
+const maxCompareRuns = 10
+
+// ListBacktestRuns returns the user's latest runs without their reports
+func (h *Handler) ListBacktestRuns(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+		return
+	}
+
+	runs, err := h.store.Backtests.ListBacktestRuns(c.Request.Context(), userID, limit)
+	if err != nil {
+		h.logger.Error("failed to list backtest runs", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// GetBacktestRun returns a run with its report once it is done
+func (h *Handler) GetBacktestRun(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+	runID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid run id"})
+		return
+	}
+
+	run, err := h.store.Backtests.GetBacktestRun(c.Request.Context(), userID, runID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "backtest run not found"})
+			return
+		}
+		h.logger.Error("failed to get backtest run", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// CancelBacktestRun stops a queued or running backtest
+func (h *Handler) CancelBacktestRun(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+	runID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid run id"})
+		return
+	}
+
+	if err := h.backtests.Cancel(c.Request.Context(), userID, runID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "backtest run not found"})
+		case errors.Is(err, storage.ErrConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": "backtest run already finished"})
+		default:
+			h.logger.Error("failed to cancel backtest run", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel backtest run"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "backtest run cancelled"})
+}
+
+// runComparison is one column of a comparison: the run's setup and, once it
+// is done, its headline metrics and equity curve
+type runComparison struct {
+	ID           int64                `json:"id"`
+	Status       model.BacktestStatus `json:"status"`
+	StrategyType string               `json:"strategy_type"`
+	Config       json.RawMessage      `json:"config"`
+	Symbols      []string             `json:"symbols"`
+	Period       string               `json:"period"`
+	Metrics      *runMetrics          `json:"metrics,omitempty"`
+	EquityCurve  []model.EquityPoint  `json:"equity_curve,omitempty"`
+}
+
+type runMetrics struct {
+	TotalReturn  float64 `json:"total_return"`
+	CAGR         float64 `json:"cagr"`
+	SharpeRatio  float64 `json:"sharpe_ratio"`
+	SortinoRatio float64 `json:"sortino_ratio"`
+	CalmarRatio  float64 `json:"calmar_ratio"`
+	MaxDrawdown  float64 `json:"max_drawdown"`
+	WinRate      float64 `json:"win_rate"`
+	ProfitFactor float64 `json:"profit_factor"`
+	ClosedTrades int     `json:"closed_trades"`
+	Exposure     float64 `json:"exposure"`
+}
+
+// CompareBacktestRuns puts the metrics of several runs side by side, e.g.
+// ?ids=3,5,8, in the order given
+func (h *Handler) CompareBacktestRuns(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+
+	var ids []int64
+	for _, s := range strings.Split(c.Query("ids"), ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ids must be a comma separated list of run ids"})
+			return
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) < 2 || len(ids) > maxCompareRuns {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "compare between 2 and 10 runs"})
+		return
+	}
+
+	runs := make([]runComparison, 0, len(ids))
+	for _, id := range ids {
+		run, err := h.store.Backtests.GetBacktestRun(c.Request.Context(), userID, id)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "backtest run not found: " + strconv.FormatInt(id, 10)})
+				return
+			}
+			h.logger.Error("failed to get backtest run", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+
+		cmp := runComparison{
+			ID:           run.ID,
+			Status:       run.Status,
+			StrategyType: run.StrategyType,
+			Config:       run.Config,
+			Symbols:      run.Symbols,
+			Period:       run.Period,
+		}
+		if r := run.Report; r != nil {
+			cmp.Metrics = &runMetrics{
+				TotalReturn:  r.TotalReturn.InexactFloat64(),
+				CAGR:         r.CAGR,
+				SharpeRatio:  r.SharpeRatio,
+				SortinoRatio: r.SortinoRatio,
+				CalmarRatio:  r.CalmarRatio,
+				MaxDrawdown:  r.MaxDrawdown,
+				WinRate:      r.WinRate,
+				ProfitFactor: r.ProfitFactor,
+				ClosedTrades: r.ClosedTrades,
+				Exposure:     r.Exposure,
+			}
+			cmp.EquityCurve = r.EquityCurve
+		}
+		runs = append(runs, cmp)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+// RunMonteCarlo bootstraps the trades or returns of a finished run; the body
+// is an optional analytics.MonteCarloConfig. Fixing the seed makes the result
+// reproducible.
+func (h *Handler) RunMonteCarlo(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+	runID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid run id"})
+		return
+	}
+
+	var cfg analytics.MonteCarloConfig
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&cfg); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	run, err := h.store.Backtests.GetBacktestRun(c.Request.Context(), userID, runID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "backtest run not found"})
+			return
+		}
+		h.logger.Error("failed to get backtest run", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+	if run.Report == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "backtest run has no report", "status": run.Status})
+		return
+	}
+
+	result, err := analytics.MonteCarlo(*run.Report, cfg)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
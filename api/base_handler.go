@@ -3,28 +3,43 @@ package api
 import (
 	"os"
 	"quant-trader/internal/analytics"
+	"quant-trader/internal/engine"
 	"quant-trader/internal/payment"
 	"quant-trader/internal/risk"
+	"quant-trader/internal/storage"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 )
 
 type Handler struct {
-	db        *pgxpool.Pool
+	store     *storage.Store
 	logger    *zap.Logger
 	risk      *risk.RiskManager
 	analytics *analytics.AnalyticsService
 	stripe    *payment.StripeService
+	loader    *engine.DataLoader
+
+	strategies  *engine.StrategyLibrary
+	deployments *engine.DeploymentManager
+
+	backtests     *engine.BacktestQueue
+	optimizations *optimizationJobs
 }
 
-func NewHandler(db *pgxpool.Pool, logger *zap.Logger) *Handler {
+func NewHandler(store *storage.Store, logger *zap.Logger, backtests *engine.BacktestQueue, deployments *engine.DeploymentManager) *Handler {
 	stripeKey := os.Getenv("STRIPE_API_KEY")
 	return &Handler{
-		db:        db,
+		store:     store,
 		logger:    logger,
-		risk:      risk.NewRiskManager(db, logger),
-		analytics: analytics.NewAnalyticsService(db),
-		stripe:    payment.NewStripeService(db, logger, stripeKey),
+		risk:      risk.NewRiskManager(store.Paper, logger),
+		analytics: analytics.NewAnalyticsService(store.Paper, store.Market),
+		stripe:    payment.NewStripeService(store.Users, logger, stripeKey),
+		loader:    engine.NewDataLoader(store.Market),
+
+		strategies:  engine.NewStrategyLibrary(store.Strategies),
+		deployments: deployments,
+
+		backtests:     backtests,
+		optimizations: newOptimizationJobs(),
 	}
 }
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"quant-trader/internal/engine"
+	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// CreateDeployment runs a stored strategy version live on one symbol and period
+func (h *Handler) CreateDeployment(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+	var req struct {
+		StrategyVersionID int64              `json:"strategy_version_id" binding:"required"`
+		Symbol            string             `json:"symbol" binding:"required"`
+		Period            string             `json:"period" binding:"required"`
+		Capital           decimal.Decimal    `json:"capital"`
+		Paper             bool               `json:"paper"`
+		Sizing            model.SizingPolicy `json:"sizing"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	d, err := h.deployments.Deploy(c.Request.Context(), model.Deployment{
+		UserID:            userID,
+		StrategyVersionID: req.StrategyVersionID,
+		Symbol:            req.Symbol,
+		Period:            req.Period,
+		Capital:           req.Capital,
+		Paper:             req.Paper,
+		Sizing:            req.Sizing,
+	})
+	if err != nil {
+		h.deploymentError(c, err, "failed to create deployment")
+		return
+	}
+	c.JSON(http.StatusCreated, d)
+}
+
+// ListDeployments returns the user's deployments, newest first
+func (h *Handler) ListDeployments(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+	list, err := h.deployments.List(c.Request.Context(), userID)
+	if err != nil {
+		h.deploymentError(c, err, "failed to list deployments")
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *Handler) GetDeployment(c *gin.Context) {
+	h.withDeployment(c, h.deployments.Get, "failed to get deployment")
+}
+
+// StartDeployment resumes a paused deployment
+func (h *Handler) StartDeployment(c *gin.Context) {
+	h.withDeployment(c, h.deployments.Start, "failed to start deployment")
+}
+
+func (h *Handler) PauseDeployment(c *gin.Context) {
+	h.withDeployment(c, h.deployments.Pause, "failed to pause deployment")
+}
+
+func (h *Handler) StopDeployment(c *gin.Context) {
+	h.withDeployment(c, h.deployments.Stop, "failed to stop deployment")
+}
+
+// ListDeploymentExecutions returns what came of the latest signals a paper
+// deployment traded, with the orders they placed and their fills
+func (h *Handler) ListDeploymentExecutions(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid deployment id"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+		return
+	}
+
+	list, err := h.deployments.Executions(c.Request.Context(), userID, id, limit)
+	if err != nil {
+		h.deploymentError(c, err, "failed to list deployment executions")
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// withDeployment answers with the deployment op returns for the :id of the user
+func (h *Handler) withDeployment(c *gin.Context, op func(ctx context.Context, userID, deploymentID int64) (model.Deployment, error), msg string) {
+	userID := c.MustGet("userID").(int64)
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid deployment id"})
+		return
+	}
+
+	d, err := op(c.Request.Context(), userID, id)
+	if err != nil {
+		h.deploymentError(c, err, msg)
+		return
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+// deploymentError answers with the status matching err, logging unexpected ones
+func (h *Handler) deploymentError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "deployment not found"})
+	case errors.Is(err, storage.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "deployment is stopped"})
+	case errors.Is(err, engine.ErrInvalidDeployment):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+	}
+}
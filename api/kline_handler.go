@@ -2,11 +2,13 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"quant-trader/internal/engine"
 	"quant-trader/internal/model"
 	"quant-trader/internal/storage"
-	"quant-trader/internal/strategy"
+	"slices"
 	"strings"
 	"time"
 
@@ -20,80 +22,177 @@ func (h *Handler) GetHistoryKLines(c *gin.Context) {
 	symbol = strings.ReplaceAll(symbol, "/", "")
 	period := c.DefaultQuery("period", "1m")
 
-	rows, err := h.db.Query(c.Request.Context(),
-		"SELECT symbol, exchange, open, high, low, close, volume, time FROM klines WHERE symbol = $1 AND period = $2 ORDER BY time DESC LIMIT 100",
-		symbol, period)
+	klines, err := h.store.Market.QueryKlines(c.Request.Context(), storage.KlineQuery{
+		Symbol:     symbol,
+		Period:     period,
+		Limit:      100,
+		Descending: true,
+	})
 	if err != nil {
 		h.logger.Error("failed to query klines", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
 		return
 	}
-	defer rows.Close()
 
-	klines := make([]model.KLine, 0)
-	for rows.Next() {
-		var k model.KLine
-		if err := rows.Scan(&k.Symbol, &k.Exchange, &k.Open, &k.High, &k.Low, &k.Close, &k.Volume, &k.Timestamp); err != nil {
-			h.logger.Error("failed to scan kline", zap.Error(err))
-			continue
+	c.JSON(http.StatusOK, klines)
+}
+
+// marginRequest is the optional account setup of a backtest; omitted means spot
+type marginRequest struct {
+	Leverage          decimal.Decimal `json:"leverage"`
+	MaintenanceMargin decimal.Decimal `json:"maintenance_margin"`
+	AllowShort        bool            `json:"allow_short"`
+	FundingRate       decimal.Decimal `json:"funding_rate"`
+	FundingInterval   string          `json:"funding_interval"` // e.g. "8h"
+}
+
+func (r *marginRequest) config() (engine.MarginConfig, error) {
+	cfg := engine.SpotMargin()
+	if r == nil {
+		return cfg, nil
+	}
+	if !r.Leverage.IsZero() {
+		cfg.Leverage = r.Leverage
+	}
+	cfg.MaintenanceMargin = r.MaintenanceMargin
+	cfg.AllowShort = r.AllowShort
+	cfg.FundingRate = r.FundingRate
+	if r.FundingInterval != "" {
+		interval, err := time.ParseDuration(r.FundingInterval)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid funding_interval: %w", err)
 		}
-		k.Period = period
-		klines = append(klines, k)
+		cfg.FundingInterval = interval
 	}
+	return cfg, nil
+}
 
-	c.JSON(http.StatusOK, klines)
+// backtestRequest is the data range and account setup shared by backtests and
+// optimizations
+type backtestRequest struct {
+	Symbol         string                 `json:"symbol"`
+	Symbols        []string               `json:"symbols"` // portfolio backtest on a shared account
+	Exchange       string                 `json:"exchange"`
+	Period         string                 `json:"period"`
+	StrategyType   string                 `json:"strategy_type"`
+	Config         map[string]interface{} `json:"config"`
+	InitialBalance decimal.Decimal        `json:"initial_balance"`
+	StartTime      time.Time              `json:"start_time" binding:"required"`
+	EndTime        time.Time              `json:"end_time" binding:"required"`
+	Margin         *marginRequest         `json:"margin"`
+	Data           string                 `json:"data"` // candles (default) or ticks
+	// StrategyVersionID runs a stored strategy version instead of strategy_type and config
+	StrategyVersionID int64 `json:"strategy_version_id"`
+	engine.ExecutionSpec
 }
 
-func (h *Handler) RunBacktest(c *gin.Context) {
-	var req struct {
-		Symbol         string                 `json:"symbol" binding:"required"`
-		StrategyType   string                 `json:"strategy_type" binding:"required"`
-		Config         map[string]interface{} `json:"config"`
-		InitialBalance decimal.Decimal        `json:"initial_balance"`
-		StartTime      time.Time              `json:"start_time" binding:"required"`
-		EndTime        time.Time              `json:"end_time" binding:"required"`
+// spec converts the request into a BacktestSpec: symbols are normalized and
+// deduplicated in request order and the period defaults to 1m. The strategy
+// config is left to the caller to check, optimizations complete it per trial.
+func (r *backtestRequest) spec() (engine.BacktestSpec, error) {
+	var symbols []string
+	for _, s := range append([]string{r.Symbol}, r.Symbols...) {
+		symbol := strings.ReplaceAll(strings.ToUpper(s), "-", "")
+		symbol = strings.ReplaceAll(symbol, "/", "")
+		if symbol != "" && !slices.Contains(symbols, symbol) {
+			symbols = append(symbols, symbol)
+		}
+	}
+	if len(symbols) == 0 {
+		return engine.BacktestSpec{}, errors.New("symbol or symbols is required")
+	}
+	if (r.StrategyType == "") == (r.StrategyVersionID == 0) {
+		return engine.BacktestSpec{}, errors.New("set either strategy_type or strategy_version_id")
+	}
+	if r.StrategyType == "wasm" {
+		// Inline modules would skip the limit caps of stored versions
+		return engine.BacktestSpec{}, errors.New("wasm strategies run from a stored strategy_version_id")
+	}
+	if r.StrategyVersionID != 0 && r.Config != nil {
+		return engine.BacktestSpec{}, errors.New("the config of a strategy version cannot be changed")
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	period := r.Period
+	if period == "" {
+		period = "1m"
+	}
+	if !slices.Contains(model.SupportedPeriods, period) {
+		return engine.BacktestSpec{}, errors.New("unsupported period: " + period)
+	}
+	if !r.EndTime.After(r.StartTime) {
+		return engine.BacktestSpec{}, errors.New("end_time must be after start_time")
+	}
+	margin, err := r.Margin.config()
+	if err != nil {
+		return engine.BacktestSpec{}, err
 	}
 
-	symbol := strings.ReplaceAll(strings.ToUpper(req.Symbol), "-", "")
-	symbol = strings.ReplaceAll(symbol, "/", "")
+	spec := engine.BacktestSpec{
+		Symbols:        symbols,
+		Exchange:       strings.ToLower(r.Exchange),
+		Period:         period,
+		StrategyType:   r.StrategyType,
+		Config:         r.Config,
+		InitialBalance: r.InitialBalance,
+		Start:          r.StartTime,
+		End:            r.EndTime,
+		Margin:         margin,
+		Data:           r.Data,
+		ExecutionSpec:  r.ExecutionSpec,
+	}
+	return spec, nil
+}
 
-	// 1. Fetch history data for backtest
-	rows, err := h.db.Query(c.Request.Context(),
-		"SELECT symbol, exchange, open, high, low, close, volume, time FROM klines WHERE symbol = $1 AND time BETWEEN $2 AND $3 ORDER BY time ASC",
-		symbol, req.StartTime, req.EndTime)
+// backtestSpec converts req into a spec, pinning the strategy version it
+// names; on failure it has already answered the request
+func (h *Handler) backtestSpec(c *gin.Context, userID int64, req *backtestRequest) (engine.BacktestSpec, bool) {
+	spec, err := req.spec()
 	if err != nil {
-		h.logger.Error("failed to fetch history for backtest", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch data"})
-		return
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return spec, false
 	}
-	defer rows.Close()
-
-	klines := make([]model.KLine, 0)
-	for rows.Next() {
-		var k model.KLine
-		if err := rows.Scan(&k.Symbol, &k.Exchange, &k.Open, &k.High, &k.Low, &k.Close, &k.Volume, &k.Timestamp); err != nil {
-			continue
+	if req.StrategyVersionID == 0 {
+		return spec, true
+	}
+	if err := h.strategies.Pin(c.Request.Context(), userID, req.StrategyVersionID, &spec); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "strategy version not found"})
+			return spec, false
 		}
-		klines = append(klines, k)
+		h.logger.Error("failed to load strategy version", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load strategy version"})
+		return spec, false
 	}
+	return spec, true
+}
 
-	// 2. Setup Strategy
-	strat, err := strategy.NewStrategy(req.StrategyType, req.Config)
-	if err != nil {
+// RunBacktest queues a backtest for the workers; poll GET /backtest/runs/:id
+// for its status and report
+func (h *Handler) RunBacktest(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+
+	var req backtestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	spec, ok := h.backtestSpec(c, userID, &req)
+	if !ok {
+		return
+	}
+	if err := spec.Validate(); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 3. Run Backtest
-	tester := engine.NewBacktester(strat, req.InitialBalance)
-	report := tester.Run(klines)
+	run, err := h.backtests.Submit(c.Request.Context(), userID, spec)
+	if err != nil {
+		h.logger.Error("failed to queue backtest", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue backtest"})
+		return
+	}
 
-	c.JSON(http.StatusOK, report)
+	c.JSON(http.StatusAccepted, run)
 }
 
 func (h *Handler) TriggerBackfill(c *gin.Context) {
@@ -109,7 +208,7 @@ func (h *Handler) TriggerBackfill(c *gin.Context) {
 		return
 	}
 
-	backfiller := storage.NewHistoryBackfiller(h.db, h.logger)
+	backfiller := storage.NewHistoryBackfiller(h.store.Market, h.logger)
 
 	// Async run backfill
 	go func() {
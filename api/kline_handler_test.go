@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"quant-trader/internal/storage"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestBacktestEndpoints_RejectInlineWasm(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewHandler(storage.NewMemoryStore(), zap.NewNop(), nil, nil)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("userID", int64(1)) })
+	router.POST("/backtest", h.RunBacktest)
+	router.POST("/backtest/optimize", h.StartOptimization)
+	router.POST("/backtest/walkforward", h.StartWalkForward)
+
+	// An empty module asking for far more fuel than a stored version may
+	body := `{"symbol": "BTCUSDT", "period": "1m", "strategy_type": "wasm",
+		"config": {"module": "AGFzbQEAAAA=", "fuel": 1e15, "timeout_ms": 3600000},
+		"start_time": "2024-01-01T00:00:00Z", "end_time": "2024-01-02T00:00:00Z",
+		"params": [{"name": "fast", "values": [2, 3]}],
+		"in_sample_bars": 100, "out_of_sample_bars": 50}`
+	for _, path := range []string{"/backtest", "/backtest/optimize", "/backtest/walkforward"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		require.Equal(t, http.StatusBadRequest, w.Code, path)
+		var resp map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Contains(t, resp["error"], "strategy_version_id", path)
+	}
+}
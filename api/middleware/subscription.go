@@ -2,12 +2,12 @@ package middleware
 
 import (
 	"net/http"
+	"quant-trader/internal/storage"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func SubscriptionMiddleware(db *pgxpool.Pool) gin.HandlerFunc {
+func SubscriptionMiddleware(users storage.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, ok := c.Get("userID")
 		if !ok {
@@ -16,17 +16,13 @@ func SubscriptionMiddleware(db *pgxpool.Pool) gin.HandlerFunc {
 			return
 		}
 
-		var tierName string
-		var maxSymbols int
-		err := db.QueryRow(c.Request.Context(),
-			`SELECT t.name, t.max_symbols FROM user_subscriptions s 
-			 JOIN subscription_tiers t ON s.tier_id = t.id 
-			 WHERE s.user_id = $1 AND s.status = 'active'`, userID).Scan(&tierName, &maxSymbols)
-
-		if err != nil {
-			// Default to Free tier if no record found
-			tierName = "Free"
-			maxSymbols = 1
+		tierName := "Free"
+		maxSymbols := 1
+		// Default to Free tier if no active subscription is found
+		sub, err := users.GetSubscription(c.Request.Context(), userID.(int64))
+		if err == nil && sub.Status == "active" {
+			tierName = sub.TierName
+			maxSymbols = sub.MaxSymbols
 		}
 
 		c.Set("tier", tierName)
@@ -34,4 +30,3 @@ func SubscriptionMiddleware(db *pgxpool.Pool) gin.HandlerFunc {
 		c.Next()
 	}
 }
-
@@ -0,0 +1,317 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"quant-trader/internal/engine"
+	"quant-trader/internal/model"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxConcurrentOptimizations bounds the jobs running at once; the rest queue
+	maxConcurrentOptimizations = 2
+	// optimizationRetention is how long finished jobs stay available
+	optimizationRetention = 24 * time.Hour
+)
+
+// optimizationJob is an optimization or walk-forward analysis running in the background
+type optimizationJob struct {
+	ID         int64      `json:"id"`
+	Kind       string     `json:"kind"`   // optimization or walk_forward
+	Status     string     `json:"status"` // queued, loading, running, done, failed
+	Done       int        `json:"done"`
+	Total      int        `json:"total"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	userID      int64
+	result      *engine.OptimizationResult
+	walkForward *engine.WalkForwardResult
+}
+
+// optimizationJobs keeps optimization jobs in memory; they are lost on restart
+type optimizationJobs struct {
+	mu     sync.Mutex
+	nextID int64
+	jobs   map[int64]*optimizationJob
+	slots  chan struct{}
+}
+
+func newOptimizationJobs() *optimizationJobs {
+	return &optimizationJobs{
+		jobs:  make(map[int64]*optimizationJob),
+		slots: make(chan struct{}, maxConcurrentOptimizations),
+	}
+}
+
+func (s *optimizationJobs) create(userID int64, kind string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, job := range s.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > optimizationRetention {
+			delete(s.jobs, id)
+		}
+	}
+	s.nextID++
+	s.jobs[s.nextID] = &optimizationJob{ID: s.nextID, Kind: kind, Status: "queued", CreatedAt: now, userID: userID}
+	return s.nextID
+}
+
+func (s *optimizationJobs) update(id int64, fn func(job *optimizationJob)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job, ok := s.jobs[id]; ok {
+		fn(job)
+	}
+}
+
+func (s *optimizationJobs) progress(id int64) func(done, total int) {
+	return func(done, total int) {
+		s.update(id, func(job *optimizationJob) {
+			job.Done, job.Total = done, total
+		})
+	}
+}
+
+// finish marks a job failed with err, or done after storing its result with set
+func (s *optimizationJobs) finish(id int64, err error, set func(job *optimizationJob)) {
+	s.update(id, func(job *optimizationJob) {
+		now := time.Now()
+		job.FinishedAt = &now
+		if err != nil {
+			job.Status = "failed"
+			job.Error = err.Error()
+			return
+		}
+		job.Status = "done"
+		set(job)
+	})
+}
+
+// get returns a snapshot of a job of the given kind owned by userID
+func (s *optimizationJobs) get(userID, id int64, kind string) (optimizationJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok || job.userID != userID || job.Kind != kind {
+		return optimizationJob{}, false
+	}
+	return *job, true
+}
+
+// optimizeRequest is a backtest request with parameter ranges to search
+type optimizeRequest struct {
+	backtestRequest
+	Params    []engine.ParamRange `json:"params" binding:"required"`
+	Method    string              `json:"method"` // grid or random
+	Samples   int                 `json:"samples"`
+	Seed      int64               `json:"seed"`
+	Objective string              `json:"objective"` // sharpe, sortino, calmar, total_return, ...
+}
+
+// optimization validates the request and builds the search it describes over
+// the strategy of spec
+func (r *optimizeRequest) optimization(spec engine.BacktestSpec) (*engine.Optimization, error) {
+	if spec.Data != "" && spec.Data != "candles" {
+		return nil, errors.New("optimizations only run on candles")
+	}
+	opt := &engine.Optimization{
+		StrategyType:   spec.StrategyType,
+		BaseConfig:     spec.StrategyConfig(),
+		Params:         r.Params,
+		Method:         r.Method,
+		Samples:        r.Samples,
+		Seed:           r.Seed,
+		Objective:      r.Objective,
+		InitialBalance: r.InitialBalance,
+		Setup:          spec.Setup,
+	}
+	if err := opt.Validate(); err != nil {
+		return nil, err
+	}
+	return opt, nil
+}
+
+// StartOptimization sweeps strategy parameters over one data range in the
+// background and returns the job to poll
+func (h *Handler) StartOptimization(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+
+	var req optimizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	spec, ok := h.backtestSpec(c, userID, &req.backtestRequest)
+	if !ok {
+		return
+	}
+	opt, err := req.optimization(spec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := h.optimizations.create(userID, "optimization")
+	opt.Progress = h.optimizations.progress(id)
+	go h.runOptimizationJob(id, spec, func(ctx context.Context, candles map[string][]model.KLine) error {
+		result, err := opt.Optimize(ctx, candles)
+		h.optimizations.finish(id, err, func(job *optimizationJob) { job.result = result })
+		return err
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{"job_id": id, "status": "queued"})
+}
+
+// StartWalkForward runs a walk-forward analysis in the background: the
+// parameters are re-optimized on each in-sample window and traded on the
+// out-of-sample window after it
+func (h *Handler) StartWalkForward(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+
+	var req struct {
+		optimizeRequest
+		InSampleBars    int  `json:"in_sample_bars" binding:"required"`
+		OutOfSampleBars int  `json:"out_of_sample_bars" binding:"required"`
+		Anchored        bool `json:"anchored"`
+		PBOBlocks       int  `json:"pbo_blocks"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	spec, ok := h.backtestSpec(c, userID, &req.backtestRequest)
+	if !ok {
+		return
+	}
+	opt, err := req.optimization(spec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := h.optimizations.create(userID, "walk_forward")
+	wf := &engine.WalkForward{
+		Optimization:    opt,
+		InSampleBars:    req.InSampleBars,
+		OutOfSampleBars: req.OutOfSampleBars,
+		Anchored:        req.Anchored,
+		PBOBlocks:       req.PBOBlocks,
+		Progress:        h.optimizations.progress(id),
+	}
+	go h.runOptimizationJob(id, spec, func(ctx context.Context, candles map[string][]model.KLine) error {
+		result, err := wf.Run(ctx, candles)
+		h.optimizations.finish(id, err, func(job *optimizationJob) { job.walkForward = result })
+		return err
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{"job_id": id, "status": "queued"})
+}
+
+// runOptimizationJob waits for a free slot, loads the candles once, to be shared
+// read-only by every trial, and hands them to run, which finishes the job
+func (h *Handler) runOptimizationJob(id int64, spec engine.BacktestSpec, run func(context.Context, map[string][]model.KLine) error) {
+	h.optimizations.slots <- struct{}{}
+	defer func() { <-h.optimizations.slots }()
+
+	ctx := context.Background()
+	h.optimizations.update(id, func(job *optimizationJob) { job.Status = "loading" })
+
+	candles := make(map[string][]model.KLine, len(spec.Symbols))
+	for _, symbol := range spec.Symbols {
+		klines, err := h.loader.LoadCandles(ctx, spec.CandleRequest(symbol))
+		if err != nil {
+			h.logger.Warn("failed to load candles for optimization", zap.Int64("job_id", id), zap.Error(err))
+			h.optimizations.finish(id, err, nil)
+			return
+		}
+		candles[symbol] = klines
+	}
+
+	h.optimizations.update(id, func(job *optimizationJob) { job.Status = "running" })
+	if err := run(ctx, candles); err != nil {
+		h.logger.Warn("optimization failed", zap.Int64("job_id", id), zap.Error(err))
+	}
+}
+
+// GetWalkForward reports a walk-forward job's progress and, once done, its result
+func (h *Handler) GetWalkForward(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+
+	job, ok := h.optimizations.get(userID, id, "walk_forward")
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "walk-forward analysis not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"job": job, "result": job.walkForward})
+}
+
+// GetOptimization reports a job's progress and, once done, the ranked results
+// table (limit rows, 50 by default) and a heatmap over parameters x and y
+// (the first two optimized parameters by default)
+func (h *Handler) GetOptimization(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+
+	job, ok := h.optimizations.get(userID, id, "optimization")
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "optimization not found"})
+		return
+	}
+	if job.result == nil {
+		c.JSON(http.StatusOK, gin.H{"job": job})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+	result := job.result
+	resp := gin.H{
+		"job":           job,
+		"strategy_type": result.StrategyType,
+		"method":        result.Method,
+		"objective":     result.Objective,
+		"params":        result.Params,
+		"results":       result.Trials[:min(limit, len(result.Trials))],
+	}
+	if best, ok := result.Best(); ok {
+		resp["best"] = best
+	}
+
+	x, y := c.Query("x"), c.Query("y")
+	if x == "" && y == "" && len(result.Params) >= 2 {
+		x, y = result.Params[0], result.Params[1]
+	}
+	if x != "" || y != "" {
+		heatmap, err := result.Heatmap(x, y)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		resp["heatmap"] = heatmap
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"net/http"
+	"quant-trader/internal/model"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -11,12 +12,11 @@ import (
 func (h *Handler) GetPaperAccount(c *gin.Context) {
 	userID := c.MustGet("userID").(int64)
 
-	var balance decimal.Decimal
-	err := h.db.QueryRow(c.Request.Context(), "SELECT balance FROM paper_accounts WHERE user_id = $1", userID).Scan(&balance)
+	balance, err := h.store.Paper.GetBalance(c.Request.Context(), userID)
 	if err != nil {
 		// Initialize with 100,000 if not exists
 		balance = decimal.NewFromFloat(100000.0)
-		_, _ = h.db.Exec(c.Request.Context(), "INSERT INTO paper_accounts (user_id, balance) VALUES ($1, $2) ON CONFLICT DO NOTHING", userID, balance)
+		_ = h.store.Paper.CreateAccount(c.Request.Context(), userID, balance)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"balance": balance})
@@ -43,11 +43,14 @@ func (h *Handler) CreatePaperOrder(c *gin.Context) {
 		return
 	}
 
-	var orderID int64
-	err := h.db.QueryRow(c.Request.Context(),
-		"INSERT INTO paper_orders (user_id, symbol, side, type, price, qty) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
-		userID, strings.ToUpper(req.Symbol), req.Side, req.Type, req.Price, req.Qty).Scan(&orderID)
-
+	orderID, err := h.store.Paper.CreateOrder(c.Request.Context(), model.PaperOrder{
+		UserID: userID,
+		Symbol: strings.ToUpper(req.Symbol),
+		Side:   req.Side,
+		Type:   req.Type,
+		Price:  req.Price,
+		Qty:    req.Qty,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to place paper order"})
 		return
@@ -59,30 +62,11 @@ func (h *Handler) CreatePaperOrder(c *gin.Context) {
 func (h *Handler) GetPaperPositions(c *gin.Context) {
 	userID := c.MustGet("userID").(int64)
 
-	rows, err := h.db.Query(c.Request.Context(),
-		"SELECT symbol, qty, avg_price FROM paper_positions WHERE user_id = $1 AND qty > 0", userID)
+	positions, err := h.store.Paper.ListPositions(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch positions"})
 		return
 	}
-	defer rows.Close()
-
-	positions := make([]map[string]interface{}, 0)
-	for rows.Next() {
-		var (
-			symbol   string
-			qty      decimal.Decimal
-			avgPrice decimal.Decimal
-		)
-		if err := rows.Scan(&symbol, &qty, &avgPrice); err != nil {
-			continue
-		}
-		positions = append(positions, map[string]interface{}{
-			"symbol":    symbol,
-			"qty":       qty,
-			"avg_price": avgPrice,
-		})
-	}
 
 	c.JSON(http.StatusOK, positions)
 }
@@ -2,7 +2,6 @@ package api
 
 import (
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,30 +9,11 @@ import (
 func (h *Handler) GetPortfolios(c *gin.Context) {
 	userID := c.MustGet("userID").(int64)
 
-	rows, err := h.db.Query(c.Request.Context(),
-		"SELECT id, name, created_at FROM portfolios WHERE user_id = $1", userID)
+	portfolios, err := h.store.Paper.ListPortfolios(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch portfolios"})
 		return
 	}
-	defer rows.Close()
-
-	var portfolios []map[string]interface{}
-	for rows.Next() {
-		var (
-			id   int64
-			name string
-			cat  time.Time
-		)
-		if err := rows.Scan(&id, &name, &cat); err != nil {
-			continue
-		}
-		portfolios = append(portfolios, map[string]interface{}{
-			"id":         id,
-			"name":       name,
-			"created_at": cat,
-		})
-	}
 
 	c.JSON(http.StatusOK, portfolios)
 }
@@ -49,11 +29,7 @@ func (h *Handler) CreatePortfolio(c *gin.Context) {
 		return
 	}
 
-	var portfolioID int64
-	err := h.db.QueryRow(c.Request.Context(),
-		"INSERT INTO portfolios (user_id, name) VALUES ($1, $2) RETURNING id",
-		userID, req.Name).Scan(&portfolioID)
-
+	portfolioID, err := h.store.Paper.CreatePortfolio(c.Request.Context(), userID, req.Name)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create portfolio"})
 		return
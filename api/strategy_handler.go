@@ -0,0 +1,194 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"quant-trader/internal/engine"
+	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
+	"quant-trader/internal/strategy"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxStrategyBody bounds a strategy upload: the module is base64 in the JSON
+const maxStrategyBody = engine.MaxWasmModuleSize/3*4 + 1<<20
+
+// strategyVersionRequest is the content of a new strategy version. WASM
+// strategies send the module base64 encoded, optionally with its SHA-256.
+type strategyVersionRequest struct {
+	Type   string                 `json:"type" binding:"required"`
+	Config map[string]interface{} `json:"config"`
+	Module []byte                 `json:"module"`
+	SHA256 string                 `json:"sha256"`
+	Note   string                 `json:"note"`
+}
+
+func (r strategyVersionRequest) draft() engine.StrategyDraft {
+	return engine.StrategyDraft{Type: r.Type, Config: r.Config, Module: r.Module, SHA256: r.SHA256, Note: r.Note}
+}
+
+// CreateStrategy stores a strategy with its first version
+func (h *Handler) CreateStrategy(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+
+	var req struct {
+		strategyVersionRequest
+		Name        string `json:"name" binding:"required,max=100"`
+		Description string `json:"description"`
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxStrategyBody)
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	strat, version, err := h.strategies.Create(c.Request.Context(),
+		model.Strategy{UserID: userID, Name: req.Name, Description: req.Description}, req.draft())
+	if err != nil {
+		h.strategyError(c, err, "failed to create strategy")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"strategy": strat, "version": version})
+}
+
+// ListStrategies returns the user's strategies, newest first
+func (h *Handler) ListStrategies(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+	list, err := h.store.Strategies.ListStrategies(c.Request.Context(), userID)
+	if err != nil {
+		h.strategyError(c, err, "failed to list strategies")
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetStrategy returns a strategy with all its versions
+func (h *Handler) GetStrategy(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+	id, ok := strategyID(c)
+	if !ok {
+		return
+	}
+
+	strat, err := h.store.Strategies.GetStrategy(c.Request.Context(), userID, id)
+	if err != nil {
+		h.strategyError(c, err, "failed to get strategy")
+		return
+	}
+	versions, err := h.store.Strategies.ListStrategyVersions(c.Request.Context(), userID, id)
+	if err != nil {
+		h.strategyError(c, err, "failed to list strategy versions")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"strategy": strat, "versions": versions})
+}
+
+// UpdateStrategy renames a strategy; its code and config change with POST
+// /strategies/:id/versions
+func (h *Handler) UpdateStrategy(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+	id, ok := strategyID(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name        string `json:"name" binding:"required,max=100"`
+		Description string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	strat := model.Strategy{ID: id, UserID: userID, Name: req.Name, Description: req.Description}
+	if err := h.store.Strategies.UpdateStrategy(c.Request.Context(), strat); err != nil {
+		h.strategyError(c, err, "failed to update strategy")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "strategy updated"})
+}
+
+// DeleteStrategy hides a strategy; runs of its versions keep working
+func (h *Handler) DeleteStrategy(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+	id, ok := strategyID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.store.Strategies.DeleteStrategy(c.Request.Context(), userID, id); err != nil {
+		h.strategyError(c, err, "failed to delete strategy")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "strategy deleted"})
+}
+
+// CreateStrategyVersion validates and stores the next version of a strategy
+func (h *Handler) CreateStrategyVersion(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+	id, ok := strategyID(c)
+	if !ok {
+		return
+	}
+
+	var req strategyVersionRequest
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxStrategyBody)
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	version, err := h.strategies.AddVersion(c.Request.Context(), userID, id, req.draft())
+	if err != nil {
+		h.strategyError(c, err, "failed to create strategy version")
+		return
+	}
+	c.JSON(http.StatusCreated, version)
+}
+
+// ListStrategyVersions returns the versions of a strategy, newest first
+func (h *Handler) ListStrategyVersions(c *gin.Context) {
+	userID := c.MustGet("userID").(int64)
+	id, ok := strategyID(c)
+	if !ok {
+		return
+	}
+
+	versions, err := h.store.Strategies.ListStrategyVersions(c.Request.Context(), userID, id)
+	if err != nil {
+		h.strategyError(c, err, "failed to list strategy versions")
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// ListStrategyTypes returns every strategy type with its parameter schema
+func (h *Handler) ListStrategyTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, strategy.Types())
+}
+
+func strategyID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid strategy id"})
+		return 0, false
+	}
+	return id, true
+}
+
+// strategyError answers with the status matching err, logging unexpected ones
+func (h *Handler) strategyError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "strategy not found"})
+	case errors.Is(err, engine.ErrInvalidStrategy):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+	}
+}
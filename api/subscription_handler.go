@@ -12,19 +12,7 @@ import (
 func (h *Handler) GetSubscription(c *gin.Context) {
 	userID := c.MustGet("userID").(int64)
 
-	var sub struct {
-		TierName   string    `json:"tier_name"`
-		MaxSymbols int       `json:"max_symbols"`
-		Status     string    `json:"status"`
-		ExpiresAt  time.Time `json:"expires_at"`
-	}
-
-	err := h.db.QueryRow(c.Request.Context(),
-		`SELECT t.name, t.max_symbols, s.status, s.expires_at 
-		 FROM user_subscriptions s 
-		 JOIN subscription_tiers t ON s.tier_id = t.id 
-		 WHERE s.user_id = $1`, userID).Scan(&sub.TierName, &sub.MaxSymbols, &sub.Status, &sub.ExpiresAt)
-
+	sub, err := h.store.Users.GetSubscription(c.Request.Context(), userID)
 	if err != nil {
 		// Return default Free tier info if no record
 		c.JSON(http.StatusOK, gin.H{
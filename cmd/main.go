@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log"
+	"os"
 
 	"quant-trader/internal/app"
 )
@@ -14,8 +15,17 @@ func main() {
 		log.Fatalf("failed to create application: %v", err)
 	}
 
-	// Initialize application (DB, NATS, etc.)
 	ctx := context.Background()
+
+	// Subcommand: migrate up | down [steps] | status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := application.Migrate(ctx, os.Args[2:]); err != nil {
+			log.Fatalf("migration failed: %v", err)
+		}
+		return
+	}
+
+	// Initialize application (DB, NATS, etc.)
 	if err := application.Init(ctx); err != nil {
 		log.Fatalf("failed to initialize application: %v", err)
 	}
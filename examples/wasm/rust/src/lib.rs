@@ -0,0 +1,244 @@
+//! Example RSI mean reversion strategy for the quant-trader WASM strategy ABI,
+//! see ../README.md. The decision logic is plain Rust and tested on the host;
+//! the `abi` module binds it to the host when built for wasm32.
+
+pub const ABI_VERSION: i32 = 1;
+
+pub const FRAME_HEADER: usize = 48;
+pub const CANDLE_SIZE: usize = 48;
+pub const INTENT_SIZE: usize = 40;
+
+pub const SIDE_BUY: u32 = 1;
+pub const SIZE_BUYING_POWER: u32 = 4;
+pub const SIZE_CLOSE: u32 = 5;
+pub const INDICATOR_RSI: i32 = 3;
+
+/// Strategy parameters, read from the JSON passed to `qt_init`
+#[derive(Clone, Copy, Debug, PartialEq)]
+pub struct Params {
+    pub period: i32,
+    /// Buy when the RSI falls below this level
+    pub oversold: f64,
+    /// Close the position when the RSI rises above this level
+    pub overbought: f64,
+    /// Fraction of buying power a new position takes
+    pub fraction: f64,
+}
+
+impl Default for Params {
+    fn default() -> Self {
+        Params { period: 14, oversold: 30.0, overbought: 70.0, fraction: 1.0 }
+    }
+}
+
+impl Params {
+    /// Reads the numeric fields of a flat JSON object over the defaults. The
+    /// example has no dependencies, so this is not a general JSON parser.
+    pub fn parse(json: &str) -> Result<Params, &'static str> {
+        let mut p = Params::default();
+        for (key, value) in flat_numbers(json) {
+            match key {
+                "period" => p.period = value as i32,
+                "oversold" => p.oversold = value,
+                "overbought" => p.overbought = value,
+                "fraction" => p.fraction = value,
+                _ => {}
+            }
+        }
+        if p.period < 2 {
+            return Err("period must be at least 2");
+        }
+        if !(0.0..100.0).contains(&p.oversold) || p.oversold >= p.overbought || p.overbought > 100.0 {
+            return Err("need 0 <= oversold < overbought <= 100");
+        }
+        if !(p.fraction > 0.0 && p.fraction <= 1.0) {
+            return Err("fraction must be in (0, 1]");
+        }
+        Ok(p)
+    }
+
+    /// Decides on the intent for the latest candle given the current RSI
+    /// (NaN while there is not enough history) and position
+    pub fn decide(&self, rsi: f64, position: f64, open_orders: u32) -> Option<Intent> {
+        if rsi.is_nan() || open_orders > 0 {
+            return None;
+        }
+        if position == 0.0 && rsi < self.oversold {
+            return Some(Intent { side: SIDE_BUY, size_mode: SIZE_BUYING_POWER, size: self.fraction, ..Intent::default() });
+        }
+        if position > 0.0 && rsi > self.overbought {
+            return Some(Intent { size_mode: SIZE_CLOSE, ..Intent::default() });
+        }
+        None
+    }
+}
+
+/// Yields the `"key": number` pairs of a flat JSON object
+fn flat_numbers(json: &str) -> impl Iterator<Item = (&str, f64)> {
+    json.trim().trim_start_matches('{').trim_end_matches('}').split(',').filter_map(|pair| {
+        let (key, value) = pair.split_once(':')?;
+        let value = value.trim().parse().ok()?;
+        Some((key.trim().trim_matches('"'), value))
+    })
+}
+
+/// The header of the frame `qt_on_candle` receives
+#[derive(Clone, Copy, Debug, Default, PartialEq)]
+pub struct Frame {
+    pub position_qty: f64,
+    pub entry_price: f64,
+    pub cash: f64,
+    pub equity: f64,
+    pub buying_power: f64,
+    pub open_orders: u32,
+    pub candle_count: u32,
+}
+
+impl Frame {
+    pub fn parse(b: &[u8]) -> Option<Frame> {
+        if b.len() < FRAME_HEADER {
+            return None;
+        }
+        let f64_at = |off: usize| f64::from_le_bytes(b[off..off + 8].try_into().unwrap());
+        let u32_at = |off: usize| u32::from_le_bytes(b[off..off + 4].try_into().unwrap());
+        let frame = Frame {
+            position_qty: f64_at(0),
+            entry_price: f64_at(8),
+            cash: f64_at(16),
+            equity: f64_at(24),
+            buying_power: f64_at(32),
+            open_orders: u32_at(40),
+            candle_count: u32_at(44),
+        };
+        if b.len() < FRAME_HEADER + frame.candle_count as usize * CANDLE_SIZE {
+            return None;
+        }
+        Some(frame)
+    }
+}
+
+/// An order intent, laid out as the ABI expects
+#[repr(C)]
+#[derive(Clone, Copy, Debug, Default, PartialEq)]
+pub struct Intent {
+    pub side: u32,
+    pub order_type: u32,
+    pub size_mode: u32,
+    pub reserved: u32,
+    pub size: f64,
+    pub limit_price: f64,
+    pub stop_price: f64,
+}
+
+#[cfg(target_arch = "wasm32")]
+mod abi {
+    use super::*;
+    use core::cell::RefCell;
+
+    #[link(wasm_import_module = "qt")]
+    extern "C" {
+        fn log(level: i32, msg: *const u8, len: u32);
+        fn indicator(kind: i32, period: i32, param: f64) -> f64;
+    }
+
+    thread_local! {
+        static PARAMS: RefCell<Params> = RefCell::new(Params::default());
+        static INBOX: RefCell<Vec<u8>> = RefCell::new(Vec::new());
+        static OUTBOX: RefCell<Intent> = RefCell::new(Intent::default());
+    }
+
+    fn info(msg: &str) {
+        unsafe { log(1, msg.as_ptr(), msg.len() as u32) }
+    }
+
+    #[no_mangle]
+    pub extern "C" fn qt_abi_version() -> i32 {
+        ABI_VERSION
+    }
+
+    #[no_mangle]
+    pub extern "C" fn qt_alloc(size: i32) -> *mut u8 {
+        INBOX.with(|inbox| {
+            let mut inbox = inbox.borrow_mut();
+            inbox.clear();
+            inbox.resize(size as usize, 0);
+            inbox.as_mut_ptr()
+        })
+    }
+
+    #[no_mangle]
+    pub extern "C" fn qt_init(ptr: *const u8, len: i32) -> i32 {
+        let raw = unsafe { core::slice::from_raw_parts(ptr, len as usize) };
+        match core::str::from_utf8(raw).map_err(|_| "params are not utf-8").and_then(Params::parse) {
+            Ok(p) => {
+                PARAMS.with(|params| *params.borrow_mut() = p);
+                0
+            }
+            Err(err) => {
+                info(err);
+                1
+            }
+        }
+    }
+
+    #[no_mangle]
+    pub extern "C" fn qt_on_candle(ptr: *const u8, len: i32) -> i64 {
+        let raw = unsafe { core::slice::from_raw_parts(ptr, len as usize) };
+        let Some(frame) = Frame::parse(raw) else {
+            info("short frame");
+            return 0;
+        };
+        let params = PARAMS.with(|p| *p.borrow());
+        let rsi = unsafe { indicator(INDICATOR_RSI, params.period, 0.0) };
+        match params.decide(rsi, frame.position_qty, frame.open_orders) {
+            Some(intent) => OUTBOX.with(|out| {
+                *out.borrow_mut() = intent;
+                ((out.as_ptr() as i64) << 32) | 1
+            }),
+            None => 0,
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn intent_layout() {
+        assert_eq!(core::mem::size_of::<Intent>(), INTENT_SIZE);
+    }
+
+    #[test]
+    fn parse_params() {
+        let p = Params::parse(r#"{"period": 7, "oversold": 25, "name": "x"}"#).unwrap();
+        assert_eq!(p.period, 7);
+        assert_eq!(p.oversold, 25.0);
+        assert_eq!(p.overbought, 70.0);
+        assert!(Params::parse(r#"{"oversold": 80}"#).is_err());
+        assert!(Params::parse("null").is_ok());
+    }
+
+    #[test]
+    fn decide() {
+        let p = Params::default();
+        assert_eq!(p.decide(f64::NAN, 0.0, 0), None);
+        let buy = p.decide(20.0, 0.0, 0).unwrap();
+        assert_eq!((buy.side, buy.size_mode, buy.size), (SIDE_BUY, SIZE_BUYING_POWER, 1.0));
+        assert_eq!(p.decide(20.0, 1.0, 0), None);
+        assert_eq!(p.decide(20.0, 0.0, 1), None);
+        assert_eq!(p.decide(80.0, 1.0, 0).unwrap().size_mode, SIZE_CLOSE);
+        assert_eq!(p.decide(50.0, 1.0, 0), None);
+    }
+
+    #[test]
+    fn parse_frame() {
+        let mut b = vec![0u8; FRAME_HEADER + CANDLE_SIZE];
+        b[0..8].copy_from_slice(&2.5f64.to_le_bytes());
+        b[40..44].copy_from_slice(&3u32.to_le_bytes());
+        b[44..48].copy_from_slice(&1u32.to_le_bytes());
+        let f = Frame::parse(&b).unwrap();
+        assert_eq!((f.position_qty, f.open_orders, f.candle_count), (2.5, 3, 1));
+        assert!(Frame::parse(&b[..FRAME_HEADER]).is_none());
+    }
+}
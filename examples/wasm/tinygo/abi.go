@@ -0,0 +1,91 @@
+//go:build tinygo || wasip1
+
+package main
+
+import (
+	"encoding/binary"
+	"strconv"
+	"unsafe"
+)
+
+//go:wasmimport qt log
+func hostLog(level int32, msg unsafe.Pointer, n uint32)
+
+//go:wasmimport qt state_get
+func hostStateGet(key unsafe.Pointer, keyLen uint32, buf unsafe.Pointer, capacity uint32) int32
+
+//go:wasmimport qt state_set
+func hostStateSet(key unsafe.Pointer, keyLen uint32, val unsafe.Pointer, valLen uint32)
+
+func logf(msg string) {
+	hostLog(logInfo, unsafe.Pointer(unsafe.StringData(msg)), uint32(len(msg)))
+}
+
+// signals counts the entries in the host state, so it survives a restart
+func signals() uint64 {
+	key := "signals"
+	var buf [8]byte
+	if hostStateGet(unsafe.Pointer(unsafe.StringData(key)), uint32(len(key)), unsafe.Pointer(&buf[0]), 8) != 8 {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(buf[:])
+}
+
+func setSignals(n uint64) {
+	key := "signals"
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], n)
+	hostStateSet(unsafe.Pointer(unsafe.StringData(key)), uint32(len(key)), unsafe.Pointer(&buf[0]), 8)
+}
+
+var (
+	params = DefaultParams
+	// inbox holds the event the host writes, outbox the intents it reads back;
+	// both stay referenced so the GC keeps them in place
+	inbox  []byte
+	outbox []byte
+)
+
+//go:wasmexport qt_abi_version
+func qtABIVersion() int32 {
+	return abiVersion
+}
+
+//go:wasmexport qt_alloc
+func qtAlloc(size uint32) unsafe.Pointer {
+	if uint32(cap(inbox)) < size || size == 0 {
+		inbox = make([]byte, size+1)
+	}
+	inbox = inbox[:size]
+	return unsafe.Pointer(unsafe.SliceData(inbox))
+}
+
+//go:wasmexport qt_init
+func qtInit(ptr unsafe.Pointer, n uint32) int32 {
+	p, err := ParseParams(unsafe.Slice((*byte)(ptr), n))
+	if err != nil {
+		logf("invalid params: " + err.Error())
+		return 1
+	}
+	params = p
+	return 0
+}
+
+//go:wasmexport qt_on_candle
+func qtOnCandle(ptr unsafe.Pointer, n uint32) uint64 {
+	frame, err := ParseFrame(unsafe.Slice((*byte)(ptr), n))
+	if err != nil {
+		logf(err.Error())
+		return 0
+	}
+	intents := params.Decide(frame)
+	if len(intents) == 0 {
+		return 0
+	}
+	count := signals() + 1
+	setSignals(count)
+	logf("signal " + strconv.FormatUint(count, 10))
+
+	outbox = EncodeIntents(intents)
+	return uint64(uintptr(unsafe.Pointer(unsafe.SliceData(outbox))))<<32 | uint64(len(intents))
+}
@@ -0,0 +1,3 @@
+module quant-trader/examples/wasm/tinygo
+
+go 1.24
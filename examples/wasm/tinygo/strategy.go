@@ -0,0 +1,152 @@
+// Command tinygo is an example moving average cross strategy for the WASM
+// strategy ABI. The logic in this file is plain Go so it can be tested on the
+// host; abi.go binds it to the host when built for wasip1.
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+)
+
+const (
+	frameHeader = 48
+	candleSize  = 48
+	intentSize  = 40
+
+	sideBuy    = 1
+	sideSell   = 2
+	sizeEquity = 3
+	sizeClose  = 5
+	typeMarket = 0
+	abiVersion = 1
+	logInfo    = 1
+)
+
+// Params are read from the JSON passed to qt_init
+type Params struct {
+	Fast int `json:"fast"`
+	Slow int `json:"slow"`
+	// Fraction of equity a new position takes
+	Fraction float64 `json:"fraction"`
+}
+
+// DefaultParams are used for fields qt_init leaves out
+var DefaultParams = Params{Fast: 5, Slow: 20, Fraction: 0.5}
+
+// ParseParams reads params over the defaults
+func ParseParams(raw []byte) (Params, error) {
+	p := DefaultParams
+	if len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return p, err
+		}
+	}
+	if p.Fast <= 0 || p.Slow <= p.Fast {
+		return p, errors.New("need 0 < fast < slow")
+	}
+	if p.Fraction <= 0 || p.Fraction > 1 {
+		return p, errors.New("fraction must be in (0, 1]")
+	}
+	return p, nil
+}
+
+// Candle is one candle of a frame
+type Candle struct {
+	TimeMs                         int64
+	Open, High, Low, Close, Volume float64
+}
+
+// Frame is the event qt_on_candle receives
+type Frame struct {
+	PositionQty, EntryPrice, Cash, Equity, BuyingPower float64
+	OpenOrders                                         uint32
+	Candles                                            []Candle
+}
+
+// Intent is an order the strategy places
+type Intent struct {
+	Side, Type, SizeMode uint32
+	Size                 float64
+	LimitPrice           float64
+	StopPrice            float64
+}
+
+// ParseFrame decodes a frame written by the host
+func ParseFrame(b []byte) (Frame, error) {
+	if len(b) < frameHeader {
+		return Frame{}, errors.New("short frame")
+	}
+	f64 := func(off int) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b[off:])) }
+	f := Frame{
+		PositionQty: f64(0),
+		EntryPrice:  f64(8),
+		Cash:        f64(16),
+		Equity:      f64(24),
+		BuyingPower: f64(32),
+		OpenOrders:  binary.LittleEndian.Uint32(b[40:]),
+	}
+	n := int(binary.LittleEndian.Uint32(b[44:]))
+	if len(b) < frameHeader+n*candleSize {
+		return Frame{}, errors.New("short frame")
+	}
+	f.Candles = make([]Candle, n)
+	for i := range f.Candles {
+		off := frameHeader + i*candleSize
+		f.Candles[i] = Candle{
+			TimeMs: int64(binary.LittleEndian.Uint64(b[off:])),
+			Open:   f64(off + 8),
+			High:   f64(off + 16),
+			Low:    f64(off + 24),
+			Close:  f64(off + 32),
+			Volume: f64(off + 40),
+		}
+	}
+	return f, nil
+}
+
+// EncodeIntents lays intents out for the host
+func EncodeIntents(intents []Intent) []byte {
+	b := make([]byte, 0, len(intents)*intentSize)
+	for _, in := range intents {
+		b = binary.LittleEndian.AppendUint32(b, in.Side)
+		b = binary.LittleEndian.AppendUint32(b, in.Type)
+		b = binary.LittleEndian.AppendUint32(b, in.SizeMode)
+		b = binary.LittleEndian.AppendUint32(b, 0)
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(in.Size))
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(in.LimitPrice))
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(in.StopPrice))
+	}
+	return b
+}
+
+// sma is the mean close of the n candles ending offset candles before the last
+func sma(candles []Candle, n, offset int) float64 {
+	sum := 0.0
+	end := len(candles) - offset
+	for _, c := range candles[end-n : end] {
+		sum += c.Close
+	}
+	return sum / float64(n)
+}
+
+// Decide goes long with Fraction of equity when the fast average crosses
+// above the slow one and closes the position when it crosses back below
+func (p Params) Decide(f Frame) []Intent {
+	if len(f.Candles) < p.Slow+1 || f.OpenOrders > 0 {
+		return nil
+	}
+	fast, slow := sma(f.Candles, p.Fast, 0), sma(f.Candles, p.Slow, 0)
+	prevFast, prevSlow := sma(f.Candles, p.Fast, 1), sma(f.Candles, p.Slow, 1)
+
+	switch {
+	case prevFast <= prevSlow && fast > slow && f.PositionQty == 0:
+		return []Intent{{Side: sideBuy, Type: typeMarket, SizeMode: sizeEquity, Size: p.Fraction}}
+	case prevFast >= prevSlow && fast < slow && f.PositionQty > 0:
+		return []Intent{{SizeMode: sizeClose}}
+	}
+	return nil
+}
+
+func main() {}
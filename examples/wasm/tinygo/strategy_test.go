@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func frameOf(position float64, closes ...float64) []byte {
+	b := make([]byte, frameHeader)
+	binary.LittleEndian.PutUint64(b[0:], math.Float64bits(position))
+	binary.LittleEndian.PutUint32(b[44:], uint32(len(closes)))
+	for i, c := range closes {
+		candle := make([]byte, candleSize)
+		binary.LittleEndian.PutUint64(candle[0:], uint64(i*60000))
+		for off := 8; off < 40; off += 8 {
+			binary.LittleEndian.PutUint64(candle[off:], math.Float64bits(c))
+		}
+		b = append(b, candle...)
+	}
+	return b
+}
+
+func TestDecide(t *testing.T) {
+	p := Params{Fast: 2, Slow: 3, Fraction: 0.5}
+
+	cases := []struct {
+		name     string
+		position float64
+		closes   []float64
+		want     []Intent
+	}{
+		{"too short", 0, []float64{1, 2, 3}, nil},
+		{"golden cross", 0, []float64{5, 4, 3, 6}, []Intent{{Side: sideBuy, SizeMode: sizeEquity, Size: 0.5}}},
+		{"golden cross while long", 1, []float64{5, 4, 3, 6}, nil},
+		{"death cross", 1, []float64{1, 2, 3, 0}, []Intent{{SizeMode: sizeClose}}},
+		{"death cross while flat", 0, []float64{1, 2, 3, 0}, nil},
+	}
+	for _, tc := range cases {
+		f, err := ParseFrame(frameOf(tc.position, tc.closes...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := p.Decide(f)
+		if len(got) != len(tc.want) || (len(got) > 0 && got[0] != tc.want[0]) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	p, err := ParseParams([]byte(`{"fast":3}`))
+	if err != nil || p.Fast != 3 || p.Slow != DefaultParams.Slow {
+		t.Fatalf("got %+v, %v", p, err)
+	}
+	if _, err := ParseParams([]byte(`{"fast":30,"slow":10}`)); err == nil {
+		t.Fatal("fast >= slow must fail")
+	}
+}
+
+func TestEncodeIntents(t *testing.T) {
+	b := EncodeIntents([]Intent{{Side: sideBuy, SizeMode: sizeEquity, Size: 0.25}})
+	if len(b) != intentSize {
+		t.Fatalf("intent is %d bytes", len(b))
+	}
+	if math.Float64frombits(binary.LittleEndian.Uint64(b[16:])) != 0.25 {
+		t.Fatal("size not at offset 16")
+	}
+}
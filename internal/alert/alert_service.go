@@ -6,24 +6,19 @@ import (
 	"fmt"
 	"quant-trader/internal/indicators"
 	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
 	"sync"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/nats-io/nats.go"
 	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
-type AlertCondition struct {
-	ID            int64
-	UserID        int64
-	Symbol        string
-	ConditionType string // "price_above", "price_below"
-	TargetValue   decimal.Decimal
-}
+// AlertCondition is an active alert rule evaluated against incoming candles
+type AlertCondition = model.Alert
 
 type AlertService struct {
-	db      *pgxpool.Pool
+	repo    storage.AlertRepository
 	js      nats.JetStreamContext
 	logger  *zap.Logger
 	alerts  map[string][]AlertCondition // key: symbol
@@ -31,9 +26,9 @@ type AlertService struct {
 	mu      sync.RWMutex
 }
 
-func NewAlertService(db *pgxpool.Pool, js nats.JetStreamContext, logger *zap.Logger) *AlertService {
+func NewAlertService(repo storage.AlertRepository, js nats.JetStreamContext, logger *zap.Logger) *AlertService {
 	return &AlertService{
-		db:      db,
+		repo:    repo,
 		js:      js,
 		logger:  logger,
 		alerts:  make(map[string][]AlertCondition),
@@ -65,21 +60,16 @@ func (s *AlertService) Start(ctx context.Context) error {
 }
 
 func (s *AlertService) loadAlerts(ctx context.Context) error {
-	rows, err := s.db.Query(ctx, "SELECT id, user_id, symbol, condition_type, target_value FROM alerts WHERE is_active = TRUE")
+	alerts, err := s.repo.ListActiveAlerts(ctx)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.alerts = make(map[string][]AlertCondition)
 
-	for rows.Next() {
-		var a AlertCondition
-		if err := rows.Scan(&a.ID, &a.UserID, &a.Symbol, &a.ConditionType, &a.TargetValue); err != nil {
-			continue
-		}
+	for _, a := range alerts {
 		s.alerts[a.Symbol] = append(s.alerts[a.Symbol], a)
 	}
 	return nil
@@ -0,0 +1,256 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"quant-trader/internal/metrics"
+	"quant-trader/internal/model"
+	"sort"
+)
+
+const (
+	// MaxMonteCarloIterations bounds the number of simulated paths
+	MaxMonteCarloIterations = 10000
+	// maxBandPoints is how many steps of each path the bands are sampled at
+	maxBandPoints = 200
+)
+
+// DefaultPercentiles are reported when a MonteCarloConfig names none
+var DefaultPercentiles = []float64{5, 25, 50, 75, 95}
+
+// MonteCarloConfig describes a robustness simulation of a backtest. Paths are
+// drawn from a seeded RNG, so the same config always gives the same result.
+type MonteCarloConfig struct {
+	// Method is "trades" to reshuffle the closed trade PnLs with replacement,
+	// or "returns" to bootstrap the bar returns of the equity curve
+	Method     string `json:"method"`
+	Iterations int    `json:"iterations"` // 1000 by default
+	Seed       int64  `json:"seed"`
+	// BlockSize resamples returns in consecutive blocks to keep their
+	// autocorrelation; 1 (the default) draws single bars
+	BlockSize int `json:"block_size"`
+	// RuinLevel is the share of the starting equity whose loss counts as
+	// ruin, 0.5 by default
+	RuinLevel   float64   `json:"ruin_level"`
+	Percentiles []float64 `json:"percentiles"`
+}
+
+// Validate fills in the defaults and checks the config
+func (c *MonteCarloConfig) Validate() error {
+	if c.Method == "" {
+		c.Method = "trades"
+	}
+	if c.Method != "trades" && c.Method != "returns" {
+		return fmt.Errorf("unknown monte carlo method %q", c.Method)
+	}
+	if c.Iterations == 0 {
+		c.Iterations = 1000
+	}
+	if c.Iterations < 0 || c.Iterations > MaxMonteCarloIterations {
+		return fmt.Errorf("iterations must be between 1 and %d", MaxMonteCarloIterations)
+	}
+	if c.BlockSize == 0 {
+		c.BlockSize = 1
+	}
+	if c.BlockSize < 0 {
+		return errors.New("block size must be positive")
+	}
+	if c.RuinLevel == 0 {
+		c.RuinLevel = 0.5
+	}
+	if c.RuinLevel <= 0 || c.RuinLevel > 1 {
+		return errors.New("ruin level must be in (0, 1]")
+	}
+	if len(c.Percentiles) == 0 {
+		c.Percentiles = DefaultPercentiles
+	}
+	for _, p := range c.Percentiles {
+		if p < 0 || p > 100 {
+			return errors.New("percentiles must be between 0 and 100")
+		}
+	}
+	return nil
+}
+
+// PercentileBand is one percentile of a quantity at each sampled step
+type PercentileBand struct {
+	Percentile float64   `json:"percentile"`
+	Values     []float64 `json:"values"`
+}
+
+// PercentileValue is one percentile of a per-path quantity
+type PercentileValue struct {
+	Percentile float64 `json:"percentile"`
+	Value      float64 `json:"value"`
+}
+
+// MonteCarloResult summarizes the distribution of the simulated paths
+type MonteCarloResult struct {
+	Method         string  `json:"method"`
+	Iterations     int     `json:"iterations"`
+	Seed           int64   `json:"seed"`
+	InitialBalance float64 `json:"initial_balance"`
+	Steps          int     `json:"steps"` // trades or bars per path
+	// Points are the steps the bands are sampled at, 0 being the start
+	Points        []int             `json:"points"`
+	EquityBands   []PercentileBand  `json:"equity_bands"`
+	DrawdownBands []PercentileBand  `json:"drawdown_bands"`
+	FinalReturn   []PercentileValue `json:"final_return"`
+	MaxDrawdown   []PercentileValue `json:"max_drawdown"`
+	// ProbabilityOfLoss is the share of paths ending below the start
+	ProbabilityOfLoss float64 `json:"probability_of_loss"`
+	// RiskOfRuin is the share of paths losing RuinLevel of the start at some point
+	RiskOfRuin float64 `json:"risk_of_ruin"`
+	RuinLevel  float64 `json:"ruin_level"`
+}
+
+// MonteCarlo resamples the trades or returns of report into cfg.Iterations
+// alternative histories and reports how their outcomes are distributed
+func MonteCarlo(report model.BacktestReport, cfg MonteCarloConfig) (*MonteCarloResult, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	start := report.InitialBalance.InexactFloat64()
+	if start <= 0 {
+		return nil, errors.New("report has no initial balance")
+	}
+
+	var (
+		steps int
+		path  func(rng *rand.Rand, equity []float64)
+	)
+	switch cfg.Method {
+	case "trades":
+		var pnls []float64
+		for _, t := range report.TradesLog {
+			if t.Closing {
+				pnls = append(pnls, t.PnL.InexactFloat64())
+			}
+		}
+		if len(pnls) == 0 {
+			return nil, errors.New("report has no closed trades")
+		}
+		steps = len(pnls)
+		path = func(rng *rand.Rand, equity []float64) {
+			for i := 1; i <= steps; i++ {
+				equity[i] = equity[i-1] + pnls[rng.Intn(len(pnls))]
+			}
+		}
+	case "returns":
+		curve := make([]float64, len(report.EquityCurve))
+		for i, p := range report.EquityCurve {
+			curve[i] = p.Equity.InexactFloat64()
+		}
+		returns := metrics.Returns(curve)
+		if len(returns) == 0 {
+			return nil, errors.New("report has no equity curve")
+		}
+		steps = len(returns)
+		block := min(cfg.BlockSize, steps)
+		path = func(rng *rand.Rand, equity []float64) {
+			for i := 1; i <= steps; {
+				from := rng.Intn(steps - block + 1)
+				for j := 0; j < block && i <= steps; j, i = j+1, i+1 {
+					equity[i] = equity[i-1] * (1 + returns[from+j])
+				}
+			}
+		}
+	}
+
+	points := bandPoints(steps)
+	equityAt := make([][]float64, len(points)) // [point][path]
+	drawdownAt := make([][]float64, len(points))
+	for k := range points {
+		equityAt[k] = make([]float64, cfg.Iterations)
+		drawdownAt[k] = make([]float64, cfg.Iterations)
+	}
+	finals := make([]float64, cfg.Iterations)
+	maxDDs := make([]float64, cfg.Iterations)
+	var losses, ruins int
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	equity := make([]float64, steps+1)
+	equity[0] = start
+	ruinAt := start * (1 - cfg.RuinLevel)
+	for it := 0; it < cfg.Iterations; it++ {
+		path(rng, equity)
+
+		k, peak, ruined := 0, start, false
+		for i, e := range equity {
+			peak = math.Max(peak, e)
+			dd := 0.0
+			if peak > 0 {
+				dd = math.Min(1, (peak-e)/peak)
+			}
+			maxDDs[it] = math.Max(maxDDs[it], dd)
+			ruined = ruined || e <= ruinAt
+			if k < len(points) && points[k] == i {
+				equityAt[k][it], drawdownAt[k][it] = e, dd
+				k++
+			}
+		}
+		finals[it] = equity[steps]/start - 1
+		if finals[it] < 0 {
+			losses++
+		}
+		if ruined {
+			ruins++
+		}
+	}
+
+	result := &MonteCarloResult{
+		Method:            cfg.Method,
+		Iterations:        cfg.Iterations,
+		Seed:              cfg.Seed,
+		InitialBalance:    start,
+		Steps:             steps,
+		Points:            points,
+		EquityBands:       bands(equityAt, cfg.Percentiles),
+		DrawdownBands:     bands(drawdownAt, cfg.Percentiles),
+		FinalReturn:       percentiles(finals, cfg.Percentiles),
+		MaxDrawdown:       percentiles(maxDDs, cfg.Percentiles),
+		ProbabilityOfLoss: float64(losses) / float64(cfg.Iterations),
+		RiskOfRuin:        float64(ruins) / float64(cfg.Iterations),
+		RuinLevel:         cfg.RuinLevel,
+	}
+	return result, nil
+}
+
+// bandPoints spreads at most maxBandPoints steps evenly over 0..steps,
+// always including both ends
+func bandPoints(steps int) []int {
+	n := min(steps+1, maxBandPoints)
+	points := make([]int, n)
+	for k := range points {
+		points[k] = int(math.Round(float64(k) * float64(steps) / float64(n-1)))
+	}
+	return points
+}
+
+// bands computes the percentiles across paths at each sampled step; values is
+// sorted in place
+func bands(values [][]float64, ps []float64) []PercentileBand {
+	out := make([]PercentileBand, len(ps))
+	for i, p := range ps {
+		out[i] = PercentileBand{Percentile: p, Values: make([]float64, len(values))}
+	}
+	for k, at := range values {
+		sort.Float64s(at)
+		for i, p := range ps {
+			out[i].Values[k] = metrics.Percentile(at, p)
+		}
+	}
+	return out
+}
+
+// percentiles sorts xs in place and picks the given percentiles
+func percentiles(xs []float64, ps []float64) []PercentileValue {
+	sort.Float64s(xs)
+	out := make([]PercentileValue, len(ps))
+	for i, p := range ps {
+		out[i] = PercentileValue{Percentile: p, Value: metrics.Percentile(xs, p)}
+	}
+	return out
+}
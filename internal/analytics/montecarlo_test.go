@@ -0,0 +1,98 @@
+package analytics
+
+import (
+	"context"
+	"quant-trader/internal/model"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mcReport has closed trades with the given PnLs on a 1000 balance, and an
+// equity curve following them
+func mcReport(pnls ...float64) model.BacktestReport {
+	report := model.BacktestReport{InitialBalance: decimal.NewFromInt(1000)}
+	equity := 1000.0
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	report.EquityCurve = append(report.EquityCurve, model.EquityPoint{Time: t0, Equity: decimal.NewFromFloat(equity)})
+	for i, pnl := range pnls {
+		report.TradesLog = append(report.TradesLog,
+			model.SimulatedTrade{Side: "buy"},
+			model.SimulatedTrade{Side: "sell", Closing: true, PnL: decimal.NewFromFloat(pnl)})
+		equity += pnl
+		report.EquityCurve = append(report.EquityCurve, model.EquityPoint{
+			Time:   t0.Add(time.Duration(i+1) * time.Hour),
+			Equity: decimal.NewFromFloat(equity),
+		})
+	}
+	return report
+}
+
+func TestMonteCarlo_Seeded(t *testing.T) {
+	report := mcReport(50, -30, 20, -10, 40, -60, 15)
+	for _, method := range []string{"trades", "returns"} {
+		cfg := MonteCarloConfig{Method: method, Iterations: 200, Seed: 7, BlockSize: 2}
+		a, err := MonteCarlo(report, cfg)
+		require.NoError(t, err)
+		b, err := MonteCarlo(report, cfg)
+		require.NoError(t, err)
+		assert.Equal(t, a, b, method)
+
+		cfg.Seed = 8
+		c, err := MonteCarlo(report, cfg)
+		require.NoError(t, err)
+		assert.NotEqual(t, a.FinalReturn, c.FinalReturn, method)
+
+		assert.Equal(t, 7, a.Steps)
+		assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7}, a.Points)
+		require.Len(t, a.EquityBands, len(DefaultPercentiles))
+		for _, band := range a.EquityBands {
+			assert.Equal(t, 1000.0, band.Values[0])
+		}
+		// Bands are ordered by percentile at every step
+		for k := range a.Points {
+			for i := 1; i < len(a.EquityBands); i++ {
+				assert.LessOrEqual(t, a.EquityBands[i-1].Values[k], a.EquityBands[i].Values[k])
+			}
+		}
+	}
+}
+
+func TestMonteCarlo_Risk(t *testing.T) {
+	winner, err := MonteCarlo(mcReport(10, 20, 5), MonteCarloConfig{Iterations: 100})
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, winner.ProbabilityOfLoss)
+	assert.Equal(t, 0.0, winner.RiskOfRuin)
+	for _, dd := range winner.MaxDrawdown {
+		assert.Equal(t, 0.0, dd.Value)
+	}
+
+	// Every path loses at least 600 of 1000
+	loser, err := MonteCarlo(mcReport(-300, -400), MonteCarloConfig{Iterations: 100, RuinLevel: 0.5})
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, loser.ProbabilityOfLoss)
+	assert.Equal(t, 1.0, loser.RiskOfRuin)
+	assert.GreaterOrEqual(t, loser.MaxDrawdown[0].Value, 0.6)
+}
+
+func TestMonteCarlo_Invalid(t *testing.T) {
+	_, err := MonteCarlo(mcReport(), MonteCarloConfig{})
+	assert.Error(t, err)
+	_, err = MonteCarlo(mcReport(10), MonteCarloConfig{Method: "bogus"})
+	assert.Error(t, err)
+	_, err = MonteCarlo(mcReport(10), MonteCarloConfig{Iterations: MaxMonteCarloIterations + 1})
+	assert.Error(t, err)
+	_, err = MonteCarlo(mcReport(10), MonteCarloConfig{RuinLevel: 2})
+	assert.Error(t, err)
+}
+
+func TestMonteCarloSimulation_Seeded(t *testing.T) {
+	s := &AnalyticsService{}
+	returns := []float64{0.01, -0.02, 0.03, 0.005}
+	a := s.MonteCarloSimulation(context.Background(), returns, 20, 30, 1)
+	assert.Equal(t, a, s.MonteCarloSimulation(context.Background(), returns, 20, 30, 1))
+	assert.NotEqual(t, a[0], a[1])
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -13,11 +14,14 @@ import (
 	"quant-trader/api/middleware"
 	"quant-trader/internal/alert"
 	"quant-trader/internal/config"
+	"quant-trader/internal/engine"
 	"quant-trader/internal/infrastructure"
+	"quant-trader/internal/migrate"
 	"quant-trader/internal/paper"
 	"quant-trader/internal/processor"
 	"quant-trader/internal/push"
 	"quant-trader/internal/storage"
+	"quant-trader/scripts/migrations"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -30,13 +34,17 @@ import (
 type App struct {
 	Config       *config.Config
 	Logger       *zap.Logger
-	DB           *pgxpool.Pool
+	DB           *pgxpool.Pool // nil when running on the in-memory store
+	Store        *storage.Store
 	NC           *nats.Conn
 	JS           nats.JetStreamContext
 	PushGateway  *push.PushGateway
 	AlertService *alert.AlertService
 	PaperEngine  *paper.PaperEngine
+	Runner       *engine.StrategyRunner
+	Deployments  *engine.DeploymentManager
 	HTTPServer   *http.Server
+	KlineSource  storage.KlineSource
 }
 
 // NewApp creates a new application instance
@@ -57,15 +65,9 @@ func NewApp() (*App, error) {
 
 // Init initializes all application components
 func (a *App) Init(ctx context.Context) error {
-	// 1. Database
-	dbPool, err := pgxpool.New(ctx, a.Config.DB_DSN)
-	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
-	}
-	a.DB = dbPool
-
-	if err := a.initDatabase(ctx); err != nil {
-		return fmt.Errorf("failed to initialize database: %w", err)
+	// 1. Storage
+	if err := a.initStorage(ctx); err != nil {
+		return err
 	}
 
 	// 2. NATS
@@ -78,8 +80,10 @@ func (a *App) Init(ctx context.Context) error {
 
 	// 3. Services
 	a.PushGateway = push.NewPushGateway(js, a.Logger)
-	a.AlertService = alert.NewAlertService(a.DB, js, a.Logger)
-	a.PaperEngine = paper.NewPaperEngine(a.DB, js, a.Logger)
+	a.AlertService = alert.NewAlertService(a.Store.Alerts, js, a.Logger)
+	a.PaperEngine = paper.NewPaperEngine(a.Store.Paper, js, a.Logger)
+	a.Runner = engine.NewStrategyRunner(js, a.Store, a.Logger)
+	a.Deployments = engine.NewDeploymentManager(a.Store, a.Runner, a.PaperEngine, js, a.Logger)
 
 	return nil
 }
@@ -87,9 +91,26 @@ func (a *App) Init(ctx context.Context) error {
 // Run starts the application services and the HTTP server
 func (a *App) Run(ctx context.Context) error {
 	// Start Persistence Service
-	tradeSaver := storage.NewBatchSaver(a.DB, a.Logger, 1*time.Second, 1000)
-	klineSaver := storage.NewKlineSaver(a.DB, a.Logger, 1*time.Second, 100)
-	a.startPersistenceService(tradeSaver, klineSaver)
+	var tradeSpill, klineSpill, bookSpill *storage.SpillLog
+	if a.DB != nil {
+		var err error
+		tradeSpill, err = storage.NewSpillLog(filepath.Join(a.Config.SpillDir, "trades"), "trades", a.Config.SpillMaxBytes, a.Logger)
+		if err != nil {
+			return fmt.Errorf("failed to open trade spill log: %w", err)
+		}
+		klineSpill, err = storage.NewSpillLog(filepath.Join(a.Config.SpillDir, "klines"), "klines", a.Config.SpillMaxBytes, a.Logger)
+		if err != nil {
+			return fmt.Errorf("failed to open kline spill log: %w", err)
+		}
+		bookSpill, err = storage.NewSpillLog(filepath.Join(a.Config.SpillDir, "order_books"), "order_books", a.Config.SpillMaxBytes, a.Logger)
+		if err != nil {
+			return fmt.Errorf("failed to open order book spill log: %w", err)
+		}
+	}
+	tradeSaver := storage.NewBatchSaver(a.Store.Market, tradeSpill, a.Logger, 1*time.Second, 1000)
+	klineSaver := storage.NewKlineSaver(a.Store.Market, klineSpill, a.Logger, 1*time.Second, 100)
+	bookSaver := storage.NewOrderBookSaver(a.Store.Market, bookSpill, a.Logger, 1*time.Second, 100)
+	a.startPersistenceService(tradeSaver, klineSaver, bookSaver)
 
 	// Start Stream Processor
 	klineProcessor := processor.NewKlineProcessor(a.JS, a.Logger)
@@ -113,6 +134,9 @@ func (a *App) Run(ctx context.Context) error {
 	// Start Strategy Runner
 	a.startStrategyRunner(ctx)
 
+	// Start Backtest Worker
+	a.startBacktestWorker(ctx)
+
 	// Setup HTTP Server
 	a.HTTPServer = &http.Server{
 		Addr:    ":" + a.Config.Port,
@@ -143,30 +167,91 @@ func (a *App) waitForShutdown() error {
 	if err := a.HTTPServer.Shutdown(ctx); err != nil {
 		return fmt.Errorf("server shutdown failed: %w", err)
 	}
+	a.Runner.Checkpoint(ctx)
+	if err := a.Deployments.Close(ctx); err != nil {
+		a.Logger.Warn("failed to release deployment lease", zap.Error(err))
+	}
 
 	a.NC.Close()
-	a.DB.Close()
+	if a.DB != nil {
+		a.DB.Close()
+	}
 
 	return nil
 }
 
-// initDatabase runs the database initialization script
-func (a *App) initDatabase(ctx context.Context) error {
-	sqlFile := "scripts/init.sql"
-	content, err := os.ReadFile(sqlFile)
+// initStorage connects the configured storage backend. The memory backend is a
+// demo mode: nothing survives a restart and no database is required.
+func (a *App) initStorage(ctx context.Context) error {
+	source, err := storage.ParseKlineSource(a.Config.KlineSource)
+	if err != nil {
+		return err
+	}
+	a.KlineSource = source
+
+	switch a.Config.StorageBackend {
+	case "memory":
+		a.Logger.Warn("using in-memory storage, data will not be persisted")
+		a.Store = storage.NewMemoryStore()
+		return nil
+	case "", "postgres":
+	default:
+		return fmt.Errorf("unknown storage backend %q", a.Config.StorageBackend)
+	}
+
+	dbPool, err := pgxpool.New(ctx, a.Config.DB_DSN)
 	if err != nil {
-		return fmt.Errorf("failed to read init script: %w", err)
+		return fmt.Errorf("failed to connect to database: %w", err)
 	}
+	a.DB = dbPool
 
-	_, err = a.DB.Exec(ctx, string(content))
+	if err := a.initDatabase(ctx); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	if err := storage.ApplyRetentionPolicies(ctx, a.DB, a.Logger, a.retentionPolicies()); err != nil {
+		a.Logger.Error("failed to apply retention policies", zap.Error(err))
+	}
+
+	a.Store = storage.NewPostgresStore(a.DB, a.KlineSource)
+	return nil
+}
+
+// initDatabase applies pending schema migrations
+func (a *App) initDatabase(ctx context.Context) error {
+	if !a.Config.AutoMigrate {
+		a.Logger.Info("automatic migrations disabled")
+		return nil
+	}
+
+	runner, err := migrate.NewRunner(a.DB, a.Logger, migrations.FS)
 	if err != nil {
-		return fmt.Errorf("failed to execute init script: %w", err)
+		return err
+	}
+	applied, err := runner.Up(ctx)
+	if err != nil {
+		return err
 	}
 
-	a.Logger.Info("database initialized successfully")
+	a.Logger.Info("database initialized successfully", zap.Int("migrations_applied", applied))
 	return nil
 }
 
+// retentionPolicies builds the per-table retention settings from config
+func (a *App) retentionPolicies() []storage.RetentionPolicy {
+	policies := []storage.RetentionPolicy{
+		{Relation: "trades", DropAfter: a.Config.RetentionTrades},
+		{Relation: "klines", DropAfter: a.Config.RetentionKlines},
+		{Relation: "order_books", DropAfter: a.Config.RetentionOrderBooks},
+	}
+	// The aggregate views only exist once migration 008 has been applied
+	if a.KlineSource == storage.KlineSourceAggregate {
+		for _, view := range storage.KlineAggregateViews() {
+			policies = append(policies, storage.RetentionPolicy{Relation: view, DropAfter: a.Config.RetentionKlineAggregates})
+		}
+	}
+	return policies
+}
+
 // setupRouter configures the Gin router and its routes
 func (a *App) setupRouter() *gin.Engine {
 	r := gin.Default()
@@ -176,21 +261,32 @@ func (a *App) setupRouter() *gin.Engine {
 		c.String(http.StatusOK, "ok")
 	})
 
-	apiHandler := api.NewHandler(a.DB, a.Logger)
+	backtests := engine.NewBacktestQueue(a.NC, a.JS, a.Store.Backtests, a.Logger)
+	apiHandler := api.NewHandler(a.Store, a.Logger, backtests, a.Deployments)
 
 	v1 := r.Group("/api/v1")
 	{
 		v1.POST("/register", apiHandler.Register)
 		v1.POST("/login", apiHandler.Login)
 		v1.GET("/klines/:symbol", apiHandler.GetHistoryKLines)
+		v1.GET("/strategy-types", apiHandler.ListStrategyTypes)
 	}
 
 	protected := r.Group("/api/v1")
 	protected.Use(middleware.AuthMiddleware())
-	protected.Use(middleware.SubscriptionMiddleware(a.DB)) // Apply subscription limits
-	protected.Use(middleware.RateLimitMiddleware())        // Apply rate limiting
+	protected.Use(middleware.SubscriptionMiddleware(a.Store.Users)) // Apply subscription limits
+	protected.Use(middleware.RateLimitMiddleware())                 // Apply rate limiting
 	{
 		protected.POST("/backtest", apiHandler.RunBacktest)
+		protected.GET("/backtest/runs", apiHandler.ListBacktestRuns)
+		protected.GET("/backtest/runs/compare", apiHandler.CompareBacktestRuns)
+		protected.GET("/backtest/runs/:id", apiHandler.GetBacktestRun)
+		protected.POST("/backtest/runs/:id/cancel", apiHandler.CancelBacktestRun)
+		protected.POST("/backtest/runs/:id/montecarlo", apiHandler.RunMonteCarlo)
+		protected.POST("/backtest/optimize", apiHandler.StartOptimization)
+		protected.GET("/backtest/optimize/:id", apiHandler.GetOptimization)
+		protected.POST("/backtest/walkforward", apiHandler.StartWalkForward)
+		protected.GET("/backtest/walkforward/:id", apiHandler.GetWalkForward)
 		protected.POST("/backfill", apiHandler.TriggerBackfill)
 
 		// Alert management
@@ -210,6 +306,24 @@ func (a *App) setupRouter() *gin.Engine {
 		protected.GET("/portfolios", apiHandler.GetPortfolios)
 		protected.POST("/portfolios", apiHandler.CreatePortfolio)
 
+		// Strategies and their versions
+		protected.GET("/strategies", apiHandler.ListStrategies)
+		protected.POST("/strategies", apiHandler.CreateStrategy)
+		protected.GET("/strategies/:id", apiHandler.GetStrategy)
+		protected.PUT("/strategies/:id", apiHandler.UpdateStrategy)
+		protected.DELETE("/strategies/:id", apiHandler.DeleteStrategy)
+		protected.GET("/strategies/:id/versions", apiHandler.ListStrategyVersions)
+		protected.POST("/strategies/:id/versions", apiHandler.CreateStrategyVersion)
+
+		// Live deployments of strategy versions
+		protected.GET("/deployments", apiHandler.ListDeployments)
+		protected.POST("/deployments", apiHandler.CreateDeployment)
+		protected.GET("/deployments/:id", apiHandler.GetDeployment)
+		protected.POST("/deployments/:id/start", apiHandler.StartDeployment)
+		protected.POST("/deployments/:id/pause", apiHandler.PauseDeployment)
+		protected.POST("/deployments/:id/stop", apiHandler.StopDeployment)
+		protected.GET("/deployments/:id/executions", apiHandler.ListDeploymentExecutions)
+
 		// Marketplace
 		protected.GET("/market/strategies", apiHandler.ListMarketStrategies)
 		protected.POST("/market/strategies/:id/purchase", apiHandler.PurchaseStrategy)
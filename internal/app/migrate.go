@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"quant-trader/internal/migrate"
+	"quant-trader/scripts/migrations"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Migrate runs the migrate subcommand: "up", "down [steps]" or "status".
+// It only connects to the database, so it can run before the rest of the stack is up.
+func (a *App) Migrate(ctx context.Context, args []string) error {
+	dbPool, err := pgxpool.New(ctx, a.Config.DB_DSN)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer dbPool.Close()
+
+	runner, err := migrate.NewRunner(dbPool, a.Logger, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	switch cmd {
+	case "up":
+		applied, err := runner.Up(ctx)
+		if err != nil {
+			return err
+		}
+		a.Logger.Info("migrations complete", zap.Int("applied", applied))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid step count: %s", args[1])
+			}
+		}
+		reverted, err := runner.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		a.Logger.Info("rollback complete", zap.Int("reverted", reverted))
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%03d  %-32s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q (want up, down [steps] or status)", cmd)
+	}
+	return nil
+}
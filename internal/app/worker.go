@@ -64,6 +64,9 @@ func (a *App) startIngestionWorker(ctx context.Context) {
 			}
 
 			go c.Run(ctx, tradeChan)
+			if depth, ok := c.(depthConnector); ok {
+				go a.publishDepth(ctx, depth)
+			}
 
 			for {
 				select {
@@ -89,8 +92,39 @@ func (a *App) startIngestionWorker(ctx context.Context) {
 	}
 }
 
-// startPersistenceService subscribes to NATS and saves trades and klines to the database
-func (a *App) startPersistenceService(tradeSaver *storage.BatchSaver, klineSaver *storage.KlineSaver) {
+// depthConnector is a connector that also streams order book snapshots
+type depthConnector interface {
+	RunDepth(context.Context, chan<- model.OrderBook)
+}
+
+// publishDepth publishes the book snapshots of c to market.depth.<exchange>.<symbol>
+func (a *App) publishDepth(ctx context.Context, c depthConnector) {
+	bookChan := make(chan model.OrderBook, 100)
+	go c.RunDepth(ctx, bookChan)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case book := <-bookChan:
+			book.Symbol = NormalizeSymbol(book.Symbol)
+
+			subject := fmt.Sprintf("market.depth.%s.%s", book.Exchange, book.Symbol)
+			data, err := json.Marshal(book)
+			if err != nil {
+				a.Logger.Error("failed to marshal order book", zap.Error(err))
+				continue
+			}
+			if _, err := a.JS.Publish(subject, data); err != nil {
+				a.Logger.Error("failed to publish to NATS", zap.Error(err))
+			}
+		}
+	}
+}
+
+// startPersistenceService subscribes to NATS and saves trades, klines and
+// order book snapshots to the database
+func (a *App) startPersistenceService(tradeSaver *storage.BatchSaver, klineSaver *storage.KlineSaver, bookSaver *storage.OrderBookSaver) {
 	// 1. Subscribe to raw trades
 	_, err := a.JS.Subscribe("market.raw.*.*", func(m *nats.Msg) {
 		var trade model.Trade
@@ -111,16 +145,38 @@ func (a *App) startPersistenceService(tradeSaver *storage.BatchSaver, klineSaver
 			a.Logger.Error("failed to unmarshal kline", zap.Error(err))
 			return
 		}
+		// Higher periods are materialized by continuous aggregates
+		if !a.KlineSource.Persists(kline.Period) {
+			return
+		}
 		klineSaver.Add(kline)
 	}, nats.Durable("kline_saver"), nats.ManualAck())
 	if err != nil {
 		a.Logger.Fatal("failed to subscribe to klines", zap.Error(err))
 	}
+
+	// 3. Subscribe to depth snapshots, which tick backtests walk for fills
+	_, err = a.JS.Subscribe("market.depth.*.*", func(m *nats.Msg) {
+		// The saver spills what the database cannot take, so nothing needs redelivery
+		defer m.Ack()
+		var book model.OrderBook
+		if err := json.Unmarshal(m.Data, &book); err != nil {
+			a.Logger.Error("failed to unmarshal order book", zap.Error(err))
+			return
+		}
+		bookSaver.Add(book)
+	}, nats.Durable("book_saver"), nats.ManualAck())
+	if err != nil {
+		a.Logger.Fatal("failed to subscribe to order books", zap.Error(err))
+	}
 }
 
 // startStrategyRunner initializes and starts the live strategy runner
 func (a *App) startStrategyRunner(ctx context.Context) {
-	runner := engine.NewStrategyRunner(a.JS, a.Logger)
+	if err := a.Deployments.Restore(ctx); err != nil {
+		a.Logger.Error("failed to restore deployments", zap.Error(err))
+	}
+	go a.Deployments.Run(ctx)
 
 	// Add default strategy for testing
 	maCross, err := strategy.NewStrategy("ma_cross_v2", map[string]interface{}{
@@ -129,11 +185,45 @@ func (a *App) startStrategyRunner(ctx context.Context) {
 	})
 	if err != nil {
 		a.Logger.Error("failed to create strategy", zap.Error(err))
-		return
+	} else if err := a.Runner.AddStrategy(ctx, "default:ma_cross_v2", maCross, strategy.Subscription{Symbols: []string{"BTCUSDT"}, Period: "1m"}); err != nil {
+		a.Logger.Error("failed to add strategy", zap.Error(err))
 	}
-	runner.AddStrategy(maCross)
 
-	if err := runner.Run(ctx); err != nil {
+	library := engine.NewStrategyLibrary(a.Store.Strategies)
+	for _, ref := range strings.Split(a.Config.LiveStrategyVersions, ",") {
+		if ref = strings.TrimSpace(ref); ref == "" {
+			continue
+		}
+		sub := strategy.Subscription{Symbols: []string{"BTCUSDT"}, Period: "1m"}
+		version, feed, ok := strings.Cut(ref, "@")
+		if ok {
+			symbols, period, _ := strings.Cut(feed, "/")
+			sub = strategy.Subscription{Symbols: strings.Split(symbols, "+"), Period: period}
+		}
+		var userID, versionID int64
+		if _, err := fmt.Sscanf(version, "%d:%d", &userID, &versionID); err != nil {
+			a.Logger.Error("invalid live strategy version, want user_id:version_id[@SYMBOL+SYMBOL/period]", zap.String("ref", ref))
+			continue
+		}
+		strat, err := library.Strategy(ctx, userID, versionID)
+		if err != nil {
+			a.Logger.Error("failed to load live strategy version", zap.String("ref", ref), zap.Error(err))
+			continue
+		}
+		if err := a.Runner.AddStrategy(ctx, "version:"+ref, strat, sub); err != nil {
+			a.Logger.Error("failed to add live strategy version", zap.String("ref", ref), zap.Error(err))
+		}
+	}
+
+	if err := a.Runner.Run(ctx); err != nil {
 		a.Logger.Error("failed to start strategy runner", zap.Error(err))
 	}
 }
+
+// startBacktestWorker consumes the backtest job queue
+func (a *App) startBacktestWorker(ctx context.Context) {
+	worker := engine.NewBacktestWorker(a.NC, a.JS, a.Store.Backtests, engine.NewStrategyLibrary(a.Store.Strategies), engine.NewDataLoader(a.Store.Market), a.Config.BacktestWorkers, a.Logger)
+	if err := worker.Start(ctx); err != nil {
+		a.Logger.Error("failed to start backtest worker", zap.Error(err))
+	}
+}
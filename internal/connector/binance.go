@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"quant-trader/internal/infrastructure"
 	"quant-trader/internal/model"
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -40,8 +41,52 @@ type BinanceTradeEvent struct {
 	Ignore       bool   `json:"M"`
 }
 
+// BinanceDepthEvent is a partial book depth snapshot from Binance WS
+type BinanceDepthEvent struct {
+	LastUpdateID int64       `json:"lastUpdateId"`
+	Bids         [][2]string `json:"bids"`
+	Asks         [][2]string `json:"asks"`
+}
+
 func (b *BinanceConnector) Run(ctx context.Context, tradeChan chan<- model.Trade) {
 	url := fmt.Sprintf("wss://stream.binance.com:9443/ws/%s@trade", b.symbol)
+	b.stream(ctx, url, func(message []byte) {
+		var event BinanceTradeEvent
+		if err := json.Unmarshal(message, &event); err != nil {
+			b.logger.Error("failed to unmarshal binance trade event", zap.Error(err))
+			return
+		}
+
+		trade := b.convertToModel(event)
+		select {
+		case tradeChan <- trade:
+		default:
+			b.logger.Warn("trade channel full, dropping trade", zap.String("trade_id", trade.ID))
+		}
+	})
+}
+
+// RunDepth streams a snapshot of the top 20 levels of the book every second
+func (b *BinanceConnector) RunDepth(ctx context.Context, bookChan chan<- model.OrderBook) {
+	url := fmt.Sprintf("wss://stream.binance.com:9443/ws/%s@depth20", b.symbol)
+	b.stream(ctx, url, func(message []byte) {
+		var event BinanceDepthEvent
+		if err := json.Unmarshal(message, &event); err != nil {
+			b.logger.Error("failed to unmarshal binance depth event", zap.Error(err))
+			return
+		}
+
+		select {
+		case bookChan <- b.convertDepth(event, time.Now()):
+		default:
+			b.logger.Warn("order book channel full, dropping snapshot", zap.Int64("last_update_id", event.LastUpdateID))
+		}
+	})
+}
+
+// stream keeps a websocket to url open, reconnecting with backoff, and hands
+// every message to handle
+func (b *BinanceConnector) stream(ctx context.Context, url string, handle func(message []byte)) {
 	backoff := time.Second
 
 	for {
@@ -67,7 +112,7 @@ func (b *BinanceConnector) Run(ctx context.Context, tradeChan chan<- model.Trade
 		b.logger.Info("connected to binance websocket")
 		infrastructure.WSConnections.Inc()
 
-		if err := b.handleConnection(ctx, conn, tradeChan); err != nil {
+		if err := b.handleConnection(ctx, conn, handle); err != nil {
 			b.logger.Error("connection closed with error", zap.Error(err))
 		}
 		infrastructure.WSConnections.Dec()
@@ -75,7 +120,7 @@ func (b *BinanceConnector) Run(ctx context.Context, tradeChan chan<- model.Trade
 	}
 }
 
-func (b *BinanceConnector) handleConnection(ctx context.Context, conn *websocket.Conn, tradeChan chan<- model.Trade) error {
+func (b *BinanceConnector) handleConnection(ctx context.Context, conn *websocket.Conn, handle func(message []byte)) error {
 	// Ping/Pong is handled automatically by gorilla/websocket default handlers if we don't override them.
 	// But we can set a read deadline to detect stale connections.
 	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
@@ -93,19 +138,7 @@ func (b *BinanceConnector) handleConnection(ctx context.Context, conn *websocket
 			if err != nil {
 				return err
 			}
-
-			var event BinanceTradeEvent
-			if err := json.Unmarshal(message, &event); err != nil {
-				b.logger.Error("failed to unmarshal binance trade event", zap.Error(err))
-				continue
-			}
-
-			trade := b.convertToModel(event)
-			select {
-			case tradeChan <- trade:
-			default:
-				b.logger.Warn("trade channel full, dropping trade", zap.String("trade_id", trade.ID))
-			}
+			handle(message)
 		}
 	}
 }
@@ -130,6 +163,18 @@ func (b *BinanceConnector) convertToModel(event BinanceTradeEvent) model.Trade {
 	}
 }
 
+// convertDepth stamps a depth snapshot, which carries no time of its own,
+// with when it was received
+func (b *BinanceConnector) convertDepth(event BinanceDepthEvent, received time.Time) model.OrderBook {
+	return model.OrderBook{
+		Symbol:    strings.ToUpper(b.symbol),
+		Exchange:  "binance",
+		Timestamp: received.Truncate(time.Millisecond),
+		Bids:      event.Bids,
+		Asks:      event.Asks,
+	}
+}
+
 func (b *BinanceConnector) increaseBackoff(current time.Duration) time.Duration {
 	next := current * 2
 	if next > time.Minute {
@@ -33,6 +33,24 @@ func TestBinanceConnector_ConvertToModel(t *testing.T) {
 	assert.Equal(t, time.Unix(0, 1640123456789*int64(time.Millisecond)), trade.Timestamp)
 }
 
+func TestBinanceConnector_ConvertDepth(t *testing.T) {
+	c := NewBinanceConnector(zap.NewNop(), "btcusdt")
+	event := BinanceDepthEvent{
+		LastUpdateID: 160,
+		Bids:         [][2]string{{"50000.00", "1.5"}, {"49999.50", "2"}},
+		Asks:         [][2]string{{"50000.50", "0.3"}},
+	}
+	received := time.Date(2024, 1, 1, 0, 0, 1, 123456789, time.UTC)
+
+	book := c.convertDepth(event, received)
+
+	assert.Equal(t, "BTCUSDT", book.Symbol)
+	assert.Equal(t, "binance", book.Exchange)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 1, 123000000, time.UTC), book.Timestamp)
+	assert.Equal(t, event.Bids, book.Bids)
+	assert.Equal(t, event.Asks, book.Asks)
+}
+
 func TestOKXConnector_ConvertToModel(t *testing.T) {
 	logger := zap.NewNop()
 	c := NewOKXConnector(logger, "BTC-USDT")
@@ -0,0 +1,253 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+const (
+	// BacktestJobSubject is the JetStream work queue backtest runs are published to
+	BacktestJobSubject = "backtest.jobs"
+	// backtestCancelPrefix is followed by the run id; every worker hears it
+	backtestCancelPrefix = "backtest.cancel."
+)
+
+// backtestJob is the queue message; the run itself lives in backtest_runs
+type backtestJob struct {
+	RunID int64 `json:"run_id"`
+}
+
+// BacktestQueue records backtest runs and hands them to the workers over NATS
+type BacktestQueue struct {
+	nc     *nats.Conn
+	js     nats.JetStreamContext
+	repo   storage.BacktestRepository
+	logger *zap.Logger
+}
+
+func NewBacktestQueue(nc *nats.Conn, js nats.JetStreamContext, repo storage.BacktestRepository, logger *zap.Logger) *BacktestQueue {
+	return &BacktestQueue{nc: nc, js: js, repo: repo, logger: logger}
+}
+
+// Submit stores spec as a queued run of userID and publishes it to the workers
+func (q *BacktestQueue) Submit(ctx context.Context, userID int64, spec BacktestSpec) (model.BacktestRun, error) {
+	if err := spec.Validate(); err != nil {
+		return model.BacktestRun{}, err
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return model.BacktestRun{}, fmt.Errorf("failed to encode backtest spec: %w", err)
+	}
+	config, err := json.Marshal(spec.Config)
+	if err != nil {
+		return model.BacktestRun{}, fmt.Errorf("failed to encode strategy config: %w", err)
+	}
+
+	run := model.BacktestRun{
+		UserID:            userID,
+		Status:            model.BacktestQueued,
+		StrategyType:      spec.StrategyType,
+		Config:            config,
+		StrategyVersionID: spec.StrategyVersionID,
+		Symbols:           spec.Symbols,
+		Exchange:          spec.Exchange,
+		Period:            spec.Period,
+		StartTime:         spec.Start,
+		EndTime:           spec.End,
+		Spec:              data,
+	}
+	if run.ID, err = q.repo.CreateBacktestRun(ctx, run); err != nil {
+		return model.BacktestRun{}, err
+	}
+
+	msg, _ := json.Marshal(backtestJob{RunID: run.ID})
+	if _, err := q.js.Publish(BacktestJobSubject, msg, nats.Context(ctx)); err != nil {
+		// Nothing will pick the run up, so it must not stay queued
+		if _, claimErr := q.repo.ClaimBacktestRun(ctx, run.ID); claimErr == nil {
+			_ = q.repo.FinishBacktestRun(ctx, run.ID, model.BacktestFailed, nil, "failed to enqueue")
+		}
+		return model.BacktestRun{}, fmt.Errorf("failed to enqueue backtest: %w", err)
+	}
+	return run, nil
+}
+
+// Cancel cancels a queued or running run of userID. A worker running it stops
+// at its next candle; whatever it computed is discarded.
+func (q *BacktestQueue) Cancel(ctx context.Context, userID, runID int64) error {
+	if err := q.repo.CancelBacktestRun(ctx, userID, runID); err != nil {
+		return err
+	}
+	if err := q.nc.Publish(backtestCancelPrefix+strconv.FormatInt(runID, 10), nil); err != nil {
+		// The run is already marked cancelled, its result will be rejected
+		q.logger.Warn("failed to notify workers of cancellation", zap.Int64("run_id", runID), zap.Error(err))
+	}
+	return nil
+}
+
+// BacktestWorker runs queued backtests, several at a time
+type BacktestWorker struct {
+	nc          *nats.Conn
+	js          nats.JetStreamContext
+	repo        storage.BacktestRepository
+	strategies  *StrategyLibrary
+	loader      *DataLoader
+	logger      *zap.Logger
+	concurrency int
+
+	mu      sync.Mutex
+	running map[int64]context.CancelFunc
+}
+
+func NewBacktestWorker(nc *nats.Conn, js nats.JetStreamContext, repo storage.BacktestRepository, strategies *StrategyLibrary, loader *DataLoader, concurrency int, logger *zap.Logger) *BacktestWorker {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &BacktestWorker{
+		nc:          nc,
+		js:          js,
+		repo:        repo,
+		strategies:  strategies,
+		loader:      loader,
+		logger:      logger,
+		concurrency: concurrency,
+		running:     make(map[int64]context.CancelFunc),
+	}
+}
+
+// Start listens for cancellations and consumes the job queue until ctx is done.
+// Jobs are acknowledged once finished; a job whose worker dies is redelivered.
+func (w *BacktestWorker) Start(ctx context.Context) error {
+	_, err := w.nc.Subscribe(backtestCancelPrefix+"*", func(msg *nats.Msg) {
+		id, err := strconv.ParseInt(strings.TrimPrefix(msg.Subject, backtestCancelPrefix), 10, 64)
+		if err == nil {
+			w.cancel(id)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.js.QueueSubscribe(BacktestJobSubject, "backtest_workers", func(msg *nats.Msg) {
+		go w.handle(ctx, msg)
+	}, nats.Durable("backtest_worker"), nats.ManualAck(), nats.MaxAckPending(w.concurrency), nats.AckWait(time.Minute))
+	if err != nil {
+		return err
+	}
+
+	w.logger.Info("backtest worker started", zap.Int("concurrency", w.concurrency))
+	return nil
+}
+
+func (w *BacktestWorker) handle(ctx context.Context, msg *nats.Msg) {
+	var job backtestJob
+	if err := json.Unmarshal(msg.Data, &job); err != nil {
+		w.logger.Error("failed to unmarshal backtest job", zap.Error(err))
+		msg.Term()
+		return
+	}
+
+	// Keep the message from being redelivered while the backtest runs
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(20 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				msg.InProgress()
+			}
+		}
+	}()
+
+	err := w.Execute(ctx, job.RunID)
+	close(done)
+	if err != nil && ctx.Err() != nil {
+		// Shutting down: leave the job for another worker
+		return
+	}
+	if err != nil {
+		w.logger.Error("backtest job failed", zap.Int64("run_id", job.RunID), zap.Error(err))
+	}
+	msg.Ack()
+}
+
+// Execute claims a run, backtests it and stores the outcome. Runs that were
+// cancelled or already finished are skipped. It only returns an error if the
+// outcome could not be stored or ctx ended the run.
+func (w *BacktestWorker) Execute(ctx context.Context, runID int64) error {
+	run, err := w.repo.ClaimBacktestRun(ctx, runID)
+	if errors.Is(err, storage.ErrConflict) || errors.Is(err, storage.ErrNotFound) {
+		w.logger.Debug("skipping backtest run", zap.Int64("run_id", runID), zap.Error(err))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var spec BacktestSpec
+	if err := json.Unmarshal(run.Spec, &spec); err != nil {
+		return w.finish(ctx, runID, nil, fmt.Errorf("invalid backtest spec: %w", err))
+	}
+	if err := w.strategies.Load(ctx, &spec); err != nil {
+		return w.finish(ctx, runID, nil, err)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	w.mu.Lock()
+	w.running[runID] = cancel
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		delete(w.running, runID)
+		w.mu.Unlock()
+		cancel()
+	}()
+
+	started := time.Now()
+	report, err := spec.Run(runCtx, w.loader)
+	switch {
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case runCtx.Err() != nil:
+		// Cancelled by the user, the run is already marked as such
+		return nil
+	case err != nil:
+		return w.finish(ctx, runID, nil, err)
+	}
+	w.logger.Info("backtest run finished", zap.Int64("run_id", runID), zap.Duration("took", time.Since(started)))
+	return w.finish(ctx, runID, &report, nil)
+}
+
+func (w *BacktestWorker) finish(ctx context.Context, runID int64, report *model.BacktestReport, runErr error) error {
+	status, msg := model.BacktestDone, ""
+	if runErr != nil {
+		status, msg = model.BacktestFailed, runErr.Error()
+	}
+	err := w.repo.FinishBacktestRun(ctx, runID, status, report, msg)
+	if errors.Is(err, storage.ErrConflict) {
+		// Cancelled while running
+		return nil
+	}
+	return err
+}
+
+func (w *BacktestWorker) cancel(runID int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if cancel, ok := w.running[runID]; ok {
+		cancel()
+	}
+}
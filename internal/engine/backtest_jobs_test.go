@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func queueRun(t *testing.T, repo storage.BacktestRepository, spec BacktestSpec) int64 {
+	t.Helper()
+	data, err := json.Marshal(spec)
+	require.NoError(t, err)
+	id, err := repo.CreateBacktestRun(context.Background(), model.BacktestRun{UserID: 1, Symbols: spec.Symbols, Spec: data})
+	require.NoError(t, err)
+	return id
+}
+
+func TestBacktestWorker_Execute(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	seedTrend(t, store.Market)
+	worker := NewBacktestWorker(nil, nil, store.Backtests, NewStrategyLibrary(store.Strategies), NewDataLoader(store.Market), 1, zap.NewNop())
+
+	// The spec survives the round trip through the table
+	spec := maSpec(start)
+	spec.FeeModel = "maker_taker"
+	spec.FeeConfig = map[string]interface{}{"maker": 0.0002, "taker": 0.0005}
+	id := queueRun(t, store.Backtests, spec)
+	require.NoError(t, worker.Execute(ctx, id))
+	run, err := store.Backtests.GetBacktestRun(ctx, 1, id)
+	require.NoError(t, err)
+	assert.Equal(t, model.BacktestDone, run.Status)
+	require.NotNil(t, run.Report)
+	direct, err := spec.Run(ctx, NewDataLoader(store.Market))
+	require.NoError(t, err)
+	assert.True(t, direct.FinalBalance.Equal(run.Report.FinalBalance))
+
+	// Missing data fails the run with the gap
+	gappy := maSpec(start)
+	gappy.End = start.Add(20 * time.Minute)
+	id = queueRun(t, store.Backtests, gappy)
+	require.NoError(t, worker.Execute(ctx, id))
+	run, err = store.Backtests.GetBacktestRun(ctx, 1, id)
+	require.NoError(t, err)
+	assert.Equal(t, model.BacktestFailed, run.Status)
+	assert.Contains(t, run.Error, "missing 1m candles")
+	assert.Nil(t, run.Report)
+
+	// Cancelled before a worker got to it
+	id = queueRun(t, store.Backtests, maSpec(start))
+	require.NoError(t, store.Backtests.CancelBacktestRun(ctx, 1, id))
+	require.NoError(t, worker.Execute(ctx, id))
+	run, err = store.Backtests.GetBacktestRun(ctx, 1, id)
+	require.NoError(t, err)
+	assert.Equal(t, model.BacktestCancelled, run.Status)
+	assert.Nil(t, run.StartedAt)
+}
+
+func TestBacktestWorker_Shutdown(t *testing.T) {
+	store := storage.NewMemoryStore()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	seedTrend(t, store.Market)
+	worker := NewBacktestWorker(nil, nil, store.Backtests, NewStrategyLibrary(store.Strategies), NewDataLoader(store.Market), 1, zap.NewNop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	id := queueRun(t, store.Backtests, maSpec(start))
+	assert.ErrorIs(t, worker.Execute(ctx, id), context.Canceled)
+
+	// Left running for redelivery, which claims it again
+	run, err := store.Backtests.ClaimBacktestRun(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, model.BacktestRunning, run.Status)
+}
@@ -1,205 +1,573 @@
 package engine
 
 import (
-	"math"
 	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
 	"quant-trader/internal/strategy"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/shopspring/decimal"
 )
 
+// Broker is the account view an order-driven strategy trades through during a backtest
+type Broker interface {
+	// Submit queues an order; it can fill from the next bar on
+	Submit(req model.OrderRequest) (int64, error)
+	Cancel(orderID int64) bool
+	OpenOrders(symbol string) []model.Order
+	// Order returns any order submitted so far, open or not
+	Order(orderID int64) (model.Order, bool)
+	Position(symbol string) Position
+	// Cash is the wallet balance: deposits plus realized PnL, fees and funding
+	Cash() decimal.Decimal
+	// Equity is the wallet balance plus the unrealized PnL of open positions
+	Equity() decimal.Decimal
+	// BuyingPower is the notional that can still be opened with the free margin
+	BuyingPower() decimal.Decimal
+	Margin() MarginConfig
+}
+
+// OrderStrategy is a strategy that manages its own orders instead of returning actions
+type OrderStrategy interface {
+	Name() string
+	// OnBar is called after the orders resting on bar have been matched
+	OnBar(bar model.KLine, broker Broker)
+}
+
+// Position is the holding of one symbol; Qty is negative for shorts
+type Position struct {
+	Symbol      string          `json:"symbol"`
+	Qty         decimal.Decimal `json:"qty"`
+	EntryPrice  decimal.Decimal `json:"entry_price"` // average, excluding fees
+	EntryFees   decimal.Decimal `json:"entry_fees"`  // fees paid for the open quantity
+	RealizedPnL decimal.Decimal `json:"realized_pnl"`
+	Funding     decimal.Decimal `json:"funding"` // paid (positive) or received (negative)
+	OpenedAt    time.Time       `json:"opened_at"`
+}
+
+// Backtester replays bars as events. Bars of several symbols are grouped by
+// timestamp; for each bar funding is settled, resting orders are matched and
+// liquidations are checked, then equity is marked at the close and the strategy
+// reacts to the whole slice.
 type Backtester struct {
-	strategy    strategy.Strategy
-	balance     decimal.Decimal
-	position    decimal.Decimal // current quantity held
-	feeRate     decimal.Decimal
-	slippage    decimal.Decimal
+	strategy    PortfolioStrategy
+	balance     decimal.Decimal // wallet balance
+	fees        FeeModel
+	slip        SlippageModel
+	fills       FillModel
+	margin      MarginConfig
+	positions   map[string]*Position
+	lastPrices  map[string]decimal.Decimal
+	lastBars    map[string]model.KLine
+	orders      []*model.Order // every order in submission order
+	open        []*model.Order
+	nextOrderID int64
+	now         time.Time
 	trades      []model.SimulatedTrade
 	equityCurve []decimal.Decimal
-	returns     []float64
+	equityTimes []time.Time
+	period      time.Duration // bar length, for annualization
+	exposedBars int
+	holdings    []time.Duration // holding time of each closing fill
+	volume      decimal.Decimal // trailing 30 day quote volume, see volumeLog
+	volumeLog   []volumeEntry
+
+	symbols       []string // in order of first appearance
+	symbolReturns map[string]map[int64]float64
+
+	liquidations []model.LiquidationEvent
+	fundingPaid  decimal.Decimal
+	marginUsage  []float64
+
+	ticks *tickState // set while RunTicks replays trades and depth snapshots
 }
 
+// NewBacktester runs an action-based strategy: buy invests all buying power with a
+// market order and sell closes the whole position, both filling on the next bar.
 func NewBacktester(strat strategy.Strategy, initialBalance decimal.Decimal) *Backtester {
+	return NewOrderBacktester(&actionStrategy{strats: map[string]strategy.Strategy{"": strat}}, initialBalance)
+}
+
+// NewOrderBacktester runs a strategy that submits its own orders
+func NewOrderBacktester(strat OrderStrategy, initialBalance decimal.Decimal) *Backtester {
+	return newBacktester(barStrategy{strat}, initialBalance)
+}
+
+func newBacktester(strat PortfolioStrategy, initialBalance decimal.Decimal) *Backtester {
 	return &Backtester{
 		strategy:    strat,
 		balance:     initialBalance,
-		position:    decimal.Zero,
-		feeRate:     decimal.NewFromFloat(0.001),  // 0.1% fee
-		slippage:    decimal.NewFromFloat(0.0005), // 0.05% slippage
+		fees:        FlatFee{Rate: decimal.NewFromFloat(0.001)}, // 0.1% fee
+		slip:        FixedSlippage{Bps: decimal.NewFromInt(5)},  // 0.05% slippage
+		fills:       NextOpenFill{},
+		margin:      SpotMargin(),
+		positions:   make(map[string]*Position),
+		lastPrices:  make(map[string]decimal.Decimal),
+		lastBars:    make(map[string]model.KLine),
 		trades:      make([]model.SimulatedTrade, 0),
 		equityCurve: make([]decimal.Decimal, 0),
-		returns:     make([]float64, 0),
+
+		symbolReturns: make(map[string]map[int64]float64),
 	}
 }
 
+// CandleSource streams candles in time order, e.g. a *CandleIterator from DataLoader
+// or a *MergedSource over several symbols
+type CandleSource interface {
+	Next() bool
+	KLine() model.KLine
+	Err() error
+}
+
 func (b *Backtester) Run(candles []model.KLine) model.BacktestReport {
+	report, _ := b.RunSource(storage.NewSliceKlineIterator(candles))
+	return report
+}
+
+// RunSource consumes candles one at a time so the whole history never has to be
+// held in memory. It stops with the source's error, e.g. an ErrDataGap.
+func (b *Backtester) RunSource(src CandleSource) (model.BacktestReport, error) {
 	initialBalance := b.balance
-	prevEquity := initialBalance
 
-	for _, candle := range candles {
-		action := b.strategy.OnCandle(candle)
+	stream := newSliceSource(src)
+	for stream.Next() {
+		slice := stream.Slice()
+		b.now = slice.Time
 
-		if action == strategy.ActionBuy && b.balance.GreaterThan(decimal.Zero) {
-			b.buy(candle)
-		} else if action == strategy.ActionSell && b.position.GreaterThan(decimal.Zero) {
-			b.sell(candle)
+		for _, bar := range slice.Bars {
+			b.settleFunding(bar)
+			b.matchOrders(bar)
+			b.closeBar(bar)
 		}
+		b.closeSlice(slice)
+	}
+	if err := stream.Err(); err != nil {
+		return model.BacktestReport{}, err
+	}
 
-		// Track equity curve and returns
-		currentEquity := b.balance.Add(b.position.Mul(candle.Close))
-		b.equityCurve = append(b.equityCurve, currentEquity)
+	b.closeOut()
+	return b.report(initialBalance), nil
+}
 
-		ret, _ := currentEquity.Sub(prevEquity).Div(prevEquity).Float64()
-		b.returns = append(b.returns, ret)
-		prevEquity = currentEquity
+// closeBar checks liquidation on bar once its orders are matched and marks its close
+func (b *Backtester) closeBar(bar model.KLine) {
+	b.checkLiquidation(bar)
+	b.trackSymbolReturn(bar)
+	b.lastPrices[bar.Symbol] = bar.Close
+	b.lastBars[bar.Symbol] = bar
+	b.observe(bar)
+	if b.period == 0 && bar.Period != "" {
+		b.period = model.PeriodToDuration(bar.Period)
 	}
+}
 
-	// Final liquidation at last price
-	if b.position.GreaterThan(decimal.Zero) && len(candles) > 0 {
-		b.sell(candles[len(candles)-1])
+// closeSlice tracks the equity curve, exposure and margin usage at the close of
+// slice and lets the strategy react to it
+func (b *Backtester) closeSlice(slice Slice) {
+	currentEquity := b.Equity()
+	b.equityCurve = append(b.equityCurve, currentEquity)
+	b.equityTimes = append(b.equityTimes, slice.Time)
+	if b.exposed() {
+		b.exposedBars++
 	}
+	b.marginUsage = append(b.marginUsage, b.marginUsageRatio(currentEquity))
 
-	totalReturn := b.balance.Sub(initialBalance).Div(initialBalance)
-	maxDD := b.calculateMaxDrawdown()
-	maxDDFloat, _ := maxDD.Float64()
+	b.strategy.OnSlice(slice, b)
+}
 
-	winRate, totalProfit := b.calculateStats()
-	sharpe := b.calculateSharpeRatio()
+// Submit implements Broker
+func (b *Backtester) Submit(req model.OrderRequest) (int64, error) {
+	if err := req.Validate(); err != nil {
+		return 0, err
+	}
+	if req.TIF == "" {
+		req.TIF = model.TIFGoodTillCancel
+	}
 
-	return model.BacktestReport{
-		StrategyName:   b.strategy.Name(),
-		TotalTrades:    len(b.trades),
-		WinRate:        winRate,
-		TotalReturn:    totalReturn,
-		TotalProfit:    totalProfit,
-		MaxDrawdown:    maxDDFloat,
-		SharpRatio:     sharpe,
-		InitialBalance: initialBalance,
-		FinalBalance:   b.balance,
-		TradesLog:      b.trades,
+	b.nextOrderID++
+	o := &model.Order{
+		ID:           b.nextOrderID,
+		OrderRequest: req,
+		Status:       model.OrderOpen,
+		CreatedAt:    b.now,
+		UpdatedAt:    b.now,
+	}
+	b.orders = append(b.orders, o)
+	if b.ticks != nil {
+		// Replaying ticks: the order waits for the next tick of its symbol
+		b.ticks.join(o)
+		b.open = append(b.open, o)
+		return o.ID, nil
+	}
+	if last, ok := b.lastBars[o.Symbol]; ok {
+		if exec, ok := b.fills.OnSubmit(o, last); ok {
+			b.fill(o, exec, last)
+			return o.ID, nil
+		}
 	}
+	b.open = append(b.open, o)
+	return o.ID, nil
 }
 
-func (b *Backtester) buy(candle model.KLine) {
-	price := candle.Close.Mul(decimal.NewFromFloat(1).Add(b.slippage))
-	qty := b.balance.Div(price.Mul(decimal.NewFromFloat(1).Add(b.feeRate)))
-
-	if qty.LessThanOrEqual(decimal.Zero) {
-		return
+// Cancel implements Broker
+func (b *Backtester) Cancel(orderID int64) bool {
+	for _, o := range b.open {
+		if o.ID == orderID && o.Status == model.OrderOpen {
+			b.finish(o, model.OrderCancelled, "cancelled by strategy")
+			b.pruneOpen()
+			if b.ticks != nil {
+				b.ticks.forget(orderID)
+			}
+			return true
+		}
 	}
+	return false
+}
 
-	fee := qty.Mul(price).Mul(b.feeRate)
-	b.balance = b.balance.Sub(qty.Mul(price)).Sub(fee)
-	b.position = b.position.Add(qty)
+// OpenOrders implements Broker
+func (b *Backtester) OpenOrders(symbol string) []model.Order {
+	var orders []model.Order
+	for _, o := range b.open {
+		if o.Symbol == symbol {
+			orders = append(orders, *o)
+		}
+	}
+	return orders
+}
 
-	b.trades = append(b.trades, model.SimulatedTrade{
-		Time:   candle.Timestamp,
-		Symbol: candle.Symbol,
-		Side:   "buy",
-		Price:  price,
-		Size:   qty,
-		Fee:    fee,
-	})
+// Order implements Broker
+func (b *Backtester) Order(orderID int64) (model.Order, bool) {
+	// IDs are assigned in submission order from 1
+	if orderID < 1 || orderID > int64(len(b.orders)) {
+		return model.Order{}, false
+	}
+	return *b.orders[orderID-1], true
 }
 
-func (b *Backtester) sell(candle model.KLine) {
-	price := candle.Close.Mul(decimal.NewFromFloat(1).Sub(b.slippage))
-	saleValue := b.position.Mul(price)
-	fee := saleValue.Mul(b.feeRate)
+// Position implements Broker
+func (b *Backtester) Position(symbol string) Position {
+	if p, ok := b.positions[symbol]; ok {
+		return *p
+	}
+	return Position{Symbol: symbol}
+}
 
-	// Calculate PnL for the closed position
-	// This is a simplified calculation: (sell_price - avg_buy_price) * qty
-	// For this demo, we assume full position sell
+// Cash implements Broker
+func (b *Backtester) Cash() decimal.Decimal {
+	return b.balance
+}
 
-	pnl := saleValue.Sub(fee) // This is not true PnL, but net sale.
-	// To be accurate we need to track cost basis.
+// Equity implements Broker: positions are marked at their last close
+func (b *Backtester) Equity() decimal.Decimal {
+	equity := b.balance
+	for symbol, p := range b.positions {
+		equity = equity.Add(p.unrealized(b.lastPrices[symbol]))
+	}
+	return equity
+}
 
-	b.balance = b.balance.Add(saleValue).Sub(fee)
+// BuyingPower implements Broker
+func (b *Backtester) BuyingPower() decimal.Decimal {
+	free := b.Equity().Sub(b.usedMargin())
+	if !free.IsPositive() {
+		return decimal.Zero
+	}
+	return free.Mul(b.margin.Leverage)
+}
 
-	b.trades = append(b.trades, model.SimulatedTrade{
-		Time:   candle.Timestamp,
-		Symbol: candle.Symbol,
-		Side:   "sell",
-		Price:  price,
-		Size:   b.position,
-		Fee:    fee,
-		PnL:    pnl, // Simplified
-	})
+// Margin implements Broker
+func (b *Backtester) Margin() MarginConfig {
+	return b.margin
+}
 
-	b.position = decimal.Zero
+// exposed reports whether any position is open
+func (b *Backtester) exposed() bool {
+	for _, p := range b.positions {
+		if !p.Qty.IsZero() {
+			return true
+		}
+	}
+	return false
 }
 
-func (b *Backtester) calculateMaxDrawdown() decimal.Decimal {
-	if len(b.equityCurve) == 0 {
+func (p *Position) unrealized(mark decimal.Decimal) decimal.Decimal {
+	if p.Qty.IsZero() || !mark.IsPositive() {
 		return decimal.Zero
 	}
-	maxEquity := b.equityCurve[0]
-	maxDD := decimal.Zero
-	for _, equity := range b.equityCurve {
-		if equity.GreaterThan(maxEquity) {
-			maxEquity = equity
+	return mark.Sub(p.EntryPrice).Mul(p.Qty)
+}
+
+func sideSign(side model.OrderSide) int {
+	if side == model.SideBuy {
+		return 1
+	}
+	return -1
+}
+
+// matchOrders fills, expires or cancels the resting orders of bar's symbol.
+// Executions are applied in the order the fill model places them within the bar.
+func (b *Backtester) matchOrders(bar model.KLine) {
+	type match struct {
+		order *model.Order
+		exec  Execution
+	}
+	var matched []match
+	for _, o := range b.open {
+		if o.Symbol != bar.Symbol || o.Status != model.OrderOpen {
+			continue
+		}
+		if o.TIF == model.TIFGoodTillDate && bar.Timestamp.After(o.ExpireAt) {
+			b.finish(o, model.OrderExpired, "")
+			continue
 		}
-		dd := maxEquity.Sub(equity).Div(maxEquity)
-		if dd.GreaterThan(maxDD) {
-			maxDD = dd
+		if exec, ok := b.fills.Match(o, bar); ok {
+			matched = append(matched, match{o, exec})
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].exec.Seq < matched[j].exec.Seq })
+	for _, m := range matched {
+		b.fill(m.order, m.exec, bar)
+	}
+
+	for _, o := range b.open {
+		if o.Symbol == bar.Symbol && o.Status == model.OrderOpen && o.TIF == model.TIFImmediate {
+			b.finish(o, model.OrderCancelled, "not filled on the next bar")
 		}
 	}
-	return maxDD
+	b.pruneOpen()
 }
 
-func (b *Backtester) calculateStats() (float64, decimal.Decimal) {
-	if len(b.trades) == 0 {
-		return 0, decimal.Zero
+// fill executes the remaining quantity of o on bar plus costs. The part that
+// reduces an existing position is always accepted; the part that opens or adds
+// exposure is capped by the available margin, and by the position size when
+// shorting is disabled.
+func (b *Backtester) fill(o *model.Order, exec Execution, bar model.KLine) {
+	price := exec.Price
+	if !price.IsPositive() {
+		b.finish(o, model.OrderRejected, "no valid price on bar")
+		return
 	}
+	qty := o.Remaining()
+	if exec.Taker && !exec.Impact {
+		price = b.slipped(bar, o.Side, qty, price)
+	}
+	maker := !exec.Taker
 
-	wins := 0
-	totalProfit := decimal.Zero
-	// Very simple win rate based on PnL of sell trades
-	for _, t := range b.trades {
-		if t.Side == "sell" {
-			if t.PnL.GreaterThan(decimal.Zero) {
-				wins++
-			}
-			totalProfit = totalProfit.Add(t.PnL)
+	pos := b.position(o.Symbol)
+	dir := sideSign(o.Side)
+
+	closeQty := decimal.Zero
+	if pos.Qty.Sign() == -dir {
+		closeQty = decimal.Min(qty, pos.Qty.Abs())
+	}
+	openQty := qty.Sub(closeQty)
+	if openQty.IsPositive() {
+		if dir < 0 && !b.margin.AllowShort {
+			openQty = decimal.Zero
+			o.Reason = "reduced to position size"
+		} else if maxOpen := b.maxOpenQty(price, closeQty, b.feeRate(bar.Exchange, o.Symbol, o.Side, price, maker)); openQty.GreaterThan(maxOpen) {
+			openQty = maxOpen
+			o.Reason = "reduced to available " + b.collateralName()
 		}
 	}
+	filled := closeQty.Add(openQty)
+	if !filled.IsPositive() {
+		b.finish(o, model.OrderRejected, "insufficient "+b.collateralName()+" or position")
+		return
+	}
+
+	if closeQty.IsPositive() {
+		fee := b.fee(bar.Exchange, o.Symbol, o.Side, closeQty, price, maker)
+		trade := b.reduce(pos, o.Side, closeQty, price, fee)
+		trade.OrderID = o.ID
+		b.trades = append(b.trades, trade)
+	}
+	if openQty.IsPositive() {
+		fee := b.fee(bar.Exchange, o.Symbol, o.Side, openQty, price, maker)
+		trade := b.increase(pos, o.Side, openQty, price, fee)
+		trade.OrderID = o.ID
+		b.trades = append(b.trades, trade)
+	}
+
+	o.FilledQty = o.FilledQty.Add(filled)
+	o.AvgFillPrice = price
+	b.finish(o, model.OrderFilled, o.Reason)
+}
+
+// increase opens or adds to a position; only the fee leaves the wallet
+func (b *Backtester) increase(pos *Position, side model.OrderSide, qty, price, fee decimal.Decimal) model.SimulatedTrade {
+	b.balance = b.balance.Sub(fee)
+
+	size := pos.Qty.Abs()
+	if size.IsZero() {
+		pos.OpenedAt = b.now
+	}
+	pos.EntryPrice = size.Mul(pos.EntryPrice).Add(qty.Mul(price)).Div(size.Add(qty))
+	pos.EntryFees = pos.EntryFees.Add(fee)
+	pos.Qty = pos.Qty.Add(qty.Mul(decimal.NewFromInt(int64(sideSign(side)))))
+
+	return model.SimulatedTrade{
+		Time:   b.now,
+		Symbol: pos.Symbol,
+		Side:   string(side),
+		Price:  price,
+		Size:   qty,
+		Fee:    fee,
+	}
+}
 
-	sellCount := 0
-	for _, t := range b.trades {
-		if t.Side == "sell" {
-			sellCount++
+// reduce closes qty of a position and realizes PnL against the entry price. The
+// trade PnL also carries the exit fee and the pro-rata share of the entry fees.
+func (b *Backtester) reduce(pos *Position, side model.OrderSide, qty, price, fee decimal.Decimal) model.SimulatedTrade {
+	gross := price.Sub(pos.EntryPrice).Mul(qty)
+	if pos.Qty.IsNegative() {
+		gross = gross.Neg()
+	}
+	entryFees := pos.EntryFees.Mul(qty).Div(pos.Qty.Abs())
+	pnl := gross.Sub(fee).Sub(entryFees)
+
+	b.balance = b.balance.Add(gross).Sub(fee)
+	pos.EntryFees = pos.EntryFees.Sub(entryFees)
+	pos.Qty = pos.Qty.Add(qty.Mul(decimal.NewFromInt(int64(sideSign(side)))))
+	pos.RealizedPnL = pos.RealizedPnL.Add(pnl)
+	b.holdings = append(b.holdings, b.now.Sub(pos.OpenedAt))
+	if pos.Qty.IsZero() {
+		pos.EntryPrice = decimal.Zero
+		pos.EntryFees = decimal.Zero
+	}
+
+	return model.SimulatedTrade{
+		Time:    b.now,
+		Symbol:  pos.Symbol,
+		Side:    string(side),
+		Price:   price,
+		Size:    qty,
+		Fee:     fee,
+		PnL:     pnl,
+		Closing: true,
+	}
+}
+
+// closeOut cancels resting orders and closes positions at their last close
+func (b *Backtester) closeOut() {
+	for _, o := range b.open {
+		b.finish(o, model.OrderCancelled, "backtest ended")
+	}
+	b.open = nil
+
+	symbols := make([]string, 0, len(b.positions))
+	for symbol := range b.positions {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	for _, symbol := range symbols {
+		pos, last := b.positions[symbol], b.lastBars[symbol]
+		if pos.Qty.IsZero() || !last.Close.IsPositive() {
+			continue
 		}
+		side := model.SideSell
+		if pos.Qty.IsNegative() {
+			side = model.SideBuy
+		}
+		size := pos.Qty.Abs()
+		price := b.slipped(last, side, size, last.Close)
+		fee := b.fee(last.Exchange, symbol, side, size, price, false)
+		b.trades = append(b.trades, b.reduce(pos, side, size, price, fee))
 	}
+}
 
-	if sellCount == 0 {
-		return 0, decimal.Zero
+func (b *Backtester) position(symbol string) *Position {
+	p, ok := b.positions[symbol]
+	if !ok {
+		p = &Position{Symbol: symbol}
+		b.positions[symbol] = p
 	}
+	return p
+}
 
-	return float64(wins) / float64(sellCount), totalProfit
+func (b *Backtester) finish(o *model.Order, status model.OrderStatus, reason string) {
+	o.Status = status
+	o.Reason = reason
+	o.UpdatedAt = b.now
 }
 
-func (b *Backtester) calculateSharpeRatio() float64 {
-	if len(b.returns) < 2 {
-		return 0
+func (b *Backtester) pruneOpen() {
+	open := b.open[:0]
+	for _, o := range b.open {
+		if o.Status == model.OrderOpen {
+			open = append(open, o)
+		}
 	}
+	b.open = open
+}
 
-	var sum float64
-	for _, r := range b.returns {
-		sum += r
+// actionStrategy adapts action-based strategies to OrderStrategy. strats is keyed
+// by symbol; the "" key is used for any symbol without its own strategy.
+type actionStrategy struct {
+	strats map[string]strategy.Strategy
+}
+
+func (s *actionStrategy) Name() string {
+	names := make([]string, 0, len(s.strats))
+	for _, strat := range s.strats {
+		if !slices.Contains(names, strat.Name()) {
+			names = append(names, strat.Name())
+		}
 	}
-	avgReturn := sum / float64(len(b.returns))
+	sort.Strings(names)
+	return strings.Join(names, "+")
+}
 
-	var sumSqDiff float64
-	for _, r := range b.returns {
-		diff := r - avgReturn
-		sumSqDiff += diff * diff
+// OnBar turns buy into "be long with all buying power" and sell into "be flat",
+// or "be short" when the margin config allows it. A reversal closes first and
+// opens on the next signal.
+func (s *actionStrategy) OnBar(bar model.KLine, broker Broker) {
+	strat, ok := s.strats[bar.Symbol]
+	if !ok {
+		if strat, ok = s.strats[""]; !ok {
+			return
+		}
+	}
+	// Skip while an earlier signal is still waiting for its fill
+	if len(broker.OpenOrders(bar.Symbol)) > 0 {
+		strat.OnCandle(bar)
+		return
 	}
-	stdDev := math.Sqrt(sumSqDiff / float64(len(b.returns)))
 
-	if stdDev == 0 {
-		return 0
+	pos := broker.Position(bar.Symbol)
+	submit := func(side model.OrderSide, qty decimal.Decimal) {
+		broker.Submit(model.OrderRequest{Symbol: bar.Symbol, Side: side, Type: model.OrderMarket, Qty: qty})
+	}
+	// The opening fill is capped to the margin available at the next open; with
+	// several symbols each one gets at most an equal share of equity
+	open := func(side model.OrderSide) {
+		power := broker.BuyingPower()
+		if n := len(s.strats); n > 1 {
+			share := broker.Equity().Mul(broker.Margin().Leverage).Div(decimal.NewFromInt(int64(n)))
+			power = decimal.Min(power, share)
+		}
+		if power.IsPositive() && bar.Close.IsPositive() {
+			submit(side, power.Div(bar.Close))
+		}
 	}
 
-	// Annualize if needed, but for 1m klines we just return the ratio
-	return avgReturn / stdDev
+	switch strat.OnCandle(bar) {
+	case strategy.ActionBuy:
+		switch {
+		case pos.Qty.IsNegative():
+			submit(model.SideBuy, pos.Qty.Abs())
+		case pos.Qty.IsZero():
+			open(model.SideBuy)
+		}
+	case strategy.ActionSell:
+		switch {
+		case pos.Qty.IsPositive():
+			submit(model.SideSell, pos.Qty)
+		case pos.Qty.IsZero() && broker.Margin().AllowShort:
+			open(model.SideSell)
+		}
+	}
 }
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBacktester(t *testing.T) {
@@ -22,9 +24,13 @@ func TestBacktester(t *testing.T) {
 	now := time.Now()
 
 	for i, p := range prices {
+		price := decimal.NewFromFloat(p)
 		candles[i] = model.KLine{
 			Symbol:    "BTCUSDT",
-			Close:     decimal.NewFromFloat(p),
+			Open:      price,
+			High:      price,
+			Low:       price,
+			Close:     price,
 			Timestamp: now.Add(time.Duration(i) * time.Minute),
 		}
 	}
@@ -46,3 +52,118 @@ func TestBacktester(t *testing.T) {
 		t.Logf("Trade: %s %s @ %s, Size: %s", trade.Side, trade.Symbol, trade.Price, trade.Size)
 	}
 }
+
+// scriptedStrategy submits the orders queued for each bar index
+type scriptedStrategy struct {
+	bar    int
+	script map[int][]model.OrderRequest
+	ids    []int64
+}
+
+func (s *scriptedStrategy) Name() string { return "scripted" }
+
+func (s *scriptedStrategy) OnBar(bar model.KLine, broker Broker) {
+	for _, req := range s.script[s.bar] {
+		req.Symbol = bar.Symbol
+		id, err := broker.Submit(req)
+		if err != nil {
+			panic(err)
+		}
+		s.ids = append(s.ids, id)
+	}
+	s.bar++
+}
+
+func bar(i int, open, high, low, close float64) model.KLine {
+	return model.KLine{
+		Symbol:    "BTCUSDT",
+		Period:    "1m",
+		Open:      decimal.NewFromFloat(open),
+		High:      decimal.NewFromFloat(high),
+		Low:       decimal.NewFromFloat(low),
+		Close:     decimal.NewFromFloat(close),
+		Timestamp: time.Date(2024, 1, 1, 0, i, 0, 0, time.UTC),
+	}
+}
+
+func newFrictionless(strat OrderStrategy) *Backtester {
+	return withoutCosts(NewOrderBacktester(strat, decimal.NewFromInt(10000)))
+}
+
+func withoutCosts(b *Backtester) *Backtester {
+	b.SetFeeModel(FlatFee{})
+	b.SetSlippageModel(FixedSlippage{})
+	return b
+}
+
+func TestBacktester_ScalingCostBasis(t *testing.T) {
+	qty := func(f float64) decimal.Decimal { return decimal.NewFromFloat(f) }
+	strat := &scriptedStrategy{script: map[int][]model.OrderRequest{
+		0: {{Side: model.SideBuy, Type: model.OrderMarket, Qty: qty(10)}},
+		1: {{Side: model.SideBuy, Type: model.OrderMarket, Qty: qty(10)}},
+		2: {{Side: model.SideSell, Type: model.OrderMarket, Qty: qty(5)}},
+		3: {{Side: model.SideSell, Type: model.OrderMarket, Qty: qty(15)}},
+	}}
+	report := newFrictionless(strat).Run([]model.KLine{
+		bar(0, 100, 100, 100, 100),
+		bar(1, 100, 100, 100, 100), // buy 10 @ 100
+		bar(2, 120, 120, 120, 120), // buy 10 @ 120, avg 110
+		bar(3, 130, 130, 130, 130), // sell 5 @ 130: +100
+		bar(4, 100, 100, 100, 100), // sell 15 @ 100: -150
+	})
+
+	require.Len(t, report.TradesLog, 4)
+	assert.True(t, report.TradesLog[2].PnL.Equal(decimal.NewFromInt(100)), report.TradesLog[2].PnL.String())
+	assert.True(t, report.TradesLog[3].PnL.Equal(decimal.NewFromInt(-150)), report.TradesLog[3].PnL.String())
+	assert.True(t, report.TotalProfit.Equal(decimal.NewFromInt(-50)))
+	assert.True(t, report.FinalBalance.Equal(decimal.NewFromInt(9950)))
+	assert.Equal(t, 0.5, report.WinRate)
+}
+
+func TestBacktester_OrderTypes(t *testing.T) {
+	px := decimal.NewFromFloat
+	expire := time.Date(2024, 1, 1, 0, 2, 0, 0, time.UTC)
+	strat := &scriptedStrategy{script: map[int][]model.OrderRequest{
+		0: {
+			{Side: model.SideBuy, Type: model.OrderLimit, Qty: px(1), LimitPrice: px(95)},                          // fills bar 2 at 95
+			{Side: model.SideBuy, Type: model.OrderLimit, Qty: px(1), LimitPrice: px(90), TIF: model.TIFImmediate}, // cancelled after bar 1
+			{Side: model.SideBuy, Type: model.OrderStop, Qty: px(1), StopPrice: px(104)},                           // fills bar 1 at 104
+			{Side: model.SideBuy, Type: model.OrderLimit, Qty: px(1), LimitPrice: px(80), TIF: model.TIFGoodTillDate, ExpireAt: expire},
+			{Side: model.SideBuy, Type: model.OrderStopLimit, Qty: px(1), StopPrice: px(108), LimitPrice: px(107)}, // triggers bar 3, fills bar 4 at 107
+		},
+	}}
+	report := newFrictionless(strat).Run([]model.KLine{
+		bar(0, 100, 100, 100, 100),
+		bar(1, 101, 105, 99, 102),
+		bar(2, 98, 99, 94, 96),
+		bar(3, 103, 110, 101, 109),
+		bar(4, 109, 109, 106, 107),
+	})
+
+	require.Len(t, report.Orders, 5)
+	status := func(i int) model.OrderStatus { return report.Orders[i].Status }
+
+	assert.Equal(t, model.OrderFilled, status(0))
+	assert.True(t, report.Orders[0].AvgFillPrice.Equal(px(95)))
+	assert.Equal(t, model.OrderCancelled, status(1))
+	assert.Equal(t, model.OrderFilled, status(2))
+	assert.True(t, report.Orders[2].AvgFillPrice.Equal(px(104)))
+	assert.Equal(t, model.OrderExpired, status(3))
+	assert.Equal(t, model.OrderFilled, status(4))
+	assert.True(t, report.Orders[4].Triggered)
+	assert.True(t, report.Orders[4].AvgFillPrice.Equal(px(107)))
+}
+
+func TestBacktester_BuyCappedByCash(t *testing.T) {
+	strat := &scriptedStrategy{script: map[int][]model.OrderRequest{
+		0: {{Side: model.SideBuy, Type: model.OrderMarket, Qty: decimal.NewFromInt(1000)}},
+	}}
+	report := newFrictionless(strat).Run([]model.KLine{
+		bar(0, 100, 100, 100, 100),
+		bar(1, 100, 100, 100, 100),
+	})
+
+	require.Len(t, report.Orders, 1)
+	assert.True(t, report.Orders[0].FilledQty.Equal(decimal.NewFromInt(100)))
+	assert.Equal(t, "reduced to available cash", report.Orders[0].Reason)
+}
@@ -0,0 +1,348 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"quant-trader/internal/indicators"
+	"quant-trader/internal/model"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// feeVolumeWindow is the trailing window volume tiers are computed over
+const feeVolumeWindow = 30 * 24 * time.Hour
+
+// FillInfo describes one execution to the fee and slippage models
+type FillInfo struct {
+	Time     time.Time
+	Symbol   string
+	Exchange string
+	Side     model.OrderSide
+	Qty      decimal.Decimal
+	Price    decimal.Decimal
+	Maker    bool
+	// Volume is the quote volume traded in the trailing 30 days before this fill
+	Volume decimal.Decimal
+}
+
+// FeeModel prices the commission of a fill in quote currency
+type FeeModel interface {
+	Fee(f FillInfo) decimal.Decimal
+}
+
+// SlippageModel returns the adverse price move of a taker fill as a fraction of
+// its price. bar is the bar the fill happens on.
+type SlippageModel interface {
+	Slippage(f FillInfo, bar model.KLine) decimal.Decimal
+}
+
+// BarObserver is implemented by models that need history, e.g. ATRSlippage.
+// Observe is called once a bar has been matched, so a bar never prices its own fills.
+type BarObserver interface {
+	Observe(bar model.KLine)
+}
+
+// FlatFee charges the same rate on every fill
+type FlatFee struct {
+	Rate decimal.Decimal
+}
+
+func (m FlatFee) Fee(f FillInfo) decimal.Decimal {
+	return f.Qty.Mul(f.Price).Mul(m.Rate)
+}
+
+// MakerTakerFee charges resting (limit) fills Maker and liquidity-taking fills Taker
+type MakerTakerFee struct {
+	Maker decimal.Decimal
+	Taker decimal.Decimal
+}
+
+func (m MakerTakerFee) Fee(f FillInfo) decimal.Decimal {
+	rate := m.Taker
+	if f.Maker {
+		rate = m.Maker
+	}
+	return f.Qty.Mul(f.Price).Mul(rate)
+}
+
+// FeeTier applies from MinVolume of trailing 30 day quote volume
+type FeeTier struct {
+	MinVolume decimal.Decimal
+	Maker     decimal.Decimal
+	Taker     decimal.Decimal
+}
+
+// TieredFee picks the maker/taker rates of the highest tier reached by the
+// account's trailing volume. Tiers must be sorted by MinVolume.
+type TieredFee struct {
+	Tiers []FeeTier
+}
+
+func (m TieredFee) Fee(f FillInfo) decimal.Decimal {
+	if len(m.Tiers) == 0 {
+		return decimal.Zero
+	}
+	tier := m.Tiers[0]
+	for _, t := range m.Tiers[1:] {
+		if f.Volume.LessThan(t.MinVolume) {
+			break
+		}
+		tier = t
+	}
+	return MakerTakerFee{Maker: tier.Maker, Taker: tier.Taker}.Fee(f)
+}
+
+// ExchangeFee routes each fill to the schedule of the exchange its bar came from
+type ExchangeFee struct {
+	Schedules map[string]FeeModel
+	Default   FeeModel
+}
+
+func (m ExchangeFee) Fee(f FillInfo) decimal.Decimal {
+	if s, ok := m.Schedules[strings.ToLower(f.Exchange)]; ok {
+		return s.Fee(f)
+	}
+	return m.Default.Fee(f)
+}
+
+func tiers(rows ...[3]float64) TieredFee {
+	t := TieredFee{Tiers: make([]FeeTier, len(rows))}
+	for i, r := range rows {
+		t.Tiers[i] = FeeTier{
+			MinVolume: decimal.NewFromFloat(r[0]),
+			Maker:     decimal.NewFromFloat(r[1]),
+			Taker:     decimal.NewFromFloat(r[2]),
+		}
+	}
+	return t
+}
+
+// exchangeSchedules are the published spot schedules of the supported connectors
+// (30 day USD volume, maker, taker). Token discounts and rebates are not modelled.
+var exchangeSchedules = map[string]TieredFee{
+	"binance":  tiers([3]float64{0, 0.001, 0.001}, [3]float64{1e6, 0.0009, 0.001}, [3]float64{5e6, 0.0008, 0.001}, [3]float64{2e7, 0.00042, 0.0006}, [3]float64{1e8, 0.00042, 0.00054}),
+	"okx":      tiers([3]float64{0, 0.0008, 0.001}, [3]float64{5e6, 0.00045, 0.0005}, [3]float64{1e7, 0.0004, 0.00045}),
+	"bybit":    tiers([3]float64{0, 0.001, 0.001}, [3]float64{1e6, 0.000675, 0.0008}, [3]float64{5e6, 0.00065, 0.000775}),
+	"coinbase": tiers([3]float64{0, 0.004, 0.006}, [3]float64{1e4, 0.0025, 0.004}, [3]float64{5e4, 0.0015, 0.0025}, [3]float64{1e5, 0.001, 0.002}, [3]float64{1e6, 0.0008, 0.0018}),
+	"kraken":   tiers([3]float64{0, 0.0025, 0.004}, [3]float64{1e4, 0.002, 0.0035}, [3]float64{5e4, 0.0014, 0.0024}, [3]float64{1e5, 0.0012, 0.0022}, [3]float64{2.5e5, 0.001, 0.002}),
+}
+
+// ExchangeSchedule returns the built-in fee schedule of an exchange
+func ExchangeSchedule(exchange string) (TieredFee, bool) {
+	s, ok := exchangeSchedules[strings.ToLower(exchange)]
+	return s, ok
+}
+
+// FixedSlippage moves every taker fill by a constant number of basis points
+type FixedSlippage struct {
+	Bps decimal.Decimal
+}
+
+func (m FixedSlippage) Slippage(FillInfo, model.KLine) decimal.Decimal {
+	return m.Bps.Div(decimal.NewFromInt(10000))
+}
+
+// ATRSlippage scales slippage with volatility: Multiplier × ATR(Period) / price,
+// never less than MinBps. The ATR is the average true range of the last Period
+// bars of the symbol, so only MinBps applies until Period+1 bars have been seen.
+type ATRSlippage struct {
+	Period     int
+	Multiplier decimal.Decimal
+	MinBps     decimal.Decimal
+
+	history map[string][]model.KLine
+}
+
+// NewATRSlippage creates an ATRSlippage ready to observe bars
+func NewATRSlippage(period int, multiplier, minBps decimal.Decimal) *ATRSlippage {
+	return &ATRSlippage{Period: period, Multiplier: multiplier, MinBps: minBps, history: make(map[string][]model.KLine)}
+}
+
+func (m *ATRSlippage) Observe(bar model.KLine) {
+	h := append(m.history[bar.Symbol], bar)
+	if len(h) > m.Period+1 {
+		h = h[len(h)-m.Period-1:]
+	}
+	m.history[bar.Symbol] = h
+}
+
+func (m *ATRSlippage) Slippage(f FillInfo, _ model.KLine) decimal.Decimal {
+	floor := m.MinBps.Div(decimal.NewFromInt(10000))
+	h := m.history[f.Symbol]
+	if len(h) < m.Period+1 || !f.Price.IsPositive() {
+		return floor
+	}
+	atr := indicators.CalculateATR(h, m.Period)
+	slip := m.Multiplier.Mul(decimal.NewFromFloat(atr[m.Period])).Div(f.Price)
+	return decimal.Max(slip, floor)
+}
+
+// SqrtImpact is the square-root market impact model: Coefficient × √(qty / bar
+// volume). Participation is capped at the whole bar, which also applies when the
+// bar has no volume.
+type SqrtImpact struct {
+	Coefficient decimal.Decimal
+}
+
+func (m SqrtImpact) Slippage(f FillInfo, bar model.KLine) decimal.Decimal {
+	participation := 1.0
+	if bar.Volume.IsPositive() {
+		participation, _ = f.Qty.Div(bar.Volume).Float64()
+		participation = math.Min(participation, 1)
+	}
+	return m.Coefficient.Mul(decimal.NewFromFloat(math.Sqrt(participation)))
+}
+
+// NewFeeModel builds a fee model from an API config, like strategy.NewStrategy
+func NewFeeModel(kind string, config map[string]interface{}) (FeeModel, error) {
+	switch kind {
+	case "", "flat":
+		return FlatFee{Rate: decimalParam(config, "rate", 0.001)}, nil
+	case "maker_taker":
+		return MakerTakerFee{Maker: decimalParam(config, "maker", 0.001), Taker: decimalParam(config, "taker", 0.001)}, nil
+	case "tiered":
+		raw, ok := config["tiers"].([]interface{})
+		if !ok || len(raw) == 0 {
+			return nil, fmt.Errorf("invalid config for tiered fees: need tiers")
+		}
+		var m TieredFee
+		for _, r := range raw {
+			t, ok := r.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid config for tiered fees: tiers must be objects")
+			}
+			m.Tiers = append(m.Tiers, FeeTier{
+				MinVolume: decimalParam(t, "min_volume", 0),
+				Maker:     decimalParam(t, "maker", 0),
+				Taker:     decimalParam(t, "taker", 0),
+			})
+		}
+		sort.SliceStable(m.Tiers, func(i, j int) bool { return m.Tiers[i].MinVolume.LessThan(m.Tiers[j].MinVolume) })
+		return m, nil
+	case "exchange":
+		// Without an explicit exchange every fill uses the schedule of its bar's exchange
+		if name, _ := config["exchange"].(string); name != "" {
+			s, ok := ExchangeSchedule(name)
+			if !ok {
+				return nil, fmt.Errorf("no fee schedule for exchange: %s", name)
+			}
+			return s, nil
+		}
+		schedules := make(map[string]FeeModel, len(exchangeSchedules))
+		for name, s := range exchangeSchedules {
+			schedules[name] = s
+		}
+		return ExchangeFee{Schedules: schedules, Default: FlatFee{Rate: decimal.NewFromFloat(0.001)}}, nil
+	default:
+		return nil, fmt.Errorf("unknown fee model: %s", kind)
+	}
+}
+
+// NewSlippageModel builds a slippage model from an API config
+func NewSlippageModel(kind string, config map[string]interface{}) (SlippageModel, error) {
+	switch kind {
+	case "", "fixed":
+		return FixedSlippage{Bps: decimalParam(config, "bps", 5)}, nil
+	case "atr":
+		period := int(decimalParam(config, "period", 14).IntPart())
+		if period < 1 {
+			return nil, fmt.Errorf("invalid config for atr slippage: period must be positive")
+		}
+		return NewATRSlippage(period, decimalParam(config, "multiplier", 0.1), decimalParam(config, "min_bps", 0)), nil
+	case "sqrt_impact":
+		return SqrtImpact{Coefficient: decimalParam(config, "coefficient", 0.1)}, nil
+	default:
+		return nil, fmt.Errorf("unknown slippage model: %s", kind)
+	}
+}
+
+// decimalParam reads a JSON number from config, def when it is missing
+func decimalParam(config map[string]interface{}, key string, def float64) decimal.Decimal {
+	if v, ok := config[key].(float64); ok {
+		return decimal.NewFromFloat(v)
+	}
+	return decimal.NewFromFloat(def)
+}
+
+// SetFeeModel replaces the default flat 0.1% fee
+func (b *Backtester) SetFeeModel(m FeeModel) {
+	b.fees = m
+}
+
+// SetSlippageModel replaces the default fixed 5 bps slippage
+func (b *Backtester) SetSlippageModel(m SlippageModel) {
+	b.slip = m
+}
+
+// SetFillModel replaces the default next-open fills
+func (b *Backtester) SetFillModel(m FillModel) {
+	b.fills = m
+}
+
+// fee prices a fill and adds it to the trailing volume used by tiered schedules
+func (b *Backtester) fee(exchange, symbol string, side model.OrderSide, qty, price decimal.Decimal, maker bool) decimal.Decimal {
+	fee := b.fees.Fee(b.fillInfo(exchange, symbol, side, qty, price, maker))
+	notional := qty.Mul(price)
+	b.volumeLog = append(b.volumeLog, volumeEntry{at: b.now, notional: notional})
+	b.volume = b.volume.Add(notional)
+	return fee
+}
+
+// feeRate is the fee per unit of notional a new fill at price would pay
+func (b *Backtester) feeRate(exchange, symbol string, side model.OrderSide, price decimal.Decimal, maker bool) decimal.Decimal {
+	if !price.IsPositive() {
+		return decimal.Zero
+	}
+	one := decimal.NewFromInt(1)
+	return b.fees.Fee(b.fillInfo(exchange, symbol, side, one, price, maker)).Div(price)
+}
+
+// slipped moves a taker fill price against the order
+func (b *Backtester) slipped(bar model.KLine, side model.OrderSide, qty, price decimal.Decimal) decimal.Decimal {
+	slip := b.slip.Slippage(b.fillInfo(bar.Exchange, bar.Symbol, side, qty, price, false), bar)
+	if side == model.SideBuy {
+		return price.Mul(decimal.NewFromInt(1).Add(slip))
+	}
+	return price.Mul(decimal.NewFromInt(1).Sub(slip))
+}
+
+func (b *Backtester) fillInfo(exchange, symbol string, side model.OrderSide, qty, price decimal.Decimal, maker bool) FillInfo {
+	return FillInfo{
+		Time:     b.now,
+		Symbol:   symbol,
+		Exchange: exchange,
+		Side:     side,
+		Qty:      qty,
+		Price:    price,
+		Maker:    maker,
+		Volume:   b.trailingVolume(),
+	}
+}
+
+type volumeEntry struct {
+	at       time.Time
+	notional decimal.Decimal
+}
+
+func (b *Backtester) trailingVolume() decimal.Decimal {
+	cutoff := b.now.Add(-feeVolumeWindow)
+	drop := 0
+	for drop < len(b.volumeLog) && b.volumeLog[drop].at.Before(cutoff) {
+		b.volume = b.volume.Sub(b.volumeLog[drop].notional)
+		drop++
+	}
+	b.volumeLog = b.volumeLog[drop:]
+	return b.volume
+}
+
+// observe feeds a matched bar to the models that keep history
+func (b *Backtester) observe(bar model.KLine) {
+	for _, m := range []interface{}{b.fees, b.slip, b.fills} {
+		if o, ok := m.(BarObserver); ok {
+			o.Observe(bar)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"quant-trader/internal/model"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTieredFee(t *testing.T) {
+	d := decimal.NewFromFloat
+	m, err := NewFeeModel("tiered", map[string]interface{}{
+		"tiers": []interface{}{
+			map[string]interface{}{"min_volume": 1000.0, "maker": 0.0005, "taker": 0.001},
+			map[string]interface{}{"min_volume": 0.0, "maker": 0.001, "taker": 0.002},
+		},
+	})
+	require.NoError(t, err)
+
+	fill := FillInfo{Qty: d(1), Price: d(100)}
+	assert.True(t, m.Fee(fill).Equal(d(0.2)))
+	fill.Maker = true
+	assert.True(t, m.Fee(fill).Equal(d(0.1)))
+	fill.Volume = d(1000)
+	assert.True(t, m.Fee(fill).Equal(d(0.05)))
+}
+
+func TestExchangeFee(t *testing.T) {
+	d := decimal.NewFromFloat
+	m, err := NewFeeModel("exchange", nil)
+	require.NoError(t, err)
+
+	fill := FillInfo{Qty: d(1), Price: d(1000), Exchange: "coinbase"}
+	assert.True(t, m.Fee(fill).Equal(d(6)), "coinbase taker 0.6%")
+	fill.Exchange = "unknown"
+	assert.True(t, m.Fee(fill).Equal(d(1)), "default 0.1%")
+
+	_, err = NewFeeModel("exchange", map[string]interface{}{"exchange": "nowhere"})
+	assert.Error(t, err)
+}
+
+func TestBacktester_FeeTierFromTrailingVolume(t *testing.T) {
+	d := decimal.NewFromFloat
+	strat := &scriptedStrategy{script: map[int][]model.OrderRequest{
+		0: {{Side: model.SideBuy, Type: model.OrderMarket, Qty: d(10)}},
+		1: {{Side: model.SideSell, Type: model.OrderMarket, Qty: d(10)}},
+	}}
+	tester := newFrictionless(strat)
+	tester.SetFeeModel(TieredFee{Tiers: []FeeTier{
+		{Maker: d(0.01), Taker: d(0.01)},
+		{MinVolume: d(1000), Maker: d(0.001), Taker: d(0.001)},
+	}})
+	report := tester.Run([]model.KLine{
+		bar(0, 100, 100, 100, 100),
+		bar(1, 100, 100, 100, 100),
+		bar(2, 100, 100, 100, 100),
+	})
+
+	require.Len(t, report.TradesLog, 2)
+	assert.True(t, report.TradesLog[0].Fee.Equal(d(10)), report.TradesLog[0].Fee.String())
+	assert.True(t, report.TradesLog[1].Fee.Equal(d(1)), "second fill reaches the 1000 volume tier")
+}
+
+func TestATRSlippage(t *testing.T) {
+	d := decimal.NewFromFloat
+	m := NewATRSlippage(2, d(0.5), d(1))
+	fill := FillInfo{Symbol: "BTCUSDT", Qty: d(1), Price: d(100)}
+
+	assert.True(t, m.Slippage(fill, model.KLine{}).Equal(d(0.0001)), "min bps until warmed up")
+	m.Observe(bar(0, 100, 100, 100, 100))
+	m.Observe(bar(1, 100, 104, 96, 100)) // TR 8
+	m.Observe(bar(2, 100, 102, 98, 100)) // TR 4
+	assert.True(t, m.Slippage(fill, model.KLine{}).Equal(d(0.03)), "0.5 * ATR 6 / 100")
+}
+
+func TestSqrtImpact(t *testing.T) {
+	d := decimal.NewFromFloat
+	m := SqrtImpact{Coefficient: d(0.1)}
+	fill := FillInfo{Qty: d(4), Price: d(100)}
+
+	assert.InDelta(t, 0.02, m.Slippage(fill, model.KLine{Volume: d(100)}).InexactFloat64(), 1e-12)
+	assert.InDelta(t, 0.1, m.Slippage(fill, model.KLine{Volume: d(2)}).InexactFloat64(), 1e-12)
+	assert.InDelta(t, 0.1, m.Slippage(fill, model.KLine{}).InexactFloat64(), 1e-12)
+}
+
+func TestNewSlippageModel(t *testing.T) {
+	m, err := NewSlippageModel("", nil)
+	require.NoError(t, err)
+	assert.True(t, m.Slippage(FillInfo{}, model.KLine{}).Equal(decimal.NewFromFloat(0.0005)))
+
+	_, err = NewSlippageModel("atr", map[string]interface{}{"period": 0.0})
+	assert.Error(t, err)
+	_, err = NewSlippageModel("magic", nil)
+	assert.Error(t, err)
+}
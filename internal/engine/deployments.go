@@ -0,0 +1,393 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
+	"quant-trader/internal/strategy"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidDeployment wraps the reasons a deployment is rejected
+var ErrInvalidDeployment = errors.New("invalid deployment")
+
+// MaxDeploymentsPerUser bounds the deployments a user has running or paused
+const MaxDeploymentsPerUser = 10
+
+// DeploymentLease is the lease of the instance that runs every deployment
+const DeploymentLease = "deployments"
+
+// DeploymentSyncInterval is how often the manager renews its lease and catches
+// up with deployments changed through other instances
+const DeploymentSyncInterval = 10 * time.Second
+
+// signalTimeout bounds the storage and paper calls handling one signal
+const signalTimeout = 5 * time.Second
+
+// PaperPlacer places paper orders, e.g. the paper.PaperEngine
+type PaperPlacer interface {
+	PlaceOrder(ctx context.Context, o model.PaperOrder) (int64, error)
+}
+
+// DeploymentManager runs users' deployments on a StrategyRunner and keeps
+// them in storage, so Restore brings them back after a restart. Signals go to
+// the user's notification.user.<id> subject and, for paper deployments,
+// through a PaperBridge to their paper account.
+//
+// Only the instance holding the DeploymentLease runs deployments, so that no
+// signal is traded twice. The others only store what their API calls change,
+// which the holder picks up on its next sync, and take over the lease once
+// its holder is gone.
+type DeploymentManager struct {
+	repo       storage.DeploymentRepository
+	leases     storage.LeaseRepository
+	paper      storage.PaperRepository
+	executions storage.ExecutionRepository
+	library    *StrategyLibrary
+	runner     *StrategyRunner
+	bridge     *PaperBridge
+	js         nats.JetStreamContext
+	logger     *zap.Logger
+
+	mu        sync.Mutex                // serializes deploy, start, pause, stop and sync
+	leader    bool                      // holds the DeploymentLease
+	positions map[int64]decimal.Decimal // deployments running here and the quantity each last recorded
+	failed    map[int64]bool            // deployments that did not build, left until changed
+}
+
+func NewDeploymentManager(store *storage.Store, runner *StrategyRunner, orders PaperPlacer, js nats.JetStreamContext, logger *zap.Logger) *DeploymentManager {
+	return &DeploymentManager{
+		repo:       store.Deployments,
+		leases:     store.Leases,
+		paper:      store.Paper,
+		executions: store.Executions,
+		library:    NewStrategyLibrary(store.Strategies),
+		runner:     runner,
+		bridge:     NewPaperBridge(store, orders, logger),
+		js:         js,
+		logger:     logger,
+		positions:  make(map[int64]decimal.Decimal),
+		failed:     make(map[int64]bool),
+	}
+}
+
+// Restore runs every deployment that is not stopped, as it was left, if this
+// instance gets the DeploymentLease. One that no longer builds is logged and
+// skipped.
+func (m *DeploymentManager) Restore(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sync(ctx)
+}
+
+// Run syncs the deployments with storage every DeploymentSyncInterval until
+// ctx is done
+func (m *DeploymentManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(DeploymentSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.mu.Lock()
+			err := m.sync(ctx)
+			m.mu.Unlock()
+			if err != nil {
+				m.logger.Error("failed to sync deployments", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Close stops the deployments running here and hands the lease on; call it
+// after the last checkpoint so the next holder resumes from it
+func (m *DeploymentManager) Close(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeAll()
+	m.leader = false
+	return m.leases.ReleaseLease(ctx, DeploymentLease)
+}
+
+// sync runs what is active in storage and not here yet, pauses and resumes
+// as stored and removes what was stopped; the caller holds m.mu
+func (m *DeploymentManager) sync(ctx context.Context) error {
+	if !m.lead(ctx) {
+		return nil
+	}
+	list, err := m.repo.ListActiveDeployments(ctx)
+	if err != nil {
+		return err
+	}
+	active := make(map[int64]bool, len(list))
+	started := 0
+	for _, d := range list {
+		active[d.ID] = true
+		if _, ok := m.positions[d.ID]; ok {
+			m.runner.SetPaused(d.ID, d.Status == model.DeploymentPaused)
+			continue
+		}
+		if m.failed[d.ID] {
+			continue
+		}
+		if err := m.build(ctx, d, m.run); err != nil {
+			m.logger.Error("failed to restore deployment", zap.Int64("deployment_id", d.ID), zap.Error(err))
+			m.failed[d.ID] = true
+			continue
+		}
+		started++
+	}
+	for id := range m.positions {
+		if !active[id] {
+			m.runner.Remove(id)
+			delete(m.positions, id)
+		}
+	}
+	for id := range m.failed {
+		if !active[id] {
+			delete(m.failed, id)
+		}
+	}
+	if started > 0 {
+		m.logger.Info("deployments restored", zap.Int("count", started))
+	}
+	return nil
+}
+
+// lead renews the DeploymentLease, taking it if it is free. Losing it stops
+// the deployments running here for the new holder to take over. The caller
+// holds m.mu.
+func (m *DeploymentManager) lead(ctx context.Context) bool {
+	ok, err := m.leases.AcquireLease(ctx, DeploymentLease)
+	if err != nil {
+		m.logger.Error("failed to acquire deployment lease", zap.Error(err))
+	}
+	switch {
+	case ok && !m.leader:
+		m.logger.Info("running deployments on this instance")
+	case !ok && m.leader:
+		m.logger.Warn("deployment lease lost, stopping deployments on this instance")
+		m.removeAll()
+	}
+	m.leader = ok
+	return ok
+}
+
+// removeAll stops every deployment running here; the caller holds m.mu
+func (m *DeploymentManager) removeAll() {
+	for id := range m.positions {
+		m.runner.Remove(id)
+	}
+	clear(m.positions)
+	clear(m.failed)
+}
+
+// Deploy validates d and runs it for d.UserID
+func (m *DeploymentManager) Deploy(ctx context.Context, d model.Deployment) (model.Deployment, error) {
+	d.Symbol = strings.ToUpper(d.Symbol)
+	d.Status = model.DeploymentRunning
+	d.PositionQty = decimal.Zero
+	if d.Capital.IsNegative() {
+		return d, fmt.Errorf("%w: capital must not be negative", ErrInvalidDeployment)
+	}
+	if d.Paper {
+		if !d.Capital.IsPositive() {
+			return d, fmt.Errorf("%w: paper trading needs capital to allocate", ErrInvalidDeployment)
+		}
+		if err := CheckSizing(d.Sizing); err != nil {
+			return d, fmt.Errorf("%w: %w", ErrInvalidDeployment, err)
+		}
+		if _, err := m.paper.GetBalance(ctx, d.UserID); errors.Is(err, storage.ErrNotFound) {
+			return d, fmt.Errorf("%w: paper trading needs a paper account", ErrInvalidDeployment)
+		} else if err != nil {
+			return d, err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list, err := m.repo.ListDeployments(ctx, d.UserID)
+	if err != nil {
+		return d, err
+	}
+	active := 0
+	for _, other := range list {
+		if other.Status != model.DeploymentStopped {
+			active++
+		}
+	}
+	if active >= MaxDeploymentsPerUser {
+		return d, fmt.Errorf("%w: at most %d deployments may run at once", ErrInvalidDeployment, MaxDeploymentsPerUser)
+	}
+
+	err = m.build(ctx, d, func(ctx context.Context, _ model.Deployment, s strategy.Strategy) error {
+		if d, err = m.repo.CreateDeployment(ctx, d); err != nil {
+			return err
+		}
+		if !m.lead(ctx) {
+			// The lease holder runs it from its next sync
+			closeLive(s)
+			return nil
+		}
+		if err := m.run(ctx, d, s); err != nil {
+			m.repo.SetDeploymentStatus(ctx, d.UserID, d.ID, model.DeploymentStopped)
+			return err
+		}
+		return nil
+	})
+	return d, err
+}
+
+// List returns the user's deployments, newest first
+func (m *DeploymentManager) List(ctx context.Context, userID int64) ([]model.Deployment, error) {
+	return m.repo.ListDeployments(ctx, userID)
+}
+
+// Get returns a deployment of userID, storage.ErrNotFound if there is none
+func (m *DeploymentManager) Get(ctx context.Context, userID, deploymentID int64) (model.Deployment, error) {
+	return m.repo.GetDeployment(ctx, userID, deploymentID)
+}
+
+// Executions returns the latest outcomes of the signals a paper deployment of
+// userID traded, newest first; storage.ErrNotFound if there is no deployment
+func (m *DeploymentManager) Executions(ctx context.Context, userID, deploymentID int64, limit int) ([]model.SignalExecution, error) {
+	if _, err := m.repo.GetDeployment(ctx, userID, deploymentID); err != nil {
+		return nil, err
+	}
+	return m.executions.ListExecutions(ctx, userID, deploymentID, limit)
+}
+
+// Start resumes a paused deployment; storage.ErrConflict once it is stopped
+func (m *DeploymentManager) Start(ctx context.Context, userID, deploymentID int64) (model.Deployment, error) {
+	return m.setStatus(ctx, userID, deploymentID, model.DeploymentRunning)
+}
+
+// Pause keeps a deployment following the market without emitting signals
+func (m *DeploymentManager) Pause(ctx context.Context, userID, deploymentID int64) (model.Deployment, error) {
+	return m.setStatus(ctx, userID, deploymentID, model.DeploymentPaused)
+}
+
+// Stop ends a deployment for good; its paper position is left as it is
+func (m *DeploymentManager) Stop(ctx context.Context, userID, deploymentID int64) (model.Deployment, error) {
+	return m.setStatus(ctx, userID, deploymentID, model.DeploymentStopped)
+}
+
+func (m *DeploymentManager) setStatus(ctx context.Context, userID, deploymentID int64, status model.DeploymentStatus) (model.Deployment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, err := m.repo.SetDeploymentStatus(ctx, userID, deploymentID, status)
+	if err != nil || !m.lead(ctx) {
+		return d, err
+	}
+	delete(m.failed, d.ID)
+	switch {
+	case status == model.DeploymentStopped:
+		m.runner.Remove(d.ID)
+		delete(m.positions, d.ID)
+	case !m.runner.SetPaused(d.ID, status == model.DeploymentPaused):
+		// Not running here, e.g. because it failed to restore
+		return d, m.build(ctx, d, m.run)
+	}
+	return d, nil
+}
+
+// build makes the strategy of d, checks its subscription and hands both to
+// use; the strategy is released unless use succeeds
+func (m *DeploymentManager) build(ctx context.Context, d model.Deployment, use func(context.Context, model.Deployment, strategy.Strategy) error) error {
+	s, err := m.library.Strategy(ctx, d.UserID, d.StrategyVersionID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("%w: strategy version %d not found", ErrInvalidDeployment, d.StrategyVersionID)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidDeployment, err)
+	}
+	if err := LiveSubscription(s, deploymentSubscription(d)).Validate(); err != nil {
+		closeLive(s)
+		return fmt.Errorf("%w: %w", ErrInvalidDeployment, err)
+	}
+	if err := use(ctx, d, s); err != nil {
+		closeLive(s)
+		return err
+	}
+	return nil
+}
+
+// run adds d to the runner as it is stored; the caller holds m.mu
+func (m *DeploymentManager) run(ctx context.Context, d model.Deployment, s strategy.Strategy) error {
+	if err := m.runner.Deploy(ctx, d.ID, s, deploymentSubscription(d), d.Status == model.DeploymentPaused, func(sig Signal) {
+		m.handleSignal(d, sig)
+	}); err != nil {
+		return err
+	}
+	m.positions[d.ID] = d.PositionQty
+	return nil
+}
+
+func deploymentSubscription(d model.Deployment) strategy.Subscription {
+	return strategy.Subscription{Symbols: []string{d.Symbol}, Period: d.Period}
+}
+
+// handleSignal notifies the user of sig and trades it on paper
+func (m *DeploymentManager) handleSignal(d model.Deployment, sig Signal) {
+	subject := fmt.Sprintf("notification.user.%d", d.UserID)
+	msg := map[string]interface{}{
+		"type":          "strategy_signal",
+		"deployment_id": d.ID,
+		"strategy":      sig.Strategy,
+		"symbol":        sig.Symbol,
+		"period":        sig.Period,
+		"action":        sig.Action,
+		"price":         sig.Price,
+		"message":       fmt.Sprintf("%s signals %s %s at %s", sig.Strategy, sig.Action, sig.Symbol, sig.Price.String()),
+		"time":          sig.Time,
+	}
+	data, _ := json.Marshal(msg)
+	if _, err := m.js.Publish(subject, data); err != nil {
+		m.logger.Error("failed to publish strategy signal", zap.Int64("deployment_id", d.ID), zap.Error(err))
+	}
+
+	if d.Paper {
+		ctx, cancel := context.WithTimeout(context.Background(), signalTimeout)
+		defer cancel()
+		if err := m.trade(ctx, d, sig); err != nil {
+			m.logger.Error("failed to trade strategy signal on paper", zap.Int64("deployment_id", d.ID), zap.Error(err))
+		}
+	}
+}
+
+// trade passes sig to the bridge and records what the deployment holds after
+func (m *DeploymentManager) trade(ctx context.Context, d model.Deployment, sig Signal) error {
+	held, err := m.bridge.Execute(ctx, d, sig)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	recorded, running := m.positions[d.ID]
+	if running {
+		m.positions[d.ID] = held
+	}
+	m.mu.Unlock()
+	if !running || held.Equal(recorded) {
+		return nil
+	}
+	return m.repo.SetDeploymentPosition(ctx, d.ID, held)
+}
+
+// closeLive releases a strategy that holds resources, e.g. a WASM module
+func closeLive(s strategy.Strategy) {
+	if c, ok := s.(io.Closer); ok {
+		c.Close()
+	}
+}
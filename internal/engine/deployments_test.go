@@ -0,0 +1,283 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// orderRecorder is a PaperPlacer that records the orders placed. With paper
+// set it stores them too, filled at their price unless unfilled is set.
+type orderRecorder struct {
+	paper    storage.PaperRepository
+	unfilled bool
+	orders   []model.PaperOrder
+}
+
+func (o *orderRecorder) PlaceOrder(ctx context.Context, order model.PaperOrder) (int64, error) {
+	o.orders = append(o.orders, order)
+	if o.paper == nil {
+		return int64(len(o.orders)), nil
+	}
+	id, err := o.paper.CreateOrder(ctx, order)
+	if err != nil || o.unfilled {
+		return id, err
+	}
+	order.ID, order.FilledPrice = id, order.Price
+	return id, o.paper.ApplyFills(ctx, []model.PaperOrder{order})
+}
+
+// newDeploymentManager stores an ma_cross_v2 2/3 version of user 1 and returns its id
+func newDeploymentManager(t *testing.T, store *storage.Store) (*DeploymentManager, *StrategyRunner, *publishRecorder, *orderRecorder, int64) {
+	_, version, err := NewStrategyLibrary(store.Strategies).Create(context.Background(), model.Strategy{UserID: 1, Name: "cross"}, StrategyDraft{
+		Type:   "ma_cross_v2",
+		Config: map[string]interface{}{"short_period": 2.0, "long_period": 3.0},
+	})
+	require.NoError(t, err)
+	js, orders := &publishRecorder{}, &orderRecorder{paper: store.Paper}
+	runner := NewStrategyRunner(js, storage.NewMemoryStore(), zap.NewNop())
+	return NewDeploymentManager(store, runner, orders, js, zap.NewNop()), runner, js, orders, version.ID
+}
+
+// crossBars cross up at 3 and back down at 6
+func crossBars(symbol string) []model.KLine {
+	return symbolBars(symbol, 10, 9, 8, 12, 13, 14, 9, 8)
+}
+
+func TestDeploymentManager_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	require.NoError(t, store.Paper.CreateAccount(ctx, 1, decimal.NewFromInt(20000)))
+	m, runner, js, orders, versionID := newDeploymentManager(t, store)
+
+	d, err := m.Deploy(ctx, model.Deployment{UserID: 1, StrategyVersionID: versionID, Symbol: "btcusdt", Period: "1m", Capital: decimal.NewFromInt(1200), Paper: true})
+	require.NoError(t, err)
+	assert.Equal(t, "BTCUSDT", d.Symbol)
+	assert.Equal(t, model.DeploymentRunning, d.Status)
+
+	for _, c := range append(symbolBars("ETHUSDT", 10, 9, 8, 12), crossBars("BTCUSDT")...) {
+		runner.executeStrategies(c)
+	}
+	assert.Equal(t, []string{"notification.user.1", "notification.user.1"}, js.subjects)
+	var msg map[string]interface{}
+	require.NoError(t, json.Unmarshal(js.data[0], &msg))
+	assert.Equal(t, "strategy_signal", msg["type"])
+	assert.Equal(t, float64(d.ID), msg["deployment_id"])
+	assert.Equal(t, "buy", msg["action"])
+
+	// 1200 at 12 buys 100, and the sell closes what was bought
+	require.Len(t, orders.orders, 2)
+	assert.Equal(t, "buy", orders.orders[0].Side)
+	assert.Equal(t, "100", orders.orders[0].Qty.String())
+	assert.Equal(t, "sell", orders.orders[1].Side)
+	assert.Equal(t, "100", orders.orders[1].Qty.String())
+	stored, err := m.Get(ctx, 1, d.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.PositionQty.IsZero())
+	executions, err := m.Executions(ctx, 1, d.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, executions, 2)
+	assert.Equal(t, "sell", executions[0].Action)
+	assert.Equal(t, "filled", executions[0].OrderStatus)
+	assert.Equal(t, model.ExecutionPlaced, executions[1].Status)
+	_, err = m.Executions(ctx, 2, d.ID, 10)
+	assert.ErrorIs(t, err, storage.ErrNotFound, "other user's deployment")
+
+	// Paused, it follows the market silently
+	_, err = m.Pause(ctx, 1, d.ID)
+	require.NoError(t, err)
+	for _, c := range symbolBars("BTCUSDT", 10, 9, 8, 12) {
+		runner.executeStrategies(c)
+	}
+	assert.Len(t, js.subjects, 2)
+
+	_, err = m.Start(ctx, 1, d.ID)
+	require.NoError(t, err)
+	_, err = m.Stop(ctx, 2, d.ID)
+	assert.ErrorIs(t, err, storage.ErrNotFound, "other user's deployment")
+	stopped, err := m.Stop(ctx, 1, d.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.DeploymentStopped, stopped.Status)
+	assert.Empty(t, runner.strategies)
+	_, err = m.Start(ctx, 1, d.ID)
+	assert.ErrorIs(t, err, storage.ErrConflict)
+}
+
+func TestDeploymentManager_UnfilledBuy(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	require.NoError(t, store.Paper.CreateAccount(ctx, 1, decimal.NewFromInt(20000)))
+	m, runner, js, orders, versionID := newDeploymentManager(t, store)
+	orders.unfilled = true
+
+	d, err := m.Deploy(ctx, model.Deployment{UserID: 1, StrategyVersionID: versionID, Symbol: "BTCUSDT", Period: "1m", Capital: decimal.NewFromInt(1200), Paper: true})
+	require.NoError(t, err)
+	for _, c := range crossBars("BTCUSDT") {
+		runner.executeStrategies(c)
+	}
+
+	// The buy never filled, so the sell has nothing to close
+	assert.Len(t, js.subjects, 2)
+	require.Len(t, orders.orders, 1)
+	assert.Equal(t, "buy", orders.orders[0].Side)
+	stored, err := m.Get(ctx, 1, d.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.PositionQty.IsZero())
+	executions, err := m.Executions(ctx, 1, d.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, executions, 2)
+	assert.Equal(t, model.ExecutionSkipped, executions[0].Status)
+	assert.Equal(t, "order still open", executions[0].Reason)
+	positions, err := store.Paper.ListPositions(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, positions)
+}
+
+func TestDeploymentManager_Restore(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	m, _, _, _, versionID := newDeploymentManager(t, store)
+
+	running, err := m.Deploy(ctx, model.Deployment{UserID: 1, StrategyVersionID: versionID, Symbol: "BTCUSDT", Period: "1m"})
+	require.NoError(t, err)
+	paused, err := m.Deploy(ctx, model.Deployment{UserID: 1, StrategyVersionID: versionID, Symbol: "ETHUSDT", Period: "5m"})
+	require.NoError(t, err)
+	_, err = m.Pause(ctx, 1, paused.ID)
+	require.NoError(t, err)
+	stopped, err := m.Deploy(ctx, model.Deployment{UserID: 1, StrategyVersionID: versionID, Symbol: "SOLUSDT", Period: "1m"})
+	require.NoError(t, err)
+	_, err = m.Stop(ctx, 1, stopped.ID)
+	require.NoError(t, err)
+
+	// A new process runs what was running or paused, as it was left
+	js := &publishRecorder{}
+	runner := NewStrategyRunner(js, storage.NewMemoryStore(), zap.NewNop())
+	require.NoError(t, NewDeploymentManager(store, runner, &orderRecorder{}, js, zap.NewNop()).Restore(ctx))
+	require.Len(t, runner.strategies, 2)
+	assert.Equal(t, running.ID, runner.strategies[0].id)
+	assert.False(t, runner.strategies[0].paused)
+	assert.Equal(t, paused.ID, runner.strategies[1].id)
+	assert.True(t, runner.strategies[1].paused)
+	assert.Equal(t, "5m", runner.strategies[1].sub.Period)
+
+	for _, c := range crossBars("BTCUSDT") {
+		runner.executeStrategies(c)
+	}
+	assert.Len(t, js.subjects, 2)
+}
+
+// leaseHolder is one of the instances sharing the lease owner
+type leaseHolder struct {
+	owner *int
+	id    int
+}
+
+func (l leaseHolder) AcquireLease(_ context.Context, _ string) (bool, error) {
+	if *l.owner == 0 {
+		*l.owner = l.id
+	}
+	return *l.owner == l.id, nil
+}
+
+func (l leaseHolder) ReleaseLease(_ context.Context, _ string) error {
+	if *l.owner == l.id {
+		*l.owner = 0
+	}
+	return nil
+}
+
+func TestDeploymentManager_SingleOwner(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	_, _, _, _, versionID := newDeploymentManager(t, store)
+
+	owner := 0
+	instance := func(id int) (*DeploymentManager, *StrategyRunner, *publishRecorder) {
+		shared := *store
+		shared.Leases = leaseHolder{owner: &owner, id: id}
+		js := &publishRecorder{}
+		runner := NewStrategyRunner(js, storage.NewMemoryStore(), zap.NewNop())
+		return NewDeploymentManager(&shared, runner, &orderRecorder{}, js, zap.NewNop()), runner, js
+	}
+	first, firstRunner, firstJS := instance(1)
+	second, secondRunner, secondJS := instance(2)
+	require.NoError(t, first.Restore(ctx))
+
+	// Deployed through the other instance, it runs on the lease holder only
+	d, err := second.Deploy(ctx, model.Deployment{UserID: 1, StrategyVersionID: versionID, Symbol: "BTCUSDT", Period: "1m"})
+	require.NoError(t, err)
+	assert.Empty(t, secondRunner.strategies)
+	require.NoError(t, first.Restore(ctx))
+	require.Len(t, firstRunner.strategies, 1)
+	for _, c := range crossBars("BTCUSDT") {
+		firstRunner.executeStrategies(c)
+		secondRunner.executeStrategies(c)
+	}
+	assert.Len(t, firstJS.subjects, 2)
+	assert.Empty(t, secondJS.subjects)
+
+	_, err = second.Pause(ctx, 1, d.ID)
+	require.NoError(t, err)
+	require.NoError(t, first.Restore(ctx))
+	assert.True(t, firstRunner.strategies[0].paused)
+
+	// Once the holder hands the lease on, the other instance takes over
+	require.NoError(t, first.Close(ctx))
+	assert.Empty(t, firstRunner.strategies)
+	require.NoError(t, second.Restore(ctx))
+	require.Len(t, secondRunner.strategies, 1)
+	assert.True(t, secondRunner.strategies[0].paused)
+	require.NoError(t, first.Restore(ctx))
+	assert.Empty(t, firstRunner.strategies)
+
+	// Stopped through the instance without the lease, it stops on the next sync
+	_, err = first.Stop(ctx, 1, d.ID)
+	require.NoError(t, err)
+	assert.Len(t, secondRunner.strategies, 1)
+	require.NoError(t, second.Restore(ctx))
+	assert.Empty(t, secondRunner.strategies)
+
+	// A holder that lost the lease stops what it ran
+	_, err = second.Deploy(ctx, model.Deployment{UserID: 1, StrategyVersionID: versionID, Symbol: "ETHUSDT", Period: "1m"})
+	require.NoError(t, err)
+	require.Len(t, secondRunner.strategies, 1)
+	owner = 3
+	require.NoError(t, second.Restore(ctx))
+	assert.Empty(t, secondRunner.strategies)
+}
+
+func TestDeploymentManager_Invalid(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	m, runner, _, _, versionID := newDeploymentManager(t, store)
+
+	for name, d := range map[string]model.Deployment{
+		"other user's version":  {UserID: 2, StrategyVersionID: versionID, Symbol: "BTCUSDT", Period: "1m"},
+		"period":                {UserID: 1, StrategyVersionID: versionID, Symbol: "BTCUSDT", Period: "7m"},
+		"capital":               {UserID: 1, StrategyVersionID: versionID, Symbol: "BTCUSDT", Period: "1m", Capital: decimal.NewFromInt(-1)},
+		"paper without capital": {UserID: 1, StrategyVersionID: versionID, Symbol: "BTCUSDT", Period: "1m", Paper: true},
+		"sizing":                {UserID: 1, StrategyVersionID: versionID, Symbol: "BTCUSDT", Period: "1m", Paper: true, Capital: decimal.NewFromInt(100), Sizing: model.SizingPolicy{Mode: "kelly"}},
+		"no paper account":      {UserID: 1, StrategyVersionID: versionID, Symbol: "BTCUSDT", Period: "1m", Paper: true, Capital: decimal.NewFromInt(100)},
+	} {
+		_, err := m.Deploy(ctx, d)
+		assert.ErrorIs(t, err, ErrInvalidDeployment, name)
+	}
+	list, err := m.List(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	assert.Empty(t, runner.strategies)
+
+	for i := 0; i < MaxDeploymentsPerUser; i++ {
+		_, err := m.Deploy(ctx, model.Deployment{UserID: 1, StrategyVersionID: versionID, Symbol: "BTCUSDT", Period: "1m"})
+		require.NoError(t, err)
+	}
+	_, err = m.Deploy(ctx, model.Deployment{UserID: 1, StrategyVersionID: versionID, Symbol: "BTCUSDT", Period: "1m"})
+	assert.ErrorIs(t, err, ErrInvalidDeployment, "too many")
+}
@@ -2,39 +2,172 @@ package engine
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
 	"time"
-
-	"github.com/jackc/pgx/v4/pgxpool"
 )
 
+// ErrDataGap is returned when stored candles do not fully cover the requested range
+var ErrDataGap = errors.New("missing candles in requested range")
+
+// GapError describes the first hole found while streaming candles
+type GapError struct {
+	Symbol   string
+	Exchange string
+	Period   string
+	From     time.Time // first missing candle
+	// To is the first candle after the hole; past a range ending in a hole it
+	// is the first candle the inclusive End no longer covers
+	To time.Time
+}
+
+func (e *GapError) Error() string {
+	exchange := e.Exchange
+	if exchange == "" {
+		exchange = "any exchange"
+	}
+	return fmt.Sprintf("missing %s candles for %s on %s from %s until %s",
+		e.Period, e.Symbol, exchange, e.From.UTC().Format(time.RFC3339), e.To.UTC().Format(time.RFC3339))
+}
+
+func (e *GapError) Unwrap() error { return ErrDataGap }
+
+// CandleRequest selects the history a backtest runs on
+type CandleRequest struct {
+	Symbol   string
+	Exchange string // optional when the symbol is only stored for one exchange
+	Period   string
+	Start    time.Time
+	End      time.Time
+}
+
+// DataLoader is the single source of historical candles for backtests
 type DataLoader struct {
-	pool *pgxpool.Pool
+	repo storage.MarketDataRepository
 }
 
-func NewDataLoader(pool *pgxpool.Pool) *DataLoader {
-	return &DataLoader{pool: pool}
+// NewDataLoader creates a loader reading candles through the market data repository,
+// which decides whether periods above 1m come from the raw table or the aggregates.
+func NewDataLoader(repo storage.MarketDataRepository) *DataLoader {
+	return &DataLoader{repo: repo}
 }
 
-func (l *DataLoader) LoadCandles(ctx context.Context, symbol string, start, end time.Time, period string) ([]model.KLine, error) {
-	rows, err := l.pool.Query(ctx, `
-		SELECT time, symbol, exchange, period, open, high, low, close, volume 
-		FROM market_klines 
-		WHERE symbol = $1 AND period = $2 AND time >= $3 AND time <= $4 
-		ORDER BY time ASC`,
-		symbol, period, start, end)
+// Candles streams the requested range in time order. The returned iterator stops
+// with a *GapError if a candle is missing, or an error if exchanges are mixed together.
+func (l *DataLoader) Candles(ctx context.Context, req CandleRequest) (*CandleIterator, error) {
+	if req.Symbol == "" || req.Period == "" {
+		return nil, errors.New("symbol and period are required")
+	}
+	if !req.End.After(req.Start) {
+		return nil, errors.New("end time must be after start time")
+	}
+
+	rows, err := l.repo.IterateKlines(ctx, storage.KlineQuery{
+		Symbol:   req.Symbol,
+		Exchange: req.Exchange,
+		Period:   req.Period,
+		Start:    req.Start,
+		End:      req.End,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query candles: %w", err)
+	}
+	return &CandleIterator{
+		rows: rows,
+		req:  req,
+		step: model.PeriodToDuration(req.Period),
+	}, nil
+}
+
+// LoadCandles materializes Candles into a slice
+func (l *DataLoader) LoadCandles(ctx context.Context, req CandleRequest) ([]model.KLine, error) {
+	it, err := l.Candles(ctx, req)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer it.Close()
 
 	var candles []model.KLine
-	for rows.Next() {
-		var k model.KLine
-		if err := rows.Scan(&k.Timestamp, &k.Symbol, &k.Exchange, &k.Period, &k.Open, &k.High, &k.Low, &k.Close, &k.Volume); err != nil {
-			return nil, err
+	for it.Next() {
+		candles = append(candles, it.KLine())
+	}
+	return candles, it.Err()
+}
+
+// CandleIterator validates continuity while streaming candles from storage
+type CandleIterator struct {
+	rows    storage.KlineIterator
+	req     CandleRequest
+	step    time.Duration
+	cur     model.KLine
+	started bool
+	done    bool
+	err     error
+}
+
+func (it *CandleIterator) Next() bool {
+	if it.done {
+		return false
+	}
+
+	if !it.rows.Next() {
+		it.done = true
+		if err := it.rows.Err(); err != nil {
+			it.err = err
+			return false
+		}
+		// Trailing hole: End is inclusive, so a candle due at End is missing too
+		last := it.req.Start.Add(-it.step)
+		if it.started {
+			last = it.cur.Timestamp
+		}
+		if from := last.Add(it.step); !from.After(it.req.End) {
+			missing := it.req.End.Sub(from)/it.step + 1
+			it.err = it.gap(from, from.Add(missing*it.step))
+		}
+		return false
+	}
+
+	k := it.rows.KLine()
+	switch {
+	case !it.started:
+		if k.Timestamp.Sub(it.req.Start) >= it.step {
+			return it.fail(it.gap(it.req.Start, k.Timestamp))
 		}
-		candles = append(candles, k)
+	case !k.Timestamp.After(it.cur.Timestamp):
+		return it.fail(fmt.Errorf("duplicate %s candle for %s at %s, specify an exchange",
+			it.req.Period, it.req.Symbol, k.Timestamp.UTC().Format(time.RFC3339)))
+	case k.Timestamp.Sub(it.cur.Timestamp) > it.step:
+		return it.fail(it.gap(it.cur.Timestamp.Add(it.step), k.Timestamp))
+	}
+
+	it.cur = k
+	it.started = true
+	return true
+}
+
+// KLine returns the current candle
+func (it *CandleIterator) KLine() model.KLine { return it.cur }
+
+// Err reports a storage failure or a *GapError once Next returns false
+func (it *CandleIterator) Err() error { return it.err }
+
+func (it *CandleIterator) Close() { it.rows.Close() }
+
+func (it *CandleIterator) fail(err error) bool {
+	it.err = err
+	it.done = true
+	return false
+}
+
+func (it *CandleIterator) gap(from, to time.Time) error {
+	return &GapError{
+		Symbol:   it.req.Symbol,
+		Exchange: it.req.Exchange,
+		Period:   it.req.Period,
+		From:     from,
+		To:       to,
 	}
-	return candles, nil
 }
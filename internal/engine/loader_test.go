@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"context"
+	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
+	"quant-trader/internal/strategy"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func seedCandles(t *testing.T, repo storage.MarketDataRepository, exchange string, start time.Time, minutes ...int) {
+	t.Helper()
+	klines := make([]model.KLine, 0, len(minutes))
+	for _, m := range minutes {
+		klines = append(klines, model.KLine{
+			Symbol:    "BTCUSDT",
+			Exchange:  exchange,
+			Period:    "1m",
+			Close:     decimal.NewFromInt(int64(100 + m)),
+			Timestamp: start.Add(time.Duration(m) * time.Minute),
+		})
+	}
+	require.NoError(t, repo.UpsertKlines(context.Background(), klines))
+}
+
+func TestDataLoader_Candles(t *testing.T) {
+	store := storage.NewMemoryStore()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	seedCandles(t, store.Market, "binance", start, 0, 1, 2, 3, 4)
+	seedCandles(t, store.Market, "okx", start, 0, 1, 3, 4)
+
+	loader := NewDataLoader(store.Market)
+	req := CandleRequest{Symbol: "BTCUSDT", Exchange: "binance", Period: "1m", Start: start, End: start.Add(4 * time.Minute)}
+
+	candles, err := loader.LoadCandles(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, candles, 5)
+	for _, c := range candles {
+		assert.Equal(t, "binance", c.Exchange)
+	}
+
+	// okx is missing the 00:02 candle
+	req.Exchange = "okx"
+	_, err = loader.LoadCandles(context.Background(), req)
+	var gap *GapError
+	require.ErrorAs(t, err, &gap)
+	assert.ErrorIs(t, err, ErrDataGap)
+	assert.True(t, gap.From.Equal(start.Add(2*time.Minute)))
+	assert.True(t, gap.To.Equal(start.Add(3*time.Minute)))
+
+	// Without an exchange both venues are interleaved
+	req.Exchange = ""
+	_, err = loader.LoadCandles(context.Background(), req)
+	assert.ErrorContains(t, err, "specify an exchange")
+}
+
+func TestDataLoader_RangeEdges(t *testing.T) {
+	store := storage.NewMemoryStore()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	seedCandles(t, store.Market, "binance", start, 2, 3, 4)
+	loader := NewDataLoader(store.Market)
+
+	_, err := loader.LoadCandles(context.Background(), CandleRequest{Symbol: "BTCUSDT", Period: "1m", Start: start, End: start.Add(4 * time.Minute)})
+	var gap *GapError
+	require.ErrorAs(t, err, &gap)
+	assert.True(t, gap.From.Equal(start))
+
+	_, err = loader.LoadCandles(context.Background(), CandleRequest{Symbol: "BTCUSDT", Period: "1m", Start: start.Add(2 * time.Minute), End: start.Add(10 * time.Minute)})
+	require.ErrorAs(t, err, &gap)
+	assert.True(t, gap.From.Equal(start.Add(5*time.Minute)))
+	assert.True(t, gap.To.Equal(start.Add(11*time.Minute)), "End is inclusive")
+
+	// Only the candle at End is missing
+	_, err = loader.LoadCandles(context.Background(), CandleRequest{Symbol: "BTCUSDT", Period: "1m", Start: start.Add(2 * time.Minute), End: start.Add(5 * time.Minute)})
+	require.ErrorAs(t, err, &gap)
+	assert.True(t, gap.From.Equal(start.Add(5*time.Minute)))
+	assert.True(t, gap.To.Equal(start.Add(6*time.Minute)))
+	candles, err := loader.LoadCandles(context.Background(), CandleRequest{Symbol: "BTCUSDT", Period: "1m", Start: start.Add(2 * time.Minute), End: start.Add(4 * time.Minute)})
+	require.NoError(t, err)
+	assert.Len(t, candles, 3)
+
+	_, err = loader.LoadCandles(context.Background(), CandleRequest{Symbol: "ETHUSDT", Period: "1m", Start: start, End: start.Add(time.Minute)})
+	assert.ErrorIs(t, err, ErrDataGap)
+}
+
+func TestBacktester_RunSourceStopsOnGap(t *testing.T) {
+	store := storage.NewMemoryStore()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	seedCandles(t, store.Market, "binance", start, 0, 1, 3)
+
+	it, err := NewDataLoader(store.Market).Candles(context.Background(), CandleRequest{
+		Symbol: "BTCUSDT", Period: "1m", Start: start, End: start.Add(3 * time.Minute),
+	})
+	require.NoError(t, err)
+	defer it.Close()
+
+	_, err = NewBacktester(strategy.NewMAStrategy(2, 5), decimal.NewFromInt(1000)).RunSource(it)
+	assert.ErrorIs(t, err, ErrDataGap)
+}
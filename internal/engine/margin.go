@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"errors"
+	"quant-trader/internal/model"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// MarginConfig describes the account the backtest trades on. The spot default
+// allows no shorts and never liquidates; perpetual swaps usually set a leverage,
+// a maintenance margin rate and a funding rate.
+type MarginConfig struct {
+	// Leverage is the maximum notional per unit of equity; initial margin is 1/Leverage
+	Leverage decimal.Decimal `json:"leverage"`
+	// MaintenanceMargin is the rate of notional below which a position is liquidated; 0 disables liquidation
+	MaintenanceMargin decimal.Decimal `json:"maintenance_margin"`
+	AllowShort        bool            `json:"allow_short"`
+	// FundingRate is paid by longs to shorts (received when negative) at every FundingInterval boundary
+	FundingRate     decimal.Decimal `json:"funding_rate"`
+	FundingInterval time.Duration   `json:"funding_interval"`
+}
+
+// SpotMargin is the default account: no leverage, no shorts, no funding
+func SpotMargin() MarginConfig {
+	return MarginConfig{Leverage: decimal.NewFromInt(1)}
+}
+
+// Validate checks that positions can be opened and are not liquidated on entry
+func (c MarginConfig) Validate() error {
+	if c.Leverage.LessThan(decimal.NewFromInt(1)) {
+		return errors.New("leverage must be at least 1")
+	}
+	if c.MaintenanceMargin.IsNegative() {
+		return errors.New("maintenance margin must not be negative")
+	}
+	if c.MaintenanceMargin.GreaterThanOrEqual(decimal.NewFromInt(1).Div(c.Leverage)) {
+		return errors.New("maintenance margin must be below the initial margin (1/leverage)")
+	}
+	if c.FundingInterval < 0 {
+		return errors.New("funding interval must not be negative")
+	}
+	if !c.FundingRate.IsZero() && c.FundingInterval == 0 {
+		return errors.New("funding rate requires a funding interval")
+	}
+	return nil
+}
+
+// SetMargin replaces the spot default; call it before Run
+func (b *Backtester) SetMargin(cfg MarginConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	b.margin = cfg
+	return nil
+}
+
+// usedMargin is the initial margin held by open positions at their last close
+func (b *Backtester) usedMargin() decimal.Decimal {
+	used := decimal.Zero
+	for symbol, p := range b.positions {
+		used = used.Add(p.Qty.Abs().Mul(b.lastPrices[symbol]))
+	}
+	return used.Div(b.margin.Leverage)
+}
+
+// maxOpenQty is the largest quantity that the free margin can open at price,
+// counting the margin released by the closeQty filled first and the entry fee
+func (b *Backtester) maxOpenQty(price, closeQty, feeRate decimal.Decimal) decimal.Decimal {
+	free := b.Equity().Sub(b.usedMargin()).Add(closeQty.Mul(price).Div(b.margin.Leverage))
+	if !free.IsPositive() {
+		return decimal.Zero
+	}
+	perUnit := price.Mul(decimal.NewFromInt(1).Div(b.margin.Leverage).Add(feeRate))
+	return free.Div(perUnit).Truncate(8)
+}
+
+// collateralName is used in order reasons so spot reports keep saying "cash"
+func (b *Backtester) collateralName() string {
+	if b.margin.Leverage.Equal(decimal.NewFromInt(1)) && !b.margin.AllowShort {
+		return "cash"
+	}
+	return "margin"
+}
+
+// settleFunding charges the position of bar's symbol for every funding boundary
+// crossed since the previous bar, marked at the bar's open
+func (b *Backtester) settleFunding(bar model.KLine) {
+	interval := b.margin.FundingInterval
+	if interval <= 0 || b.margin.FundingRate.IsZero() {
+		return
+	}
+	pos, ok := b.positions[bar.Symbol]
+	last, seen := b.lastBars[bar.Symbol]
+	if !ok || !seen || pos.Qty.IsZero() {
+		return
+	}
+
+	periods := int64(bar.Timestamp.Truncate(interval).Sub(last.Timestamp.Truncate(interval)) / interval)
+	if periods <= 0 {
+		return
+	}
+	payment := pos.Qty.Mul(bar.Open).Mul(b.margin.FundingRate).Mul(decimal.NewFromInt(periods))
+	b.balance = b.balance.Sub(payment)
+	pos.Funding = pos.Funding.Add(payment)
+	b.fundingPaid = b.fundingPaid.Add(payment)
+}
+
+// checkLiquidation closes the position of bar's symbol when the bar reaches the
+// price at which account equity falls to its maintenance margin. Margin is
+// crossed: other positions count at their last close.
+func (b *Backtester) checkLiquidation(bar model.KLine) {
+	mmr := b.margin.MaintenanceMargin
+	if !mmr.IsPositive() {
+		return
+	}
+	pos, ok := b.positions[bar.Symbol]
+	if !ok || pos.Qty.IsZero() {
+		return
+	}
+
+	collateral := b.balance
+	for symbol, p := range b.positions {
+		if symbol == bar.Symbol {
+			continue
+		}
+		mark := b.lastPrices[symbol]
+		collateral = collateral.Add(p.unrealized(mark)).Sub(p.Qty.Abs().Mul(mark).Mul(mmr))
+	}
+
+	one := decimal.NewFromInt(1)
+	size := pos.Qty.Abs()
+	entry := size.Mul(pos.EntryPrice)
+	var price decimal.Decimal
+	if pos.Qty.IsPositive() {
+		liq := entry.Sub(collateral).Div(size.Mul(one.Sub(mmr)))
+		if bar.Low.GreaterThan(liq) {
+			return
+		}
+		price = decimal.Min(bar.Open, liq)
+	} else {
+		liq := collateral.Add(entry).Div(size.Mul(one.Add(mmr)))
+		if bar.High.LessThan(liq) {
+			return
+		}
+		price = decimal.Max(bar.Open, liq)
+	}
+	if !price.IsPositive() {
+		price = bar.Low
+	}
+
+	for _, o := range b.open {
+		if o.Symbol == bar.Symbol {
+			b.finish(o, model.OrderCancelled, "position liquidated")
+		}
+	}
+	b.pruneOpen()
+
+	event := model.LiquidationEvent{Time: bar.Timestamp, Symbol: bar.Symbol, Side: "long", Qty: size, Price: price}
+	side := model.SideSell
+	if pos.Qty.IsNegative() {
+		event.Side = "short"
+		side = model.SideBuy
+	}
+	trade := b.reduce(pos, side, size, price, b.fee(bar.Exchange, bar.Symbol, side, size, price, false))
+	event.PnL = trade.PnL
+	b.trades = append(b.trades, trade)
+	b.liquidations = append(b.liquidations, event)
+
+	// Losses beyond the account are not carried: the wallet is floored at zero equity
+	if equity := b.Equity(); equity.IsNegative() {
+		b.balance = b.balance.Sub(equity)
+	}
+}
+
+// marginUsageRatio is used margin over equity, 1 once equity is exhausted
+func (b *Backtester) marginUsageRatio(equity decimal.Decimal) float64 {
+	used := b.usedMargin()
+	if used.IsZero() {
+		return 0
+	}
+	if !equity.IsPositive() {
+		return 1
+	}
+	ratio, _ := used.Div(equity).Float64()
+	return ratio
+}
+
+func summarizeUsage(usage []float64) (maxUsage, avgUsage float64) {
+	if len(usage) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, u := range usage {
+		sum += u
+		if u > maxUsage {
+			maxUsage = u
+		}
+	}
+	return maxUsage, sum / float64(len(usage))
+}
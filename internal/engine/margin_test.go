@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"quant-trader/internal/model"
+	"quant-trader/internal/strategy"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMargined(t *testing.T, strat OrderStrategy, cfg MarginConfig) *Backtester {
+	b := newFrictionless(strat)
+	require.NoError(t, b.SetMargin(cfg))
+	return b
+}
+
+func TestMarginConfig_Validate(t *testing.T) {
+	d := decimal.NewFromFloat
+	assert.NoError(t, SpotMargin().Validate())
+	assert.NoError(t, MarginConfig{Leverage: d(10), MaintenanceMargin: d(0.005)}.Validate())
+	assert.Error(t, MarginConfig{}.Validate())
+	assert.Error(t, MarginConfig{Leverage: d(10), MaintenanceMargin: d(0.1)}.Validate())
+	assert.Error(t, MarginConfig{Leverage: d(1), FundingRate: d(0.0001)}.Validate())
+}
+
+func TestBacktester_ShortRoundTrip(t *testing.T) {
+	qty := decimal.NewFromInt(10)
+	strat := &scriptedStrategy{script: map[int][]model.OrderRequest{
+		0: {{Side: model.SideSell, Type: model.OrderMarket, Qty: qty}},
+		2: {{Side: model.SideBuy, Type: model.OrderMarket, Qty: qty}},
+	}}
+	report := newMargined(t, strat, MarginConfig{Leverage: decimal.NewFromInt(1), AllowShort: true}).Run([]model.KLine{
+		bar(0, 100, 100, 100, 100),
+		bar(1, 100, 100, 100, 100), // short 10 @ 100
+		bar(2, 90, 90, 90, 90),
+		bar(3, 80, 80, 80, 80), // cover 10 @ 80: +200
+	})
+
+	require.Len(t, report.TradesLog, 2)
+	assert.True(t, report.TradesLog[1].Closing)
+	assert.True(t, report.TradesLog[1].PnL.Equal(decimal.NewFromInt(200)), report.TradesLog[1].PnL.String())
+	assert.True(t, report.FinalBalance.Equal(decimal.NewFromInt(10200)))
+	assert.InDelta(t, 0.1, report.MaxMarginUsage, 1e-9) // 1000 notional on 10000 equity
+}
+
+func TestBacktester_SpotRejectsShort(t *testing.T) {
+	strat := &scriptedStrategy{script: map[int][]model.OrderRequest{
+		0: {{Side: model.SideSell, Type: model.OrderMarket, Qty: decimal.NewFromInt(1)}},
+	}}
+	report := newFrictionless(strat).Run([]model.KLine{
+		bar(0, 100, 100, 100, 100),
+		bar(1, 100, 100, 100, 100),
+	})
+
+	require.Len(t, report.Orders, 1)
+	assert.Equal(t, model.OrderRejected, report.Orders[0].Status)
+	assert.Empty(t, report.TradesLog)
+}
+
+func TestBacktester_LeverageCapsMargin(t *testing.T) {
+	strat := &scriptedStrategy{script: map[int][]model.OrderRequest{
+		0: {{Side: model.SideBuy, Type: model.OrderMarket, Qty: decimal.NewFromInt(1000)}},
+	}}
+	report := newMargined(t, strat, MarginConfig{Leverage: decimal.NewFromInt(5)}).Run([]model.KLine{
+		bar(0, 100, 100, 100, 100),
+		bar(1, 100, 100, 100, 100),
+	})
+
+	require.Len(t, report.Orders, 1)
+	assert.True(t, report.Orders[0].FilledQty.Equal(decimal.NewFromInt(500)), report.Orders[0].FilledQty.String())
+	assert.Equal(t, "reduced to available margin", report.Orders[0].Reason)
+	assert.InDelta(t, 1.0, report.MaxMarginUsage, 1e-9)
+}
+
+func TestBacktester_Liquidation(t *testing.T) {
+	d := decimal.NewFromFloat
+	strat := &scriptedStrategy{script: map[int][]model.OrderRequest{
+		0: {{Side: model.SideBuy, Type: model.OrderMarket, Qty: d(900)}},
+		1: {{Side: model.SideSell, Type: model.OrderLimit, Qty: d(900), LimitPrice: d(120)}},
+	}}
+	cfg := MarginConfig{Leverage: d(10), MaintenanceMargin: d(0.005)}
+	report := newMargined(t, strat, cfg).Run([]model.KLine{
+		bar(0, 100, 100, 100, 100),
+		bar(1, 100, 100, 100, 100), // long 900 @ 100 with 9000 margin
+		bar(2, 95, 96, 85, 90),     // liquidation price (90000-10000)/(900*0.995) ~ 89.34
+		bar(3, 90, 90, 90, 90),
+	})
+
+	require.Len(t, report.Liquidations, 1)
+	liq := report.Liquidations[0]
+	assert.Equal(t, "long", liq.Side)
+	assert.True(t, liq.Qty.Equal(d(900)))
+	assert.InDelta(t, 89.3355, liq.Price.InexactFloat64(), 1e-3)
+
+	// Equity left equals the maintenance margin at the liquidation price
+	maintenance := liq.Price.Mul(d(900)).Mul(cfg.MaintenanceMargin)
+	assert.InDelta(t, maintenance.InexactFloat64(), report.FinalBalance.InexactFloat64(), 1e-6)
+	assert.Equal(t, model.OrderCancelled, report.Orders[1].Status)
+	assert.Equal(t, "position liquidated", report.Orders[1].Reason)
+}
+
+func TestBacktester_LiquidationGapFloorsEquity(t *testing.T) {
+	d := decimal.NewFromFloat
+	strat := &scriptedStrategy{script: map[int][]model.OrderRequest{
+		0: {{Side: model.SideSell, Type: model.OrderMarket, Qty: d(900)}},
+	}}
+	cfg := MarginConfig{Leverage: d(10), MaintenanceMargin: d(0.005), AllowShort: true}
+	report := newMargined(t, strat, cfg).Run([]model.KLine{
+		bar(0, 100, 100, 100, 100),
+		bar(1, 100, 100, 100, 100), // short 900 @ 100
+		bar(2, 125, 130, 120, 128), // gaps through the liquidation price
+	})
+
+	require.Len(t, report.Liquidations, 1)
+	assert.Equal(t, "short", report.Liquidations[0].Side)
+	assert.True(t, report.Liquidations[0].Price.Equal(d(125)))
+	assert.True(t, report.FinalBalance.IsZero(), report.FinalBalance.String())
+}
+
+func TestBacktester_Funding(t *testing.T) {
+	d := decimal.NewFromFloat
+	cases := []struct {
+		side model.OrderSide
+		paid float64
+	}{
+		{model.SideBuy, 2},
+		{model.SideSell, -2},
+	}
+	for _, tc := range cases {
+		t.Run(string(tc.side), func(t *testing.T) {
+			strat := &scriptedStrategy{script: map[int][]model.OrderRequest{
+				0: {{Side: tc.side, Type: model.OrderMarket, Qty: d(10)}},
+			}}
+			cfg := MarginConfig{Leverage: d(2), AllowShort: true, FundingRate: d(0.001), FundingInterval: 2 * time.Minute}
+			report := newMargined(t, strat, cfg).Run([]model.KLine{
+				bar(0, 100, 100, 100, 100),
+				bar(1, 100, 100, 100, 100), // open 10 @ 100
+				bar(2, 100, 100, 100, 100), // funding 10 * 100 * 0.001
+				bar(3, 100, 100, 100, 100),
+				bar(4, 100, 100, 100, 100), // funding again
+			})
+
+			assert.True(t, report.FundingPaid.Equal(d(tc.paid)), report.FundingPaid.String())
+			assert.True(t, report.FinalBalance.Equal(d(10000-tc.paid)), report.FinalBalance.String())
+		})
+	}
+}
+
+// signalStrategy returns a fixed action per bar
+type signalStrategy []strategy.Action
+
+func (s signalStrategy) Name() string { return "signals" }
+
+func (s signalStrategy) OnCandle(candle model.KLine) strategy.Action {
+	if i := candle.Timestamp.Minute(); i < len(s) {
+		return s[i]
+	}
+	return strategy.ActionHold
+}
+
+func TestBacktester_ActionStrategyShorts(t *testing.T) {
+	signals := signalStrategy{strategy.ActionSell, strategy.ActionHold, strategy.ActionBuy}
+	tester := NewBacktester(signals, decimal.NewFromInt(10000))
+	withoutCosts(tester)
+	require.NoError(t, tester.SetMargin(MarginConfig{Leverage: decimal.NewFromInt(2), AllowShort: true}))
+
+	report := tester.Run([]model.KLine{
+		bar(0, 100, 100, 100, 100),
+		bar(1, 100, 100, 100, 100), // short 200 @ 100 with 2x buying power
+		bar(2, 90, 90, 90, 90),
+		bar(3, 90, 90, 90, 90), // cover @ 90: +2000
+	})
+
+	require.Len(t, report.TradesLog, 2)
+	assert.Equal(t, "sell", report.TradesLog[0].Side)
+	assert.True(t, report.TradesLog[0].Size.Equal(decimal.NewFromInt(200)))
+	assert.True(t, report.FinalBalance.Equal(decimal.NewFromInt(12000)), report.FinalBalance.String())
+}
@@ -0,0 +1,244 @@
+package engine
+
+import (
+	"fmt"
+	"quant-trader/internal/model"
+
+	"github.com/shopspring/decimal"
+)
+
+// Execution is a fill decided by a FillModel, before slippage and fees
+type Execution struct {
+	Price decimal.Decimal
+	// Taker is set when the fill removed liquidity and so pays slippage
+	Taker bool
+	// Impact is set when Price already includes the market impact, e.g. from
+	// walking an order book, so no slippage model is applied
+	Impact bool
+	// Seq orders executions within one bar: lower fills first
+	Seq float64
+}
+
+// FillModel decides when and at what price orders execute
+type FillModel interface {
+	// Match is called for every resting order on each new bar of its symbol
+	Match(o *model.Order, bar model.KLine) (Execution, bool)
+	// OnSubmit may execute a new order right away against last, the bar the
+	// strategy has just seen
+	OnSubmit(o *model.Order, last model.KLine) (Execution, bool)
+}
+
+// NextOpenFill is the default model: orders wait for the next bar, which is
+// resolved with high/low touch rules
+type NextOpenFill struct{}
+
+func (NextOpenFill) Match(o *model.Order, bar model.KLine) (Execution, bool) {
+	price, taker, ok := matchOrder(o, bar)
+	return Execution{Price: price, Taker: taker}, ok
+}
+
+func (NextOpenFill) OnSubmit(*model.Order, model.KLine) (Execution, bool) {
+	return Execution{}, false
+}
+
+// CloseFill only uses closes: market orders execute at the close of the bar
+// they are submitted on, resting orders when a later close crosses their price
+type CloseFill struct{}
+
+func (CloseFill) Match(o *model.Order, bar model.KLine) (Execution, bool) {
+	flat := model.KLine{Open: bar.Close, High: bar.Close, Low: bar.Close, Close: bar.Close}
+	price, taker, ok := matchOrder(o, flat)
+	return Execution{Price: price, Taker: taker}, ok
+}
+
+func (CloseFill) OnSubmit(o *model.Order, last model.KLine) (Execution, bool) {
+	if o.Type != model.OrderMarket || !last.Close.IsPositive() {
+		return Execution{}, false
+	}
+	return Execution{Price: last.Close, Taker: true}, true
+}
+
+// IntrabarFill walks an assumed path through each bar: open, low, high, close
+// for an up bar and open, high, low, close for a down bar. Orders fill at the
+// first point the path reaches their price, in path order, and a stop-limit can
+// trigger and fill on the same bar once the path comes back to its limit.
+type IntrabarFill struct{}
+
+func (IntrabarFill) Match(o *model.Order, bar model.KLine) (Execution, bool) {
+	path := newOHLCPath(bar)
+	buy := o.Side == model.SideBuy
+
+	switch o.Type {
+	case model.OrderMarket:
+		return Execution{Price: bar.Open, Taker: true}, true
+	case model.OrderLimit:
+		price, seq, ok := path.touch(0, o.LimitPrice, buy)
+		return Execution{Price: price, Seq: seq}, ok
+	case model.OrderStop:
+		price, seq, ok := path.touch(0, o.StopPrice, !buy)
+		return Execution{Price: price, Taker: true, Seq: seq}, ok
+	case model.OrderStopLimit:
+		from := 0.0
+		if !o.Triggered {
+			trigger, seq, touched := path.touch(0, o.StopPrice, !buy)
+			if !touched {
+				return Execution{}, false
+			}
+			o.Triggered = true
+			if (buy && trigger.LessThanOrEqual(o.LimitPrice)) || (!buy && trigger.GreaterThanOrEqual(o.LimitPrice)) {
+				return Execution{Price: trigger, Seq: seq}, true
+			}
+			from = seq
+		}
+		price, seq, ok := path.touch(from, o.LimitPrice, buy)
+		return Execution{Price: price, Seq: seq}, ok
+	}
+	return Execution{}, false
+}
+
+func (IntrabarFill) OnSubmit(*model.Order, model.KLine) (Execution, bool) {
+	return Execution{}, false
+}
+
+// NewFillModel builds a fill model by name
+func NewFillModel(kind string) (FillModel, error) {
+	switch kind {
+	case "", "next_open":
+		return NextOpenFill{}, nil
+	case "close":
+		return CloseFill{}, nil
+	case "intrabar":
+		return IntrabarFill{}, nil
+	default:
+		return nil, fmt.Errorf("unknown fill model: %s", kind)
+	}
+}
+
+// ohlcPath is the piecewise linear price path assumed inside a bar
+type ohlcPath []decimal.Decimal
+
+func newOHLCPath(bar model.KLine) ohlcPath {
+	if bar.Close.GreaterThanOrEqual(bar.Open) {
+		return ohlcPath{bar.Open, bar.Low, bar.High, bar.Close}
+	}
+	return ohlcPath{bar.Open, bar.High, bar.Low, bar.Close}
+}
+
+// at interpolates the price at position pos, 0 being the open and len-1 the close
+func (p ohlcPath) at(pos float64) decimal.Decimal {
+	i := int(pos)
+	if i >= len(p)-1 {
+		return p[len(p)-1]
+	}
+	frac := decimal.NewFromFloat(pos - float64(i))
+	return p[i].Add(p[i+1].Sub(p[i]).Mul(frac))
+}
+
+// touch finds the first position from pos on where the price is at or below
+// level (down) or at or above it. A level already crossed fills at the current
+// price, otherwise the path fills exactly at level.
+func (p ohlcPath) touch(pos float64, level decimal.Decimal, down bool) (decimal.Decimal, float64, bool) {
+	beyond := func(price decimal.Decimal) bool {
+		if down {
+			return price.LessThanOrEqual(level)
+		}
+		return price.GreaterThanOrEqual(level)
+	}
+	if start := p.at(pos); beyond(start) {
+		return start, pos, true
+	}
+	for i := int(pos); i < len(p)-1; i++ {
+		if beyond(p[i+1]) {
+			frac, _ := level.Sub(p[i]).Div(p[i+1].Sub(p[i])).Float64()
+			return level, float64(i) + frac, true
+		}
+	}
+	return decimal.Zero, 0, false
+}
+
+// matchOrder decides whether o executes on bar and at what price, before costs.
+// The bar's path is unknown, so prices are resolved with high/low touch rules:
+// an order whose price is already crossed at the open fills at the open, otherwise
+// it fills at its own price if the high or low touches it. taker reports whether
+// the fill removed liquidity (market and stop orders) and so pays slippage.
+//
+// Stop-limit orders are marked Triggered when the stop is touched; once
+// triggered they behave as a limit order.
+func matchOrder(o *model.Order, bar model.KLine) (price decimal.Decimal, taker bool, ok bool) {
+	buy := o.Side == model.SideBuy
+
+	switch o.Type {
+	case model.OrderMarket:
+		return bar.Open, true, true
+
+	case model.OrderLimit:
+		price, ok = touchLimit(buy, o.LimitPrice, bar)
+		return price, false, ok
+
+	case model.OrderStop:
+		price, ok = touchStop(buy, o.StopPrice, bar)
+		return price, true, ok
+
+	case model.OrderStopLimit:
+		if o.Triggered {
+			price, ok = touchLimit(buy, o.LimitPrice, bar)
+			return price, false, ok
+		}
+		trigger, touched := touchStop(buy, o.StopPrice, bar)
+		if !touched {
+			return decimal.Zero, false, false
+		}
+		o.Triggered = true
+		// Fill immediately only if the trigger price is within the limit
+		if (buy && trigger.LessThanOrEqual(o.LimitPrice)) || (!buy && trigger.GreaterThanOrEqual(o.LimitPrice)) {
+			return trigger, false, true
+		}
+		// Triggered at the open beyond the limit: the rest of the bar may still come back to it
+		if trigger.Equal(bar.Open) {
+			price, ok = touchLimit(buy, o.LimitPrice, bar)
+			return price, false, ok
+		}
+		return decimal.Zero, false, false
+	}
+	return decimal.Zero, false, false
+}
+
+// touchLimit fills a buy limit at or below limit, a sell limit at or above it
+func touchLimit(buy bool, limit decimal.Decimal, bar model.KLine) (decimal.Decimal, bool) {
+	if buy {
+		if bar.Open.LessThanOrEqual(limit) {
+			return bar.Open, true
+		}
+		if bar.Low.LessThanOrEqual(limit) {
+			return limit, true
+		}
+		return decimal.Zero, false
+	}
+	if bar.Open.GreaterThanOrEqual(limit) {
+		return bar.Open, true
+	}
+	if bar.High.GreaterThanOrEqual(limit) {
+		return limit, true
+	}
+	return decimal.Zero, false
+}
+
+// touchStop triggers a buy stop at or above stop, a sell stop at or below it
+func touchStop(buy bool, stop decimal.Decimal, bar model.KLine) (decimal.Decimal, bool) {
+	if buy {
+		if bar.Open.GreaterThanOrEqual(stop) {
+			return bar.Open, true
+		}
+		if bar.High.GreaterThanOrEqual(stop) {
+			return stop, true
+		}
+		return decimal.Zero, false
+	}
+	if bar.Open.LessThanOrEqual(stop) {
+		return bar.Open, true
+	}
+	if bar.Low.LessThanOrEqual(stop) {
+		return stop, true
+	}
+	return decimal.Zero, false
+}
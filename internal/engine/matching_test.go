@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"quant-trader/internal/model"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchOrder(t *testing.T) {
+	px := decimal.NewFromFloat
+	b := bar(0, 100, 110, 90, 105)
+
+	tests := []struct {
+		name  string
+		order model.Order
+		price float64
+		taker bool
+		ok    bool
+	}{
+		{"market fills at open", model.Order{OrderRequest: model.OrderRequest{Side: model.SideBuy, Type: model.OrderMarket}}, 100, true, true},
+		{"buy limit touched by low", model.Order{OrderRequest: model.OrderRequest{Side: model.SideBuy, Type: model.OrderLimit, LimitPrice: px(95)}}, 95, false, true},
+		{"buy limit above open fills at open", model.Order{OrderRequest: model.OrderRequest{Side: model.SideBuy, Type: model.OrderLimit, LimitPrice: px(102)}}, 100, false, true},
+		{"buy limit not touched", model.Order{OrderRequest: model.OrderRequest{Side: model.SideBuy, Type: model.OrderLimit, LimitPrice: px(85)}}, 0, false, false},
+		{"sell limit touched by high", model.Order{OrderRequest: model.OrderRequest{Side: model.SideSell, Type: model.OrderLimit, LimitPrice: px(108)}}, 108, false, true},
+		{"sell stop touched by low", model.Order{OrderRequest: model.OrderRequest{Side: model.SideSell, Type: model.OrderStop, StopPrice: px(92)}}, 92, true, true},
+		{"sell stop gapped through at open", model.Order{OrderRequest: model.OrderRequest{Side: model.SideSell, Type: model.OrderStop, StopPrice: px(101)}}, 100, true, true},
+		{"buy stop not touched", model.Order{OrderRequest: model.OrderRequest{Side: model.SideBuy, Type: model.OrderStop, StopPrice: px(111)}}, 0, false, false},
+		{"buy stop-limit triggers within limit", model.Order{OrderRequest: model.OrderRequest{Side: model.SideBuy, Type: model.OrderStopLimit, StopPrice: px(106), LimitPrice: px(107)}}, 106, false, true},
+		{"buy stop-limit triggers beyond limit", model.Order{OrderRequest: model.OrderRequest{Side: model.SideBuy, Type: model.OrderStopLimit, StopPrice: px(106), LimitPrice: px(104)}}, 0, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := tt.order
+			price, taker, ok := matchOrder(&o, b)
+			assert.Equal(t, tt.ok, ok)
+			if ok {
+				assert.True(t, price.Equal(px(tt.price)), price.String())
+				assert.Equal(t, tt.taker, taker)
+			}
+		})
+	}
+}
+
+func TestIntrabarFill(t *testing.T) {
+	px := decimal.NewFromFloat
+	up := bar(0, 100, 110, 90, 105)  // path 100 -> 90 -> 110 -> 105
+	down := bar(0, 100, 110, 90, 95) // path 100 -> 110 -> 90 -> 95
+	stopLimit := func() *model.Order {
+		return &model.Order{OrderRequest: model.OrderRequest{Side: model.SideBuy, Type: model.OrderStopLimit, StopPrice: px(106), LimitPrice: px(104)}}
+	}
+
+	o := stopLimit()
+	_, ok := IntrabarFill{}.Match(o, up)
+	assert.False(t, ok, "never comes back below the limit after triggering")
+	assert.True(t, o.Triggered)
+
+	o = stopLimit()
+	exec, ok := IntrabarFill{}.Match(o, down)
+	assert.True(t, ok, "falls back to the limit after triggering on the way up")
+	assert.True(t, exec.Price.Equal(px(104)), exec.Price.String())
+	assert.InDelta(t, 1.3, exec.Seq, 1e-9)
+
+	takeProfit := &model.Order{OrderRequest: model.OrderRequest{Side: model.SideSell, Type: model.OrderLimit, LimitPrice: px(108)}}
+	stopLoss := &model.Order{OrderRequest: model.OrderRequest{Side: model.SideSell, Type: model.OrderStop, StopPrice: px(92)}}
+	tp, _ := IntrabarFill{}.Match(takeProfit, up)
+	sl, _ := IntrabarFill{}.Match(stopLoss, up)
+	assert.Less(t, sl.Seq, tp.Seq, "the up bar visits its low first")
+	assert.True(t, sl.Taker)
+}
+
+func TestBacktester_IntrabarBracket(t *testing.T) {
+	px := decimal.NewFromFloat
+	strat := &scriptedStrategy{script: map[int][]model.OrderRequest{
+		0: {{Side: model.SideBuy, Type: model.OrderMarket, Qty: px(1)}},
+		1: {
+			{Side: model.SideSell, Type: model.OrderLimit, Qty: px(1), LimitPrice: px(108)},
+			{Side: model.SideSell, Type: model.OrderStop, Qty: px(1), StopPrice: px(92)},
+		},
+	}}
+	tester := newFrictionless(strat)
+	tester.SetFillModel(IntrabarFill{})
+	report := tester.Run([]model.KLine{
+		bar(0, 100, 100, 100, 100),
+		bar(1, 100, 100, 100, 100),
+		bar(2, 100, 110, 90, 105),
+	})
+
+	require.Len(t, report.Orders, 3)
+	assert.Equal(t, model.OrderFilled, report.Orders[2].Status)
+	assert.True(t, report.Orders[2].AvgFillPrice.Equal(px(92)))
+	assert.Equal(t, model.OrderRejected, report.Orders[1].Status)
+}
+
+func TestCloseFill(t *testing.T) {
+	px := decimal.NewFromFloat
+	strat := &scriptedStrategy{script: map[int][]model.OrderRequest{
+		0: {{Side: model.SideBuy, Type: model.OrderMarket, Qty: px(1)}},
+		1: {{Side: model.SideSell, Type: model.OrderLimit, Qty: px(1), LimitPrice: px(108)}},
+	}}
+	tester := newFrictionless(strat)
+	tester.SetFillModel(CloseFill{})
+	report := tester.Run([]model.KLine{
+		bar(0, 100, 100, 100, 101),
+		bar(1, 102, 104, 100, 103),
+		bar(2, 103, 112, 103, 107), // high crosses 108 but the close does not
+		bar(3, 107, 110, 107, 109),
+	})
+
+	require.Len(t, report.Orders, 2)
+	assert.True(t, report.Orders[0].AvgFillPrice.Equal(px(101)), "fills at the close it was submitted on")
+	assert.True(t, report.Orders[0].CreatedAt.Equal(report.Orders[0].UpdatedAt))
+	assert.True(t, report.Orders[1].AvgFillPrice.Equal(px(109)))
+	assert.True(t, report.Orders[1].UpdatedAt.Equal(bar(3, 0, 0, 0, 0).Timestamp))
+}
+
+func TestNewFillModel(t *testing.T) {
+	for _, kind := range []string{"", "next_open", "close", "intrabar"} {
+		_, err := NewFillModel(kind)
+		assert.NoError(t, err, kind)
+	}
+	_, err := NewFillModel("vwap")
+	assert.Error(t, err)
+}
@@ -0,0 +1,492 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand"
+	"quant-trader/internal/metrics"
+	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
+	"quant-trader/internal/strategy"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+// MaxTrials bounds the number of backtests a single optimization may run
+const MaxTrials = 10000
+
+// ParamRange is the set of values one strategy parameter is swept over: either
+// explicit Values, or Min to Max (inclusive) in Step increments
+type ParamRange struct {
+	Name   string    `json:"name"`
+	Min    float64   `json:"min"`
+	Max    float64   `json:"max"`
+	Step   float64   `json:"step"`
+	Values []float64 `json:"values"`
+}
+
+func (r ParamRange) values() ([]float64, error) {
+	if r.Name == "" {
+		return nil, errors.New("parameter name is required")
+	}
+	if len(r.Values) > 0 {
+		return r.Values, nil
+	}
+	if r.Step <= 0 || r.Max < r.Min {
+		return nil, fmt.Errorf("parameter %s needs values, or min <= max and a positive step", r.Name)
+	}
+	n := int(math.Floor((r.Max-r.Min)/r.Step+1e-9)) + 1
+	if n > MaxTrials {
+		return nil, fmt.Errorf("parameter %s has more than %d values", r.Name, MaxTrials)
+	}
+	values := make([]float64, n)
+	for i := range values {
+		// Rounded so that 0.1 steps don't drift into 0.30000000000000004
+		values[i] = math.Round((r.Min+float64(i)*r.Step)*1e9) / 1e9
+	}
+	return values, nil
+}
+
+// schemaRange fills a range given only by name from the bounds its
+// parameter declares in the strategy type's schema: every whole number for
+// integers, ten steps for the rest
+func (o *Optimization) schemaRange(r ParamRange) (ParamRange, error) {
+	if len(r.Values) > 0 || r.Min != 0 || r.Max != 0 || r.Step != 0 {
+		return r, nil
+	}
+	param, ok := o.schemaParam(r.Name)
+	if !ok || param.Min == nil || param.Max == nil {
+		return r, nil
+	}
+	r.Min, r.Max = *param.Min, *param.Max
+	if param.Type == strategy.ParamInt {
+		r.Step = 1
+	} else {
+		r.Step = (r.Max - r.Min) / 10
+	}
+	return r, nil
+}
+
+// checkValues rejects values the strategy type's schema does not allow
+func (o *Optimization) checkValues(name string, values []float64) error {
+	info, ok := strategy.LookupType(o.StrategyType)
+	if !ok || info.Schema == nil {
+		return nil
+	}
+	param, ok := info.Schema.Param(name)
+	if !ok {
+		return fmt.Errorf("%s has no parameter %s", o.StrategyType, name)
+	}
+	for _, v := range values {
+		if err := param.Check(v); err != nil {
+			return fmt.Errorf("invalid range for %s: %w", o.StrategyType, err)
+		}
+	}
+	return nil
+}
+
+func (o *Optimization) schemaParam(name string) (strategy.Param, bool) {
+	info, ok := strategy.LookupType(o.StrategyType)
+	if !ok || info.Schema == nil {
+		return strategy.Param{}, false
+	}
+	return info.Schema.Param(name)
+}
+
+// Objectives score a backtest report for ranking, higher is better
+var Objectives = map[string]func(model.BacktestReport) float64{
+	"sharpe":        func(r model.BacktestReport) float64 { return r.SharpeRatio },
+	"sortino":       func(r model.BacktestReport) float64 { return r.SortinoRatio },
+	"calmar":        func(r model.BacktestReport) float64 { return r.CalmarRatio },
+	"cagr":          func(r model.BacktestReport) float64 { return r.CAGR },
+	"total_return":  func(r model.BacktestReport) float64 { return r.TotalReturn.InexactFloat64() },
+	"profit_factor": func(r model.BacktestReport) float64 { return r.ProfitFactor },
+	"expectancy":    func(r model.BacktestReport) float64 { return r.Expectancy },
+	"win_rate":      func(r model.BacktestReport) float64 { return r.WinRate },
+	"max_drawdown":  func(r model.BacktestReport) float64 { return -r.MaxDrawdown }, // shallower is better
+}
+
+// Optimization sweeps the parameters of a strategy from strategy.NewStrategy.
+// Every trial builds fresh strategies from BaseConfig overlaid with its parameters.
+type Optimization struct {
+	StrategyType   string
+	BaseConfig     map[string]interface{}
+	Params         []ParamRange
+	Method         string // grid (default) or random
+	Samples        int    // random search only; the whole grid if it is smaller
+	Seed           int64  // random search only
+	Objective      string // a key of Objectives, sharpe by default
+	Workers        int    // defaults to the number of CPUs
+	InitialBalance decimal.Decimal
+	// Setup configures every backtester before it runs, e.g. margin and cost models
+	Setup func(*Backtester) error
+	// Progress is called from one goroutine after every finished trial
+	Progress func(done, total int)
+
+	keepReturns bool // keep every trial's per-bar returns, for overfitting diagnostics
+}
+
+// Trial is one parameter set and the metrics of its backtest
+type Trial struct {
+	Rank         int                `json:"rank"`
+	Params       map[string]float64 `json:"params"`
+	Score        float64            `json:"score"`
+	TotalReturn  float64            `json:"total_return"`
+	SharpeRatio  float64            `json:"sharpe_ratio"`
+	SortinoRatio float64            `json:"sortino_ratio"`
+	CalmarRatio  float64            `json:"calmar_ratio"`
+	CAGR         float64            `json:"cagr"`
+	MaxDrawdown  float64            `json:"max_drawdown"`
+	WinRate      float64            `json:"win_rate"`
+	ProfitFactor float64            `json:"profit_factor"`
+	Trades       int                `json:"trades"`
+	Error        string             `json:"error,omitempty"`
+
+	returns []float64
+}
+
+// OptimizationResult holds the trials ranked by objective; failed trials come last
+type OptimizationResult struct {
+	StrategyType string   `json:"strategy_type"`
+	Method       string   `json:"method"`
+	Objective    string   `json:"objective"`
+	Params       []string `json:"params"`
+	Trials       []Trial  `json:"trials"`
+}
+
+// Best returns the top ranked trial, false if every trial failed
+func (r *OptimizationResult) Best() (Trial, bool) {
+	if len(r.Trials) == 0 || r.Trials[0].Error != "" {
+		return Trial{}, false
+	}
+	return r.Trials[0], true
+}
+
+// Heatmap is the best score for every pair of values of two parameters, taken
+// over the remaining parameters
+type Heatmap struct {
+	X       string    `json:"x"`
+	Y       string    `json:"y"`
+	XValues []float64 `json:"x_values"`
+	YValues []float64 `json:"y_values"`
+	// Scores is indexed [y][x]; nil where no trial succeeded
+	Scores [][]*float64 `json:"scores"`
+}
+
+// Heatmap projects the trials onto parameters x and y
+func (r *OptimizationResult) Heatmap(x, y string) (Heatmap, error) {
+	if x == y || !slices.Contains(r.Params, x) || !slices.Contains(r.Params, y) {
+		return Heatmap{}, fmt.Errorf("heatmap needs two different optimized parameters, got %q and %q", x, y)
+	}
+	h := Heatmap{X: x, Y: y}
+	for _, t := range r.Trials {
+		if !slices.Contains(h.XValues, t.Params[x]) {
+			h.XValues = append(h.XValues, t.Params[x])
+		}
+		if !slices.Contains(h.YValues, t.Params[y]) {
+			h.YValues = append(h.YValues, t.Params[y])
+		}
+	}
+	slices.Sort(h.XValues)
+	slices.Sort(h.YValues)
+
+	h.Scores = make([][]*float64, len(h.YValues))
+	for i := range h.Scores {
+		h.Scores[i] = make([]*float64, len(h.XValues))
+	}
+	for _, t := range r.Trials {
+		if t.Error != "" {
+			continue
+		}
+		xi, _ := slices.BinarySearch(h.XValues, t.Params[x])
+		yi, _ := slices.BinarySearch(h.YValues, t.Params[y])
+		if cell := h.Scores[yi][xi]; cell == nil || t.Score > *cell {
+			score := t.Score
+			h.Scores[yi][xi] = &score
+		}
+	}
+	return h, nil
+}
+
+// Validate checks the search space and objective, that the strategy can be
+// built from its first parameter set and that Setup accepts a backtester
+func (o *Optimization) Validate() error {
+	if _, err := o.candidates(); err != nil {
+		return err
+	}
+	if o.Setup != nil {
+		return o.Setup(newBacktester(nil, o.InitialBalance))
+	}
+	return nil
+}
+
+// Optimize runs every candidate parameter set against the same in-memory candles,
+// keyed by symbol, in parallel. It stops early with ctx's error.
+func (o *Optimization) Optimize(ctx context.Context, candles map[string][]model.KLine) (*OptimizationResult, error) {
+	params, err := o.candidates()
+	if err != nil {
+		return nil, err
+	}
+	if len(candles) == 0 {
+		return nil, errors.New("no candles to optimize on")
+	}
+	symbols := slices.Sorted(maps.Keys(candles))
+	score := Objectives[o.objective()]
+
+	workers := o.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	workers = min(workers, len(params))
+
+	type outcome struct {
+		index int
+		trial Trial
+	}
+	jobs := make(chan int)
+	results := make(chan outcome)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results <- outcome{i, o.trial(params[i], symbols, candles, score)}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range params {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Trials already running when ctx is cancelled still finish
+	trials := make([]Trial, len(params))
+	done := 0
+	for r := range results {
+		trials[r.index] = r.trial
+		done++
+		if o.Progress != nil {
+			o.Progress(done, len(params))
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(trials, func(i, j int) bool {
+		if (trials[i].Error == "") != (trials[j].Error == "") {
+			return trials[i].Error == ""
+		}
+		return trials[i].Score > trials[j].Score
+	})
+	for i := range trials {
+		trials[i].Rank = i + 1
+	}
+
+	names := make([]string, len(o.Params))
+	for i, p := range o.Params {
+		names[i] = p.Name
+	}
+	return &OptimizationResult{
+		StrategyType: o.StrategyType,
+		Method:       o.method(),
+		Objective:    o.objective(),
+		Params:       names,
+		Trials:       trials,
+	}, nil
+}
+
+func (o *Optimization) method() string {
+	if o.Method == "" {
+		return "grid"
+	}
+	return o.Method
+}
+
+func (o *Optimization) objective() string {
+	if o.Objective == "" {
+		return "sharpe"
+	}
+	return o.Objective
+}
+
+// candidates enumerates the parameter sets to try
+func (o *Optimization) candidates() ([]map[string]float64, error) {
+	if _, ok := Objectives[o.objective()]; !ok {
+		return nil, fmt.Errorf("unknown objective: %s", o.Objective)
+	}
+	if len(o.Params) == 0 {
+		return nil, errors.New("at least one parameter range is required")
+	}
+
+	axes := make([][]float64, len(o.Params))
+	size := 1
+	for i, p := range o.Params {
+		if slices.ContainsFunc(o.Params[:i], func(q ParamRange) bool { return q.Name == p.Name }) {
+			return nil, fmt.Errorf("parameter %s is listed twice", p.Name)
+		}
+		p, err := o.schemaRange(p)
+		if err != nil {
+			return nil, err
+		}
+		values, err := p.values()
+		if err != nil {
+			return nil, err
+		}
+		if err := o.checkValues(p.Name, values); err != nil {
+			return nil, err
+		}
+		axes[i] = values
+		size = min(size*len(values), MaxTrials+1)
+	}
+
+	var indices [][]int
+	switch o.method() {
+	case "grid":
+		if size > MaxTrials {
+			return nil, fmt.Errorf("grid has more than %d combinations, use random search", MaxTrials)
+		}
+		indices = gridIndices(axes)
+	case "random":
+		if o.Samples <= 0 || o.Samples > MaxTrials {
+			return nil, fmt.Errorf("random search needs between 1 and %d samples", MaxTrials)
+		}
+		if o.Samples >= size {
+			indices = gridIndices(axes)
+		} else {
+			indices = randomIndices(axes, o.Samples, o.Seed)
+		}
+	default:
+		return nil, fmt.Errorf("unknown optimization method: %s", o.Method)
+	}
+
+	params := make([]map[string]float64, len(indices))
+	for i, idx := range indices {
+		params[i] = make(map[string]float64, len(axes))
+		for j, k := range idx {
+			params[i][o.Params[j].Name] = axes[j][k]
+		}
+	}
+	strat, err := buildStrategy(o.StrategyType, o.config(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	closeStrategy(strat)
+	return params, nil
+}
+
+// gridIndices is the cartesian product of the axes, last axis varying fastest
+func gridIndices(axes [][]float64) [][]int {
+	out := [][]int{{}}
+	for _, axis := range axes {
+		next := make([][]int, 0, len(out)*len(axis))
+		for _, prefix := range out {
+			for k := range axis {
+				next = append(next, append(slices.Clip(prefix), k))
+			}
+		}
+		out = next
+	}
+	return out
+}
+
+// randomIndices draws n distinct grid points, reproducibly for a given seed
+func randomIndices(axes [][]float64, n int, seed int64) [][]int {
+	rng := rand.New(rand.NewSource(seed))
+	seen := make(map[string]bool, n)
+	out := make([][]int, 0, n)
+	for len(out) < n {
+		idx := make([]int, len(axes))
+		key := make([]string, len(axes))
+		for j, axis := range axes {
+			idx[j] = rng.Intn(len(axis))
+			key[j] = strconv.Itoa(idx[j])
+		}
+		if k := strings.Join(key, ","); !seen[k] {
+			seen[k] = true
+			out = append(out, idx)
+		}
+	}
+	return out
+}
+
+func (o *Optimization) config(params map[string]float64) map[string]interface{} {
+	config := make(map[string]interface{}, len(o.BaseConfig)+len(params))
+	maps.Copy(config, o.BaseConfig)
+	for name, v := range params {
+		config[name] = v
+	}
+	return config
+}
+
+// trial backtests one parameter set; candles are only read, so trials share them
+func (o *Optimization) trial(params map[string]float64, symbols []string, candles map[string][]model.KLine, score func(model.BacktestReport) float64) Trial {
+	t := Trial{Params: params}
+	report, err := o.backtest(params, symbols, candles, o.InitialBalance)
+	if err != nil {
+		t.Error = err.Error()
+		return t
+	}
+
+	t.Score = score(report)
+	if math.IsNaN(t.Score) || math.IsInf(t.Score, 0) {
+		t.Score = 0
+	}
+	t.TotalReturn = report.TotalReturn.InexactFloat64()
+	t.SharpeRatio = report.SharpeRatio
+	t.SortinoRatio = report.SortinoRatio
+	t.CalmarRatio = report.CalmarRatio
+	t.CAGR = report.CAGR
+	t.MaxDrawdown = report.MaxDrawdown
+	t.WinRate = report.WinRate
+	t.ProfitFactor = report.ProfitFactor
+	t.Trades = report.TotalTrades
+	if o.keepReturns {
+		equity := []float64{report.InitialBalance.InexactFloat64()}
+		for _, p := range report.EquityCurve {
+			equity = append(equity, p.Equity.InexactFloat64())
+		}
+		t.returns = metrics.Returns(equity)
+	}
+	return t
+}
+
+// backtest runs one parameter set from the given starting balance
+func (o *Optimization) backtest(params map[string]float64, symbols []string, candles map[string][]model.KLine, balance decimal.Decimal) (model.BacktestReport, error) {
+	tester, release, err := newStrategyTester(o.StrategyType, o.config(params), symbols, balance)
+	if err != nil {
+		return model.BacktestReport{}, err
+	}
+	defer release()
+	if o.Setup != nil {
+		if err := o.Setup(tester); err != nil {
+			return model.BacktestReport{}, err
+		}
+	}
+
+	sources := make([]CandleSource, len(symbols))
+	for i, symbol := range symbols {
+		sources[i] = storage.NewSliceKlineIterator(candles[symbol])
+	}
+	return tester.RunSource(MergeSources(sources...))
+}
@@ -0,0 +1,196 @@
+package engine
+
+import (
+	"context"
+	"math"
+	"quant-trader/internal/model"
+	"quant-trader/internal/strategy"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParamRange_Values(t *testing.T) {
+	values, err := ParamRange{Name: "x", Min: 0.1, Max: 0.5, Step: 0.1}.values()
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.1, 0.2, 0.3, 0.4, 0.5}, values)
+
+	values, err = ParamRange{Name: "x", Values: []float64{3, 1}}.values()
+	require.NoError(t, err)
+	assert.Equal(t, []float64{3, 1}, values)
+
+	_, err = ParamRange{Name: "x", Min: 5, Max: 1, Step: 1}.values()
+	assert.Error(t, err)
+	_, err = ParamRange{Min: 1, Max: 5, Step: 1}.values()
+	assert.Error(t, err)
+}
+
+func maCrossSweep() *Optimization {
+	return &Optimization{
+		StrategyType: "ma_cross",
+		Params: []ParamRange{
+			{Name: "short_period", Min: 2, Max: 4, Step: 1},
+			{Name: "long_period", Values: []float64{6, 8}},
+		},
+		Objective:      "total_return",
+		Workers:        4,
+		InitialBalance: decimal.NewFromInt(10000),
+	}
+}
+
+// waveCandles oscillates so that different MA lengths trade differently
+func waveCandles(n int) []model.KLine {
+	closes := make([]float64, n)
+	for i := range closes {
+		closes[i] = 100 + 10*math.Sin(float64(i)/4) + float64(i)/5
+	}
+	return symbolBars("BTCUSDT", closes...)
+}
+
+func TestOptimization_Grid(t *testing.T) {
+	opt := maCrossSweep()
+	var progress []int
+	opt.Progress = func(done, total int) {
+		assert.Equal(t, 6, total)
+		progress = append(progress, done)
+	}
+
+	result, err := opt.Optimize(context.Background(), map[string][]model.KLine{"BTCUSDT": waveCandles(120)})
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, progress)
+	assert.Equal(t, "grid", result.Method)
+	assert.Equal(t, []string{"short_period", "long_period"}, result.Params)
+	require.Len(t, result.Trials, 6)
+	for i, trial := range result.Trials {
+		assert.Empty(t, trial.Error)
+		assert.Equal(t, i+1, trial.Rank)
+		assert.Equal(t, trial.TotalReturn, trial.Score)
+		if i > 0 {
+			assert.GreaterOrEqual(t, result.Trials[i-1].Score, trial.Score)
+		}
+	}
+
+	// The best trial reproduces with a plain backtest
+	best, ok := result.Best()
+	require.True(t, ok)
+	strat, err := strategy.NewStrategy("ma_cross", map[string]interface{}{
+		"short_period": best.Params["short_period"],
+		"long_period":  best.Params["long_period"],
+	})
+	require.NoError(t, err)
+	report := NewMultiSymbolBacktester(map[string]strategy.Strategy{"BTCUSDT": strat}, opt.InitialBalance).Run(waveCandles(120))
+	assert.Equal(t, report.TotalReturn.InexactFloat64(), best.TotalReturn)
+}
+
+func TestOptimization_Random(t *testing.T) {
+	opt := maCrossSweep()
+	opt.Params[0] = ParamRange{Name: "short_period", Min: 2, Max: 5, Step: 1}
+	opt.Params[1] = ParamRange{Name: "long_period", Min: 6, Max: 20, Step: 1}
+	opt.Method = "random"
+	opt.Samples = 10
+	opt.Seed = 7
+
+	first, err := opt.candidates()
+	require.NoError(t, err)
+	require.Len(t, first, 10)
+	seen := map[[2]float64]bool{}
+	for _, p := range first {
+		key := [2]float64{p["short_period"], p["long_period"]}
+		assert.False(t, seen[key], "duplicate sample %v", key)
+		seen[key] = true
+	}
+
+	again, err := opt.candidates()
+	require.NoError(t, err)
+	assert.Equal(t, first, again)
+
+	// More samples than grid points runs the whole grid
+	opt.Samples = 1000
+	all, err := opt.candidates()
+	require.NoError(t, err)
+	assert.Len(t, all, 60)
+}
+
+func TestOptimization_Validate(t *testing.T) {
+	cases := map[string]func(o *Optimization){
+		"objective":  func(o *Optimization) { o.Objective = "luck" },
+		"method":     func(o *Optimization) { o.Method = "genetic" },
+		"samples":    func(o *Optimization) { o.Method = "random" },
+		"no params":  func(o *Optimization) { o.Params = nil },
+		"duplicate":  func(o *Optimization) { o.Params[1].Name = "short_period" },
+		"strategy":   func(o *Optimization) { o.StrategyType = "unknown" },
+		"incomplete": func(o *Optimization) { o.Params = o.Params[:1] },
+		"too large": func(o *Optimization) {
+			o.Params[0] = ParamRange{Name: "short_period", Min: 1, Max: 200, Step: 1}
+			o.Params[1] = ParamRange{Name: "long_period", Min: 1, Max: 200, Step: 1}
+		},
+	}
+	assert.NoError(t, maCrossSweep().Validate())
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			opt := maCrossSweep()
+			mutate(opt)
+			assert.Error(t, opt.Validate())
+		})
+	}
+}
+
+func TestOptimization_Schema(t *testing.T) {
+	// A range given by name sweeps the schema's bounds
+	opt := &Optimization{
+		StrategyType: "rsi_reversion",
+		Params:       []ParamRange{{Name: "period"}, {Name: "oversold"}},
+		Method:       "random",
+		Samples:      20,
+	}
+	candidates, err := opt.candidates()
+	require.NoError(t, err)
+	for _, p := range candidates {
+		assert.GreaterOrEqual(t, p["period"], 2.0)
+		assert.LessOrEqual(t, p["period"], 200.0)
+		assert.Equal(t, math.Trunc(p["period"]), p["period"])
+	}
+
+	opt = maCrossSweep()
+	opt.Params[0] = ParamRange{Name: "short_period", Values: []float64{2.5}}
+	assert.ErrorContains(t, opt.Validate(), "short_period must be a whole number")
+	opt.Params[0] = ParamRange{Name: "medium_period", Values: []float64{5}}
+	assert.ErrorContains(t, opt.Validate(), "ma_cross has no parameter medium_period")
+}
+
+func TestOptimization_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := maCrossSweep().Optimize(ctx, map[string][]model.KLine{"BTCUSDT": waveCandles(50)})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestOptimizationResult_Heatmap(t *testing.T) {
+	result := &OptimizationResult{
+		Params: []string{"a", "b", "c"},
+		Trials: []Trial{
+			{Params: map[string]float64{"a": 2, "b": 10, "c": 0}, Score: 3},
+			{Params: map[string]float64{"a": 2, "b": 10, "c": 1}, Score: 1},
+			{Params: map[string]float64{"a": 1, "b": 10, "c": 0}, Score: 2},
+			{Params: map[string]float64{"a": 1, "b": 20, "c": 0}, Error: "boom"},
+		},
+	}
+
+	h, err := result.Heatmap("a", "b")
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1, 2}, h.XValues)
+	assert.Equal(t, []float64{10, 20}, h.YValues)
+	require.NotNil(t, h.Scores[0][0])
+	assert.Equal(t, 2.0, *h.Scores[0][0])
+	assert.Equal(t, 3.0, *h.Scores[0][1]) // best over c
+	assert.Nil(t, h.Scores[1][0])
+	assert.Nil(t, h.Scores[1][1])
+
+	_, err = result.Heatmap("a", "a")
+	assert.Error(t, err)
+	_, err = result.Heatmap("a", "d")
+	assert.Error(t, err)
+}
@@ -0,0 +1,229 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"quant-trader/internal/model"
+	"quant-trader/internal/paper"
+	"quant-trader/internal/risk"
+	"quant-trader/internal/storage"
+	"quant-trader/internal/strategy"
+	"slices"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// DefaultSizingATRPeriod is the ATR period of atr sizing that does not set one
+const DefaultSizingATRPeriod = 14
+
+// PaperBridge turns the signals of paper deployments into paper orders: buys
+// are sized by the deployment's SizingPolicy and checked against the risk
+// limits, sells close what the deployment holds. What a deployment holds is
+// what its filled orders bought and sold, and it places no order while one is
+// open. What came of each signal is recorded with the order it placed.
+type PaperBridge struct {
+	market     storage.MarketDataRepository
+	paper      storage.PaperRepository
+	executions storage.ExecutionRepository
+	orders     PaperPlacer
+	risk       *risk.RiskManager
+	logger     *zap.Logger
+}
+
+func NewPaperBridge(store *storage.Store, orders PaperPlacer, logger *zap.Logger) *PaperBridge {
+	return &PaperBridge{
+		market:     store.Market,
+		paper:      store.Paper,
+		executions: store.Executions,
+		orders:     orders,
+		risk:       risk.NewRiskManager(store.Paper, logger),
+		logger:     logger,
+	}
+}
+
+// CheckSizing validates the sizing policy of a deployment
+func CheckSizing(p model.SizingPolicy) error {
+	one := decimal.NewFromInt(1)
+	switch p.Mode {
+	case "", model.SizingFixedNotional:
+		if p.Notional.IsNegative() {
+			return errors.New("sizing notional must not be negative")
+		}
+	case model.SizingEquityPct:
+		if !p.EquityPct.IsPositive() || p.EquityPct.GreaterThan(one) {
+			return errors.New("sizing equity_pct must be above 0 and at most 1")
+		}
+	case model.SizingATR:
+		if !p.RiskPct.IsPositive() || p.RiskPct.GreaterThan(one) {
+			return errors.New("sizing risk_pct must be above 0 and at most 1")
+		}
+		if p.ATRPeriod != 0 && (p.ATRPeriod < 2 || p.ATRPeriod > 200) {
+			return errors.New("sizing atr_period must be between 2 and 200")
+		}
+	default:
+		return fmt.Errorf("unknown sizing mode %q", p.Mode)
+	}
+	return nil
+}
+
+// Execute trades sig for d and returns what the filled orders of d hold after
+func (b *PaperBridge) Execute(ctx context.Context, d model.Deployment, sig Signal) (decimal.Decimal, error) {
+	e := model.SignalExecution{
+		DeploymentID: d.ID,
+		UserID:       d.UserID,
+		Strategy:     sig.Strategy,
+		Symbol:       sig.Symbol,
+		Action:       sig.Action,
+		SignalPrice:  sig.Price,
+		SignalTime:   sig.Time,
+		Sizing:       d.Sizing.Mode,
+	}
+	if e.Sizing == "" {
+		e.Sizing = model.SizingFixedNotional
+	}
+
+	held, err := b.execute(ctx, d, sig, &e)
+	if err != nil {
+		e.Status, e.Reason = model.ExecutionFailed, err.Error()
+	}
+	if _, recordErr := b.executions.RecordExecution(ctx, e); recordErr != nil {
+		b.logger.Error("failed to record signal execution", zap.Int64("deployment_id", d.ID), zap.Error(recordErr))
+	} else if e.Status == model.ExecutionPlaced {
+		// An order filled right away counts now, others once they fill
+		if after, _, err := b.executions.DeploymentFills(ctx, d.ID); err == nil {
+			held = after
+		}
+	}
+	return held, err
+}
+
+// execute fills in e with what it does and returns what d held before it;
+// the error is a failure to trade
+func (b *PaperBridge) execute(ctx context.Context, d model.Deployment, sig Signal, e *model.SignalExecution) (decimal.Decimal, error) {
+	held, open, err := b.executions.DeploymentFills(ctx, d.ID)
+	if err != nil {
+		return held, fmt.Errorf("failed to get deployment fills: %w", err)
+	}
+	if open > 0 {
+		e.Status, e.Reason = model.ExecutionSkipped, "order still open"
+		return held, nil
+	}
+
+	order := paper.Order{UserID: d.UserID, Symbol: sig.Symbol, Type: "market", Price: sig.Price}
+	switch {
+	case sig.Action == string(strategy.ActionBuy) && !held.IsPositive():
+		qty, err := b.size(ctx, d, sig)
+		if err != nil {
+			return held, err
+		}
+		e.Qty = qty
+		if !qty.IsPositive() {
+			e.Status, e.Reason = model.ExecutionSkipped, "sized to nothing"
+			return held, nil
+		}
+		if err := b.risk.PreTradeCheck(ctx, d.UserID, sig.Symbol, "buy", qty, sig.Price); err != nil {
+			e.Status, e.Reason = model.ExecutionRejected, err.Error()
+			return held, nil
+		}
+		order.Side, order.Qty = "buy", qty
+	case sig.Action == string(strategy.ActionSell) && held.IsPositive():
+		// Closing only lowers exposure, so it skips the risk limits
+		order.Side, order.Qty = "sell", held
+		e.Qty = held
+	case sig.Action == string(strategy.ActionBuy):
+		e.Status, e.Reason = model.ExecutionSkipped, "already holding"
+		return held, nil
+	default:
+		e.Status, e.Reason = model.ExecutionSkipped, "nothing to sell"
+		return held, nil
+	}
+
+	id, err := b.orders.PlaceOrder(ctx, order)
+	if err != nil {
+		return held, err
+	}
+	e.Status, e.OrderID = model.ExecutionPlaced, id
+	return held, nil
+}
+
+// size is the quantity a buy of d at sig.Price gets, never worth more than
+// its Capital
+func (b *PaperBridge) size(ctx context.Context, d model.Deployment, sig Signal) (decimal.Decimal, error) {
+	if !sig.Price.IsPositive() {
+		return decimal.Zero, fmt.Errorf("cannot size a buy at price %s", sig.Price)
+	}
+	var notional decimal.Decimal
+	switch p := d.Sizing; p.Mode {
+	case "", model.SizingFixedNotional:
+		notional = p.Notional
+		if notional.IsZero() {
+			notional = d.Capital
+		}
+	case model.SizingEquityPct:
+		equity, err := b.equity(ctx, d.UserID, sig)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		notional = equity.Mul(p.EquityPct)
+	case model.SizingATR:
+		period := p.ATRPeriod
+		if period == 0 {
+			period = DefaultSizingATRPeriod
+		}
+		atr, err := b.atr(ctx, d, sig, period)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		equity, err := b.equity(ctx, d.UserID, sig)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		// One ATR against the position loses RiskPct of equity
+		notional = equity.Mul(p.RiskPct).Div(atr).Mul(sig.Price)
+	default:
+		return decimal.Zero, fmt.Errorf("unknown sizing mode %q", p.Mode)
+	}
+	if d.Capital.IsPositive() {
+		notional = decimal.Min(notional, d.Capital)
+	}
+	return notional.Div(sig.Price).Round(8), nil
+}
+
+// equity is the paper cash of userID plus its positions, sig.Symbol at the
+// signal price and the others at their latest close
+func (b *PaperBridge) equity(ctx context.Context, userID int64, sig Signal) (decimal.Decimal, error) {
+	equity, err := b.paper.GetBalance(ctx, userID)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to get paper balance: %w", err)
+	}
+	positions, err := b.paper.ListPositions(ctx, userID)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to list paper positions: %w", err)
+	}
+	for _, p := range positions {
+		price := p.AvgPrice
+		if p.Symbol == sig.Symbol {
+			price = sig.Price
+		} else if last, err := b.market.LatestClose(ctx, p.Symbol); err == nil {
+			price = last
+		}
+		equity = equity.Add(p.Qty.Mul(price))
+	}
+	return equity, nil
+}
+
+// atr is the ATR of the stored candles of d up to the signal
+func (b *PaperBridge) atr(ctx context.Context, d model.Deployment, sig Signal, period int) (decimal.Decimal, error) {
+	candles, err := b.market.QueryKlines(ctx, storage.KlineQuery{Symbol: sig.Symbol, Period: d.Period, End: sig.Time, Limit: period + 1, Descending: true})
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to load candles for ATR: %w", err)
+	}
+	slices.Reverse(candles)
+	atr, ok := strategy.NewIndicators(candles).ATR(period)
+	if !ok || atr <= 0 {
+		return decimal.Zero, fmt.Errorf("not enough %s candles for a %d period ATR", d.Period, period)
+	}
+	return decimal.NewFromFloat(atr), nil
+}
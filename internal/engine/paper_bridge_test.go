@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"context"
+	"quant-trader/internal/model"
+	"quant-trader/internal/paper"
+	"quant-trader/internal/storage"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newPaperBridge gives user 1 a paper account of 100000 and stores 20 candles
+// of BTCUSDT at 100 with a range of 2, so their ATR is 2
+func newPaperBridge(t *testing.T) (*PaperBridge, *storage.Store) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	require.NoError(t, store.Paper.CreateAccount(ctx, 1, decimal.NewFromInt(100000)))
+	var bars []model.KLine
+	for i := 0; i < 20; i++ {
+		bars = append(bars, bar(i, 100, 101, 99, 100))
+	}
+	require.NoError(t, store.Market.UpsertKlines(ctx, bars))
+	return NewPaperBridge(store, paper.NewPaperEngine(store.Paper, nil, zap.NewNop()), zap.NewNop()), store
+}
+
+func buySignal() Signal {
+	return Signal{Strategy: "MA_Cross", Symbol: "BTCUSDT", Period: "1m", Action: "buy", Price: decimal.NewFromInt(100), Time: time.Date(2024, 1, 1, 0, 20, 0, 0, time.UTC)}
+}
+
+func TestPaperBridge_Sizing(t *testing.T) {
+	for name, tc := range map[string]struct {
+		capital int64
+		sizing  model.SizingPolicy
+		want    string
+	}{
+		"notional":           {1000, model.SizingPolicy{Mode: model.SizingFixedNotional, Notional: decimal.NewFromInt(500)}, "5"},
+		"capital":            {1000, model.SizingPolicy{}, "10"},
+		"equity pct":         {1000, model.SizingPolicy{Mode: model.SizingEquityPct, EquityPct: decimal.NewFromFloat(0.005)}, "5"},
+		"equity pct, capped": {1000, model.SizingPolicy{Mode: model.SizingEquityPct, EquityPct: decimal.NewFromFloat(0.5)}, "10"},
+		// 0.01% of 100000 risked per ATR of 2
+		"atr": {1000, model.SizingPolicy{Mode: model.SizingATR, RiskPct: decimal.NewFromFloat(0.0001), ATRPeriod: 5}, "5"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, CheckSizing(tc.sizing))
+			b, _ := newPaperBridge(t)
+			d := model.Deployment{ID: 1, UserID: 1, Period: "1m", Capital: decimal.NewFromInt(tc.capital), Sizing: tc.sizing}
+			qty, err := b.size(context.Background(), d, buySignal())
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, qty.String())
+		})
+	}
+
+	for name, p := range map[string]model.SizingPolicy{
+		"mode":       {Mode: "kelly"},
+		"notional":   {Notional: decimal.NewFromInt(-1)},
+		"equity pct": {Mode: model.SizingEquityPct, EquityPct: decimal.NewFromFloat(1.5)},
+		"risk pct":   {Mode: model.SizingATR},
+		"atr period": {Mode: model.SizingATR, RiskPct: decimal.NewFromFloat(0.01), ATRPeriod: 1},
+	} {
+		assert.Error(t, CheckSizing(p), name)
+	}
+}
+
+func TestPaperBridge_Execute(t *testing.T) {
+	ctx := context.Background()
+	b, store := newPaperBridge(t)
+	d := model.Deployment{ID: 1, UserID: 1, Period: "1m", Capital: decimal.NewFromInt(1000)}
+
+	// Over the 10% limit of the risk manager
+	big := d
+	big.Capital = decimal.NewFromInt(20000)
+	held, err := b.Execute(ctx, big, buySignal())
+	require.NoError(t, err)
+	assert.True(t, held.IsZero())
+
+	sell := buySignal()
+	sell.Action = "sell"
+	held, err = b.Execute(ctx, d, sell)
+	require.NoError(t, err)
+	assert.True(t, held.IsZero())
+
+	// Nothing is held until the order fills, and nothing traded meanwhile
+	held, err = b.Execute(ctx, d, buySignal())
+	require.NoError(t, err)
+	assert.True(t, held.IsZero())
+	held, err = b.Execute(ctx, d, sell)
+	require.NoError(t, err)
+	assert.True(t, held.IsZero())
+
+	list, err := store.Executions.ListExecutions(ctx, 1, d.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, list, 4)
+	placed := list[1]
+	require.Equal(t, model.ExecutionPlaced, placed.Status)
+	assert.Equal(t, "open", placed.OrderStatus)
+	require.NoError(t, store.Paper.ApplyFills(ctx, []model.PaperOrder{{ID: placed.OrderID, UserID: 1, Symbol: "BTCUSDT", Side: "buy", Qty: placed.Qty, FilledPrice: decimal.NewFromInt(101)}}))
+
+	held, err = b.Execute(ctx, d, buySignal())
+	require.NoError(t, err)
+	assert.Equal(t, "10", held.String())
+	held, err = b.Execute(ctx, d, sell)
+	require.NoError(t, err)
+	assert.Equal(t, "10", held.String(), "until the sell fills")
+
+	// Not enough candles for the ATR before the first one
+	early := buySignal()
+	early.Time = bar(0, 0, 0, 0, 0).Timestamp
+	atr := d
+	atr.ID = 2
+	atr.Sizing = model.SizingPolicy{Mode: model.SizingATR, RiskPct: decimal.NewFromFloat(0.01)}
+	_, err = b.Execute(ctx, atr, early)
+	assert.ErrorContains(t, err, "not enough 1m candles")
+
+	// The fill of the order shows up on the signal that placed it
+	list, err = store.Executions.ListExecutions(ctx, 1, d.ID, 0)
+	require.NoError(t, err)
+	var statuses []model.ExecutionStatus
+	for _, e := range list {
+		statuses = append(statuses, e.Status)
+	}
+	assert.Equal(t, []model.ExecutionStatus{model.ExecutionPlaced, model.ExecutionSkipped, model.ExecutionSkipped, model.ExecutionPlaced, model.ExecutionSkipped, model.ExecutionRejected}, statuses)
+	assert.Equal(t, "sell", list[0].Action)
+	assert.Equal(t, "10", list[0].Qty.String())
+	assert.Equal(t, "already holding", list[1].Reason)
+	assert.Equal(t, "order still open", list[2].Reason)
+	assert.Equal(t, "filled", list[3].OrderStatus)
+	assert.Equal(t, "101", list[3].FilledPrice.String())
+	assert.Equal(t, model.SizingFixedNotional, list[3].Sizing)
+	assert.Equal(t, "nothing to sell", list[4].Reason)
+	assert.Contains(t, list[5].Reason, "10% of account balance")
+}
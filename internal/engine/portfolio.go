@@ -0,0 +1,262 @@
+package engine
+
+import (
+	"fmt"
+	"maps"
+	"quant-trader/internal/metrics"
+	"quant-trader/internal/model"
+	"quant-trader/internal/strategy"
+	"slices"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Slice is the set of bars sharing one timestamp across the portfolio's symbols
+type Slice struct {
+	Time time.Time
+	// Bars closed at Time, in the order of the merged sources; symbols without a bar are absent
+	Bars []model.KLine
+	// Latest is the most recent bar of every symbol seen so far; read only
+	Latest map[string]model.KLine
+}
+
+// Bar returns the bar of symbol at Time, if there is one
+func (s Slice) Bar(symbol string) (model.KLine, bool) {
+	for _, k := range s.Bars {
+		if k.Symbol == symbol {
+			return k, true
+		}
+	}
+	return model.KLine{}, false
+}
+
+// PortfolioStrategy sees every symbol of a multi-symbol backtest at once
+type PortfolioStrategy interface {
+	Name() string
+	// OnSlice is called after the orders of all bars in slice have been matched
+	OnSlice(slice Slice, broker Broker)
+}
+
+// NewPortfolioBacktester runs a strategy over several symbols sharing one account
+func NewPortfolioBacktester(strat PortfolioStrategy, initialBalance decimal.Decimal) *Backtester {
+	return newBacktester(strat, initialBalance)
+}
+
+// NewMultiSymbolBacktester runs one action-based strategy per symbol on a shared
+// account. Each symbol opens with at most an equal share of equity.
+func NewMultiSymbolBacktester(strats map[string]strategy.Strategy, initialBalance decimal.Decimal) *Backtester {
+	return NewOrderBacktester(&actionStrategy{strats: strats}, initialBalance)
+}
+
+// barStrategy adapts an OrderStrategy to PortfolioStrategy, one bar at a time
+type barStrategy struct {
+	OrderStrategy
+}
+
+func (s barStrategy) OnSlice(slice Slice, broker Broker) {
+	for _, bar := range slice.Bars {
+		s.OnBar(bar, broker)
+	}
+}
+
+// MergedSource merges per-symbol candle streams into one stream in timestamp
+// order. Bars with equal timestamps keep the order of the sources.
+type MergedSource struct {
+	sources []CandleSource
+	heads   []model.KLine
+	alive   []bool
+	started bool
+	cur     model.KLine
+	err     error
+}
+
+// MergeSources creates a MergedSource; each source must already be in time order
+func MergeSources(sources ...CandleSource) *MergedSource {
+	return &MergedSource{
+		sources: sources,
+		heads:   make([]model.KLine, len(sources)),
+		alive:   make([]bool, len(sources)),
+	}
+}
+
+func (m *MergedSource) Next() bool {
+	if m.err != nil {
+		return false
+	}
+	if !m.started {
+		m.started = true
+		for i := range m.sources {
+			if !m.advance(i) {
+				return false
+			}
+		}
+	}
+
+	next := -1
+	for i, ok := range m.alive {
+		if ok && (next < 0 || m.heads[i].Timestamp.Before(m.heads[next].Timestamp)) {
+			next = i
+		}
+	}
+	if next < 0 {
+		return false
+	}
+	m.cur = m.heads[next]
+	return m.advance(next)
+}
+
+// advance loads the next head of source i; it reports false only on an error
+func (m *MergedSource) advance(i int) bool {
+	if m.sources[i].Next() {
+		m.heads[i] = m.sources[i].KLine()
+		m.alive[i] = true
+		return true
+	}
+	m.alive[i] = false
+	if err := m.sources[i].Err(); err != nil {
+		m.err = err
+		return false
+	}
+	return true
+}
+
+func (m *MergedSource) KLine() model.KLine { return m.cur }
+
+// Err returns the first error of any source
+func (m *MergedSource) Err() error { return m.err }
+
+// sliceSource groups a time-ordered candle stream into slices of equal timestamps
+type sliceSource struct {
+	src    CandleSource
+	next   model.KLine
+	peeked bool
+	slice  Slice
+	err    error
+}
+
+func newSliceSource(src CandleSource) *sliceSource {
+	return &sliceSource{src: src, slice: Slice{Latest: make(map[string]model.KLine)}}
+}
+
+func (s *sliceSource) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	if !s.peeked {
+		if !s.src.Next() {
+			return false
+		}
+		s.next = s.src.KLine()
+	}
+	s.peeked = false
+
+	if !s.slice.Time.IsZero() && !s.next.Timestamp.After(s.slice.Time) {
+		s.err = fmt.Errorf("candle for %s at %s is out of time order", s.next.Symbol, s.next.Timestamp.UTC().Format(time.RFC3339))
+		return false
+	}
+	s.slice.Time = s.next.Timestamp
+	s.slice.Bars = []model.KLine{s.next}
+	for s.src.Next() {
+		k := s.src.KLine()
+		if !k.Timestamp.Equal(s.slice.Time) {
+			s.next, s.peeked = k, true
+			break
+		}
+		if _, dup := s.slice.Bar(k.Symbol); dup {
+			s.err = fmt.Errorf("duplicate candle for %s at %s", k.Symbol, k.Timestamp.UTC().Format(time.RFC3339))
+			return false
+		}
+		s.slice.Bars = append(s.slice.Bars, k)
+	}
+	for _, k := range s.slice.Bars {
+		s.slice.Latest[k.Symbol] = k
+	}
+	return true
+}
+
+func (s *sliceSource) Slice() Slice { return s.slice }
+
+func (s *sliceSource) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.src.Err()
+}
+
+// trackSymbolReturn records the close-to-close return of bar's symbol for correlation
+func (b *Backtester) trackSymbolReturn(bar model.KLine) {
+	prev, seen := b.lastPrices[bar.Symbol]
+	if !seen {
+		b.symbols = append(b.symbols, bar.Symbol)
+		b.symbolReturns[bar.Symbol] = make(map[int64]float64)
+		return
+	}
+	if prev.IsPositive() {
+		ret, _ := bar.Close.Div(prev).Sub(decimal.NewFromInt(1)).Float64()
+		b.symbolReturns[bar.Symbol][bar.Timestamp.UnixNano()] = ret
+	}
+}
+
+// attribution splits the final PnL by symbol
+func (b *Backtester) attribution(initialBalance decimal.Decimal) []model.SymbolAttribution {
+	index := make(map[string]int, len(b.symbols))
+	result := make([]model.SymbolAttribution, len(b.symbols))
+	for i, symbol := range b.symbols {
+		index[symbol] = i
+		result[i].Symbol = symbol
+		if p, ok := b.positions[symbol]; ok {
+			result[i].Funding = p.Funding
+		}
+	}
+	for _, t := range b.trades {
+		i, ok := index[t.Symbol]
+		if !ok {
+			continue
+		}
+		a := &result[i]
+		a.Trades++
+		a.Fees = a.Fees.Add(t.Fee)
+		if t.Closing {
+			a.RealizedPnL = a.RealizedPnL.Add(t.PnL)
+		}
+	}
+	for i := range result {
+		a := &result[i]
+		a.NetPnL = a.RealizedPnL.Sub(a.Funding)
+		if initialBalance.IsPositive() {
+			a.Contribution, _ = a.NetPnL.Div(initialBalance).Float64()
+		}
+	}
+	return result
+}
+
+// correlation is the Pearson correlation matrix of the symbols' bar returns,
+// each pair aligned on the timestamps where both have a return. It is nil for a
+// single symbol.
+func (b *Backtester) correlation() [][]float64 {
+	n := len(b.symbols)
+	if n < 2 {
+		return nil
+	}
+	matrix := make([][]float64, n)
+	for i := range matrix {
+		matrix[i] = make([]float64, n)
+		matrix[i][i] = 1
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			ri, rj := b.symbolReturns[b.symbols[i]], b.symbolReturns[b.symbols[j]]
+			var xs, ys []float64
+			for _, ts := range slices.Sorted(maps.Keys(ri)) {
+				if y, ok := rj[ts]; ok {
+					xs = append(xs, ri[ts])
+					ys = append(ys, y)
+				}
+			}
+			c := metrics.Correlation(xs, ys)
+			matrix[i][j], matrix[j][i] = c, c
+		}
+	}
+	return matrix
+}
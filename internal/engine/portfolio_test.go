@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"errors"
+	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
+	"quant-trader/internal/strategy"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func symbolBars(symbol string, closes ...float64) []model.KLine {
+	bars := make([]model.KLine, len(closes))
+	for i, c := range closes {
+		bars[i] = bar(i, c, c, c, c)
+		bars[i].Symbol = symbol
+	}
+	return bars
+}
+
+// failingSource yields its candles and then fails
+type failingSource struct {
+	*storage.SliceKlineIterator
+	err error
+}
+
+func (s failingSource) Err() error { return s.err }
+
+func TestMergeSources(t *testing.T) {
+	btc := symbolBars("BTCUSDT", 1, 2, 3)
+	eth := symbolBars("ETHUSDT", 10, 20)[1:] // starts one bar later
+
+	merged := MergeSources(storage.NewSliceKlineIterator(btc), storage.NewSliceKlineIterator(eth))
+	var got []string
+	for merged.Next() {
+		k := merged.KLine()
+		got = append(got, k.Symbol+"@"+k.Close.String())
+	}
+	require.NoError(t, merged.Err())
+	assert.Equal(t, []string{"BTCUSDT@1", "BTCUSDT@2", "ETHUSDT@20", "BTCUSDT@3"}, got)
+
+	boom := errors.New("boom")
+	merged = MergeSources(
+		storage.NewSliceKlineIterator(btc),
+		failingSource{storage.NewSliceKlineIterator(eth), boom},
+	)
+	for merged.Next() {
+	}
+	assert.ErrorIs(t, merged.Err(), boom)
+}
+
+func TestBacktester_RejectsOutOfOrderCandles(t *testing.T) {
+	candles := append(symbolBars("BTCUSDT", 1, 2), symbolBars("ETHUSDT", 1)...)
+	_, err := NewOrderBacktester(&scriptedStrategy{}, decimal.NewFromInt(100)).
+		RunSource(storage.NewSliceKlineIterator(candles))
+	assert.ErrorContains(t, err, "out of time order")
+}
+
+// sliceRecorder buys both symbols once and records what it was shown
+type sliceRecorder struct {
+	slices []Slice
+}
+
+func (s *sliceRecorder) Name() string { return "recorder" }
+
+func (s *sliceRecorder) OnSlice(slice Slice, broker Broker) {
+	s.slices = append(s.slices, slice)
+	if len(s.slices) == 1 {
+		for _, k := range slice.Bars {
+			broker.Submit(model.OrderRequest{Symbol: k.Symbol, Side: model.SideBuy, Type: model.OrderMarket, Qty: decimal.NewFromInt(40)})
+		}
+	}
+}
+
+func TestPortfolioBacktester(t *testing.T) {
+	btc := symbolBars("BTCUSDT", 100, 100, 110, 120)
+	eth := symbolBars("ETHUSDT", 100, 100, 95, 90)
+	strat := &sliceRecorder{}
+	tester := NewPortfolioBacktester(strat, decimal.NewFromInt(10000))
+	withoutCosts(tester)
+
+	report, err := tester.RunSource(MergeSources(storage.NewSliceKlineIterator(btc), storage.NewSliceKlineIterator(eth)))
+	require.NoError(t, err)
+
+	require.Len(t, strat.slices, 4)
+	assert.Len(t, strat.slices[0].Bars, 2)
+	assert.Len(t, strat.slices[3].Latest, 2)
+	_, ok := strat.slices[2].Bar("ETHUSDT")
+	assert.True(t, ok)
+
+	// Both buys share one cash balance: 4000 + 4000 of 10000
+	assert.Equal(t, []string{"BTCUSDT", "ETHUSDT"}, report.Symbols)
+	require.Len(t, report.EquityCurve, 4)
+	assert.True(t, report.EquityCurve[2].Equity.Equal(decimal.NewFromInt(10200))) // +400 -200
+
+	require.Len(t, report.Attribution, 2)
+	assert.True(t, report.Attribution[0].NetPnL.Equal(decimal.NewFromInt(800)))
+	assert.True(t, report.Attribution[1].NetPnL.Equal(decimal.NewFromInt(-400)))
+	assert.InDelta(t, 0.08, report.Attribution[0].Contribution, 1e-9)
+	total := report.Attribution[0].NetPnL.Add(report.Attribution[1].NetPnL)
+	assert.True(t, report.FinalBalance.Sub(report.InitialBalance).Equal(total))
+
+	require.Len(t, report.Correlation, 2)
+	assert.Equal(t, 1.0, report.Correlation[0][0])
+	assert.Less(t, report.Correlation[0][1], 0.0)
+	assert.Equal(t, report.Correlation[0][1], report.Correlation[1][0])
+}
+
+func TestMultiSymbolBacktester_SplitsEquity(t *testing.T) {
+	buy := signalStrategy{strategy.ActionBuy}
+	tester := NewMultiSymbolBacktester(map[string]strategy.Strategy{"BTCUSDT": buy, "ETHUSDT": buy}, decimal.NewFromInt(10000))
+	withoutCosts(tester)
+
+	report, err := tester.RunSource(MergeSources(
+		storage.NewSliceKlineIterator(symbolBars("BTCUSDT", 100, 100)),
+		storage.NewSliceKlineIterator(symbolBars("ETHUSDT", 50, 50)),
+	))
+	require.NoError(t, err)
+
+	require.Len(t, report.Orders, 2)
+	assert.True(t, report.Orders[0].FilledQty.Equal(decimal.NewFromInt(50)), report.Orders[0].FilledQty.String())
+	assert.True(t, report.Orders[1].FilledQty.Equal(decimal.NewFromInt(100)), report.Orders[1].FilledQty.String())
+	assert.Equal(t, "signals", report.StrategyName)
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"quant-trader/internal/metrics"
+	"quant-trader/internal/model"
+
+	"github.com/shopspring/decimal"
+)
+
+// report builds the BacktestReport once the run has been closed out. Ratios are
+// annualized from the bar period; the equity series is marked before the final
+// close-out, FinalBalance after it.
+func (b *Backtester) report(initialBalance decimal.Decimal) model.BacktestReport {
+	initial := initialBalance.InexactFloat64()
+	final := b.balance.InexactFloat64()
+
+	equity := make([]float64, len(b.equityCurve))
+	for i, e := range b.equityCurve {
+		equity[i] = e.InexactFloat64()
+	}
+	drawdowns := metrics.Drawdowns(equity)
+	curve := make([]model.EquityPoint, len(equity))
+	for i := range equity {
+		curve[i] = model.EquityPoint{Time: b.equityTimes[i], Equity: b.equityCurve[i], Drawdown: drawdowns[i]}
+	}
+
+	period := b.period
+	if period == 0 && len(b.equityTimes) > 1 {
+		period = b.equityTimes[1].Sub(b.equityTimes[0])
+	}
+	perYear := metrics.PeriodsPerYear(period)
+	returns := metrics.Returns(append([]float64{initial}, equity...))
+	maxDD := metrics.MaxDrawdown(equity)
+
+	var cagr float64
+	if n := len(b.equityTimes); n > 0 {
+		span := b.equityTimes[n-1].Sub(b.equityTimes[0]) + period
+		cagr = metrics.CAGR(initial, final, span)
+	}
+
+	var pnls []float64
+	totalProfit := decimal.Zero
+	for _, t := range b.trades {
+		if t.Closing {
+			pnls = append(pnls, t.PnL.InexactFloat64())
+			totalProfit = totalProfit.Add(t.PnL)
+		}
+	}
+	stats := metrics.Trades(pnls)
+	durations := metrics.Durations(b.holdings)
+
+	var exposure float64
+	if len(equity) > 0 {
+		exposure = float64(b.exposedBars) / float64(len(equity))
+	}
+
+	var monthly []model.MonthlyReturn
+	for _, m := range metrics.MonthlyReturns(initial, b.equityTimes, equity) {
+		monthly = append(monthly, model.MonthlyReturn{Year: m.Year, Month: int(m.Month), Return: m.Return})
+	}
+
+	orders := make([]model.Order, len(b.orders))
+	for i, o := range b.orders {
+		orders[i] = *o
+	}
+	maxUsage, avgUsage := summarizeUsage(b.marginUsage)
+	sharpe := metrics.Sharpe(returns, 0, perYear)
+
+	return model.BacktestReport{
+		StrategyName:   b.strategy.Name(),
+		TotalTrades:    len(b.trades),
+		WinRate:        stats.WinRate,
+		TotalReturn:    b.balance.Sub(initialBalance).Div(initialBalance),
+		TotalProfit:    totalProfit,
+		MaxDrawdown:    maxDD,
+		SharpRatio:     sharpe,
+		InitialBalance: initialBalance,
+		FinalBalance:   b.balance,
+		TradesLog:      b.trades,
+		Orders:         orders,
+		MaxMarginUsage: maxUsage,
+		AvgMarginUsage: avgUsage,
+		FundingPaid:    b.fundingPaid,
+		Liquidations:   b.liquidations,
+		Symbols:        b.symbols,
+		EquityCurve:    curve,
+		Attribution:    b.attribution(initialBalance),
+		Correlation:    b.correlation(),
+
+		SharpeRatio:         sharpe,
+		SortinoRatio:        metrics.Sortino(returns, 0, perYear),
+		CalmarRatio:         metrics.Calmar(cagr, maxDD),
+		CAGR:                cagr,
+		MaxDrawdownDuration: metrics.MaxDrawdownDuration(b.equityTimes, equity).Seconds(),
+		ClosedTrades:        stats.Count,
+		ProfitFactor:        stats.ProfitFactor,
+		Expectancy:          stats.Expectancy,
+		AvgWin:              stats.AvgWin,
+		AvgLoss:             stats.AvgLoss,
+		Exposure:            exposure,
+		TradeDuration: model.DurationSummary{
+			Min:    durations.Min.Seconds(),
+			Max:    durations.Max.Seconds(),
+			Mean:   durations.Mean.Seconds(),
+			Median: durations.Median.Seconds(),
+		},
+		MonthlyReturns: monthly,
+	}
+}
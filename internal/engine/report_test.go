@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"quant-trader/internal/model"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dailyBar(day time.Time, close float64) model.KLine {
+	c := decimal.NewFromFloat(close)
+	return model.KLine{Symbol: "BTCUSDT", Period: "1d", Open: c, High: c, Low: c, Close: c, Timestamp: day}
+}
+
+func TestBacktester_ReportAnalytics(t *testing.T) {
+	qty := decimal.NewFromInt(10)
+	strat := &scriptedStrategy{script: map[int][]model.OrderRequest{
+		0: {{Side: model.SideBuy, Type: model.OrderMarket, Qty: qty}},
+		2: {{Side: model.SideSell, Type: model.OrderMarket, Qty: qty}},
+		3: {{Side: model.SideBuy, Type: model.OrderMarket, Qty: qty}},
+		4: {{Side: model.SideSell, Type: model.OrderMarket, Qty: qty}},
+	}}
+	start := time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC)
+	var candles []model.KLine
+	for i, c := range []float64{100, 100, 110, 110, 100, 80} {
+		candles = append(candles, dailyBar(start.AddDate(0, 0, i), c))
+	}
+	report := newFrictionless(strat).Run(candles)
+
+	// Round trips: +100 held 2 days (Jan 31 -> Feb 2), -200 held 1 day (Feb 3 -> Feb 4)
+	assert.Equal(t, 2, report.ClosedTrades)
+	assert.Equal(t, 0.5, report.WinRate)
+	assert.InDelta(t, -50, report.Expectancy, 1e-9)
+	assert.InDelta(t, 100, report.AvgWin, 1e-9)
+	assert.InDelta(t, -200, report.AvgLoss, 1e-9)
+	assert.InDelta(t, 0.5, report.ProfitFactor, 1e-9)
+	assert.Equal(t, (36 * time.Hour).Seconds(), report.TradeDuration.Mean)
+	assert.InDelta(t, 0.5, report.Exposure, 1e-9)
+
+	require.Len(t, report.EquityCurve, 6)
+	last := report.EquityCurve[5]
+	assert.True(t, last.Equity.Equal(decimal.NewFromInt(9900)), last.Equity.String())
+	assert.InDelta(t, 200.0/10100, last.Drawdown, 1e-9)
+	assert.InDelta(t, 200.0/10100, report.MaxDrawdown, 1e-9)
+	assert.Equal(t, (24 * time.Hour).Seconds(), report.MaxDrawdownDuration)
+
+	require.Len(t, report.MonthlyReturns, 2)
+	assert.Equal(t, model.MonthlyReturn{Year: 2024, Month: 1, Return: 0}, report.MonthlyReturns[0])
+	assert.InDelta(t, -0.01, report.MonthlyReturns[1].Return, 1e-9)
+
+	assert.Less(t, report.CAGR, 0.0)
+	assert.Less(t, report.SharpeRatio, 0.0)
+	assert.Equal(t, report.SharpeRatio, report.SharpRatio)
+	assert.Less(t, report.SortinoRatio, 0.0)
+	assert.Greater(t, report.CalmarRatio, -1e9)
+}
@@ -0,0 +1,246 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"quant-trader/internal/model"
+	"quant-trader/internal/strategy"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ExecutionSpec names the fee, slippage and fill models of a backtest; empty
+// fields keep the defaults
+type ExecutionSpec struct {
+	FeeModel       string                 `json:"fee_model,omitempty"` // flat, maker_taker, tiered, exchange
+	FeeConfig      map[string]interface{} `json:"fee_config,omitempty"`
+	SlippageModel  string                 `json:"slippage_model,omitempty"` // fixed, atr, sqrt_impact
+	SlippageConfig map[string]interface{} `json:"slippage_config,omitempty"`
+	FillModel      string                 `json:"fill_model,omitempty"` // next_open, close, intrabar
+}
+
+func (s ExecutionSpec) models() (FeeModel, SlippageModel, FillModel, error) {
+	fees, err := NewFeeModel(s.FeeModel, s.FeeConfig)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	slip, err := NewSlippageModel(s.SlippageModel, s.SlippageConfig)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	fills, err := NewFillModel(s.FillModel)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return fees, slip, fills, nil
+}
+
+// Apply sets fresh instances of the models on tester; models keep state, so
+// every backtester needs its own
+func (s ExecutionSpec) Apply(tester *Backtester) error {
+	fees, slip, fills, err := s.models()
+	if err != nil {
+		return err
+	}
+	tester.SetFeeModel(fees)
+	tester.SetSlippageModel(slip)
+	tester.SetFillModel(fills)
+	return nil
+}
+
+// BacktestSpec describes a backtest completely, so it can be stored and run
+// later, e.g. by a BacktestWorker
+type BacktestSpec struct {
+	Symbols        []string               `json:"symbols"`
+	Exchange       string                 `json:"exchange,omitempty"`
+	Period         string                 `json:"period"`
+	StrategyType   string                 `json:"strategy_type"`
+	Config         map[string]interface{} `json:"config"`
+	InitialBalance decimal.Decimal        `json:"initial_balance"`
+	Start          time.Time              `json:"start_time"`
+	End            time.Time              `json:"end_time"`
+	Margin         MarginConfig           `json:"margin"`
+	// StrategyVersionID is the stored version the strategy came from, see
+	// StrategyLibrary.Pin. Its module is kept out of the spec: WasmHash names
+	// it and Module holds it once loaded.
+	StrategyVersionID int64  `json:"strategy_version_id,omitempty"`
+	WasmHash          string `json:"wasm_hash,omitempty"`
+	Module            []byte `json:"-"`
+	// Data is "candles" (the default) or "ticks" to replay stored trades and
+	// order book snapshots, see Backtester.RunTicks
+	Data string `json:"data,omitempty"`
+	ExecutionSpec
+}
+
+// Validate checks everything that can be checked without candles
+func (s BacktestSpec) Validate() error {
+	if len(s.Symbols) == 0 || s.Period == "" {
+		return errors.New("symbols and period are required")
+	}
+	if !s.End.After(s.Start) {
+		return errors.New("end time must be after start time")
+	}
+	if s.Data != "" && s.Data != "candles" && s.Data != "ticks" {
+		return fmt.Errorf("unknown backtest data %q, use candles or ticks", s.Data)
+	}
+	if s.StrategyType == "wasm" && s.WasmHash == "" {
+		return errors.New("wasm strategies only run from a strategy version")
+	}
+	if s.WasmHash != "" && s.Module == nil {
+		return fmt.Errorf("wasm module %s is not loaded", s.WasmHash)
+	}
+	strat, err := buildStrategy(s.StrategyType, s.StrategyConfig())
+	if err != nil {
+		return err
+	}
+	closeStrategy(strat)
+	if err := s.Margin.Validate(); err != nil {
+		return err
+	}
+	_, _, _, err = s.models()
+	return err
+}
+
+// StrategyConfig is the config to build the strategy with, including the module
+func (s BacktestSpec) StrategyConfig() map[string]interface{} {
+	if s.Module == nil {
+		return s.Config
+	}
+	config := maps.Clone(s.Config)
+	if config == nil {
+		config = make(map[string]interface{})
+	}
+	config["module"] = s.Module
+	return config
+}
+
+// Setup applies the margin and execution models to tester
+func (s BacktestSpec) Setup(tester *Backtester) error {
+	if err := tester.SetMargin(s.Margin); err != nil {
+		return err
+	}
+	return s.Apply(tester)
+}
+
+// CandleRequest selects the history of one of the spec's symbols
+func (s BacktestSpec) CandleRequest(symbol string) CandleRequest {
+	return CandleRequest{
+		Symbol:   symbol,
+		Exchange: s.Exchange,
+		Period:   s.Period,
+		Start:    s.Start,
+		End:      s.End,
+	}
+}
+
+// Run streams the spec's candles, or ticks, from loader through a new
+// backtester. It stops with ctx's error once ctx is done.
+func (s BacktestSpec) Run(ctx context.Context, loader *DataLoader) (model.BacktestReport, error) {
+	tester, release, err := newStrategyTester(s.StrategyType, s.StrategyConfig(), s.Symbols, s.InitialBalance)
+	if err != nil {
+		return model.BacktestReport{}, err
+	}
+	defer release()
+	if err := s.Setup(tester); err != nil {
+		return model.BacktestReport{}, err
+	}
+
+	if s.Data == "ticks" {
+		sources := make([]TickSource, 0, len(s.Symbols))
+		for _, symbol := range s.Symbols {
+			// End is the open of the last bar, as for candles, so its ticks are included
+			req := s.CandleRequest(symbol)
+			req.End = req.End.Add(model.PeriodToDuration(s.Period))
+			ticks, err := loader.Ticks(ctx, req)
+			if err != nil {
+				return model.BacktestReport{}, err
+			}
+			defer ticks.Close()
+			sources = append(sources, ticks)
+		}
+		return tester.RunTicks(&tickContext{ctx: ctx, src: MergeTicks(sources...)}, s.Period)
+	}
+
+	sources := make([]CandleSource, 0, len(s.Symbols))
+	for _, symbol := range s.Symbols {
+		candles, err := loader.Candles(ctx, s.CandleRequest(symbol))
+		if err != nil {
+			return model.BacktestReport{}, err
+		}
+		defer candles.Close()
+		sources = append(sources, candles)
+	}
+	return tester.RunSource(withContext(ctx, MergeSources(sources...)))
+}
+
+// newStrategyTester builds one strategy instance per symbol, since strategies
+// keep state. Action-based strategies keep the equal shares of
+// NewMultiSymbolBacktester, others run on the event-driven runtime. release
+// frees the strategies once the run is over.
+func newStrategyTester(strategyType string, config map[string]interface{}, symbols []string, balance decimal.Decimal) (tester *Backtester, release func(), err error) {
+	legacy := make(map[string]strategy.Strategy, len(symbols))
+	native := make(map[string]strategy.StrategyV2, len(symbols))
+	release = func() {
+		for _, strat := range native {
+			closeStrategy(strat)
+		}
+	}
+	for _, symbol := range symbols {
+		strat, err := buildStrategy(strategyType, config)
+		if err != nil {
+			release()
+			return nil, nil, err
+		}
+		if l := strategy.Legacy(strat); l != nil {
+			legacy[symbol] = l
+		} else {
+			native[symbol] = strat
+		}
+	}
+	if len(native) == 0 {
+		return NewMultiSymbolBacktester(legacy, balance), release, nil
+	}
+	if tester, err = NewStrategyBacktester(native, balance); err != nil {
+		release()
+		return nil, nil, err
+	}
+	return tester, release, nil
+}
+
+// closeStrategy releases strategies holding resources, e.g. a WASM runtime
+func closeStrategy(strat strategy.StrategyV2) {
+	if c, ok := strat.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// contextSource ends a candle stream with ctx's error once ctx is done
+type contextSource struct {
+	ctx context.Context
+	src CandleSource
+	err error
+}
+
+func withContext(ctx context.Context, src CandleSource) *contextSource {
+	return &contextSource{ctx: ctx, src: src}
+}
+
+func (s *contextSource) Next() bool {
+	if s.err = s.ctx.Err(); s.err != nil {
+		return false
+	}
+	return s.src.Next()
+}
+
+func (s *contextSource) KLine() model.KLine { return s.src.KLine() }
+
+func (s *contextSource) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.src.Err()
+}
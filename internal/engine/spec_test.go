@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"context"
+	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seedTrend stores ten rising binance candles from 2024-01-01 00:00
+func seedTrend(t *testing.T, repo storage.MarketDataRepository) {
+	t.Helper()
+	klines := make([]model.KLine, 10)
+	for i := range klines {
+		p := float64(100 + i)
+		klines[i] = bar(i, p, p, p, p)
+		klines[i].Exchange = "binance"
+	}
+	require.NoError(t, repo.UpsertKlines(context.Background(), klines))
+}
+
+func maSpec(start time.Time) BacktestSpec {
+	return BacktestSpec{
+		Symbols:        []string{"BTCUSDT"},
+		Exchange:       "binance",
+		Period:         "1m",
+		StrategyType:   "ma_cross",
+		Config:         map[string]interface{}{"short_period": 2.0, "long_period": 3.0},
+		InitialBalance: decimal.NewFromInt(10000),
+		Start:          start,
+		End:            start.Add(9 * time.Minute),
+		Margin:         SpotMargin(),
+	}
+}
+
+func TestBacktestSpec_Validate(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, maSpec(start).Validate())
+
+	cases := map[string]func(s *BacktestSpec){
+		"symbols":  func(s *BacktestSpec) { s.Symbols = nil },
+		"range":    func(s *BacktestSpec) { s.End = s.Start },
+		"strategy": func(s *BacktestSpec) { s.StrategyType = "unknown" },
+		"margin":   func(s *BacktestSpec) { s.Margin = MarginConfig{} },
+		"fees":     func(s *BacktestSpec) { s.FeeModel = "free" },
+	}
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			spec := maSpec(start)
+			mutate(&spec)
+			assert.Error(t, spec.Validate())
+		})
+	}
+}
+
+func TestBacktestSpec_Run(t *testing.T) {
+	store := storage.NewMemoryStore()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	seedTrend(t, store.Market)
+	loader := NewDataLoader(store.Market)
+
+	report, err := maSpec(start).Run(context.Background(), loader)
+	require.NoError(t, err)
+	assert.Len(t, report.EquityCurve, 10)
+	assert.NotZero(t, report.TotalTrades)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = maSpec(start).Run(ctx, loader)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,243 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
+	"quant-trader/internal/strategy"
+	"strings"
+	"time"
+)
+
+// ErrInvalidStrategy wraps the reasons a strategy version is rejected
+var ErrInvalidStrategy = errors.New("invalid strategy")
+
+// MaxWasmModuleSize bounds an uploaded WASM module
+const MaxWasmModuleSize = 8 << 20
+
+// MaxWasmLimits are the most a stored WASM strategy may configure
+var MaxWasmLimits = strategy.WasmLimits{MemoryPages: 1024, Fuel: 100_000_000, Timeout: 5 * time.Second}
+
+// StrategyDraft is the content of a new strategy version
+type StrategyDraft struct {
+	Type   string
+	Config map[string]interface{}
+	// Module is the code of a "wasm" strategy; a "module" in Config is moved here
+	Module []byte
+	// SHA256 is the hex digest the uploader computed of Module, checked if set
+	SHA256 string
+	Note   string
+}
+
+// StrategyLibrary keeps users' strategies as immutable versions and turns a
+// version back into something to run
+type StrategyLibrary struct {
+	repo storage.StrategyRepository
+}
+
+func NewStrategyLibrary(repo storage.StrategyRepository) *StrategyLibrary {
+	return &StrategyLibrary{repo: repo}
+}
+
+// Create validates draft and stores it as version 1 of strat
+func (l *StrategyLibrary) Create(ctx context.Context, strat model.Strategy, draft StrategyDraft) (model.Strategy, model.StrategyVersion, error) {
+	version, wasm, err := draft.version()
+	if err != nil {
+		return model.Strategy{}, model.StrategyVersion{}, err
+	}
+	return l.repo.CreateStrategy(ctx, strat, version, wasm)
+}
+
+// AddVersion validates draft and stores it as the next version of a strategy of userID
+func (l *StrategyLibrary) AddVersion(ctx context.Context, userID, strategyID int64, draft StrategyDraft) (model.StrategyVersion, error) {
+	version, wasm, err := draft.version()
+	if err != nil {
+		return model.StrategyVersion{}, err
+	}
+	version.StrategyID = strategyID
+	return l.repo.CreateStrategyVersion(ctx, userID, version, wasm)
+}
+
+// Resolve loads a version of userID and the config to build it with, which
+// carries the verified module of a WASM strategy
+func (l *StrategyLibrary) Resolve(ctx context.Context, userID, versionID int64) (model.StrategyVersion, map[string]interface{}, error) {
+	version, err := l.repo.GetStrategyVersion(ctx, userID, versionID)
+	if err != nil {
+		return version, nil, err
+	}
+	config := make(map[string]interface{})
+	if err := json.Unmarshal(version.Config, &config); err != nil {
+		return version, nil, fmt.Errorf("invalid config of strategy version %d: %w", versionID, err)
+	}
+	if version.WasmHash != "" {
+		wasm, err := l.Module(ctx, version.WasmHash)
+		if err != nil {
+			return version, nil, err
+		}
+		config["module"] = wasm
+	}
+	return version, config, nil
+}
+
+// Strategy builds an instance of a version of userID for the live runner
+func (l *StrategyLibrary) Strategy(ctx context.Context, userID, versionID int64) (strategy.Strategy, error) {
+	version, config, err := l.Resolve(ctx, userID, versionID)
+	if err != nil {
+		return nil, err
+	}
+	if version.Type != "wasm" {
+		return strategy.NewStrategy(version.Type, config)
+	}
+	wasm, err := newWasm(config)
+	if err != nil {
+		return nil, err
+	}
+	return wasm.Actions(), nil
+}
+
+// Pin makes spec run a version of userID. The spec keeps the module's hash,
+// not the module, so it stays small when stored; see Load.
+func (l *StrategyLibrary) Pin(ctx context.Context, userID, versionID int64, spec *BacktestSpec) error {
+	version, config, err := l.Resolve(ctx, userID, versionID)
+	if err != nil {
+		return err
+	}
+	spec.Module, _ = config["module"].([]byte)
+	delete(config, "module")
+	spec.StrategyType = version.Type
+	spec.Config = config
+	spec.StrategyVersionID = version.ID
+	spec.WasmHash = version.WasmHash
+	return nil
+}
+
+// Load reads the module of a pinned spec, e.g. one decoded from a queued run
+func (l *StrategyLibrary) Load(ctx context.Context, spec *BacktestSpec) error {
+	if spec.WasmHash == "" || spec.Module != nil {
+		return nil
+	}
+	wasm, err := l.Module(ctx, spec.WasmHash)
+	if err != nil {
+		return err
+	}
+	spec.Module = wasm
+	return nil
+}
+
+// Module loads the module stored under hash and checks it still hashes to it
+func (l *StrategyLibrary) Module(ctx context.Context, hash string) ([]byte, error) {
+	wasm, err := l.repo.GetStrategyBlob(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load wasm module %s: %w", hash, err)
+	}
+	if wasmHash(wasm) != hash {
+		return nil, fmt.Errorf("wasm module %s failed hash verification", hash)
+	}
+	return wasm, nil
+}
+
+// version checks that the draft builds a strategy, within MaxWasmLimits for
+// WASM, and returns the version to store with its module
+func (d StrategyDraft) version() (model.StrategyVersion, []byte, error) {
+	config := maps.Clone(d.Config)
+	if config == nil {
+		config = make(map[string]interface{})
+	}
+	wasm := d.Module
+	if raw, ok := config["module"]; ok {
+		if wasm != nil {
+			return model.StrategyVersion{}, nil, fmt.Errorf("%w: module is given twice", ErrInvalidStrategy)
+		}
+		s, ok := raw.(string)
+		if !ok {
+			return model.StrategyVersion{}, nil, fmt.Errorf("%w: module must be base64", ErrInvalidStrategy)
+		}
+		var err error
+		if wasm, err = base64.StdEncoding.DecodeString(s); err != nil {
+			return model.StrategyVersion{}, nil, fmt.Errorf("%w: module must be base64: %w", ErrInvalidStrategy, err)
+		}
+		delete(config, "module")
+	}
+
+	var hash string
+	if d.Type == "wasm" {
+		if len(wasm) == 0 {
+			return model.StrategyVersion{}, nil, fmt.Errorf("%w: wasm strategies need a module", ErrInvalidStrategy)
+		}
+		hash = wasmHash(wasm)
+		if d.SHA256 != "" && !strings.EqualFold(d.SHA256, hash) {
+			return model.StrategyVersion{}, nil, fmt.Errorf("%w: module hashes to %s, not %s", ErrInvalidStrategy, hash, d.SHA256)
+		}
+	} else if len(wasm) > 0 {
+		return model.StrategyVersion{}, nil, fmt.Errorf("%w: only wasm strategies take a module", ErrInvalidStrategy)
+	}
+
+	// Building the strategy checks the config and, for WASM, the limits and exports
+	build := maps.Clone(config)
+	if wasm != nil {
+		build["module"] = wasm
+	}
+	strat, err := buildStrategy(d.Type, build)
+	if err != nil {
+		return model.StrategyVersion{}, nil, fmt.Errorf("%w: %w", ErrInvalidStrategy, err)
+	}
+	closeStrategy(strat)
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return model.StrategyVersion{}, nil, fmt.Errorf("%w: %w", ErrInvalidStrategy, err)
+	}
+	return model.StrategyVersion{Type: d.Type, Config: data, WasmHash: hash, Note: d.Note}, wasm, nil
+}
+
+// buildStrategy builds a strategy for the event-driven runtime. It is the one
+// way to build a WASM strategy, which newWasm holds to the caps.
+func buildStrategy(strategyType string, config map[string]interface{}) (strategy.StrategyV2, error) {
+	if strategyType == "wasm" {
+		return newWasm(config)
+	}
+	return strategy.NewStrategyV2(strategyType, config)
+}
+
+// newWasm builds a WASM strategy whose module is within MaxWasmModuleSize and
+// whose limits are within MaxWasmLimits
+func newWasm(config map[string]interface{}) (*strategy.WasmStrategy, error) {
+	if wasm, ok := config["module"].([]byte); ok && len(wasm) > MaxWasmModuleSize {
+		return nil, fmt.Errorf("module is %d bytes, at most %d are allowed", len(wasm), MaxWasmModuleSize)
+	}
+	if err := checkWasmLimits(config); err != nil {
+		return nil, err
+	}
+	return strategy.NewWasmFromConfig(config)
+}
+
+// checkWasmLimits rejects limits above MaxWasmLimits; unset ones take the
+// runner's defaults, which are below them
+func checkWasmLimits(config map[string]interface{}) error {
+	limits := []struct {
+		key string
+		max float64
+	}{
+		{"memory_pages", float64(MaxWasmLimits.MemoryPages)},
+		{"fuel", float64(MaxWasmLimits.Fuel)},
+		{"timeout_ms", float64(MaxWasmLimits.Timeout.Milliseconds())},
+	}
+	for _, limit := range limits {
+		if v, ok := config[limit.key].(float64); ok && v > limit.max {
+			return fmt.Errorf("%s is %g, at most %g is allowed", limit.key, v, limit.max)
+		}
+	}
+	return nil
+}
+
+func wasmHash(wasm []byte) string {
+	sum := sha256.Sum256(wasm)
+	return hex.EncodeToString(sum[:])
+}
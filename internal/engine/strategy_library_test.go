@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// buildExampleWasm builds the TinyGo example strategy with the Go toolchain
+func buildExampleWasm(t *testing.T) []byte {
+	if testing.Short() {
+		t.Skip("builds a wasm module")
+	}
+	out := filepath.Join(t.TempDir(), "ma_cross.wasm")
+	cmd := exec.Command("go", "build", "-buildmode=c-shared", "-o", out, ".")
+	cmd.Dir = filepath.Join("..", "..", "examples", "wasm", "tinygo")
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm", "GOWORK=off")
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+	wasm, err := os.ReadFile(out)
+	require.NoError(t, err)
+	return wasm
+}
+
+func TestStrategyLibrary_Versions(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	library := NewStrategyLibrary(store.Strategies)
+
+	strat, v1, err := library.Create(ctx, model.Strategy{UserID: 1, Name: "cross"}, StrategyDraft{
+		Type:   "ma_cross",
+		Config: map[string]interface{}{"short_period": 2.0, "long_period": 3.0},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, v1.Version)
+	assert.Empty(t, v1.WasmHash)
+
+	invalid := map[string]StrategyDraft{
+		"config":         {Type: "ma_cross", Config: map[string]interface{}{"short_period": "fast"}},
+		"module":         {Type: "ma_cross", Module: []byte{0, 'a', 's', 'm'}},
+		"no module":      {Type: "wasm"},
+		"not wasm":       {Type: "wasm", Module: []byte("nope")},
+		"hash":           {Type: "wasm", Module: []byte("nope"), SHA256: "00"},
+		"limits":         {Type: "wasm", Module: []byte("nope"), Config: map[string]interface{}{"timeout_ms": 60000.0}},
+		"module twice":   {Type: "wasm", Module: []byte("nope"), Config: map[string]interface{}{"module": "bm9wZQ=="}},
+		"unknown":        {Type: "unknown"},
+		"module encoded": {Type: "wasm", Config: map[string]interface{}{"module": "%%%"}},
+	}
+	for name, draft := range invalid {
+		_, err := library.AddVersion(ctx, 1, strat.ID, draft)
+		assert.ErrorIs(t, err, ErrInvalidStrategy, name)
+	}
+
+	v2, err := library.AddVersion(ctx, 1, strat.ID, StrategyDraft{
+		Type:   "ma_cross",
+		Config: map[string]interface{}{"short_period": 3.0, "long_period": 5.0},
+		Note:   "slower",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, v2.Version)
+	_, err = library.AddVersion(ctx, 2, strat.ID, StrategyDraft{Type: "ma_cross", Config: maSpec(time.Time{}).Config})
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Pinning the first version runs exactly that config
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	spec := maSpec(start)
+	spec.StrategyType, spec.Config = "", nil
+	require.NoError(t, library.Pin(ctx, 1, v1.ID, &spec))
+	assert.Equal(t, maSpec(start).Config, spec.Config)
+	assert.Equal(t, v1.ID, spec.StrategyVersionID)
+	require.NoError(t, spec.Validate())
+	assert.ErrorIs(t, library.Pin(ctx, 2, v1.ID, &spec), storage.ErrNotFound)
+}
+
+// tamperedBlobs serves modules that no longer match their hash
+type tamperedBlobs struct {
+	storage.StrategyRepository
+}
+
+func (r tamperedBlobs) GetStrategyBlob(ctx context.Context, hash string) ([]byte, error) {
+	wasm, err := r.StrategyRepository.GetStrategyBlob(ctx, hash)
+	return append(wasm, 0), err
+}
+
+func TestStrategyLibrary_Wasm(t *testing.T) {
+	ctx := context.Background()
+	wasm := buildExampleWasm(t)
+	sum := sha256.Sum256(wasm)
+	store := storage.NewMemoryStore()
+	seedTrend(t, store.Market)
+	library := NewStrategyLibrary(store.Strategies)
+
+	_, version, err := library.Create(ctx, model.Strategy{UserID: 1, Name: "wasm cross"}, StrategyDraft{
+		Type:   "wasm",
+		Config: map[string]interface{}{"params": map[string]interface{}{"fast": 2.0, "slow": 3.0}},
+		Module: wasm,
+		SHA256: hex.EncodeToString(sum[:]),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(sum[:]), version.WasmHash)
+	assert.Equal(t, len(wasm), version.WasmSize)
+	assert.NotContains(t, string(version.Config), "module")
+
+	// The queued spec names the module by hash and the worker loads it
+	spec := maSpec(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, library.Pin(ctx, 1, version.ID, &spec))
+	assert.Equal(t, wasm, spec.Module)
+	data, err := json.Marshal(spec)
+	require.NoError(t, err)
+	assert.Less(t, len(data), 2048, "the module is not part of the spec")
+
+	worker := NewBacktestWorker(nil, nil, store.Backtests, library, NewDataLoader(store.Market), 1, zap.NewNop())
+	id := queueRun(t, store.Backtests, spec)
+	require.NoError(t, worker.Execute(ctx, id))
+	run, err := store.Backtests.GetBacktestRun(ctx, 1, id)
+	require.NoError(t, err)
+	assert.Equal(t, model.BacktestDone, run.Status, run.Error)
+	direct, err := spec.Run(ctx, NewDataLoader(store.Market))
+	require.NoError(t, err)
+	require.NotNil(t, run.Report)
+	assert.True(t, direct.FinalBalance.Equal(run.Report.FinalBalance))
+
+	// The caps hold for every build, not only for the stored version
+	over := spec
+	over.Config = map[string]interface{}{"fuel": float64(MaxWasmLimits.Fuel + 1)}
+	assert.ErrorContains(t, over.Validate(), "fuel")
+	inline := spec
+	inline.StrategyVersionID, inline.WasmHash = 0, ""
+	assert.ErrorContains(t, inline.Validate(), "only run from a strategy version")
+
+	// A module that changed in storage is refused
+	tampered := NewStrategyLibrary(tamperedBlobs{store.Strategies})
+	err = tampered.Pin(ctx, 1, version.ID, &spec)
+	assert.ErrorContains(t, err, "failed hash verification")
+}
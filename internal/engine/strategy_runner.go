@@ -3,32 +3,240 @@ package engine
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
 	"quant-trader/internal/strategy"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/nats-io/nats.go"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
-// StrategyRunner 负责在实盘数据流上运行策略
+// CheckpointInterval is how often the runner saves the state of its strategies
+const CheckpointInterval = time.Minute
+
+// Signal is a buy or sell of a live strategy
+type Signal struct {
+	Strategy string          `json:"strategy"`
+	Symbol   string          `json:"symbol"`
+	Period   string          `json:"period"`
+	Action   string          `json:"action"` // buy, sell
+	Price    decimal.Decimal `json:"price"`
+	Time     time.Time       `json:"time"`
+}
+
+// StrategyRunner 负责在实盘数据流上运行策略. Strategies are warmed up on
+// stored candles before they go live, and the state of a StatefulStrategy is
+// checkpointed so a restart resumes it.
 type StrategyRunner struct {
-	js         nats.JetStreamContext
-	logger     *zap.Logger
-	strategies []strategy.Strategy
+	js     nats.JetStreamContext
+	market storage.MarketDataRepository
+	states storage.StateRepository
+	logger *zap.Logger
+
+	// mu guards strategies; a strategy is only removed between candles.
+	// Candles are handled one at a time under the read lock.
+	mu         sync.RWMutex
+	strategies []*liveStrategy
 }
 
-func NewStrategyRunner(js nats.JetStreamContext, logger *zap.Logger) *StrategyRunner {
+// liveStrategy is a strategy with the candles it is subscribed to
+type liveStrategy struct {
+	id       int64  // deployment id, 0 for AddStrategy
+	key      string // of its checkpoint, empty keeps none
+	strat    strategy.Strategy
+	sub      strategy.Subscription
+	view     *strategy.LiveTimeframes
+	last     map[string]time.Time // latest candle handled, per symbol
+	paused   bool
+	onSignal func(Signal) // nil publishes to the strategy.signal subjects
+}
+
+// checkpoint is what the runner saves of a strategy under its key
+type checkpoint struct {
+	Last  map[string]time.Time `json:"last"`
+	State []byte               `json:"state"`
+}
+
+func NewStrategyRunner(js nats.JetStreamContext, store *storage.Store, logger *zap.Logger) *StrategyRunner {
 	return &StrategyRunner{
 		js:     js,
+		market: store.Market,
+		states: store.States,
 		logger: logger,
 	}
 }
 
-// AddStrategy 添加要运行的策略
-func (r *StrategyRunner) AddStrategy(s strategy.Strategy) {
-	r.strategies = append(r.strategies, s)
+// AddStrategy 添加要运行的策略. The strategy only sees the candles of sub,
+// and a MultiTimeframeStrategy also gets the periods and lookback it asks for.
+// Its state is checkpointed under key unless that is empty.
+func (r *StrategyRunner) AddStrategy(ctx context.Context, key string, s strategy.Strategy, sub strategy.Subscription) error {
+	return r.add(ctx, &liveStrategy{key: key, strat: s, sub: sub})
+}
+
+// Deploy runs s for the deployment id, replacing an earlier one. Its signals
+// go to onSignal; while paused it follows the market without emitting any.
+func (r *StrategyRunner) Deploy(ctx context.Context, id int64, s strategy.Strategy, sub strategy.Subscription, paused bool, onSignal func(Signal)) error {
+	r.Remove(id)
+	return r.add(ctx, &liveStrategy{id: id, key: fmt.Sprintf("deployment:%d", id), strat: s, sub: sub, paused: paused, onSignal: onSignal})
+}
+
+func (r *StrategyRunner) add(ctx context.Context, live *liveStrategy) error {
+	live.sub = LiveSubscription(live.strat, live.sub)
+	if err := live.sub.Validate(); err != nil {
+		return fmt.Errorf("strategy %s: %w", live.strat.Name(), err)
+	}
+	live.view = strategy.NewTimeframes(live.sub)
+	live.last = make(map[string]time.Time)
+	r.restore(ctx, live)
+	r.warmUp(ctx, live)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.strategies = append(r.strategies, live)
+	return nil
+}
+
+// restore loads the checkpoint of a StatefulStrategy. Without one it starts
+// cold, which warmUp makes up for as far as the stored candles go.
+func (r *StrategyRunner) restore(ctx context.Context, live *liveStrategy) {
+	stateful, ok := live.strat.(strategy.StatefulStrategy)
+	if !ok || live.key == "" {
+		return
+	}
+	data, err := r.states.GetStrategyState(ctx, live.key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return
+	}
+	var cp checkpoint
+	if err == nil {
+		err = json.Unmarshal(data, &cp)
+	}
+	if err == nil {
+		err = stateful.Restore(cp.State)
+	}
+	if err != nil {
+		r.logger.Error("failed to restore strategy state", zap.String("key", live.key), zap.Error(err))
+		return
+	}
+	for symbol, t := range cp.Last {
+		live.last[symbol] = t
+	}
+}
+
+// warmUp replays the latest stored candles the strategy needs. Those its
+// restored state already covers only fill the view; the actions of the others
+// are dropped, so nothing is signalled for the past.
+func (r *StrategyRunner) warmUp(ctx context.Context, live *liveStrategy) {
+	n := strategy.WarmUpWindow(live.strat, live.sub)
+	if n == 0 {
+		return
+	}
+	for _, symbol := range live.sub.Symbols {
+		candles, err := r.market.QueryKlines(ctx, storage.KlineQuery{Symbol: symbol, Period: live.sub.Period, Limit: n, Descending: true})
+		if err != nil {
+			r.logger.Error("failed to load strategy warm-up candles", zap.String("strategy", live.strat.Name()), zap.String("symbol", symbol), zap.Error(err))
+			continue
+		}
+		slices.Reverse(candles)
+		for _, candle := range candles {
+			if !candle.Timestamp.After(live.last[symbol]) {
+				live.view.Add(candle)
+				continue
+			}
+			live.feed(candle)
+		}
+	}
+}
+
+// feed hands candle to the strategy and returns its action. A candle at or
+// before the last one handled, e.g. one redelivered after warm-up, is held.
+func (live *liveStrategy) feed(candle model.KLine) strategy.Action {
+	if last, ok := live.last[candle.Symbol]; ok && !candle.Timestamp.After(last) {
+		return strategy.ActionHold
+	}
+	live.last[candle.Symbol] = candle.Timestamp
+	live.view.Add(candle)
+	if mtf, ok := live.strat.(strategy.MultiTimeframeStrategy); ok {
+		return mtf.OnCandleWith(live.view, candle)
+	}
+	return live.strat.OnCandle(candle)
+}
+
+// Checkpoint saves the state of every StatefulStrategy that has a key
+func (r *StrategyRunner) Checkpoint(ctx context.Context) {
+	saved := make(map[string][]byte)
+	// The write lock keeps candles out while the strategies are snapshot
+	r.mu.Lock()
+	for _, live := range r.strategies {
+		stateful, ok := live.strat.(strategy.StatefulStrategy)
+		if !ok || live.key == "" || len(live.last) == 0 {
+			continue
+		}
+		state, err := stateful.Snapshot()
+		if err == nil {
+			saved[live.key], err = json.Marshal(checkpoint{Last: live.last, State: state})
+		}
+		if err != nil {
+			r.logger.Error("failed to snapshot strategy state", zap.String("key", live.key), zap.Error(err))
+		}
+	}
+	r.mu.Unlock()
+
+	for key, data := range saved {
+		if err := r.states.SaveStrategyState(ctx, key, data); err != nil {
+			r.logger.Error("failed to save strategy state", zap.String("key", key), zap.Error(err))
+		}
+	}
+}
+
+// SetPaused pauses or resumes the deployment id, false if it is not running
+func (r *StrategyRunner) SetPaused(id int64, paused bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, live := range r.strategies {
+		if live.id == id {
+			live.paused = paused
+			return true
+		}
+	}
+	return false
+}
+
+// Remove stops the deployment id and releases its strategy
+func (r *StrategyRunner) Remove(id int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.strategies = slices.DeleteFunc(r.strategies, func(live *liveStrategy) bool {
+		if live.id != id || id == 0 {
+			return false
+		}
+		closeLive(live.strat)
+		return true
+	})
+}
+
+// LiveSubscription is sub with the periods and lookback s reads, if it is a
+// MultiTimeframeStrategy
+func LiveSubscription(s strategy.Strategy, sub strategy.Subscription) strategy.Subscription {
+	mtf, ok := s.(strategy.MultiTimeframeStrategy)
+	if !ok {
+		return sub
+	}
+	periods, lookback := mtf.ContextPeriods()
+	for _, period := range periods {
+		if !slices.Contains(sub.Context, period) {
+			sub.Context = append(slices.Clone(sub.Context), period)
+		}
+	}
+	sub.Lookback = max(sub.Lookback, lookback)
+	return sub
 }
 
 // Run 启动策略运行引擎
@@ -49,35 +257,72 @@ func (r *StrategyRunner) Run(ctx context.Context) error {
 		return err
 	}
 
-	r.logger.Info("strategy runner started", zap.Int("strategy_count", len(r.strategies)))
+	go func() {
+		ticker := time.NewTicker(CheckpointInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.Checkpoint(ctx)
+			}
+		}
+	}()
+
+	r.mu.RLock()
+	count := len(r.strategies)
+	r.mu.RUnlock()
+	r.logger.Info("strategy runner started", zap.Int("strategy_count", count))
 	return nil
 }
 
 func (r *StrategyRunner) executeStrategies(candle model.KLine) {
-	for _, s := range r.strategies {
-		action := s.OnCandle(candle)
-		if action != strategy.ActionHold {
-			r.logger.Info("STRATEGY SIGNAL",
-				zap.String("strategy", s.Name()),
-				zap.String("symbol", candle.Symbol),
-				zap.String("period", candle.Period),
-				zap.String("action", string(action)),
-				zap.String("price", candle.Close.String()),
-				zap.Time("time", candle.Timestamp),
-			)
-
-			// 将信号推送到 NATS，以便 UI 实时展示
-			signalSubject := fmt.Sprintf("strategy.signal.%s.%s", s.Name(), candle.Symbol)
-			signalData := map[string]interface{}{
-				"strategy": s.Name(),
-				"symbol":   candle.Symbol,
-				"period":   candle.Period,
-				"action":   strings.ToLower(string(action)),
-				"price":    candle.Close.String(),
-				"time":     candle.Timestamp,
-			}
-			data, _ := json.Marshal(signalData)
-			r.js.Publish(signalSubject, data)
+	type emitted struct {
+		live   *liveStrategy
+		signal Signal
+	}
+	var signals []emitted
+
+	r.mu.RLock()
+	for _, live := range r.strategies {
+		if !live.sub.Matches(candle) {
+			continue
+		}
+		action := live.feed(candle)
+		if action == strategy.ActionHold || live.paused {
+			continue
 		}
+		signals = append(signals, emitted{live, Signal{
+			Strategy: live.strat.Name(),
+			Symbol:   candle.Symbol,
+			Period:   candle.Period,
+			Action:   strings.ToLower(string(action)),
+			Price:    candle.Close,
+			Time:     candle.Timestamp,
+		}})
+	}
+	r.mu.RUnlock()
+
+	// Delivered outside the lock, which pausing and removing take
+	for _, e := range signals {
+		r.logger.Info("STRATEGY SIGNAL",
+			zap.String("strategy", e.signal.Strategy),
+			zap.Int64("deployment_id", e.live.id),
+			zap.String("symbol", e.signal.Symbol),
+			zap.String("period", e.signal.Period),
+			zap.String("action", e.signal.Action),
+			zap.String("price", e.signal.Price.String()),
+			zap.Time("time", e.signal.Time),
+		)
+		if e.live.onSignal != nil {
+			e.live.onSignal(e.signal)
+			continue
+		}
+
+		// 将信号推送到 NATS，以便 UI 实时展示
+		signalSubject := fmt.Sprintf("strategy.signal.%s.%s", e.signal.Strategy, e.signal.Symbol)
+		data, _ := json.Marshal(e.signal)
+		r.js.Publish(signalSubject, data)
 	}
 }
@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
+	"quant-trader/internal/strategy"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// publishRecorder is a JetStream that records what is published
+type publishRecorder struct {
+	nats.JetStreamContext
+	subjects []string
+	data     [][]byte
+}
+
+func (p *publishRecorder) Publish(subject string, data []byte, _ ...nats.PubOpt) (*nats.PubAck, error) {
+	p.subjects = append(p.subjects, subject)
+	p.data = append(p.data, data)
+	return &nats.PubAck{}, nil
+}
+
+// candleCounter buys on every candle it sees and counts them
+type candleCounter struct {
+	seen []model.KLine
+}
+
+func (s *candleCounter) Name() string { return "counter" }
+
+func (s *candleCounter) OnCandle(candle model.KLine) strategy.Action {
+	s.seen = append(s.seen, candle)
+	return strategy.ActionBuy
+}
+
+func TestStrategyRunner_Routing(t *testing.T) {
+	js := &publishRecorder{}
+	r := NewStrategyRunner(js, storage.NewMemoryStore(), zap.NewNop())
+	counter := &candleCounter{}
+	require.NoError(t, r.AddStrategy(context.Background(), "", counter, strategy.Subscription{Symbols: []string{"ETHUSDT"}, Period: "1m"}))
+	require.Error(t, r.AddStrategy(context.Background(), "", &candleCounter{}, strategy.Subscription{Period: "1m"}))
+
+	for _, c := range []model.KLine{
+		{Symbol: "BTCUSDT", Period: "1m"},
+		{Symbol: "ETHUSDT", Period: "5m"},
+		{Symbol: "ETHUSDT", Period: "1m"},
+	} {
+		r.executeStrategies(c)
+	}
+	require.Len(t, counter.seen, 1)
+	assert.Equal(t, "ETHUSDT", counter.seen[0].Symbol)
+	assert.Equal(t, []string{"strategy.signal.counter.ETHUSDT"}, js.subjects)
+}
+
+func TestStrategyRunner_MultiTimeframe(t *testing.T) {
+	var closes []float64
+	for i := 0; i <= 200; i++ {
+		closes = append(closes, 100+float64(i)/2)
+	}
+	for _, c := range []float64{198, 196, 194, 192, 190, 194, 198, 202, 206} {
+		closes = append(closes, c)
+	}
+
+	// Subscribed to 1m only, the strategy still gets the 15m view it asks for
+	js := &publishRecorder{}
+	r := NewStrategyRunner(js, storage.NewMemoryStore(), zap.NewNop())
+	require.NoError(t, r.AddStrategy(context.Background(), "", strategy.NewMTFTrendStrategy("15m", 2, 2, 3), strategy.Subscription{Symbols: []string{"BTCUSDT"}, Period: "1m"}))
+	assert.Equal(t, []string{"15m"}, r.strategies[0].sub.Context)
+	assert.Equal(t, 9, r.strategies[0].sub.Lookback)
+
+	own := strategy.NewMTFTrendStrategy("15m", 2, 2, 3)
+	var want []string
+	for _, c := range symbolBars("BTCUSDT", closes...) {
+		if own.OnCandle(c) != strategy.ActionHold {
+			want = append(want, "strategy.signal.MTF_Trend.BTCUSDT")
+		}
+		r.executeStrategies(c)
+	}
+	require.NotEmpty(t, want)
+	assert.Equal(t, want, js.subjects)
+}
+
+func TestStrategyRunner_WarmUpAndCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	bars := crossBars("BTCUSDT")
+	require.NoError(t, store.Market.UpsertKlines(ctx, bars[:6]))
+	sub := strategy.Subscription{Symbols: []string{"BTCUSDT"}, Period: "1m"}
+
+	// The cross up is in the stored history, so only the cross down is signalled
+	js := &publishRecorder{}
+	r := NewStrategyRunner(js, store, zap.NewNop())
+	first := strategy.NewMACrossStrategy(2, 3)
+	require.NoError(t, r.AddStrategy(ctx, "test:cross", first, sub))
+	assert.Empty(t, js.subjects)
+	r.executeStrategies(bars[6])
+	require.Len(t, js.data, 1)
+	var sig Signal
+	require.NoError(t, json.Unmarshal(js.data[0], &sig))
+	assert.Equal(t, "sell", sig.Action)
+	assert.True(t, bars[6].Timestamp.Equal(sig.Time))
+	r.Checkpoint(ctx)
+
+	// A restart resumes from the checkpoint; the candle it has seen is not
+	// handled again when it is redelivered
+	require.NoError(t, store.Market.UpsertKlines(ctx, bars[6:7]))
+	js = &publishRecorder{}
+	r = NewStrategyRunner(js, store, zap.NewNop())
+	second := strategy.NewMACrossStrategy(2, 3)
+	require.NoError(t, r.AddStrategy(ctx, "test:cross", second, sub))
+	r.executeStrategies(bars[6])
+	assert.Empty(t, js.subjects)
+	want, err := first.Snapshot()
+	require.NoError(t, err)
+	got, err := second.Snapshot()
+	require.NoError(t, err)
+	assert.JSONEq(t, string(want), string(got))
+	assert.True(t, bars[6].Timestamp.Equal(r.strategies[0].last["BTCUSDT"]))
+}
@@ -0,0 +1,234 @@
+package engine
+
+import (
+	"fmt"
+	"maps"
+	"quant-trader/internal/model"
+	"quant-trader/internal/strategy"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// historyLimit bounds the candles kept per symbol for Context.History
+const historyLimit = 1000
+
+// NewStrategyBacktester runs event-driven strategies on a shared account. strats
+// is keyed by symbol like NewMultiSymbolBacktester, the "" key taking every
+// symbol without its own strategy. Each strategy is initialized before it returns.
+func NewStrategyBacktester(strats map[string]strategy.StrategyV2, initialBalance decimal.Decimal) (*Backtester, error) {
+	s := &eventStrategy{
+		bySymbol: make(map[string]*eventRunner, len(strats)),
+		history:  strategy.NewHistoryBuffer(historyLimit),
+		last:     make(map[string]decimal.Decimal),
+	}
+	b := newBacktester(s, initialBalance)
+	s.broker = b
+
+	for _, symbol := range slices.Sorted(maps.Keys(strats)) {
+		r := &eventRunner{s: s, strat: strats[symbol]}
+		s.bySymbol[symbol] = r
+		s.runners = append(s.runners, r)
+		if err := r.strat.Init(r); err != nil {
+			return nil, fmt.Errorf("init strategy %s: %w", r.strat.Name(), err)
+		}
+	}
+	return b, nil
+}
+
+// eventStrategy adapts StrategyV2 strategies to PortfolioStrategy and
+// TickStrategy, turning slices and ticks into their events
+type eventStrategy struct {
+	broker   Broker
+	runners  []*eventRunner // in symbol order
+	bySymbol map[string]*eventRunner
+	history  *strategy.HistoryBuffer
+	last     map[string]decimal.Decimal // last price of each symbol
+	now      time.Time
+	pending  []*ownedOrder // orders not yet reported as finished, in submission order
+	sized    bool          // history was grown for the feed's period
+}
+
+// ownedOrder is an order placed by a strategy and its last reported state
+type ownedOrder struct {
+	id     int64
+	runner *eventRunner
+	status model.OrderStatus
+	filled decimal.Decimal
+}
+
+func (s *eventStrategy) Name() string {
+	var names []string
+	for _, r := range s.runners {
+		if !slices.Contains(names, r.strat.Name()) {
+			names = append(names, r.strat.Name())
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, "+")
+}
+
+func (s *eventStrategy) route(symbol string) *eventRunner {
+	if r, ok := s.bySymbol[symbol]; ok {
+		return r
+	}
+	return s.bySymbol[""]
+}
+
+// OnSlice reports the fills of the slice, then feeds its candles and fires
+// the timers that are due
+func (s *eventStrategy) OnSlice(slice Slice, _ Broker) {
+	s.now = slice.Time
+	s.reportOrders()
+	// Every bar of the slice is in the history before any strategy sees one
+	for _, bar := range slice.Bars {
+		if !s.sized {
+			// Multi-timeframe views resample longer periods from the feed
+			for _, r := range s.runners {
+				s.history.Grow(strategy.TimeframeWindow(r.strat, bar.Period))
+			}
+			s.sized = true
+		}
+		s.history.Add(bar)
+		s.last[bar.Symbol] = bar.Close
+	}
+	for _, bar := range slice.Bars {
+		if r := s.route(bar.Symbol); r != nil {
+			r.strat.OnCandle(r, bar)
+		}
+	}
+	s.fireTimers()
+	s.reportOrders()
+}
+
+// OnTick implements TickStrategy: public trades go to OnTrade
+func (s *eventStrategy) OnTick(tick Tick, _ Broker) {
+	s.now = tick.Time()
+	s.reportOrders()
+	if t := tick.Trade; t != nil {
+		s.last[t.Symbol] = t.Price
+		if r := s.route(t.Symbol); r != nil {
+			r.strat.OnTrade(r, *t)
+		}
+	}
+	s.fireTimers()
+	s.reportOrders()
+}
+
+// reportOrders calls OnOrderUpdate for every owned order whose status or
+// filled quantity changed since it was last reported
+func (s *eventStrategy) reportOrders() {
+	// Orders submitted from OnOrderUpdate collect in s.pending meanwhile
+	reported := s.pending
+	s.pending = nil
+	var open []*ownedOrder
+	for _, o := range reported {
+		order, ok := s.broker.Order(o.id)
+		if !ok {
+			continue
+		}
+		if order.Status != o.status || !order.FilledQty.Equal(o.filled) {
+			o.status, o.filled = order.Status, order.FilledQty
+			o.runner.strat.OnOrderUpdate(o.runner, order)
+		}
+		if order.Status == model.OrderOpen {
+			open = append(open, o)
+		}
+	}
+	s.pending = append(open, s.pending...)
+}
+
+// fireTimers calls OnTimer once for each strategy whose next boundary has
+// passed; boundaries missed in a gap are not replayed
+func (s *eventStrategy) fireTimers() {
+	for _, r := range s.runners {
+		if r.interval <= 0 {
+			continue
+		}
+		if r.next.IsZero() {
+			r.next = s.now.Truncate(r.interval).Add(r.interval)
+			continue
+		}
+		if s.now.Before(r.next) {
+			continue
+		}
+		at := r.next
+		r.next = s.now.Truncate(r.interval).Add(r.interval)
+		r.strat.OnTimer(r, at)
+	}
+}
+
+// eventRunner is the strategy.Context of one strategy
+type eventRunner struct {
+	s        *eventStrategy
+	strat    strategy.StrategyV2
+	interval time.Duration
+	next     time.Time
+}
+
+func (r *eventRunner) Now() time.Time {
+	return r.s.now
+}
+
+func (r *eventRunner) Position(symbol string) strategy.PositionInfo {
+	p := r.s.broker.Position(symbol)
+	return strategy.PositionInfo{
+		Symbol:        symbol,
+		Qty:           p.Qty,
+		EntryPrice:    p.EntryPrice,
+		UnrealizedPnL: p.unrealized(r.s.last[symbol]),
+	}
+}
+
+func (r *eventRunner) Account() strategy.AccountInfo {
+	broker := r.s.broker
+	margin := broker.Margin()
+	return strategy.AccountInfo{
+		Cash:        broker.Cash(),
+		Equity:      broker.Equity(),
+		BuyingPower: broker.BuyingPower(),
+		Leverage:    margin.Leverage,
+		AllowShort:  margin.AllowShort,
+	}
+}
+
+func (r *eventRunner) History(symbol, period string, n int) []model.KLine {
+	return r.s.history.History(symbol, period, n)
+}
+
+func (r *eventRunner) Indicators(symbol, period string) strategy.Indicators {
+	return strategy.NewIndicators(r.History(symbol, period, 0))
+}
+
+func (r *eventRunner) OpenOrders(symbol string) []model.Order {
+	return r.s.broker.OpenOrders(symbol)
+}
+
+func (r *eventRunner) Size(intent strategy.OrderIntent) (model.OrderRequest, error) {
+	return strategy.SizeIntent(intent, r.s.last[intent.Symbol], r.Account())
+}
+
+func (r *eventRunner) Submit(intent strategy.OrderIntent) (int64, error) {
+	req, err := r.Size(intent)
+	if err != nil {
+		return 0, err
+	}
+	id, err := r.s.broker.Submit(req)
+	if err != nil {
+		return 0, err
+	}
+	r.s.pending = append(r.s.pending, &ownedOrder{id: id, runner: r, status: model.OrderOpen})
+	return id, nil
+}
+
+func (r *eventRunner) Cancel(orderID int64) bool {
+	return r.s.broker.Cancel(orderID)
+}
+
+func (r *eventRunner) SetTimer(interval time.Duration) {
+	r.interval = interval
+	r.next = time.Time{}
+}
@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"errors"
+	"quant-trader/internal/model"
+	"quant-trader/internal/strategy"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrategyBacktester_AdaptedMatchesActionStrategy(t *testing.T) {
+	closes := []float64{100, 99, 98, 97, 99, 102, 105, 107, 106, 103, 99, 96, 95, 97, 100, 104, 108, 107, 103, 98}
+	candles := symbolBars("BTCUSDT", closes...)
+
+	for _, newStrat := range []func() strategy.Strategy{
+		func() strategy.Strategy { return strategy.NewMAStrategy(2, 4) },
+		func() strategy.Strategy { return strategy.NewMACrossStrategy(2, 4) },
+	} {
+		want := NewBacktester(newStrat(), decimal.NewFromInt(10000)).Run(candles)
+
+		b, err := NewStrategyBacktester(map[string]strategy.StrategyV2{"": strategy.Adapt(newStrat())}, decimal.NewFromInt(10000))
+		require.NoError(t, err)
+		got := b.Run(candles)
+
+		require.NotZero(t, want.TotalTrades, newStrat().Name())
+		assert.Equal(t, want.TradesLog, got.TradesLog, newStrat().Name())
+		assert.True(t, want.FinalBalance.Equal(got.FinalBalance), newStrat().Name())
+	}
+}
+
+func TestStrategyBacktester_MultiTimeframe(t *testing.T) {
+	var closes []float64
+	for i := 0; i <= 200; i++ {
+		closes = append(closes, 100+float64(i)/2)
+	}
+	for _, c := range []float64{198, 196, 194, 192, 190, 194, 198, 202, 206, 199, 192} {
+		closes = append(closes, c)
+	}
+	candles := symbolBars("BTCUSDT", closes...)
+
+	// The backtest context is the same synchronized view the strategy keeps
+	// for itself, so both runs trade alike
+	want := NewBacktester(strategy.NewMTFTrendStrategy("15m", 2, 2, 3), decimal.NewFromInt(10000)).Run(candles)
+
+	adapted := strategy.Adapt(strategy.NewMTFTrendStrategy("15m", 2, 2, 3))
+	require.Nil(t, strategy.Legacy(adapted))
+	b, err := NewStrategyBacktester(map[string]strategy.StrategyV2{"": adapted}, decimal.NewFromInt(10000))
+	require.NoError(t, err)
+	got := b.Run(candles)
+
+	require.NotZero(t, want.TotalTrades)
+	assert.Equal(t, want.TradesLog, got.TradesLog)
+	assert.True(t, want.FinalBalance.Equal(got.FinalBalance))
+}
+
+// eventRecorder buys on its first candle with a limit below the market and
+// records every event it sees
+type eventRecorder struct {
+	history int
+	updates []model.Order
+	timers  []time.Time
+	trades  int
+	pos     strategy.PositionInfo
+	account strategy.AccountInfo
+}
+
+func (s *eventRecorder) Name() string { return "recorder" }
+
+func (s *eventRecorder) Init(ctx strategy.Context) error {
+	ctx.SetTimer(2 * time.Minute)
+	return nil
+}
+
+func (s *eventRecorder) OnCandle(ctx strategy.Context, candle model.KLine) {
+	s.history = len(ctx.History(candle.Symbol, "1m", 0))
+	if s.history == 1 {
+		_, err := ctx.Submit(strategy.OrderIntent{
+			Symbol: candle.Symbol, Side: model.SideBuy, Type: model.OrderLimit,
+			LimitPrice: decimal.NewFromInt(95), Notional: decimal.NewFromInt(950),
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+	s.pos = ctx.Position(candle.Symbol)
+	s.account = ctx.Account()
+}
+
+func (s *eventRecorder) OnTrade(strategy.Context, model.Trade) { s.trades++ }
+
+func (s *eventRecorder) OnOrderUpdate(_ strategy.Context, order model.Order) {
+	s.updates = append(s.updates, order)
+}
+
+func (s *eventRecorder) OnTimer(_ strategy.Context, now time.Time) {
+	s.timers = append(s.timers, now)
+}
+
+func TestStrategyBacktester_Events(t *testing.T) {
+	rec := &eventRecorder{}
+	b, err := NewStrategyBacktester(map[string]strategy.StrategyV2{"BTCUSDT": rec}, decimal.NewFromInt(10000))
+	require.NoError(t, err)
+	withoutCosts(b)
+
+	b.Run([]model.KLine{
+		bar(0, 100, 100, 100, 100),
+		bar(1, 99, 99, 97, 98),
+		bar(2, 97, 97, 94, 96), // limit 95 fills
+		bar(3, 96, 100, 96, 100),
+		bar(4, 100, 100, 100, 100),
+	})
+
+	assert.Equal(t, 5, rec.history)
+	require.Len(t, rec.updates, 1)
+	assert.Equal(t, model.OrderFilled, rec.updates[0].Status)
+	assert.Equal(t, "10", rec.updates[0].FilledQty.String())
+	assert.Equal(t, "10", rec.pos.Qty.String())
+	assert.Equal(t, "50", rec.pos.UnrealizedPnL.String())
+	assert.Equal(t, "10050", rec.account.Equity.String())
+	assert.Equal(t, []time.Time{bar(2, 0, 0, 0, 0).Timestamp, bar(4, 0, 0, 0, 0).Timestamp}, rec.timers)
+	assert.Zero(t, rec.trades, "candle backtests have no public trades")
+}
+
+func TestStrategyBacktester_OnTrade(t *testing.T) {
+	rec := &eventRecorder{}
+	b, err := NewStrategyBacktester(map[string]strategy.StrategyV2{"": rec}, decimal.NewFromInt(10000))
+	require.NoError(t, err)
+
+	_, err = b.RunTicks(&sliceTicks{ticks: []Tick{
+		tradeTick(0, 100, 1, "buy"),
+		tradeTick(30, 101, 1, "sell"),
+		tradeTick(60, 102, 1, "buy"),
+	}}, "1m")
+	require.NoError(t, err)
+	assert.Equal(t, 3, rec.trades)
+	assert.Equal(t, 2, rec.history)
+}
+
+// failingInit cannot start
+type failingInit struct{ eventRecorder }
+
+func (failingInit) Init(strategy.Context) error { return errors.New("bad params") }
+
+func TestStrategyBacktester_InitError(t *testing.T) {
+	_, err := NewStrategyBacktester(map[string]strategy.StrategyV2{"": &failingInit{}}, decimal.NewFromInt(10000))
+	assert.ErrorContains(t, err, "bad params")
+}
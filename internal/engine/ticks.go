@@ -0,0 +1,551 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Tick is one market event of a tick-level backtest: a trade or a depth snapshot
+type Tick struct {
+	Trade *model.Trade
+	Book  *model.OrderBook
+}
+
+func (t Tick) Symbol() string {
+	if t.Trade != nil {
+		return t.Trade.Symbol
+	}
+	return t.Book.Symbol
+}
+
+func (t Tick) Time() time.Time {
+	if t.Trade != nil {
+		return t.Trade.Timestamp
+	}
+	return t.Book.Timestamp
+}
+
+// TickStrategy is a PortfolioStrategy that also sees every tick RunTicks replays
+type TickStrategy interface {
+	// OnTick is called after the open orders of tick's symbol have been matched
+	OnTick(tick Tick, broker Broker)
+}
+
+// TickSource streams ticks in time order, e.g. a *TickStream from DataLoader or
+// a *MergedTicks over several symbols
+type TickSource interface {
+	Next() bool
+	Tick() Tick
+	Err() error
+}
+
+// MergedTicks merges tick streams into one stream in time order. Ticks with
+// equal timestamps keep the order of the sources.
+type MergedTicks struct {
+	sources []TickSource
+	heads   []Tick
+	alive   []bool
+	started bool
+	cur     Tick
+	err     error
+}
+
+// MergeTicks creates a MergedTicks; each source must already be in time order
+func MergeTicks(sources ...TickSource) *MergedTicks {
+	return &MergedTicks{
+		sources: sources,
+		heads:   make([]Tick, len(sources)),
+		alive:   make([]bool, len(sources)),
+	}
+}
+
+func (m *MergedTicks) Next() bool {
+	if m.err != nil {
+		return false
+	}
+	if !m.started {
+		m.started = true
+		for i := range m.sources {
+			if !m.advance(i) {
+				return false
+			}
+		}
+	}
+
+	next := -1
+	for i, ok := range m.alive {
+		if ok && (next < 0 || m.heads[i].Time().Before(m.heads[next].Time())) {
+			next = i
+		}
+	}
+	if next < 0 {
+		return false
+	}
+	m.cur = m.heads[next]
+	return m.advance(next)
+}
+
+// advance loads the next head of source i; it reports false only on an error
+func (m *MergedTicks) advance(i int) bool {
+	if m.sources[i].Next() {
+		m.heads[i] = m.sources[i].Tick()
+		m.alive[i] = true
+		return true
+	}
+	m.alive[i] = false
+	if err := m.sources[i].Err(); err != nil {
+		m.err = err
+		return false
+	}
+	return true
+}
+
+func (m *MergedTicks) Tick() Tick { return m.cur }
+
+// Err returns the first error of any source
+func (m *MergedTicks) Err() error { return m.err }
+
+// tradeTicks and bookTicks adapt the storage iterators to TickSource
+type tradeTicks struct{ storage.TradeIterator }
+
+func (t tradeTicks) Tick() Tick {
+	trade := t.Trade()
+	return Tick{Trade: &trade}
+}
+
+type bookTicks struct{ storage.OrderBookIterator }
+
+func (t bookTicks) Tick() Tick {
+	book := t.OrderBook()
+	return Tick{Book: &book}
+}
+
+// TickStream is the trades of one symbol merged with its depth snapshots
+type TickStream struct {
+	*MergedTicks
+	trades storage.TradeIterator
+	books  storage.OrderBookIterator
+}
+
+func (s *TickStream) Close() {
+	s.trades.Close()
+	s.books.Close()
+}
+
+// Ticks streams the trades and order book snapshots of req's symbol in
+// [Start, End), a snapshot first when both share a timestamp. Period is not
+// used; bars are built from the trades by the backtester.
+func (l *DataLoader) Ticks(ctx context.Context, req CandleRequest) (*TickStream, error) {
+	if req.Symbol == "" {
+		return nil, errors.New("symbol is required")
+	}
+	if !req.End.After(req.Start) {
+		return nil, errors.New("end time must be after start time")
+	}
+
+	q := storage.TickQuery{Symbol: req.Symbol, Exchange: req.Exchange, Start: req.Start, End: req.End}
+	books, err := l.repo.IterateOrderBooks(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order books: %w", err)
+	}
+	trades, err := l.repo.IterateTrades(ctx, q)
+	if err != nil {
+		books.Close()
+		return nil, fmt.Errorf("failed to query trades: %w", err)
+	}
+	return &TickStream{
+		MergedTicks: MergeTicks(bookTicks{books}, tradeTicks{trades}),
+		trades:      trades,
+		books:       books,
+	}, nil
+}
+
+// RunTicks replays trades and depth snapshots instead of candles. Orders are
+// matched against every tick:
+//   - market and triggered stop orders walk the latest snapshot of the book,
+//     or fill at the next trade plus the slippage model when there is none
+//   - resting limit orders join the back of the queue at their price and fill
+//     once trades at that price have consumed the quantity in front of them, or
+//     as soon as a trade goes through their price
+//
+// Trades are also aggregated into bars of period, which the strategy sees
+// exactly as in RunSource, so reports of both modes can be compared. Periods
+// without trades produce no bar.
+func (b *Backtester) RunTicks(src TickSource, period string) (model.BacktestReport, error) {
+	initialBalance := b.balance
+	step := model.PeriodToDuration(period)
+	b.period = step
+	b.ticks = newTickState(step)
+	defer func() { b.ticks = nil }()
+
+	var (
+		bucket time.Time
+		bars   []*model.KLine // bars of the current bucket in order of their first trade
+		latest = make(map[string]model.KLine)
+	)
+	closeBucket := func() {
+		if len(bars) == 0 {
+			return
+		}
+		slice := Slice{Time: bucket, Bars: make([]model.KLine, len(bars)), Latest: latest}
+		for i, bar := range bars {
+			slice.Bars[i] = *bar
+			b.settleFunding(*bar)
+			b.closeBar(*bar)
+			latest[bar.Symbol] = *bar
+		}
+		bars = nil
+		b.ticks.bars = make(map[string]*model.KLine)
+		b.closeSlice(slice)
+	}
+
+	for src.Next() {
+		tick := src.Tick()
+		at := tick.Time()
+		switch start := at.Truncate(step); {
+		case start.Before(bucket):
+			return model.BacktestReport{}, fmt.Errorf("tick for %s at %s is out of time order", tick.Symbol(), at.UTC().Format(time.RFC3339Nano))
+		case start.After(bucket):
+			closeBucket()
+			bucket = start
+		}
+		b.now = at
+
+		if tick.Book != nil {
+			if err := b.ticks.update(*tick.Book); err != nil {
+				return model.BacktestReport{}, err
+			}
+		}
+		b.matchTick(tick)
+		if t := tick.Trade; t != nil {
+			bar, ok := b.ticks.bars[t.Symbol]
+			if !ok {
+				bar = &model.KLine{Symbol: t.Symbol, Exchange: t.Exchange, Period: period, Open: t.Price, High: t.Price, Low: t.Price, Timestamp: bucket}
+				b.ticks.bars[t.Symbol] = bar
+				bars = append(bars, bar)
+			}
+			bar.High = decimal.Max(bar.High, t.Price)
+			bar.Low = decimal.Min(bar.Low, t.Price)
+			bar.Close = t.Price
+			bar.Volume = bar.Volume.Add(t.Amount)
+		}
+		if ts, ok := b.strategy.(TickStrategy); ok {
+			ts.OnTick(tick, b)
+		}
+	}
+	if err := src.Err(); err != nil {
+		return model.BacktestReport{}, err
+	}
+	closeBucket()
+
+	b.closeOut()
+	return b.report(initialBalance), nil
+}
+
+// matchTick fills, expires or cancels the open orders of tick's symbol, in
+// submission order
+func (b *Backtester) matchTick(tick Tick) {
+	symbol := tick.Symbol()
+	for _, o := range b.open {
+		if o.Symbol != symbol || o.Status != model.OrderOpen {
+			continue
+		}
+		if o.TIF == model.TIFGoodTillDate && b.now.After(o.ExpireAt) {
+			b.finish(o, model.OrderExpired, "")
+		} else if exec, ok := b.ticks.match(o, tick, b.now); ok {
+			b.fill(o, exec, b.ticks.bar(symbol, b.lastBars[symbol]))
+		} else if o.TIF == model.TIFImmediate {
+			b.finish(o, model.OrderCancelled, "not filled on the next tick")
+		}
+		if o.Status != model.OrderOpen {
+			b.ticks.forget(o.ID)
+		}
+	}
+	b.pruneOpen()
+}
+
+// bookLevel is one parsed price level of a depth snapshot
+type bookLevel struct {
+	price decimal.Decimal
+	qty   decimal.Decimal
+}
+
+// depth is a parsed snapshot, bids best (highest) first and asks best (lowest) first
+type depth struct {
+	time time.Time
+	bids []bookLevel
+	asks []bookLevel
+}
+
+// tickState is the matching state of a tick replay
+type tickState struct {
+	maxAge time.Duration // snapshots older than this are ignored
+	books  map[string]*depth
+	bars   map[string]*model.KLine // bars being built in the current period
+	// ahead is the quantity queued in front of each resting limit order
+	ahead map[int64]decimal.Decimal
+	// fresh orders have not seen a tick since they were submitted
+	fresh map[int64]bool
+}
+
+func newTickState(maxAge time.Duration) *tickState {
+	return &tickState{
+		maxAge: maxAge,
+		books:  make(map[string]*depth),
+		bars:   make(map[string]*model.KLine),
+		ahead:  make(map[int64]decimal.Decimal),
+		fresh:  make(map[int64]bool),
+	}
+}
+
+// update replaces the book of the snapshot's symbol
+func (s *tickState) update(book model.OrderBook) error {
+	parse := func(levels [][2]string) ([]bookLevel, error) {
+		out := make([]bookLevel, 0, len(levels))
+		for _, l := range levels {
+			price, err := decimal.NewFromString(l[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid order book price %q for %s: %w", l[0], book.Symbol, err)
+			}
+			qty, err := decimal.NewFromString(l[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid order book amount %q for %s: %w", l[1], book.Symbol, err)
+			}
+			if price.IsPositive() && qty.IsPositive() {
+				out = append(out, bookLevel{price: price, qty: qty})
+			}
+		}
+		return out, nil
+	}
+	bids, err := parse(book.Bids)
+	if err != nil {
+		return err
+	}
+	asks, err := parse(book.Asks)
+	if err != nil {
+		return err
+	}
+	sort.Slice(bids, func(i, j int) bool { return bids[i].price.GreaterThan(bids[j].price) })
+	sort.Slice(asks, func(i, j int) bool { return asks[i].price.LessThan(asks[j].price) })
+	s.books[book.Symbol] = &depth{time: book.Timestamp, bids: bids, asks: asks}
+	return nil
+}
+
+// book returns the snapshot of symbol unless it is older than maxAge at now
+func (s *tickState) book(symbol string, now time.Time) *depth {
+	d, ok := s.books[symbol]
+	if !ok || now.Sub(d.time) > s.maxAge {
+		return nil
+	}
+	return d
+}
+
+// bar is the bar fills of symbol are priced against: the one being built, else last
+func (s *tickState) bar(symbol string, last model.KLine) model.KLine {
+	if bar, ok := s.bars[symbol]; ok {
+		return *bar
+	}
+	return last
+}
+
+// join registers a new order; a limit order queues behind the quantity
+// already resting at its price
+func (s *tickState) join(o *model.Order) {
+	s.fresh[o.ID] = true
+	if o.Type == model.OrderLimit {
+		s.queue(o, o.CreatedAt)
+	}
+}
+
+func (s *tickState) queue(o *model.Order, now time.Time) {
+	s.ahead[o.ID] = decimal.Zero
+	if d := s.book(o.Symbol, now); d != nil {
+		s.ahead[o.ID] = restingAt(d, o.Side, o.LimitPrice)
+	}
+}
+
+func (s *tickState) forget(orderID int64) {
+	delete(s.ahead, orderID)
+	delete(s.fresh, orderID)
+}
+
+// match decides whether o executes on tick and at what price, before costs
+func (s *tickState) match(o *model.Order, tick Tick, now time.Time) (Execution, bool) {
+	fresh := s.fresh[o.ID]
+	delete(s.fresh, o.ID)
+	buy := o.Side == model.SideBuy
+
+	switch o.Type {
+	case model.OrderMarket:
+		return s.take(o, tick, now)
+	case model.OrderStop:
+		if tick.Trade != nil && crossed(buy, tick.Trade.Price, o.StopPrice) {
+			return s.take(o, tick, now)
+		}
+	case model.OrderStopLimit:
+		if o.Triggered {
+			return s.rest(o, tick, now, false)
+		}
+		if tick.Trade == nil || !crossed(buy, tick.Trade.Price, o.StopPrice) {
+			return Execution{}, false
+		}
+		o.Triggered = true
+		// As on candles: fill at the trigger price if it is within the limit,
+		// otherwise rest at the limit from now on
+		if price := tick.Trade.Price; (buy && price.LessThanOrEqual(o.LimitPrice)) || (!buy && price.GreaterThanOrEqual(o.LimitPrice)) {
+			return Execution{Price: price}, true
+		}
+		s.queue(o, now)
+	case model.OrderLimit:
+		return s.rest(o, tick, now, fresh)
+	}
+	return Execution{}, false
+}
+
+// crossed reports whether price is at or beyond level in the direction a buy
+// stop (up) or sell stop (down) triggers
+func crossed(buy bool, price, level decimal.Decimal) bool {
+	if buy {
+		return price.GreaterThanOrEqual(level)
+	}
+	return price.LessThanOrEqual(level)
+}
+
+// take executes o as a taker: by walking the book if a recent snapshot has the
+// needed side, otherwise at the trade's price
+func (s *tickState) take(o *model.Order, tick Tick, now time.Time) (Execution, bool) {
+	if d := s.book(o.Symbol, now); d != nil {
+		levels := d.asks
+		if o.Side == model.SideSell {
+			levels = d.bids
+		}
+		if price, ok := walk(levels, o.Remaining()); ok {
+			return Execution{Price: price, Taker: true, Impact: true}, true
+		}
+	}
+	if tick.Trade != nil {
+		return Execution{Price: tick.Trade.Price, Taker: true}, true
+	}
+	return Execution{}, false
+}
+
+// rest matches a limit order. A fresh order that is already marketable takes
+// the book up to its limit, or fills at the first trade beyond it. A resting one
+// fills at its limit once a trade goes through it or the queue in front of it
+// has traded away; snapshots showing less quantity at its price shorten the queue.
+func (s *tickState) rest(o *model.Order, tick Tick, now time.Time, fresh bool) (Execution, bool) {
+	buy := o.Side == model.SideBuy
+	limit := o.LimitPrice
+
+	if tick.Book != nil {
+		d := s.book(o.Symbol, now)
+		if d == nil {
+			return Execution{}, false
+		}
+		if fresh {
+			levels := d.asks
+			if !buy {
+				levels = d.bids
+			}
+			var within []bookLevel
+			for _, l := range levels {
+				if (buy && l.price.GreaterThan(limit)) || (!buy && l.price.LessThan(limit)) {
+					break
+				}
+				within = append(within, l)
+			}
+			if price, ok := walk(within, o.Remaining()); ok {
+				return Execution{Price: price, Taker: true, Impact: true}, true
+			}
+		}
+		if ahead, ok := s.ahead[o.ID]; ok {
+			s.ahead[o.ID] = decimal.Min(ahead, restingAt(d, o.Side, limit))
+		}
+		return Execution{}, false
+	}
+
+	trade := tick.Trade
+	through := (buy && trade.Price.LessThan(limit)) || (!buy && trade.Price.GreaterThan(limit))
+	at := trade.Price.Equal(limit)
+	switch {
+	case through && fresh:
+		// Marketable on arrival, like a candle opening beyond the limit
+		return Execution{Price: trade.Price}, true
+	case through:
+		return Execution{Price: limit}, true
+	case at && model.OrderSide(trade.Side) != o.Side:
+		// A trade at our price against our side of the book; one whose
+		// aggressor is unknown counts as well
+		ahead := s.ahead[o.ID].Sub(trade.Amount)
+		s.ahead[o.ID] = ahead
+		if ahead.IsNegative() {
+			return Execution{Price: limit}, true
+		}
+	}
+	return Execution{}, false
+}
+
+// restingAt is the quantity resting on side's half of the book at exactly price
+func restingAt(d *depth, side model.OrderSide, price decimal.Decimal) decimal.Decimal {
+	levels := d.bids
+	if side == model.SideSell {
+		levels = d.asks
+	}
+	for _, l := range levels {
+		if l.price.Equal(price) {
+			return l.qty
+		}
+	}
+	return decimal.Zero
+}
+
+// walk returns the average price of taking qty from levels, best first.
+// Quantity beyond the snapshot is assumed to fill at its worst level.
+func walk(levels []bookLevel, qty decimal.Decimal) (decimal.Decimal, bool) {
+	if len(levels) == 0 || !qty.IsPositive() {
+		return decimal.Zero, false
+	}
+	remaining, cost := qty, decimal.Zero
+	for _, l := range levels {
+		take := decimal.Min(remaining, l.qty)
+		cost = cost.Add(take.Mul(l.price))
+		remaining = remaining.Sub(take)
+		if remaining.IsZero() {
+			break
+		}
+	}
+	if remaining.IsPositive() {
+		cost = cost.Add(remaining.Mul(levels[len(levels)-1].price))
+	}
+	return cost.Div(qty), true
+}
+
+// tickContext ends a tick stream with ctx's error once ctx is done
+type tickContext struct {
+	ctx context.Context
+	src TickSource
+	err error
+}
+
+func (s *tickContext) Next() bool {
+	if s.err = s.ctx.Err(); s.err != nil {
+		return false
+	}
+	return s.src.Next()
+}
+
+func (s *tickContext) Tick() Tick { return s.src.Tick() }
+
+func (s *tickContext) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.src.Err()
+}
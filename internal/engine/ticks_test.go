@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var tickStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func tradeTick(sec int, price, amount float64, side string) Tick {
+	return Tick{Trade: &model.Trade{
+		Symbol:    "BTCUSDT",
+		Price:     decimal.NewFromFloat(price),
+		Amount:    decimal.NewFromFloat(amount),
+		Side:      side,
+		Timestamp: tickStart.Add(time.Duration(sec) * time.Second),
+	}}
+}
+
+func bookTick(sec int, bids, asks [][2]string) Tick {
+	return Tick{Book: &model.OrderBook{
+		Symbol:    "BTCUSDT",
+		Bids:      bids,
+		Asks:      asks,
+		Timestamp: tickStart.Add(time.Duration(sec) * time.Second),
+	}}
+}
+
+// sliceTicks replays ticks held in memory
+type sliceTicks struct {
+	ticks []Tick
+	pos   int
+}
+
+func (s *sliceTicks) Next() bool {
+	s.pos++
+	return s.pos <= len(s.ticks)
+}
+
+func (s *sliceTicks) Tick() Tick { return s.ticks[s.pos-1] }
+
+func (s *sliceTicks) Err() error { return nil }
+
+func TestBacktester_MarketOrderWalksBook(t *testing.T) {
+	strat := &scriptedStrategy{script: map[int][]model.OrderRequest{
+		0: {{Side: model.SideBuy, Type: model.OrderMarket, Qty: decimal.NewFromInt(3)}},
+	}}
+	tester := NewOrderBacktester(strat, decimal.NewFromInt(10000))
+	report, err := tester.RunTicks(&sliceTicks{ticks: []Tick{
+		tradeTick(0, 100, 1, "buy"),
+		bookTick(60, [][2]string{{"99", "4"}}, [][2]string{{"101", "1"}, {"100", "1"}, {"102", "5"}}),
+		tradeTick(61, 100, 1, "buy"),
+	}}, "1m")
+	require.NoError(t, err)
+
+	require.NotEmpty(t, report.TradesLog)
+	fill := report.TradesLog[0]
+	assert.True(t, fill.Price.Equal(decimal.NewFromInt(101)), "average of 100, 101 and 102 without extra slippage: %s", fill.Price)
+	assert.True(t, fill.Time.Equal(tickStart.Add(time.Minute)))
+	assert.Len(t, report.EquityCurve, 2)
+}
+
+func TestBacktester_LimitOrderQueue(t *testing.T) {
+	strat := &scriptedStrategy{script: map[int][]model.OrderRequest{
+		0: {{Side: model.SideBuy, Type: model.OrderLimit, Qty: decimal.NewFromInt(1), LimitPrice: decimal.NewFromInt(99)}},
+	}}
+	tester := newFrictionless(strat)
+	report, err := tester.RunTicks(&sliceTicks{ticks: []Tick{
+		tradeTick(0, 100, 1, "buy"),
+		// Five units bid at 99 are ahead of the order
+		bookTick(59, [][2]string{{"99", "5"}}, [][2]string{{"100", "2"}}),
+		tradeTick(61, 99, 2, "sell"),
+		tradeTick(62, 99, 3, "buy"),                                       // lifts the offer side, not the bids
+		bookTick(63, [][2]string{{"99", "2"}}, [][2]string{{"100", "2"}}), // one unit cancelled ahead
+		tradeTick(64, 99, 2, "sell"),
+		tradeTick(65, 99, 1, "sell"),
+		tradeTick(66, 100, 1, "buy"),
+	}}, "1m")
+	require.NoError(t, err)
+
+	require.NotEmpty(t, report.TradesLog)
+	fill := report.TradesLog[0]
+	assert.True(t, fill.Price.Equal(decimal.NewFromInt(99)))
+	assert.True(t, fill.Time.Equal(tickStart.Add(65*time.Second)), "fills once the queue ahead is gone: %s", fill.Time)
+}
+
+func TestBacktester_TickStopsAndLimits(t *testing.T) {
+	px := decimal.NewFromFloat
+	strat := &scriptedStrategy{script: map[int][]model.OrderRequest{
+		0: {{Side: model.SideBuy, Type: model.OrderMarket, Qty: px(1)}},
+		1: {
+			{Side: model.SideSell, Type: model.OrderStop, Qty: px(1), StopPrice: px(95)},
+			{Side: model.SideBuy, Type: model.OrderLimit, Qty: px(1), LimitPrice: px(90)},
+		},
+	}}
+	tester := newFrictionless(strat)
+	report, err := tester.RunTicks(&sliceTicks{ticks: []Tick{
+		tradeTick(0, 100, 1, "buy"),
+		tradeTick(60, 100, 1, "buy"),
+		tradeTick(120, 97, 1, "sell"),
+		tradeTick(121, 94, 1, "sell"),
+		tradeTick(122, 89, 1, "sell"), // through the limit
+	}}, "1m")
+	require.NoError(t, err)
+
+	require.Len(t, report.TradesLog, 4) // buy, stop, limit, close out
+	assert.True(t, report.TradesLog[1].Price.Equal(px(94)), "stop fills at the trade that triggers it")
+	assert.True(t, report.TradesLog[2].Price.Equal(px(90)), "resting limit fills at its price")
+}
+
+func TestBacktester_TicksOutOfOrder(t *testing.T) {
+	tester := newFrictionless(&scriptedStrategy{})
+	_, err := tester.RunTicks(&sliceTicks{ticks: []Tick{
+		tradeTick(60, 100, 1, "buy"),
+		tradeTick(0, 100, 1, "buy"),
+	}}, "1m")
+	assert.Error(t, err)
+}
+
+// Ticks tracing open, low, high and close of each candle give the same report
+func TestBacktestSpec_TicksMatchCandles(t *testing.T) {
+	store := storage.NewMemoryStore()
+	ctx := context.Background()
+	var klines []model.KLine
+	var trades []model.Trade
+	for i := 0; i < 10; i++ {
+		p := float64(100 + 3*i%7)
+		k := bar(i, p, p+2, p-1, p+1)
+		k.Exchange = "binance"
+		klines = append(klines, k)
+		for j, price := range []decimal.Decimal{k.Open, k.Low, k.High, k.Close} {
+			trades = append(trades, model.Trade{
+				ID:        fmt.Sprintf("%d-%d", i, j),
+				Symbol:    k.Symbol,
+				Exchange:  k.Exchange,
+				Price:     price,
+				Amount:    decimal.NewFromInt(1),
+				Side:      "buy",
+				Timestamp: k.Timestamp.Add(time.Duration(j*15) * time.Second),
+			})
+		}
+	}
+	require.NoError(t, store.Market.UpsertKlines(ctx, klines))
+	require.NoError(t, store.Market.InsertTrades(ctx, trades))
+	loader := NewDataLoader(store.Market)
+
+	spec := maSpec(tickStart)
+	candles, err := spec.Run(ctx, loader)
+	require.NoError(t, err)
+	spec.Data = "ticks"
+	ticks, err := spec.Run(ctx, loader)
+	require.NoError(t, err)
+
+	require.NotZero(t, candles.TotalTrades)
+	assert.Equal(t, candles.TotalTrades, ticks.TotalTrades)
+	assert.True(t, candles.FinalBalance.Equal(ticks.FinalBalance), "%s != %s", candles.FinalBalance, ticks.FinalBalance)
+	assert.Equal(t, len(candles.EquityCurve), len(ticks.EquityCurve))
+
+	spec.Data = "quotes"
+	assert.Error(t, spec.Validate())
+}
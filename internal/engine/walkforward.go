@@ -0,0 +1,248 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"quant-trader/internal/metrics"
+	"quant-trader/internal/model"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// WalkForward re-optimizes a strategy on successive in-sample windows and trades
+// each window's winner on the out-of-sample bars that follow it. Out-of-sample
+// runs start flat with cold strategies, compounding from the previous run's
+// final balance.
+type WalkForward struct {
+	Optimization    *Optimization
+	InSampleBars    int
+	OutOfSampleBars int
+	Anchored        bool // in-sample windows all start at the first bar and grow
+	// PBOBlocks is the number of blocks for the probability of backtest
+	// overfitting; even, 10 by default
+	PBOBlocks int
+	// Progress is called after each window and after the diagnostics
+	Progress func(done, total int)
+}
+
+// WalkForwardWindow is one in-sample optimization and its out-of-sample run;
+// times are those of the first and last bar
+type WalkForwardWindow struct {
+	InSampleStart     time.Time          `json:"in_sample_start"`
+	InSampleEnd       time.Time          `json:"in_sample_end"`
+	OutOfSampleStart  time.Time          `json:"out_of_sample_start"`
+	OutOfSampleEnd    time.Time          `json:"out_of_sample_end"`
+	Params            map[string]float64 `json:"params"`
+	InSampleScore     float64            `json:"in_sample_score"`
+	InSampleCAGR      float64            `json:"in_sample_cagr"`
+	OutOfSampleScore  float64            `json:"out_of_sample_score"`
+	OutOfSampleCAGR   float64            `json:"out_of_sample_cagr"`
+	OutOfSampleReturn float64            `json:"out_of_sample_return"`
+	Trades            int                `json:"trades"`
+}
+
+// WalkForwardResult is the stitched out-of-sample performance and the
+// overfitting diagnostics of a walk-forward analysis
+type WalkForwardResult struct {
+	StrategyType string              `json:"strategy_type"`
+	Objective    string              `json:"objective"`
+	Anchored     bool                `json:"anchored"`
+	Windows      []WalkForwardWindow `json:"windows"`
+	// EquityCurve chains the out-of-sample equity curves of all windows
+	EquityCurve    []model.EquityPoint `json:"equity_curve"`
+	InitialBalance decimal.Decimal     `json:"initial_balance"`
+	FinalBalance   decimal.Decimal     `json:"final_balance"`
+	TotalReturn    float64             `json:"total_return"`
+	CAGR           float64             `json:"cagr"`
+	SharpeRatio    float64             `json:"sharpe_ratio"`
+	MaxDrawdown    float64             `json:"max_drawdown"`
+	// Efficiency is the mean out-of-sample CAGR over the mean in-sample CAGR of
+	// the selected parameters; 0 when the in-sample mean is not positive
+	Efficiency float64 `json:"efficiency"`
+	// Trials is the number of parameter sets the full-range sweep tried
+	Trials int `json:"trials"`
+	// DeflatedSharpe is the probability that the stitched out-of-sample Sharpe
+	// ratio beats the best one expected by luck from Trials attempts
+	DeflatedSharpe float64 `json:"deflated_sharpe"`
+	// PBO is the probability of backtest overfitting of the full-range sweep
+	PBO float64 `json:"pbo"`
+}
+
+// Run performs the walk-forward analysis over candles keyed by symbol
+func (w *WalkForward) Run(ctx context.Context, candles map[string][]model.KLine) (*WalkForwardResult, error) {
+	if w.Optimization == nil {
+		return nil, errors.New("walk-forward needs an optimization")
+	}
+	if w.InSampleBars <= 0 || w.OutOfSampleBars <= 0 {
+		return nil, errors.New("in-sample and out-of-sample bars must be positive")
+	}
+	blocks := w.PBOBlocks
+	if blocks == 0 {
+		blocks = 10
+	}
+	if blocks < 2 || blocks%2 != 0 || blocks > 16 {
+		return nil, errors.New("pbo blocks must be an even number between 2 and 16")
+	}
+	if err := w.Optimization.Validate(); err != nil {
+		return nil, err
+	}
+
+	times := barTimes(candles)
+	if len(times) <= w.InSampleBars {
+		return nil, fmt.Errorf("need more than %d bars for one in-sample window, have %d", w.InSampleBars, len(times))
+	}
+	windows := w.windows(len(times))
+	total := len(windows) + 1
+
+	// Each stage gets a private copy so it neither reports progress nor keeps returns
+	stage := *w.Optimization
+	stage.Progress = nil
+	score := Objectives[stage.objective()]
+
+	result := &WalkForwardResult{
+		StrategyType:   stage.StrategyType,
+		Objective:      stage.objective(),
+		Anchored:       w.Anchored,
+		InitialBalance: stage.InitialBalance,
+	}
+	balance := stage.InitialBalance
+	var isCAGR, oosCAGR float64
+	for i, win := range windows {
+		inSample := between(candles, times, win[0], win[1])
+		opt, err := stage.Optimize(ctx, inSample)
+		if err != nil {
+			return nil, err
+		}
+		best, ok := opt.Best()
+		if !ok {
+			return nil, fmt.Errorf("window %d: every trial failed: %s", i+1, opt.Trials[0].Error)
+		}
+
+		outOfSample := between(candles, times, win[1], win[2])
+		report, err := stage.backtest(best.Params, slices.Sorted(maps.Keys(outOfSample)), outOfSample, balance)
+		if err != nil {
+			return nil, fmt.Errorf("window %d: %w", i+1, err)
+		}
+		balance = report.FinalBalance
+		result.EquityCurve = append(result.EquityCurve, report.EquityCurve...)
+		result.Windows = append(result.Windows, WalkForwardWindow{
+			InSampleStart:     times[win[0]],
+			InSampleEnd:       times[win[1]-1],
+			OutOfSampleStart:  times[win[1]],
+			OutOfSampleEnd:    times[win[2]-1],
+			Params:            best.Params,
+			InSampleScore:     best.Score,
+			InSampleCAGR:      best.CAGR,
+			OutOfSampleScore:  score(report),
+			OutOfSampleCAGR:   report.CAGR,
+			OutOfSampleReturn: report.TotalReturn.InexactFloat64(),
+			Trades:            report.TotalTrades,
+		})
+		isCAGR += best.CAGR
+		oosCAGR += report.CAGR
+		if w.Progress != nil {
+			w.Progress(i+1, total)
+		}
+	}
+	if isCAGR > 0 {
+		result.Efficiency = oosCAGR / isCAGR
+	}
+
+	initial := stage.InitialBalance.InexactFloat64()
+	equity := make([]float64, len(result.EquityCurve))
+	for i, p := range result.EquityCurve {
+		equity[i] = p.Equity.InexactFloat64()
+	}
+	for i, dd := range metrics.Drawdowns(equity) {
+		result.EquityCurve[i].Drawdown = dd
+	}
+	period := times[1].Sub(times[0])
+	returns := metrics.Returns(append([]float64{initial}, equity...))
+	result.FinalBalance = balance
+	result.TotalReturn = balance.InexactFloat64()/initial - 1
+	result.SharpeRatio = metrics.Sharpe(returns, 0, metrics.PeriodsPerYear(period))
+	result.MaxDrawdown = metrics.MaxDrawdown(equity)
+	if n := len(result.EquityCurve); n > 0 {
+		span := result.EquityCurve[n-1].Time.Sub(result.EquityCurve[0].Time) + period
+		result.CAGR = metrics.CAGR(initial, balance.InexactFloat64(), span)
+	}
+
+	// The diagnostics look at every parameter set over the whole range: how many
+	// were tried and how their Sharpe ratios spread deflates the stitched one
+	full := stage
+	full.keepReturns = true
+	sweep, err := full.Optimize(ctx, candles)
+	if err != nil {
+		return nil, err
+	}
+	var sharpes []float64
+	var trialReturns [][]float64
+	for _, t := range sweep.Trials {
+		if t.Error == "" {
+			sharpes = append(sharpes, metrics.Sharpe(t.returns, 0, 1))
+			trialReturns = append(trialReturns, t.returns)
+		}
+	}
+	sd := metrics.StdDev(sharpes)
+	result.Trials = len(sharpes)
+	result.DeflatedSharpe = metrics.DeflatedSharpe(returns, len(sharpes), sd*sd)
+	result.PBO = metrics.PBO(trialReturns, blocks)
+	if w.Progress != nil {
+		w.Progress(total, total)
+	}
+	return result, nil
+}
+
+// windows returns [in-sample start, out-of-sample start, out-of-sample end)
+// bar indices; the last out-of-sample window may be short
+func (w *WalkForward) windows(bars int) [][3]int {
+	var out [][3]int
+	for k := 0; ; k++ {
+		start, split := k*w.OutOfSampleBars, w.InSampleBars+k*w.OutOfSampleBars
+		if w.Anchored {
+			start = 0
+		}
+		if split >= bars {
+			return out
+		}
+		out = append(out, [3]int{start, split, min(split+w.OutOfSampleBars, bars)})
+	}
+}
+
+// barTimes returns the distinct bar timestamps across all symbols, in order
+func barTimes(candles map[string][]model.KLine) []time.Time {
+	seen := make(map[int64]bool)
+	var times []time.Time
+	for _, klines := range candles {
+		for _, k := range klines {
+			if ns := k.Timestamp.UnixNano(); !seen[ns] {
+				seen[ns] = true
+				times = append(times, k.Timestamp)
+			}
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times
+}
+
+// between selects the candles from times[from] up to, not including, times[to];
+// symbols without a bar in the range are left out
+func between(candles map[string][]model.KLine, times []time.Time, from, to int) map[string][]model.KLine {
+	out := make(map[string][]model.KLine, len(candles))
+	for symbol, klines := range candles {
+		lo := sort.Search(len(klines), func(i int) bool { return !klines[i].Timestamp.Before(times[from]) })
+		hi := len(klines)
+		if to < len(times) {
+			hi = sort.Search(len(klines), func(i int) bool { return !klines[i].Timestamp.Before(times[to]) })
+		}
+		if hi > lo {
+			out[symbol] = klines[lo:hi]
+		}
+	}
+	return out
+}
@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"context"
+	"quant-trader/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkForward_Windows(t *testing.T) {
+	rolling := &WalkForward{InSampleBars: 4, OutOfSampleBars: 2}
+	assert.Equal(t, [][3]int{{0, 4, 6}, {2, 6, 8}, {4, 8, 9}}, rolling.windows(9))
+
+	anchored := &WalkForward{InSampleBars: 4, OutOfSampleBars: 2, Anchored: true}
+	assert.Equal(t, [][3]int{{0, 4, 6}, {0, 6, 8}, {0, 8, 9}}, anchored.windows(9))
+
+	assert.Empty(t, rolling.windows(4))
+}
+
+func TestWalkForward_Run(t *testing.T) {
+	candles := map[string][]model.KLine{"BTCUSDT": waveCandles(200)}
+	var progress []int
+	wf := &WalkForward{
+		Optimization:    maCrossSweep(),
+		InSampleBars:    80,
+		OutOfSampleBars: 40,
+		PBOBlocks:       4,
+		Progress:        func(done, total int) { progress = append(progress, done) },
+	}
+
+	result, err := wf.Run(context.Background(), candles)
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{1, 2, 3, 4}, progress)
+	require.Len(t, result.Windows, 3)
+	for i, win := range result.Windows {
+		assert.Equal(t, candles["BTCUSDT"][80+40*i].Timestamp, win.OutOfSampleStart)
+		assert.Equal(t, candles["BTCUSDT"][40*i].Timestamp, win.InSampleStart)
+		assert.Contains(t, win.Params, "short_period")
+	}
+
+	// The stitched curve covers every out-of-sample bar once, in order
+	require.Len(t, result.EquityCurve, 120)
+	for i := 1; i < len(result.EquityCurve); i++ {
+		assert.True(t, result.EquityCurve[i].Time.After(result.EquityCurve[i-1].Time))
+	}
+	assert.InDelta(t, result.FinalBalance.InexactFloat64()/result.InitialBalance.InexactFloat64()-1, result.TotalReturn, 1e-12)
+
+	assert.Equal(t, 6, result.Trials)
+	assert.GreaterOrEqual(t, result.DeflatedSharpe, 0.0)
+	assert.LessOrEqual(t, result.DeflatedSharpe, 1.0)
+	assert.GreaterOrEqual(t, result.PBO, 0.0)
+	assert.LessOrEqual(t, result.PBO, 1.0)
+}
+
+func TestWalkForward_Invalid(t *testing.T) {
+	candles := map[string][]model.KLine{"BTCUSDT": waveCandles(50)}
+	cases := map[string]*WalkForward{
+		"no optimization": {InSampleBars: 10, OutOfSampleBars: 5},
+		"no windows":      {Optimization: maCrossSweep(), InSampleBars: 50, OutOfSampleBars: 5},
+		"zero bars":       {Optimization: maCrossSweep(), InSampleBars: 10},
+		"odd blocks":      {Optimization: maCrossSweep(), InSampleBars: 10, OutOfSampleBars: 5, PBOBlocks: 3},
+	}
+	for name, wf := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := wf.Run(context.Background(), candles)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -30,4 +30,24 @@ var (
 		Name: "goroutine_count",
 		Help: "Number of active goroutines",
 	})
+
+	SpilledRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_spill_records_total",
+		Help: "Total number of records spilled to disk while the DB was unavailable",
+	}, []string{"table"})
+
+	ReplayedRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_spill_replayed_total",
+		Help: "Total number of spilled records replayed into the DB",
+	}, []string{"table"})
+
+	SpillDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_spill_dropped_total",
+		Help: "Total number of records dropped because the spill budget was exhausted",
+	}, []string{"table"})
+
+	SpillBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "db_spill_bytes",
+		Help: "Bytes currently held in spill segments awaiting replay",
+	}, []string{"table"})
 )
@@ -1,6 +1,8 @@
 package infrastructure
 
 import (
+	"time"
+
 	"github.com/nats-io/nats.go"
 	"go.uber.org/zap"
 )
@@ -32,5 +34,31 @@ func InitNATS(url string, logger *zap.Logger) (*nats.Conn, nats.JetStreamContext
 		}
 	}
 
+	// Depth snapshots arrive every second per symbol and are only kept until
+	// the persistence service stores them
+	depth := &nats.StreamConfig{
+		Name:     "MARKET_DEPTH",
+		Subjects: []string{"market.depth.*.*"},
+		MaxAge:   time.Hour,
+		MaxBytes: 512 << 20,
+	}
+	if _, err := js.AddStream(depth); err != nil {
+		if _, err := js.UpdateStream(depth); err != nil {
+			logger.Warn("failed to create or update depth stream", zap.Error(err))
+		}
+	}
+
+	// Backtest jobs are consumed once, by whichever worker takes them
+	backtests := &nats.StreamConfig{
+		Name:      "BACKTEST",
+		Subjects:  []string{"backtest.jobs"},
+		Retention: nats.WorkQueuePolicy,
+	}
+	if _, err := js.AddStream(backtests); err != nil {
+		if _, err := js.UpdateStream(backtests); err != nil {
+			logger.Warn("failed to create or update backtest stream", zap.Error(err))
+		}
+	}
+
 	return nc, js, nil
 }
@@ -0,0 +1,296 @@
+// Package metrics holds the performance statistics shared by the backtester and
+// the analytics service. Series are plain float64 slices in time order.
+package metrics
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Year is the annualization basis: crypto markets trade every day of the year
+const Year = 365 * 24 * time.Hour
+
+// PeriodsPerYear is the number of bars of the given length in a year
+func PeriodsPerYear(period time.Duration) float64 {
+	if period <= 0 {
+		return 0
+	}
+	return float64(Year) / float64(period)
+}
+
+// Returns converts an equity series into simple period returns. A period
+// starting from non-positive equity has a return of 0.
+func Returns(equity []float64) []float64 {
+	if len(equity) < 2 {
+		return nil
+	}
+	returns := make([]float64, len(equity)-1)
+	for i := 1; i < len(equity); i++ {
+		if equity[i-1] > 0 {
+			returns[i-1] = equity[i]/equity[i-1] - 1
+		}
+	}
+	return returns
+}
+
+// Mean returns the arithmetic mean, 0 for an empty series
+func Mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// StdDev returns the sample standard deviation, 0 for fewer than two points
+func StdDev(xs []float64) float64 {
+	if len(xs) < 2 {
+		return 0
+	}
+	mean := Mean(xs)
+	var ss float64
+	for _, x := range xs {
+		ss += (x - mean) * (x - mean)
+	}
+	return math.Sqrt(ss / float64(len(xs)-1))
+}
+
+// Sharpe is the mean excess return over its standard deviation, scaled by
+// √periodsPerYear. riskFree is per period; pass periodsPerYear 1 for a raw ratio.
+func Sharpe(returns []float64, riskFree, periodsPerYear float64) float64 {
+	excess := make([]float64, len(returns))
+	for i, r := range returns {
+		excess[i] = r - riskFree
+	}
+	sd := StdDev(excess)
+	if sd == 0 {
+		return 0
+	}
+	return Mean(excess) / sd * math.Sqrt(periodsPerYear)
+}
+
+// Sortino is Sharpe with only the returns below riskFree counted as risk
+func Sortino(returns []float64, riskFree, periodsPerYear float64) float64 {
+	if len(returns) < 2 {
+		return 0
+	}
+	var downside, sum float64
+	for _, r := range returns {
+		sum += r - riskFree
+		if d := math.Min(r-riskFree, 0); d < 0 {
+			downside += d * d
+		}
+	}
+	dd := math.Sqrt(downside / float64(len(returns)))
+	if dd == 0 {
+		return 0
+	}
+	return sum / float64(len(returns)) / dd * math.Sqrt(periodsPerYear)
+}
+
+// CAGR is the compound annual growth rate from start to end over the given span
+func CAGR(start, end float64, span time.Duration) float64 {
+	if start <= 0 || span <= 0 {
+		return 0
+	}
+	if end <= 0 {
+		return -1
+	}
+	return math.Pow(end/start, float64(Year)/float64(span)) - 1
+}
+
+// Calmar is CAGR over maximum drawdown, 0 without a drawdown
+func Calmar(cagr, maxDrawdown float64) float64 {
+	if maxDrawdown <= 0 {
+		return 0
+	}
+	return cagr / maxDrawdown
+}
+
+// Drawdowns returns, for every point, the fraction below the running peak
+func Drawdowns(equity []float64) []float64 {
+	dd := make([]float64, len(equity))
+	peak := math.Inf(-1)
+	for i, e := range equity {
+		peak = math.Max(peak, e)
+		if peak > 0 {
+			dd[i] = (peak - e) / peak
+		}
+	}
+	return dd
+}
+
+// MaxDrawdown is the largest fraction lost from a running peak
+func MaxDrawdown(equity []float64) float64 {
+	var max float64
+	for _, d := range Drawdowns(equity) {
+		max = math.Max(max, d)
+	}
+	return max
+}
+
+// MaxDrawdownDuration is the longest time spent below a previous peak; a
+// drawdown still open at the end lasts until the last point
+func MaxDrawdownDuration(times []time.Time, equity []float64) time.Duration {
+	if len(times) != len(equity) || len(equity) == 0 {
+		return 0
+	}
+	var longest time.Duration
+	peak, peakAt := equity[0], times[0]
+	for i, e := range equity {
+		if e >= peak {
+			peak, peakAt = e, times[i]
+			continue
+		}
+		if d := times[i].Sub(peakAt); d > longest {
+			longest = d
+		}
+	}
+	return longest
+}
+
+// TradeStats summarizes the PnL of closed trades
+type TradeStats struct {
+	Count        int     `json:"count"`
+	Wins         int     `json:"wins"`
+	Losses       int     `json:"losses"`
+	WinRate      float64 `json:"win_rate"`
+	AvgWin       float64 `json:"avg_win"`
+	AvgLoss      float64 `json:"avg_loss"` // negative
+	ProfitFactor float64 `json:"profit_factor"`
+	Expectancy   float64 `json:"expectancy"` // average PnL per trade
+}
+
+// Trades computes TradeStats. Profit factor is gross profit over gross loss, 0
+// when nothing was lost (it would be infinite, which JSON cannot carry).
+func Trades(pnls []float64) TradeStats {
+	s := TradeStats{Count: len(pnls)}
+	if s.Count == 0 {
+		return s
+	}
+	var grossWin, grossLoss float64
+	for _, p := range pnls {
+		switch {
+		case p > 0:
+			s.Wins++
+			grossWin += p
+		case p < 0:
+			s.Losses++
+			grossLoss -= p
+		}
+	}
+	s.WinRate = float64(s.Wins) / float64(s.Count)
+	if s.Wins > 0 {
+		s.AvgWin = grossWin / float64(s.Wins)
+	}
+	if s.Losses > 0 {
+		s.AvgLoss = -grossLoss / float64(s.Losses)
+	}
+	if grossLoss > 0 {
+		s.ProfitFactor = grossWin / grossLoss
+	}
+	s.Expectancy = (grossWin - grossLoss) / float64(s.Count)
+	return s
+}
+
+// DurationStats summarizes how long trades were held
+type DurationStats struct {
+	Min    time.Duration
+	Max    time.Duration
+	Mean   time.Duration
+	Median time.Duration
+}
+
+// Durations computes DurationStats, zero for no trades
+func Durations(ds []time.Duration) DurationStats {
+	if len(ds) == 0 {
+		return DurationStats{}
+	}
+	sorted := append([]time.Duration(nil), ds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+	n := len(sorted)
+	median := sorted[n/2]
+	if n%2 == 0 {
+		median = (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	return DurationStats{Min: sorted[0], Max: sorted[n-1], Mean: sum / time.Duration(n), Median: median}
+}
+
+// MonthlyReturn is the equity change over one calendar month (UTC)
+type MonthlyReturn struct {
+	Year   int        `json:"year"`
+	Month  time.Month `json:"month"`
+	Return float64    `json:"return"`
+}
+
+// MonthlyReturns compounds an equity series into calendar month returns. Each
+// month is measured from the last point of the previous month, or from start
+// for the first month.
+func MonthlyReturns(start float64, times []time.Time, equity []float64) []MonthlyReturn {
+	if len(times) != len(equity) || len(equity) == 0 {
+		return nil
+	}
+	var months []MonthlyReturn
+	base := start
+	for i, t := range times {
+		t = t.UTC()
+		last := i == len(times)-1
+		if !last {
+			next := times[i+1].UTC()
+			if next.Year() == t.Year() && next.Month() == t.Month() {
+				continue
+			}
+		}
+		ret := 0.0
+		if base > 0 {
+			ret = equity[i]/base - 1
+		}
+		months = append(months, MonthlyReturn{Year: t.Year(), Month: t.Month(), Return: ret})
+		base = equity[i]
+	}
+	return months
+}
+
+// Correlation is the Pearson correlation of two equally long series; 0 when
+// either is constant or shorter than two points
+func Correlation(xs, ys []float64) float64 {
+	if len(xs) < 2 || len(xs) != len(ys) {
+		return 0
+	}
+	mx, my := Mean(xs), Mean(ys)
+	var cov, vx, vy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(vx*vy)
+}
+
+// Percentile interpolates the p-th percentile (0-100) of an ascending sorted
+// series; 0 for an empty one
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := math.Max(0, math.Min(1, p/100)) * float64(len(sorted)-1)
+	lo := int(pos)
+	if lo == len(sorted)-1 {
+		return sorted[lo]
+	}
+	frac := pos - float64(lo)
+	return sorted[lo] + frac*(sorted[lo+1]-sorted[lo])
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharpeAndSortino(t *testing.T) {
+	returns := []float64{0.01, -0.01, 0.02, 0.0}
+
+	// mean 0.005, sample std 0.012910
+	assert.InDelta(t, 0.387298, Sharpe(returns, 0, 1), 1e-6)
+	assert.InDelta(t, 0.387298*math.Sqrt(365), Sharpe(returns, 0, PeriodsPerYear(24*time.Hour)), 1e-4)
+	assert.Equal(t, 0.0, Sharpe([]float64{0.01, 0.01}, 0, 1))
+
+	// downside deviation sqrt(0.0001 / 4) = 0.005
+	assert.InDelta(t, 1.0, Sortino(returns, 0, 1), 1e-9)
+	assert.Equal(t, 0.0, Sortino([]float64{0.01, 0.02}, 0, 1), "no downside")
+}
+
+func TestDrawdowns(t *testing.T) {
+	equity := []float64{100, 120, 90, 110, 130, 117}
+	dd := Drawdowns(equity)
+
+	assert.InDelta(t, 0.25, dd[2], 1e-12)
+	assert.InDelta(t, 0.1, dd[5], 1e-12)
+	assert.InDelta(t, 0.25, MaxDrawdown(equity), 1e-12)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	times := make([]time.Time, len(equity))
+	for i := range times {
+		times[i] = start.Add(time.Duration(i) * time.Hour)
+	}
+	// peak at 1h, recovered at 4h: the drawdown lasts 2h before the new peak
+	assert.Equal(t, 2*time.Hour, MaxDrawdownDuration(times, equity))
+}
+
+func TestCAGRAndCalmar(t *testing.T) {
+	assert.InDelta(t, 0.1, CAGR(100, 121, 2*Year), 1e-9)
+	assert.InDelta(t, 0.21, CAGR(100, 110, Year/2), 1e-9)
+	assert.Equal(t, -1.0, CAGR(100, 0, Year))
+	assert.InDelta(t, 0.5, Calmar(0.1, 0.2), 1e-12)
+	assert.Equal(t, 0.0, Calmar(0.1, 0))
+}
+
+func TestTrades(t *testing.T) {
+	s := Trades([]float64{100, -50, 200, -50, 0})
+
+	assert.Equal(t, 5, s.Count)
+	assert.Equal(t, 2, s.Wins)
+	assert.Equal(t, 2, s.Losses)
+	assert.InDelta(t, 0.4, s.WinRate, 1e-12)
+	assert.InDelta(t, 150, s.AvgWin, 1e-12)
+	assert.InDelta(t, -50, s.AvgLoss, 1e-12)
+	assert.InDelta(t, 3, s.ProfitFactor, 1e-12)
+	assert.InDelta(t, 40, s.Expectancy, 1e-12)
+
+	assert.Equal(t, 0.0, Trades([]float64{10}).ProfitFactor)
+	assert.Equal(t, TradeStats{}, Trades(nil))
+}
+
+func TestDurations(t *testing.T) {
+	s := Durations([]time.Duration{4 * time.Minute, time.Minute, 2 * time.Minute, 9 * time.Minute})
+	assert.Equal(t, time.Minute, s.Min)
+	assert.Equal(t, 9*time.Minute, s.Max)
+	assert.Equal(t, 4*time.Minute, s.Mean)
+	assert.Equal(t, 3*time.Minute, s.Median)
+}
+
+func TestMonthlyReturns(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2024, m, d, 0, 0, 0, 0, time.UTC) }
+	times := []time.Time{day(1, 10), day(1, 31), day(2, 1), day(2, 29), day(3, 1)}
+	equity := []float64{105, 110, 100, 121, 121}
+
+	months := MonthlyReturns(100, times, equity)
+	assert.Len(t, months, 3)
+	assert.Equal(t, MonthlyReturn{Year: 2024, Month: time.January, Return: months[0].Return}, months[0])
+	assert.InDelta(t, 0.1, months[0].Return, 1e-12)
+	assert.InDelta(t, 0.1, months[1].Return, 1e-12)
+	assert.Equal(t, 0.0, months[2].Return)
+}
+
+func TestCorrelation(t *testing.T) {
+	assert.InDelta(t, 1, Correlation([]float64{1, 2, 3}, []float64{2, 4, 6}), 1e-12)
+	assert.InDelta(t, -1, Correlation([]float64{1, 2, 3}, []float64{3, 2, 1}), 1e-12)
+	assert.Equal(t, 0.0, Correlation([]float64{1, 1, 1}, []float64{1, 2, 3}))
+	assert.Equal(t, 0.0, Correlation([]float64{1}, []float64{1}))
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5}
+	assert.Equal(t, 1.0, Percentile(sorted, 0))
+	assert.Equal(t, 3.0, Percentile(sorted, 50))
+	assert.Equal(t, 5.0, Percentile(sorted, 100))
+	assert.InDelta(t, 1.4, Percentile(sorted, 10), 1e-12)
+	assert.Equal(t, 0.0, Percentile(nil, 50))
+}
@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"math"
+)
+
+// eulerGamma is the Euler–Mascheroni constant
+const eulerGamma = 0.5772156649015329
+
+// Skewness is the sample skewness (third standardized moment), 0 for a constant series
+func Skewness(xs []float64) float64 {
+	mean, sd := Mean(xs), populationStdDev(xs)
+	if sd == 0 {
+		return 0
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += math.Pow((x-mean)/sd, 3)
+	}
+	return sum / float64(len(xs))
+}
+
+// Kurtosis is the (non-excess) fourth standardized moment, 3 for a normal
+// distribution and for a constant series
+func Kurtosis(xs []float64) float64 {
+	mean, sd := Mean(xs), populationStdDev(xs)
+	if sd == 0 {
+		return 3
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += math.Pow((x-mean)/sd, 4)
+	}
+	return sum / float64(len(xs))
+}
+
+func populationStdDev(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	mean := Mean(xs)
+	var ss float64
+	for _, x := range xs {
+		ss += (x - mean) * (x - mean)
+	}
+	return math.Sqrt(ss / float64(len(xs)))
+}
+
+// NormCDF is the standard normal cumulative distribution function
+func NormCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// NormInv is the inverse of NormCDF (Acklam's rational approximation, relative
+// error below 1.2e-9). It returns ±Inf at 0 and 1.
+func NormInv(p float64) float64 {
+	switch {
+	case p <= 0:
+		return math.Inf(-1)
+	case p >= 1:
+		return math.Inf(1)
+	}
+	a := [...]float64{-3.969683028665376e+01, 2.209460984245205e+02, -2.759285104469687e+02,
+		1.383577518672690e+02, -3.066479806614716e+01, 2.506628277459239e+00}
+	b := [...]float64{-5.447609879822406e+01, 1.615858368580409e+02, -1.556989798598866e+02,
+		6.680131188771972e+01, -1.328068155288572e+01}
+	c := [...]float64{-7.784894002430293e-03, -3.223964580411365e-01, -2.400758277161838e+00,
+		-2.549732539343734e+00, 4.374664141464968e+00, 2.938163982698783e+00}
+	d := [...]float64{7.784695709041462e-03, 3.224671290700398e-01, 2.445134137142996e+00,
+		3.754408661907416e+00}
+
+	const low = 0.02425
+	if p < low {
+		q := math.Sqrt(-2 * math.Log(p))
+		return (((((c[0]*q+c[1])*q+c[2])*q+c[3])*q+c[4])*q + c[5]) /
+			((((d[0]*q+d[1])*q+d[2])*q+d[3])*q + 1)
+	}
+	if p > 1-low {
+		return -NormInv(1 - p)
+	}
+	q := p - 0.5
+	r := q * q
+	return (((((a[0]*r+a[1])*r+a[2])*r+a[3])*r+a[4])*r + a[5]) * q /
+		(((((b[0]*r+b[1])*r+b[2])*r+b[3])*r+b[4])*r + 1)
+}
+
+// ExpectedMaxSharpe is the Sharpe ratio the best of trials independent
+// strategies with no skill would reach by luck, given the variance of their
+// Sharpe ratios (Bailey and López de Prado)
+func ExpectedMaxSharpe(trials int, variance float64) float64 {
+	if trials <= 1 || variance <= 0 {
+		return 0
+	}
+	n := float64(trials)
+	return math.Sqrt(variance) * ((1-eulerGamma)*NormInv(1-1/n) + eulerGamma*NormInv(1-1/(n*math.E)))
+}
+
+// DeflatedSharpe is the probability that the true Sharpe ratio of returns is
+// above the one expected from selecting the best of trials attempts, correcting
+// for the length, skewness and kurtosis of the series. sharpeVariance is the
+// variance of the per-period (not annualized) Sharpe ratios across the trials.
+func DeflatedSharpe(returns []float64, trials int, sharpeVariance float64) float64 {
+	if len(returns) < 2 {
+		return 0
+	}
+	sr := Sharpe(returns, 0, 1)
+	sr0 := ExpectedMaxSharpe(trials, sharpeVariance)
+	denom := 1 - Skewness(returns)*sr + (Kurtosis(returns)-1)/4*sr*sr
+	if denom <= 0 {
+		return 0
+	}
+	return NormCDF((sr - sr0) * math.Sqrt(float64(len(returns)-1)) / math.Sqrt(denom))
+}
+
+// PBO is the probability of backtest overfitting by combinatorially symmetric
+// cross-validation. returns holds one equally long per-period return series per
+// trial; the periods are cut into blocks (an even number) and, for every way to
+// pick half of them as in-sample, the in-sample best trial is ranked out of
+// sample by Sharpe ratio. PBO is the share of splits where it ranks at or below
+// the median.
+func PBO(returns [][]float64, blocks int) float64 {
+	if len(returns) < 2 || blocks < 2 || blocks%2 != 0 {
+		return 0
+	}
+	periods := len(returns[0])
+	for _, r := range returns {
+		periods = min(periods, len(r))
+	}
+	size := periods / blocks
+	if size == 0 {
+		return 0
+	}
+
+	// Per block moments, so each split's Sharpe comes from sums
+	type moments struct{ n, sum, sumsq float64 }
+	stats := make([][]moments, len(returns))
+	for i, r := range returns {
+		stats[i] = make([]moments, blocks)
+		for b := range blocks {
+			for _, x := range r[b*size : (b+1)*size] {
+				stats[i][b].n++
+				stats[i][b].sum += x
+				stats[i][b].sumsq += x * x
+			}
+		}
+	}
+	sharpe := func(trial int, inSample []bool, want bool) float64 {
+		var m moments
+		for b, in := range inSample {
+			if in == want {
+				s := stats[trial][b]
+				m.n, m.sum, m.sumsq = m.n+s.n, m.sum+s.sum, m.sumsq+s.sumsq
+			}
+		}
+		mean := m.sum / m.n
+		variance := (m.sumsq - m.n*mean*mean) / (m.n - 1)
+		if variance <= 0 {
+			return 0
+		}
+		return mean / math.Sqrt(variance)
+	}
+
+	var splits, overfit int
+	inSample := make([]bool, blocks)
+	var choose func(start, left int)
+	choose = func(start, left int) {
+		if left == 0 {
+			best, bestIS := 0, math.Inf(-1)
+			for i := range returns {
+				if s := sharpe(i, inSample, true); s > bestIS {
+					best, bestIS = i, s
+				}
+			}
+			oos := sharpe(best, inSample, false)
+			var below, equal float64
+			for i := range returns {
+				switch s := sharpe(i, inSample, false); {
+				case s < oos:
+					below++
+				case s == oos:
+					equal++
+				}
+			}
+			// Relative rank in (0, 1), ties averaged; logit <= 0 means at or below the median
+			omega := (below + (equal+1)/2) / float64(len(returns)+1)
+			if math.Log(omega/(1-omega)) <= 0 {
+				overfit++
+			}
+			splits++
+			return
+		}
+		for b := start; b <= blocks-left; b++ {
+			inSample[b] = true
+			choose(b+1, left-1)
+			inSample[b] = false
+		}
+	}
+	choose(0, blocks/2)
+	return float64(overfit) / float64(splits)
+}
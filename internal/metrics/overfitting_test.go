@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoments(t *testing.T) {
+	assert.InDelta(t, 0, Skewness([]float64{1, 2, 3}), 1e-12)
+	assert.Greater(t, Skewness([]float64{0, 0, 0, 10}), 0.0)
+	assert.InDelta(t, 1.5, Kurtosis([]float64{1, 2, 3}), 1e-12)
+	assert.Equal(t, 3.0, Kurtosis([]float64{5, 5}))
+}
+
+func TestNormInv(t *testing.T) {
+	assert.InDelta(t, 0.5, NormCDF(0), 1e-12)
+	assert.InDelta(t, 0.975, NormCDF(1.959964), 1e-6)
+	for _, p := range []float64{0.001, 0.02, 0.3, 0.5, 0.9, 0.999} {
+		assert.InDelta(t, p, NormCDF(NormInv(p)), 1e-9)
+	}
+	assert.True(t, math.IsInf(NormInv(0), -1))
+}
+
+func TestDeflatedSharpe(t *testing.T) {
+	assert.Equal(t, 0.0, ExpectedMaxSharpe(1, 0.01))
+	// More trials raise the bar luck alone clears
+	assert.Greater(t, ExpectedMaxSharpe(100, 0.01), ExpectedMaxSharpe(10, 0.01))
+
+	rng := rand.New(rand.NewSource(1))
+	returns := make([]float64, 1000)
+	for i := range returns {
+		returns[i] = 0.002 + 0.01*rng.NormFloat64()
+	}
+	single := DeflatedSharpe(returns, 1, 0)
+	assert.Greater(t, single, 0.95) // per-period Sharpe ~0.2 over 1000 periods
+	assert.Less(t, DeflatedSharpe(returns, 1000, 0.01), single)
+}
+
+func TestPBO(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	series := func(drift float64) []float64 {
+		r := make([]float64, 400)
+		for i := range r {
+			r[i] = drift + 0.01*rng.NormFloat64()
+		}
+		return r
+	}
+
+	// One trial with real edge among noise is picked in-sample and holds up out of sample
+	skilled := [][]float64{series(0.005)}
+	for range 9 {
+		skilled = append(skilled, series(0))
+	}
+	assert.Less(t, PBO(skilled, 8), 0.1)
+
+	// Pure noise: the in-sample winner is a coin flip out of sample
+	var noise [][]float64
+	for range 20 {
+		noise = append(noise, series(0))
+	}
+	pbo := PBO(noise, 8)
+	assert.Greater(t, pbo, 0.2)
+	assert.Less(t, pbo, 0.8)
+
+	assert.Equal(t, 0.0, PBO(noise, 3), "odd block count")
+	assert.Equal(t, 0.0, PBO(noise[:1], 8), "single trial")
+}
@@ -0,0 +1,67 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"quant-trader/scripts/migrations"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_EmbeddedMigrations(t *testing.T) {
+	ms, err := Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, ms)
+
+	for i, m := range ms {
+		assert.NotEmpty(t, m.Up, "migration %d has empty up", m.Version)
+		assert.NotEmpty(t, m.Down, "migration %d has no down file", m.Version)
+		if i > 0 {
+			assert.Greater(t, m.Version, ms[i-1].Version)
+		}
+	}
+	assert.Equal(t, int64(1), ms[0].Version)
+}
+
+func TestLoad_Validation(t *testing.T) {
+	_, err := Load(fstest.MapFS{
+		"001_a.down.sql": {Data: []byte("DROP TABLE a;")},
+	})
+	assert.Error(t, err, "down without up")
+
+	_, err = Load(fstest.MapFS{
+		"001_a.up.sql": {Data: []byte("CREATE TABLE a();")},
+		"001_b.up.sql": {Data: []byte("CREATE TABLE b();")},
+	})
+	assert.Error(t, err, "duplicate version")
+
+	ms, err := Load(fstest.MapFS{
+		"002_b.up.sql": {Data: []byte("-- migrate:no-transaction\nCREATE TABLE b();")},
+		"001_a.up.sql": {Data: []byte("CREATE TABLE a();")},
+		"README.md":    {Data: []byte("ignored")},
+	})
+	require.NoError(t, err)
+	require.Len(t, ms, 2)
+	assert.Equal(t, "a", ms[0].Name)
+	assert.False(t, ms[0].UpNoTx)
+	assert.True(t, ms[1].UpNoTx)
+}
+
+func TestSplitStatements(t *testing.T) {
+	sql := `-- header; with semicolon
+CREATE TABLE a (note TEXT DEFAULT 'x;y');
+DO $$
+BEGIN
+    PERFORM 1;
+END $$;
+SELECT $fn$ a;b $fn$;
+-- trailing comment`
+
+	stmts := SplitStatements(sql)
+	require.Len(t, stmts, 3)
+	assert.Contains(t, stmts[0], "'x;y'")
+	assert.Contains(t, stmts[1], "PERFORM 1;")
+	assert.Equal(t, "SELECT $fn$ a;b $fn$", stmts[2])
+}
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Alert 用户配置的价格/指标提醒
+type Alert struct {
+	ID            int64           `json:"id" db:"id"`
+	UserID        int64           `json:"-" db:"user_id"`
+	Symbol        string          `json:"symbol" db:"symbol"`
+	ConditionType string          `json:"condition_type" db:"condition_type"` // price_above, price_below, rsi_overbought, rsi_oversold
+	TargetValue   decimal.Decimal `json:"target_value" db:"target_value"`
+	IsActive      bool            `json:"is_active" db:"is_active"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// DeploymentStatus 实盘部署状态
+type DeploymentStatus string
+
+const (
+	DeploymentRunning DeploymentStatus = "running"
+	// DeploymentPaused keeps up with the market but emits no signals
+	DeploymentPaused  DeploymentStatus = "paused"
+	DeploymentStopped DeploymentStatus = "stopped"
+)
+
+// Deployment is a stored strategy version running live for its user on one
+// symbol and period
+type Deployment struct {
+	ID                int64            `json:"id" db:"id"`
+	UserID            int64            `json:"user_id" db:"user_id"`
+	StrategyVersionID int64            `json:"strategy_version_id" db:"strategy_version_id"`
+	Symbol            string           `json:"symbol" db:"symbol"`
+	Period            string           `json:"period" db:"period"`
+	Capital           decimal.Decimal  `json:"capital" db:"capital"` // most quote amount a buy signal spends
+	Paper             bool             `json:"paper" db:"paper"`     // signals also trade the paper account
+	Sizing            SizingPolicy     `json:"sizing" db:"sizing"`
+	Status            DeploymentStatus `json:"status" db:"status"`
+	// PositionQty is what the deployment's filled paper orders hold, as of its
+	// latest signal
+	PositionQty decimal.Decimal `json:"position_qty" db:"position_qty"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// SizingMode is how a paper deployment sizes its buys
+type SizingMode string
+
+const (
+	// SizingFixedNotional spends Notional, or Capital when it is zero
+	SizingFixedNotional SizingMode = "fixed_notional"
+	// SizingEquityPct spends EquityPct of the paper account's equity
+	SizingEquityPct SizingMode = "equity_pct"
+	// SizingATR targets volatility: a move of one ATR costs RiskPct of equity
+	SizingATR SizingMode = "atr"
+)
+
+// SizingPolicy sizes the buys of a paper deployment, never above its Capital.
+// Sells close what the deployment holds.
+type SizingPolicy struct {
+	Mode      SizingMode      `json:"mode,omitempty"` // fixed_notional when empty
+	Notional  decimal.Decimal `json:"notional,omitempty"`
+	EquityPct decimal.Decimal `json:"equity_pct,omitempty"` // e.g. 0.05 for 5%
+	RiskPct   decimal.Decimal `json:"risk_pct,omitempty"`   // e.g. 0.01 for 1%
+	ATRPeriod int             `json:"atr_period,omitempty"` // 14 when zero
+}
+
+// ExecutionStatus is what came of a signal of a paper deployment
+type ExecutionStatus string
+
+const (
+	ExecutionPlaced ExecutionStatus = "placed"
+	// ExecutionSkipped is a buy while holding or a sell while flat
+	ExecutionSkipped  ExecutionStatus = "skipped"
+	ExecutionRejected ExecutionStatus = "rejected" // by the risk limits
+	ExecutionFailed   ExecutionStatus = "failed"
+)
+
+// SignalExecution links a signal of a paper deployment to the order it placed
+// and, through the order, to its fill
+type SignalExecution struct {
+	ID           int64           `json:"id" db:"id"`
+	DeploymentID int64           `json:"deployment_id" db:"deployment_id"`
+	UserID       int64           `json:"-" db:"user_id"`
+	Strategy     string          `json:"strategy" db:"strategy"`
+	Symbol       string          `json:"symbol" db:"symbol"`
+	Action       string          `json:"action" db:"action"` // buy, sell
+	SignalPrice  decimal.Decimal `json:"signal_price" db:"signal_price"`
+	SignalTime   time.Time       `json:"signal_time" db:"signal_time"`
+	Sizing       SizingMode      `json:"sizing" db:"sizing"`
+	Qty          decimal.Decimal `json:"qty" db:"qty"`
+	Status       ExecutionStatus `json:"status" db:"status"`
+	Reason       string          `json:"reason,omitempty" db:"reason"`
+	OrderID      int64           `json:"order_id,omitempty" db:"order_id"` // 0 unless placed
+	// OrderStatus and FilledPrice are read from the order when listed
+	OrderStatus string          `json:"order_status,omitempty" db:"order_status"`
+	FilledPrice decimal.Decimal `json:"filled_price" db:"filled_price"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
@@ -56,7 +56,8 @@ func PeriodToDuration(period string) time.Duration {
 // OrderBook 代表深度快照 (用于回测时的高精度模拟)
 type OrderBook struct {
 	Symbol    string      `json:"s"`
+	Exchange  string      `json:"e,omitempty"`
 	Timestamp time.Time   `json:"t"`
-	Bids      [][2]string `json:"b"` // 使用 string 防止精度丢失，[Price, Amount]
+	Bids      [][2]string `json:"b"` // 使用 string 防止精度丢失，[Price, Amount]，价格从优到劣
 	Asks      [][2]string `json:"a"`
 }
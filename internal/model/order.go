@@ -0,0 +1,118 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// OrderSide 订单方向
+type OrderSide string
+
+const (
+	SideBuy  OrderSide = "buy"
+	SideSell OrderSide = "sell"
+)
+
+// OrderType 订单类型
+type OrderType string
+
+const (
+	OrderMarket    OrderType = "market"
+	OrderLimit     OrderType = "limit"
+	OrderStop      OrderType = "stop"
+	OrderStopLimit OrderType = "stop_limit"
+)
+
+// TimeInForce 订单有效期
+type TimeInForce string
+
+const (
+	TIFGoodTillCancel TimeInForce = "gtc" // rests until filled or cancelled
+	TIFImmediate      TimeInForce = "ioc" // only tried against the next bar
+	TIFGoodTillDate   TimeInForce = "gtd" // rests until ExpireAt
+)
+
+// OrderStatus 订单状态
+type OrderStatus string
+
+const (
+	OrderOpen      OrderStatus = "open"
+	OrderFilled    OrderStatus = "filled"
+	OrderCancelled OrderStatus = "cancelled"
+	OrderExpired   OrderStatus = "expired"
+	OrderRejected  OrderStatus = "rejected"
+)
+
+// OrderRequest 策略提交的下单意图
+type OrderRequest struct {
+	Symbol     string          `json:"symbol"`
+	Side       OrderSide       `json:"side"`
+	Type       OrderType       `json:"type"`
+	Qty        decimal.Decimal `json:"qty"`
+	LimitPrice decimal.Decimal `json:"limit_price,omitempty"` // limit, stop_limit
+	StopPrice  decimal.Decimal `json:"stop_price,omitempty"`  // stop, stop_limit
+	TIF        TimeInForce     `json:"tif"`                   // defaults to gtc
+	ExpireAt   time.Time       `json:"expire_at,omitempty"`   // gtd only
+	Tag        string          `json:"tag,omitempty"`
+}
+
+// Validate checks that the fields required by the order type are present
+func (r OrderRequest) Validate() error {
+	if r.Symbol == "" {
+		return errors.New("order symbol is required")
+	}
+	if r.Side != SideBuy && r.Side != SideSell {
+		return errors.New("order side must be buy or sell")
+	}
+	if !r.Qty.IsPositive() {
+		return errors.New("order qty must be positive")
+	}
+	switch r.Type {
+	case OrderMarket:
+	case OrderLimit:
+		if !r.LimitPrice.IsPositive() {
+			return errors.New("limit order requires a positive limit price")
+		}
+	case OrderStop:
+		if !r.StopPrice.IsPositive() {
+			return errors.New("stop order requires a positive stop price")
+		}
+	case OrderStopLimit:
+		if !r.StopPrice.IsPositive() || !r.LimitPrice.IsPositive() {
+			return errors.New("stop-limit order requires positive stop and limit prices")
+		}
+	default:
+		return errors.New("unknown order type: " + string(r.Type))
+	}
+	switch r.TIF {
+	case "", TIFGoodTillCancel, TIFImmediate:
+	case TIFGoodTillDate:
+		if r.ExpireAt.IsZero() {
+			return errors.New("gtd order requires expire_at")
+		}
+	default:
+		return errors.New("unknown time in force: " + string(r.TIF))
+	}
+	return nil
+}
+
+// Order 回测中的订单及其成交结果
+type Order struct {
+	ID int64 `json:"id"`
+	OrderRequest
+	Status       OrderStatus     `json:"status"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	FilledQty    decimal.Decimal `json:"filled_qty"`
+	AvgFillPrice decimal.Decimal `json:"avg_fill_price"`
+	Reason       string          `json:"reason,omitempty"` // why the order was rejected or reduced
+	// Triggered is set once the stop price of a stop-limit order has been touched
+	Triggered bool `json:"triggered,omitempty"`
+}
+
+// Remaining returns the unfilled quantity
+func (o *Order) Remaining() decimal.Decimal {
+	return o.Qty.Sub(o.FilledQty)
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PaperOrder 模拟盘订单
+type PaperOrder struct {
+	ID          int64           `json:"id" db:"id"`
+	UserID      int64           `json:"user_id" db:"user_id"`
+	Symbol      string          `json:"symbol" db:"symbol"`
+	Side        string          `json:"side" db:"side"` // buy, sell
+	Type        string          `json:"type" db:"type"` // market, limit
+	Price       decimal.Decimal `json:"price" db:"price"`
+	Qty         decimal.Decimal `json:"qty" db:"qty"`
+	Status      string          `json:"status" db:"status"` // open, filled, cancelled
+	FilledPrice decimal.Decimal `json:"filled_price" db:"filled_price"`
+}
+
+// PaperPosition 模拟盘持仓
+type PaperPosition struct {
+	UserID   int64           `json:"-" db:"user_id"`
+	Symbol   string          `json:"symbol" db:"symbol"`
+	Qty      decimal.Decimal `json:"qty" db:"qty"`
+	AvgPrice decimal.Decimal `json:"avg_price" db:"avg_price"`
+}
+
+// Portfolio 用户自定义的资产组合
+type Portfolio struct {
+	ID        int64     `json:"id" db:"id"`
+	UserID    int64     `json:"-" db:"user_id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -7,28 +7,125 @@ import (
 	"github.com/shopspring/decimal"
 )
 
-// Strategy 策略配置实体
+// Strategy 策略配置实体；Type 和 Config 是最新版本的
 type Strategy struct {
-	ID        int64           `json:"id" db:"id"`
-	UserID    int64           `json:"user_id" db:"user_id"`
-	Name      string          `json:"name" db:"name"`
-	Type      string          `json:"type" db:"type"`
-	Config    json.RawMessage `json:"config" db:"config"` // 灵活存储配置
-	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	ID            int64           `json:"id" db:"id"`
+	UserID        int64           `json:"user_id" db:"user_id"`
+	Name          string          `json:"name" db:"name"`
+	Description   string          `json:"description" db:"description"`
+	Type          string          `json:"type" db:"type"`
+	Config        json.RawMessage `json:"config" db:"config"` // 灵活存储配置
+	LatestVersion int             `json:"latest_version" db:"latest_version"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// StrategyVersion is an immutable snapshot of a strategy, referenced by
+// backtests and live runs through its ID
+type StrategyVersion struct {
+	ID         int64           `json:"id" db:"id"`
+	StrategyID int64           `json:"strategy_id" db:"strategy_id"`
+	Version    int             `json:"version" db:"version"` // 1, 2, ... per strategy
+	Type       string          `json:"type" db:"type"`
+	Config     json.RawMessage `json:"config" db:"config"`                 // without the WASM module
+	WasmHash   string          `json:"wasm_hash,omitempty" db:"wasm_hash"` // hex SHA-256 of the module
+	WasmSize   int             `json:"wasm_size,omitempty" db:"wasm_size"`
+	Note       string          `json:"note,omitempty" db:"note"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+}
+
+// MarketItem 策略市场中公开的策略
+type MarketItem struct {
+	ID          int64           `json:"id" db:"id"`
+	Price       decimal.Decimal `json:"price" db:"price"`
+	Description string          `json:"description" db:"description"`
+	Metrics     json.RawMessage `json:"metrics" db:"performance_metrics"`
+	Name        string          `json:"name" db:"name"`
+	Author      string          `json:"author" db:"author"`
 }
 
 // BacktestReport 回测结果报告
 type BacktestReport struct {
-	StrategyName   string          `json:"strategy_name"`
-	TotalTrades    int             `json:"total_trades"`
-	WinRate        float64         `json:"win_rate"`
-	TotalReturn    decimal.Decimal `json:"total_return"`
-	TotalProfit    decimal.Decimal `json:"total_profit"` // 净利润
-	MaxDrawdown    float64         `json:"max_drawdown"` // 最大回撤
-	SharpRatio     float64         `json:"sharp_ratio"`
-	InitialBalance decimal.Decimal `json:"initial_balance"`
-	FinalBalance   decimal.Decimal `json:"final_balance"`
-	TradesLog      []SimulatedTrade `json:"trades_log"`   // 交易明细
+	StrategyName string          `json:"strategy_name"`
+	TotalTrades  int             `json:"total_trades"` // fills, see ClosedTrades for trades realizing PnL
+	WinRate      float64         `json:"win_rate"`
+	TotalReturn  decimal.Decimal `json:"total_return"`
+	TotalProfit  decimal.Decimal `json:"total_profit"` // 净利润
+	MaxDrawdown  float64         `json:"max_drawdown"` // 最大回撤
+	// Deprecated: same value as SharpeRatio, kept for existing clients
+	SharpRatio     float64            `json:"sharp_ratio"`
+	InitialBalance decimal.Decimal    `json:"initial_balance"`
+	FinalBalance   decimal.Decimal    `json:"final_balance"`
+	TradesLog      []SimulatedTrade   `json:"trades_log"`       // 交易明细
+	Orders         []Order            `json:"orders"`           // 策略提交的全部订单
+	MaxMarginUsage float64            `json:"max_margin_usage"` // 已用保证金 / 权益 的峰值
+	AvgMarginUsage float64            `json:"avg_margin_usage"`
+	FundingPaid    decimal.Decimal    `json:"funding_paid"` // negative when funding was received
+	Liquidations   []LiquidationEvent `json:"liquidations"`
+	// 组合回测：按首次出现顺序的交易对，Correlation 的行列与之对应
+	Symbols     []string            `json:"symbols"`
+	EquityCurve []EquityPoint       `json:"equity_curve"` // one point per timestamp
+	Attribution []SymbolAttribution `json:"attribution"`
+	Correlation [][]float64         `json:"correlation,omitempty"` // of bar returns, nil for one symbol
+
+	// 风险收益指标，按 365 天年化
+	SharpeRatio         float64 `json:"sharpe_ratio"`
+	SortinoRatio        float64 `json:"sortino_ratio"`
+	CalmarRatio         float64 `json:"calmar_ratio"`
+	CAGR                float64 `json:"cagr"`
+	MaxDrawdownDuration float64 `json:"max_drawdown_duration_seconds"`
+	// 交易统计，基于平仓成交
+	ClosedTrades   int             `json:"closed_trades"`
+	ProfitFactor   float64         `json:"profit_factor"` // 0 when no trade lost
+	Expectancy     float64         `json:"expectancy"`    // average PnL per closed trade
+	AvgWin         float64         `json:"avg_win"`
+	AvgLoss        float64         `json:"avg_loss"`
+	Exposure       float64         `json:"exposure"` // share of bars with an open position
+	TradeDuration  DurationSummary `json:"trade_duration"`
+	MonthlyReturns []MonthlyReturn `json:"monthly_returns"`
+}
+
+// EquityPoint 权益曲线上的一个点
+type EquityPoint struct {
+	Time     time.Time       `json:"time"`
+	Equity   decimal.Decimal `json:"equity"`
+	Drawdown float64         `json:"drawdown"` // fraction below the running peak
+}
+
+// DurationSummary 持仓时长统计（秒）
+type DurationSummary struct {
+	Min    float64 `json:"min_seconds"`
+	Max    float64 `json:"max_seconds"`
+	Mean   float64 `json:"mean_seconds"`
+	Median float64 `json:"median_seconds"`
+}
+
+// MonthlyReturn 月度收益
+type MonthlyReturn struct {
+	Year   int     `json:"year"`
+	Month  int     `json:"month"`
+	Return float64 `json:"return"`
+}
+
+// SymbolAttribution 单个交易对对组合收益的贡献
+type SymbolAttribution struct {
+	Symbol       string          `json:"symbol"`
+	Trades       int             `json:"trades"`
+	Fees         decimal.Decimal `json:"fees"`
+	RealizedPnL  decimal.Decimal `json:"realized_pnl"` // net of fees
+	Funding      decimal.Decimal `json:"funding"`
+	NetPnL       decimal.Decimal `json:"net_pnl"`
+	Contribution float64         `json:"contribution"` // NetPnL / initial balance
+}
+
+// LiquidationEvent 回测中的强平记录
+type LiquidationEvent struct {
+	Time   time.Time       `json:"time"`
+	Symbol string          `json:"symbol"`
+	Side   string          `json:"side"` // side of the liquidated position: "long", "short"
+	Qty    decimal.Decimal `json:"qty"`
+	Price  decimal.Decimal `json:"price"`
+	PnL    decimal.Decimal `json:"pnl"`
 }
 
 // SimulatedTrade 回测中的单笔交易记录
@@ -39,5 +136,41 @@ type SimulatedTrade struct {
 	Price  decimal.Decimal `json:"price"`
 	Size   decimal.Decimal `json:"size"`
 	Fee    decimal.Decimal `json:"fee"`
-	PnL    decimal.Decimal `json:"pnl"` // Profit and Loss
+	PnL    decimal.Decimal `json:"pnl"` // realized against the average cost basis
+	// Closing is set when the fill reduced a position and realized PnL
+	Closing bool  `json:"closing"`
+	OrderID int64 `json:"order_id,omitempty"` // 0 for the final liquidation
+}
+
+// BacktestStatus 回测任务状态
+type BacktestStatus string
+
+const (
+	BacktestQueued    BacktestStatus = "queued"
+	BacktestRunning   BacktestStatus = "running"
+	BacktestDone      BacktestStatus = "done"
+	BacktestFailed    BacktestStatus = "failed"
+	BacktestCancelled BacktestStatus = "cancelled"
+)
+
+// BacktestRun is a backtest job queued for the workers and, once done, its report
+type BacktestRun struct {
+	ID           int64           `json:"id" db:"id"`
+	UserID       int64           `json:"user_id" db:"user_id"`
+	Status       BacktestStatus  `json:"status" db:"status"`
+	StrategyType string          `json:"strategy_type" db:"strategy_type"`
+	Config       json.RawMessage `json:"config" db:"config"`
+	Symbols      []string        `json:"symbols" db:"symbols"`
+	Exchange     string          `json:"exchange" db:"exchange"`
+	Period       string          `json:"period" db:"period"`
+	StartTime    time.Time       `json:"start_time" db:"start_time"`
+	EndTime      time.Time       `json:"end_time" db:"end_time"`
+	Spec         json.RawMessage `json:"spec" db:"spec"` // everything the worker needs to run it
+	Report       *BacktestReport `json:"report,omitempty" db:"report"`
+	Error        string          `json:"error,omitempty" db:"error"`
+	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
+	StartedAt    *time.Time      `json:"started_at,omitempty" db:"started_at"`
+	FinishedAt   *time.Time      `json:"finished_at,omitempty" db:"finished_at"`
+	// StrategyVersionID is the stored strategy version run, 0 for an inline config
+	StrategyVersionID int64 `json:"strategy_version_id,omitempty" db:"strategy_version_id"`
 }
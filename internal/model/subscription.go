@@ -21,3 +21,11 @@ type UserSubscription struct {
 	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
+
+// SubscriptionInfo 用户当前订阅及其等级信息
+type SubscriptionInfo struct {
+	TierName   string    `json:"tier_name"`
+	MaxSymbols int       `json:"max_symbols"`
+	Status     string    `json:"status"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
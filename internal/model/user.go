@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// User 平台用户
+type User struct {
+	ID           int64     `json:"id" db:"id"`
+	Email        string    `json:"email" db:"email"`
+	PasswordHash string    `json:"-" db:"password_hash"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// APIKey 用户的 API 访问密钥，KeySecret 仅保存哈希
+type APIKey struct {
+	ID        int64     `json:"id" db:"id"`
+	UserID    int64     `json:"-" db:"user_id"`
+	KeyID     string    `json:"key_id" db:"key_id"`
+	KeySecret string    `json:"-" db:"key_secret"`
+	Name      string    `json:"name" db:"name"`
+	IsActive  bool      `json:"is_active" db:"is_active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -6,29 +6,20 @@ import (
 	"errors"
 	"fmt"
 	"quant-trader/internal/model"
+	"quant-trader/internal/storage"
 	"sync"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/nats-io/nats.go"
 	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
-type Order struct {
-	ID          int64
-	UserID      int64
-	Symbol      string
-	Side        string
-	Type        string
-	Price       decimal.Decimal
-	Qty         decimal.Decimal
-	Status      string
-	FilledPrice decimal.Decimal
-}
+// Order is a simulated order tracked by the engine until it fills
+type Order = model.PaperOrder
 
 type PaperEngine struct {
-	db       *pgxpool.Pool
+	repo     storage.PaperRepository
 	js       nats.JetStreamContext
 	logger   *zap.Logger
 	orders   map[string][]Order // key: symbol
@@ -36,9 +27,9 @@ type PaperEngine struct {
 	fillChan chan Order
 }
 
-func NewPaperEngine(db *pgxpool.Pool, js nats.JetStreamContext, logger *zap.Logger) *PaperEngine {
+func NewPaperEngine(repo storage.PaperRepository, js nats.JetStreamContext, logger *zap.Logger) *PaperEngine {
 	return &PaperEngine{
-		db:       db,
+		repo:     repo,
 		js:       js,
 		logger:   logger,
 		orders:   make(map[string][]Order),
@@ -71,21 +62,16 @@ func (e *PaperEngine) Start(ctx context.Context) error {
 }
 
 func (e *PaperEngine) loadOpenOrders(ctx context.Context) error {
-	rows, err := e.db.Query(ctx, "SELECT id, user_id, symbol, side, type, price, qty, status FROM paper_orders WHERE status = 'open'")
+	orders, err := e.repo.ListOpenOrders(ctx)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
 
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	e.orders = make(map[string][]Order)
 
-	for rows.Next() {
-		var o Order
-		if err := rows.Scan(&o.ID, &o.UserID, &o.Symbol, &o.Side, &o.Type, &o.Price, &o.Qty, &o.Status); err != nil {
-			continue
-		}
+	for _, o := range orders {
 		e.orders[o.Symbol] = append(e.orders[o.Symbol], o)
 	}
 	return nil
@@ -160,49 +146,8 @@ func (e *PaperEngine) batchFlushLoop(ctx context.Context) {
 }
 
 func (e *PaperEngine) flushBatch(ctx context.Context, batch []Order) {
-	tx, err := e.db.Begin(ctx)
-	if err != nil {
-		e.logger.Error("failed to start batch transaction", zap.Error(err))
-		return
-	}
-	defer tx.Rollback(ctx)
-
-	for _, o := range batch {
-		// 1. Update order status
-		_, err = tx.Exec(ctx, "UPDATE paper_orders SET status = 'filled', filled_price = $1, filled_time = NOW() WHERE id = $2",
-			o.FilledPrice, o.ID)
-		if err != nil {
-			continue
-		}
-
-		// 2. Update balance
-		amount := o.Qty.Mul(o.FilledPrice)
-		if o.Side == "buy" {
-			_, err = tx.Exec(ctx, "UPDATE paper_accounts SET balance = balance - $1 WHERE user_id = $2", amount, o.UserID)
-		} else {
-			_, err = tx.Exec(ctx, "UPDATE paper_accounts SET balance = balance + $1 WHERE user_id = $2", amount, o.UserID)
-		}
-		if err != nil {
-			continue
-		}
-
-		// 3. Update positions
-		if o.Side == "buy" {
-			_, err = tx.Exec(ctx, `
-				INSERT INTO paper_positions (user_id, symbol, qty, avg_price) 
-				VALUES ($1, $2, $3, $4)
-				ON CONFLICT (user_id, symbol) DO UPDATE SET
-					avg_price = (paper_positions.qty * paper_positions.avg_price + $3 * $4) / (paper_positions.qty + $3),
-					qty = paper_positions.qty + $3`,
-				o.UserID, o.Symbol, o.Qty, o.FilledPrice)
-		} else {
-			_, err = tx.Exec(ctx, "UPDATE paper_positions SET qty = qty - $1 WHERE user_id = $2 AND symbol = $3",
-				o.Qty, o.UserID, o.Symbol)
-		}
-	}
-
-	if err := tx.Commit(ctx); err != nil {
-		e.logger.Error("failed to commit batch transaction", zap.Error(err))
+	if err := e.repo.ApplyFills(ctx, batch); err != nil {
+		e.logger.Error("failed to apply paper order fills", zap.Error(err))
 		return
 	}
 	e.logger.Info("paper order batch flushed", zap.Int("count", len(batch)))
@@ -211,8 +156,7 @@ func (e *PaperEngine) flushBatch(ctx context.Context, batch []Order) {
 func (e *PaperEngine) PlaceOrder(ctx context.Context, o Order) (int64, error) {
 	// Simple validation: check balance if buy
 	if o.Side == "buy" {
-		var balance decimal.Decimal
-		err := e.db.QueryRow(ctx, "SELECT balance FROM paper_accounts WHERE user_id = $1", o.UserID).Scan(&balance)
+		balance, err := e.repo.GetBalance(ctx, o.UserID)
 		if err != nil {
 			return 0, fmt.Errorf("failed to get balance: %w", err)
 		}
@@ -225,16 +169,13 @@ func (e *PaperEngine) PlaceOrder(ctx context.Context, o Order) (int64, error) {
 		}
 	}
 
-	var id int64
-	err := e.db.QueryRow(ctx,
-		"INSERT INTO paper_orders (user_id, symbol, side, type, price, qty) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
-		o.UserID, o.Symbol, o.Side, o.Type, o.Price, o.Qty).Scan(&id)
-
+	id, err := e.repo.CreateOrder(ctx, o)
 	if err != nil {
 		return 0, err
 	}
 
 	o.ID = id
+	o.Status = "open"
 	e.mu.Lock()
 	e.orders[o.Symbol] = append(e.orders[o.Symbol], o)
 	e.mu.Unlock()
@@ -4,9 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"quant-trader/internal/storage"
 	"strconv"
+	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stripe/stripe-go/v74"
 	"github.com/stripe/stripe-go/v74/checkout/session"
 	"github.com/stripe/stripe-go/v74/webhook"
@@ -14,14 +15,14 @@ import (
 )
 
 type StripeService struct {
-	db     *pgxpool.Pool
+	users  storage.UserRepository
 	logger *zap.Logger
 }
 
-func NewStripeService(db *pgxpool.Pool, logger *zap.Logger, apiKey string) *StripeService {
+func NewStripeService(users storage.UserRepository, logger *zap.Logger, apiKey string) *StripeService {
 	stripe.Key = apiKey
 	return &StripeService{
-		db:     db,
+		users:  users,
 		logger: logger,
 	}
 }
@@ -75,24 +76,7 @@ func (s *StripeService) HandleWebhook(payload []byte, sigHeader string, endpoint
 }
 
 func (s *StripeService) UpdateUserTier(ctx context.Context, userID int64, tierName string) error {
-	// 1. Get tier ID
-	var tierID int64
-	err := s.db.QueryRow(ctx, "SELECT id FROM subscription_tiers WHERE name = $1", tierName).Scan(&tierID)
-	if err != nil {
-		return fmt.Errorf("tier not found: %w", err)
-	}
-
-	// 2. Upsert subscription
-	_, err = s.db.Exec(ctx, `
-		INSERT INTO user_subscriptions (user_id, tier_id, status, expires_at)
-		VALUES ($1, $2, 'active', NOW() + INTERVAL '30 days')
-		ON CONFLICT (user_id) DO UPDATE SET
-			tier_id = $2,
-			status = 'active',
-			expires_at = NOW() + INTERVAL '30 days'`,
-		userID, tierID)
-
-	if err != nil {
+	if err := s.users.SetSubscriptionTier(ctx, userID, tierName, time.Now().AddDate(0, 0, 30)); err != nil {
 		return fmt.Errorf("failed to update subscription: %w", err)
 	}
 
@@ -3,20 +3,20 @@ package risk
 import (
 	"context"
 	"errors"
+	"quant-trader/internal/storage"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
 type RiskManager struct {
-	db     *pgxpool.Pool
+	repo   storage.PaperRepository
 	logger *zap.Logger
 }
 
-func NewRiskManager(db *pgxpool.Pool, logger *zap.Logger) *RiskManager {
+func NewRiskManager(repo storage.PaperRepository, logger *zap.Logger) *RiskManager {
 	return &RiskManager{
-		db:     db,
+		repo:   repo,
 		logger: logger,
 	}
 }
@@ -24,8 +24,7 @@ func NewRiskManager(db *pgxpool.Pool, logger *zap.Logger) *RiskManager {
 // PreTradeCheck validates if an order can be placed based on risk limits
 func (m *RiskManager) PreTradeCheck(ctx context.Context, userID int64, symbol string, side string, qty decimal.Decimal, price decimal.Decimal) error {
 	// 1. Check max position size (example: 10% of balance)
-	var balance decimal.Decimal
-	err := m.db.QueryRow(ctx, "SELECT balance FROM paper_accounts WHERE user_id = $1", userID).Scan(&balance)
+	balance, err := m.repo.GetBalance(ctx, userID)
 	if err != nil {
 		return errors.New("failed to get account balance for risk check")
 	}
@@ -36,11 +35,14 @@ func (m *RiskManager) PreTradeCheck(ctx context.Context, userID int64, symbol st
 	}
 
 	// 2. Check for existing exposure
-	var totalExposure decimal.Decimal
-	err = m.db.QueryRow(ctx, "SELECT COALESCE(SUM(qty * avg_price), 0) FROM paper_positions WHERE user_id = $1", userID).Scan(&totalExposure)
+	positions, err := m.repo.ListPositions(ctx, userID)
 	if err != nil {
 		return errors.New("failed to calculate total exposure")
 	}
+	totalExposure := decimal.Zero
+	for _, p := range positions {
+		totalExposure = totalExposure.Add(p.Qty.Mul(p.AvgPrice))
+	}
 
 	if totalExposure.Add(orderValue).GreaterThan(balance.Mul(decimal.NewFromFloat(0.5))) {
 		return errors.New("total portfolio exposure would exceed 50% limit")
@@ -51,19 +53,14 @@ func (m *RiskManager) PreTradeCheck(ctx context.Context, userID int64, symbol st
 
 // CheckStopLoss monitors positions and returns a list of symbols to liquidate
 func (m *RiskManager) CheckStopLoss(ctx context.Context, userID int64, currentPrices map[string]decimal.Decimal) ([]string, error) {
-	rows, err := m.db.Query(ctx, "SELECT symbol, qty, avg_price FROM paper_positions WHERE user_id = $1 AND qty > 0", userID)
+	positions, err := m.repo.ListPositions(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
 	var toLiquidate []string
-	for rows.Next() {
-		var symbol string
-		var qty, avgPrice decimal.Decimal
-		if err := rows.Scan(&symbol, &qty, &avgPrice); err != nil {
-			continue
-		}
+	for _, p := range positions {
+		symbol, avgPrice := p.Symbol, p.AvgPrice
 
 		price, ok := currentPrices[symbol]
 		if !ok {
@@ -9,20 +9,19 @@ import (
 	"quant-trader/internal/model"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
 // HistoryBackfiller 负责从交易所拉取历史 K 线数据并存入数据库
 type HistoryBackfiller struct {
-	db     *pgxpool.Pool
+	repo   MarketDataRepository
 	logger *zap.Logger
 }
 
-func NewHistoryBackfiller(db *pgxpool.Pool, logger *zap.Logger) *HistoryBackfiller {
+func NewHistoryBackfiller(repo MarketDataRepository, logger *zap.Logger) *HistoryBackfiller {
 	return &HistoryBackfiller{
-		db:     db,
+		repo:   repo,
 		logger: logger,
 	}
 }
@@ -79,7 +78,7 @@ func (b *HistoryBackfiller) BackfillBinance(ctx context.Context, symbol string,
 		}
 
 		// Save to DB
-		if err := b.saveKLines(ctx, klines); err != nil {
+		if err := b.repo.UpsertKlines(ctx, klines); err != nil {
 			return fmt.Errorf("failed to save klines: %w", err)
 		}
 
@@ -96,28 +95,6 @@ func (b *HistoryBackfiller) BackfillBinance(ctx context.Context, symbol string,
 	return nil
 }
 
-func (b *HistoryBackfiller) saveKLines(ctx context.Context, klines []model.KLine) error {
-	tx, err := b.db.Begin(ctx)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(ctx)
-
-	for _, k := range klines {
-		_, err := tx.Exec(ctx,
-			`INSERT INTO klines (symbol, exchange, period, open, high, low, close, volume, time)
-			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-			 ON CONFLICT (symbol, exchange, period, time) DO UPDATE SET
-			 open = EXCLUDED.open, high = EXCLUDED.high, low = EXCLUDED.low, close = EXCLUDED.close, volume = EXCLUDED.volume`,
-			k.Symbol, k.Exchange, k.Period, k.Open, k.High, k.Low, k.Close, k.Volume, k.Timestamp)
-		if err != nil {
-			return err
-		}
-	}
-
-	return tx.Commit(ctx)
-}
-
 func parseDecimal(s string) decimal.Decimal {
 	d, _ := decimal.NewFromString(s)
 	return d
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"quant-trader/internal/infrastructure"
+	"quant-trader/internal/model"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// OrderBookSaver batches depth snapshots into order_books, spilling batches
+// the database cannot take, as the trade and kline savers do
+type OrderBookSaver struct {
+	repo      MarketDataRepository
+	spill     *SpillLog // optional, nil disables spilling
+	logger    *zap.Logger
+	buffer    []model.OrderBook
+	mu        sync.Mutex
+	flushIntv time.Duration
+	batchSize int
+	// replayAfter delays the next replay attempt after the DB was seen failing
+	replayAfter time.Time
+}
+
+func NewOrderBookSaver(repo MarketDataRepository, spill *SpillLog, logger *zap.Logger, flushIntv time.Duration, batchSize int) *OrderBookSaver {
+	saver := &OrderBookSaver{
+		repo:      repo,
+		spill:     spill,
+		logger:    logger,
+		buffer:    make([]model.OrderBook, 0, batchSize),
+		flushIntv: flushIntv,
+		batchSize: batchSize,
+	}
+	go saver.run()
+	return saver
+}
+
+func (s *OrderBookSaver) Add(book model.OrderBook) {
+	s.mu.Lock()
+	s.buffer = append(s.buffer, book)
+	full := len(s.buffer) >= s.batchSize
+	s.mu.Unlock()
+
+	if full {
+		s.Flush()
+	}
+}
+
+func (s *OrderBookSaver) run() {
+	ticker := time.NewTicker(s.flushIntv)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.Flush()
+		s.replay()
+	}
+}
+
+func (s *OrderBookSaver) Flush() {
+	s.mu.Lock()
+	if len(s.buffer) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	books := s.buffer
+	s.buffer = make([]model.OrderBook, 0, s.batchSize)
+	s.mu.Unlock()
+
+	if err := s.insert(books); err != nil {
+		s.logger.Error("failed to execute order book batch insert", zap.Error(err))
+		s.spillBooks(books, err)
+		return
+	}
+	s.deferReplay(0)
+	infrastructure.DBInsertRate.WithLabelValues("order_books").Add(float64(len(books)))
+}
+
+func (s *OrderBookSaver) insert(books []model.OrderBook) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.repo.InsertOrderBooks(ctx, books)
+}
+
+func (s *OrderBookSaver) spillBooks(books []model.OrderBook, cause error) {
+	if s.spill == nil || !shouldSpill(cause) {
+		return
+	}
+	s.deferReplay(spillRetryInterval)
+
+	records := make([][]byte, 0, len(books))
+	for _, b := range books {
+		data, err := json.Marshal(b)
+		if err != nil {
+			continue
+		}
+		records = append(records, data)
+	}
+	if err := s.spill.Append(records); err != nil {
+		s.logger.Error("failed to spill order books, dropping batch", zap.Int("count", len(books)), zap.Error(err))
+		return
+	}
+	s.logger.Warn("database unavailable, spilled order books to disk", zap.Int("count", len(records)))
+}
+
+// replay pushes spilled order books back into the database once inserts succeed again
+func (s *OrderBookSaver) replay() {
+	if s.spill == nil || !s.replayDue() || !s.spill.Pending() {
+		return
+	}
+
+	n, err := s.spill.Replay(s.batchSize, func(records [][]byte) error {
+		books := make([]model.OrderBook, 0, len(records))
+		for _, r := range records {
+			var b model.OrderBook
+			if err := json.Unmarshal(r, &b); err != nil {
+				s.logger.Warn("skipping corrupt spilled order book", zap.Error(err))
+				continue
+			}
+			books = append(books, b)
+		}
+		if err := s.insert(books); err != nil {
+			if shouldSpill(err) {
+				return err
+			}
+			// The server rejected the rows; retrying will not help.
+			s.logger.Error("dropping spilled order books rejected by database", zap.Int("count", len(books)), zap.Error(err))
+			return nil
+		}
+		infrastructure.DBInsertRate.WithLabelValues("order_books").Add(float64(len(books)))
+		return nil
+	})
+	if err != nil {
+		s.deferReplay(spillRetryInterval)
+		s.logger.Warn("failed to replay spilled order books", zap.Int("replayed", n), zap.Error(err))
+		return
+	}
+	if n > 0 {
+		s.logger.Info("replayed spilled order books", zap.Int("count", n))
+	}
+}
+
+func (s *OrderBookSaver) deferReplay(d time.Duration) {
+	s.mu.Lock()
+	s.replayAfter = time.Now().Add(d)
+	s.mu.Unlock()
+}
+
+func (s *OrderBookSaver) replayDue() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !time.Now().Before(s.replayAfter)
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"quant-trader/internal/model"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestOrderBookSaver(t *testing.T) {
+	store := NewMemoryStore()
+	saver := NewOrderBookSaver(store.Market, nil, zap.NewNop(), time.Hour, 2)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	books := []model.OrderBook{
+		{Symbol: "BTCUSDT", Exchange: "binance", Timestamp: base, Bids: [][2]string{{"99", "1"}}},
+		{Symbol: "BTCUSDT", Exchange: "binance", Timestamp: base.Add(time.Second), Asks: [][2]string{{"101", "1"}}},
+		{Symbol: "BTCUSDT", Exchange: "binance", Timestamp: base.Add(2 * time.Second), Bids: [][2]string{{"100", "1"}}},
+	}
+
+	stored := func() []model.OrderBook {
+		it, err := store.Market.IterateOrderBooks(context.Background(), TickQuery{Symbol: "BTCUSDT", Start: base, End: base.Add(time.Minute)})
+		require.NoError(t, err)
+		var got []model.OrderBook
+		for it.Next() {
+			got = append(got, it.OrderBook())
+		}
+		return got
+	}
+
+	// A full batch is written straight away, the rest on Flush
+	for _, b := range books {
+		saver.Add(b)
+	}
+	assert.Equal(t, books[:2], stored())
+	saver.Flush()
+	assert.Equal(t, books, stored())
+}
+
+// downBooks fails order book inserts while down is set
+type downBooks struct {
+	MarketDataRepository
+	down bool
+}
+
+func (r *downBooks) InsertOrderBooks(ctx context.Context, books []model.OrderBook) error {
+	if r.down {
+		return errors.New("connection refused")
+	}
+	return r.MarketDataRepository.InsertOrderBooks(ctx, books)
+}
+
+func TestOrderBookSaver_SpillsWhileDatabaseIsDown(t *testing.T) {
+	store := NewMemoryStore()
+	repo := &downBooks{MarketDataRepository: store.Market, down: true}
+	spill, err := NewSpillLog(t.TempDir(), "order_books", 0, zap.NewNop())
+	require.NoError(t, err)
+	saver := NewOrderBookSaver(repo, spill, zap.NewNop(), time.Hour, 10)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	book := model.OrderBook{Symbol: "BTCUSDT", Exchange: "binance", Timestamp: base, Bids: [][2]string{{"99", "1"}}}
+
+	saver.Add(book)
+	saver.Flush()
+	assert.True(t, spill.Pending())
+
+	// Once the database is back the spilled snapshot is replayed
+	repo.down = false
+	saver.deferReplay(0)
+	saver.replay()
+	assert.False(t, spill.Pending())
+	it, err := store.Market.IterateOrderBooks(context.Background(), TickQuery{Symbol: "BTCUSDT", Start: base, End: base.Add(time.Minute)})
+	require.NoError(t, err)
+	require.True(t, it.Next())
+	assert.Equal(t, book, it.OrderBook())
+}
@@ -0,0 +1,105 @@
+package storage
+
+import "quant-trader/internal/model"
+
+// KlineIterator streams candles in query order. Callers must Close it and
+// check Err once Next returns false.
+type KlineIterator interface {
+	Next() bool
+	KLine() model.KLine
+	Err() error
+	Close()
+}
+
+// SliceKlineIterator iterates over candles already held in memory
+type SliceKlineIterator struct {
+	klines []model.KLine
+	pos    int
+}
+
+// NewSliceKlineIterator wraps klines in a KlineIterator
+func NewSliceKlineIterator(klines []model.KLine) *SliceKlineIterator {
+	return &SliceKlineIterator{klines: klines, pos: -1}
+}
+
+func (it *SliceKlineIterator) Next() bool {
+	if it.pos+1 >= len(it.klines) {
+		return false
+	}
+	it.pos++
+	return true
+}
+
+func (it *SliceKlineIterator) KLine() model.KLine { return it.klines[it.pos] }
+
+func (it *SliceKlineIterator) Err() error { return nil }
+
+func (it *SliceKlineIterator) Close() {}
+
+// TradeIterator streams trades in time order. Callers must Close it and check
+// Err once Next returns false.
+type TradeIterator interface {
+	Next() bool
+	Trade() model.Trade
+	Err() error
+	Close()
+}
+
+// SliceTradeIterator iterates over trades already held in memory
+type SliceTradeIterator struct {
+	trades []model.Trade
+	pos    int
+}
+
+// NewSliceTradeIterator wraps trades in a TradeIterator
+func NewSliceTradeIterator(trades []model.Trade) *SliceTradeIterator {
+	return &SliceTradeIterator{trades: trades, pos: -1}
+}
+
+func (it *SliceTradeIterator) Next() bool {
+	if it.pos+1 >= len(it.trades) {
+		return false
+	}
+	it.pos++
+	return true
+}
+
+func (it *SliceTradeIterator) Trade() model.Trade { return it.trades[it.pos] }
+
+func (it *SliceTradeIterator) Err() error { return nil }
+
+func (it *SliceTradeIterator) Close() {}
+
+// OrderBookIterator streams depth snapshots in time order. Callers must Close
+// it and check Err once Next returns false.
+type OrderBookIterator interface {
+	Next() bool
+	OrderBook() model.OrderBook
+	Err() error
+	Close()
+}
+
+// SliceOrderBookIterator iterates over snapshots already held in memory
+type SliceOrderBookIterator struct {
+	books []model.OrderBook
+	pos   int
+}
+
+// NewSliceOrderBookIterator wraps books in an OrderBookIterator
+func NewSliceOrderBookIterator(books []model.OrderBook) *SliceOrderBookIterator {
+	return &SliceOrderBookIterator{books: books, pos: -1}
+}
+
+func (it *SliceOrderBookIterator) Next() bool {
+	if it.pos+1 >= len(it.books) {
+		return false
+	}
+	it.pos++
+	return true
+}
+
+func (it *SliceOrderBookIterator) OrderBook() model.OrderBook { return it.books[it.pos] }
+
+func (it *SliceOrderBookIterator) Err() error { return nil }
+
+func (it *SliceOrderBookIterator) Close() {}
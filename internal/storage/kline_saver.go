@@ -2,28 +2,31 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"quant-trader/internal/infrastructure"
 	"quant-trader/internal/model"
 	"sync"
 	"time"
 
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 )
 
 type KlineSaver struct {
-	pool      *pgxpool.Pool
+	repo      MarketDataRepository
+	spill     *SpillLog // optional, nil disables spilling
 	logger    *zap.Logger
 	buffer    []model.KLine
 	mu        sync.Mutex
 	flushIntv time.Duration
 	batchSize int
+	// replayAfter delays the next replay attempt after the DB was seen failing
+	replayAfter time.Time
 }
 
-func NewKlineSaver(pool *pgxpool.Pool, logger *zap.Logger, flushIntv time.Duration, batchSize int) *KlineSaver {
+func NewKlineSaver(repo MarketDataRepository, spill *SpillLog, logger *zap.Logger, flushIntv time.Duration, batchSize int) *KlineSaver {
 	saver := &KlineSaver{
-		pool:      pool,
+		repo:      repo,
+		spill:     spill,
 		logger:    logger,
 		buffer:    make([]model.KLine, 0, batchSize),
 		flushIntv: flushIntv,
@@ -36,9 +39,10 @@ func NewKlineSaver(pool *pgxpool.Pool, logger *zap.Logger, flushIntv time.Durati
 func (s *KlineSaver) Add(kline model.KLine) {
 	s.mu.Lock()
 	s.buffer = append(s.buffer, kline)
+	full := len(s.buffer) >= s.batchSize
 	s.mu.Unlock()
 
-	if len(s.buffer) >= s.batchSize {
+	if full {
 		s.Flush()
 	}
 }
@@ -49,6 +53,7 @@ func (s *KlineSaver) run() {
 
 	for range ticker.C {
 		s.Flush()
+		s.replay()
 	}
 }
 
@@ -62,30 +67,88 @@ func (s *KlineSaver) Flush() {
 	s.buffer = make([]model.KLine, 0, s.batchSize)
 	s.mu.Unlock()
 
+	if err := s.insert(klines); err != nil {
+		s.logger.Error("failed to execute kline batch insert", zap.Error(err))
+		s.spillKlines(klines, err)
+		return
+	}
+	s.deferReplay(0)
+	infrastructure.DBInsertRate.WithLabelValues("klines").Add(float64(len(klines)))
+}
+
+func (s *KlineSaver) insert(klines []model.KLine) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	batch := &pgx.Batch{}
+	return s.repo.UpsertKlines(ctx, klines)
+}
+
+func (s *KlineSaver) spillKlines(klines []model.KLine, cause error) {
+	if s.spill == nil || !shouldSpill(cause) {
+		return
+	}
+	s.deferReplay(spillRetryInterval)
+
+	records := make([][]byte, 0, len(klines))
 	for _, k := range klines {
-		batch.Queue(`INSERT INTO klines (time, symbol, exchange, period, open, high, low, close, volume) 
-                     VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-                     ON CONFLICT (symbol, exchange, period, time) DO UPDATE SET
-                     open = EXCLUDED.open,
-                     high = EXCLUDED.high,
-                     low = EXCLUDED.low,
-                     close = EXCLUDED.close,
-                     volume = EXCLUDED.volume`,
-			k.Timestamp, k.Symbol, k.Exchange, k.Period, k.Open, k.High, k.Low, k.Close, k.Volume)
+		data, err := json.Marshal(k)
+		if err != nil {
+			continue
+		}
+		records = append(records, data)
 	}
+	if err := s.spill.Append(records); err != nil {
+		s.logger.Error("failed to spill klines, dropping batch", zap.Int("count", len(klines)), zap.Error(err))
+		return
+	}
+	s.logger.Warn("database unavailable, spilled klines to disk", zap.Int("count", len(records)))
+}
 
-	br := s.pool.SendBatch(ctx, batch)
-	defer br.Close()
+// replay pushes spilled klines back into the database once inserts succeed again
+func (s *KlineSaver) replay() {
+	if s.spill == nil || !s.replayDue() || !s.spill.Pending() {
+		return
+	}
 
-	for i := 0; i < len(klines); i++ {
-		_, err := br.Exec()
-		if err != nil {
-			s.logger.Error("failed to execute kline batch insert", zap.Error(err))
+	n, err := s.spill.Replay(s.batchSize, func(records [][]byte) error {
+		klines := make([]model.KLine, 0, len(records))
+		for _, r := range records {
+			var k model.KLine
+			if err := json.Unmarshal(r, &k); err != nil {
+				s.logger.Warn("skipping corrupt spilled kline", zap.Error(err))
+				continue
+			}
+			klines = append(klines, k)
+		}
+		if err := s.insert(klines); err != nil {
+			if shouldSpill(err) {
+				return err
+			}
+			// The server rejected the rows; retrying will not help.
+			s.logger.Error("dropping spilled klines rejected by database", zap.Int("count", len(klines)), zap.Error(err))
+			return nil
 		}
+		infrastructure.DBInsertRate.WithLabelValues("klines").Add(float64(len(klines)))
+		return nil
+	})
+	if err != nil {
+		s.deferReplay(spillRetryInterval)
+		s.logger.Warn("failed to replay spilled klines", zap.Int("replayed", n), zap.Error(err))
+		return
 	}
-	infrastructure.DBInsertRate.WithLabelValues("klines").Add(float64(len(klines)))
+	if n > 0 {
+		s.logger.Info("replayed spilled klines", zap.Int("count", n))
+	}
+}
+
+func (s *KlineSaver) deferReplay(d time.Duration) {
+	s.mu.Lock()
+	s.replayAfter = time.Now().Add(d)
+	s.mu.Unlock()
+}
+
+func (s *KlineSaver) replayDue() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !time.Now().Before(s.replayAfter)
 }
@@ -0,0 +1,875 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"quant-trader/internal/model"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// NewMemoryStore creates repositories that keep everything in process memory.
+// It mirrors the Postgres semantics closely enough for tests and the local demo mode.
+func NewMemoryStore() *Store {
+	m := &memoryBackend{
+		trades:        make(map[string]model.Trade),
+		orderBooks:    make(map[string]model.OrderBook),
+		klines:        make(map[string]model.KLine),
+		alerts:        make(map[int64]model.Alert),
+		accounts:      make(map[int64]decimal.Decimal),
+		orders:        make(map[int64]model.PaperOrder),
+		positions:     make(map[int64]map[string]model.PaperPosition),
+		portfolios:    make(map[int64]model.Portfolio),
+		users:         make(map[int64]model.User),
+		subscriptions: make(map[int64]model.SubscriptionInfo),
+		apiKeys:       make(map[int64]model.APIKey),
+		marketItems:   make(map[int64]model.MarketItem),
+		purchases:     make(map[[2]int64]time.Time),
+		strategies:    make(map[int64]model.Strategy),
+		deleted:       make(map[int64]bool),
+		versions:      make(map[int64]model.StrategyVersion),
+		blobs:         make(map[string][]byte),
+		backtests:     make(map[int64]model.BacktestRun),
+		deployments:   make(map[int64]model.Deployment),
+		states:        make(map[string][]byte),
+		executions:    make(map[int64]model.SignalExecution),
+		tiers: map[string]int{
+			"Free":       1,
+			"Pro":        10,
+			"Enterprise": 1000,
+		},
+	}
+	return &Store{
+		Market:     m,
+		Alerts:     m,
+		Paper:      m,
+		Users:      m,
+		Strategies: m,
+		Backtests:  m,
+
+		Deployments: m,
+		States:      m,
+		Executions:  m,
+		Leases:      m,
+	}
+}
+
+// memoryBackend implements every repository interface behind a single lock
+type memoryBackend struct {
+	mu     sync.RWMutex
+	nextID int64
+
+	trades     map[string]model.Trade     // key: exchange|symbol|trade_id|time
+	orderBooks map[string]model.OrderBook // key: exchange|symbol|time
+	klines     map[string]model.KLine     // key: exchange|symbol|period|time
+
+	alerts map[int64]model.Alert
+
+	accounts   map[int64]decimal.Decimal
+	orders     map[int64]model.PaperOrder
+	positions  map[int64]map[string]model.PaperPosition // user -> symbol
+	portfolios map[int64]model.Portfolio
+
+	users         map[int64]model.User
+	subscriptions map[int64]model.SubscriptionInfo
+	apiKeys       map[int64]model.APIKey
+	tiers         map[string]int // name -> max symbols
+
+	marketItems map[int64]model.MarketItem
+	purchases   map[[2]int64]time.Time
+	strategies  map[int64]model.Strategy
+	deleted     map[int64]bool // strategy ids
+	versions    map[int64]model.StrategyVersion
+	blobs       map[string][]byte // by hash
+
+	backtests map[int64]model.BacktestRun
+
+	deployments map[int64]model.Deployment
+	states      map[string][]byte // by runner key
+	executions  map[int64]model.SignalExecution
+}
+
+func (m *memoryBackend) newID() int64 {
+	m.nextID++
+	return m.nextID
+}
+
+// Market data
+
+func (m *memoryBackend) InsertTrades(ctx context.Context, trades []model.Trade) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range trades {
+		key := fmt.Sprintf("%s|%s|%s|%d", t.Exchange, t.Symbol, t.ID, t.Timestamp.UnixNano())
+		if _, ok := m.trades[key]; !ok {
+			m.trades[key] = t
+		}
+	}
+	return nil
+}
+
+func (m *memoryBackend) IterateTrades(ctx context.Context, q TickQuery) (TradeIterator, error) {
+	m.mu.RLock()
+	result := make([]model.Trade, 0)
+	for _, t := range m.trades {
+		if t.Symbol == q.Symbol && (q.Exchange == "" || t.Exchange == q.Exchange) && inTickRange(q, t.Timestamp) {
+			result = append(result, t)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Timestamp.Equal(result[j].Timestamp) {
+			return result[i].Timestamp.Before(result[j].Timestamp)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return NewSliceTradeIterator(result), nil
+}
+
+func (m *memoryBackend) InsertOrderBooks(ctx context.Context, books []model.OrderBook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, b := range books {
+		m.orderBooks[fmt.Sprintf("%s|%s|%d", b.Exchange, b.Symbol, b.Timestamp.UnixNano())] = b
+	}
+	return nil
+}
+
+func (m *memoryBackend) IterateOrderBooks(ctx context.Context, q TickQuery) (OrderBookIterator, error) {
+	m.mu.RLock()
+	result := make([]model.OrderBook, 0)
+	for _, b := range m.orderBooks {
+		if b.Symbol == q.Symbol && (q.Exchange == "" || b.Exchange == q.Exchange) && inTickRange(q, b.Timestamp) {
+			result = append(result, b)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Timestamp.Before(result[j].Timestamp) })
+	return NewSliceOrderBookIterator(result), nil
+}
+
+func inTickRange(q TickQuery, t time.Time) bool {
+	return !t.Before(q.Start) && t.Before(q.End)
+}
+
+func (m *memoryBackend) UpsertKlines(ctx context.Context, klines []model.KLine) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range klines {
+		m.klines[klineKey(k)] = k
+	}
+	return nil
+}
+
+func klineKey(k model.KLine) string {
+	return fmt.Sprintf("%s|%s|%s|%d", k.Exchange, k.Symbol, k.Period, k.Timestamp.UnixNano())
+}
+
+func (m *memoryBackend) QueryKlines(ctx context.Context, q KlineQuery) ([]model.KLine, error) {
+	m.mu.RLock()
+	result := make([]model.KLine, 0)
+	for _, k := range m.klines {
+		if k.Symbol != q.Symbol || k.Period != q.Period {
+			continue
+		}
+		if q.Exchange != "" && k.Exchange != q.Exchange {
+			continue
+		}
+		if !q.Start.IsZero() && k.Timestamp.Before(q.Start) {
+			continue
+		}
+		if !q.End.IsZero() && k.Timestamp.After(q.End) {
+			continue
+		}
+		result = append(result, k)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if q.Descending {
+			return result[i].Timestamp.After(result[j].Timestamp)
+		}
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+	if q.Limit > 0 && len(result) > q.Limit {
+		result = result[:q.Limit]
+	}
+	return result, nil
+}
+
+func (m *memoryBackend) IterateKlines(ctx context.Context, q KlineQuery) (KlineIterator, error) {
+	klines, err := m.QueryKlines(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return NewSliceKlineIterator(klines), nil
+}
+
+func (m *memoryBackend) LatestClose(ctx context.Context, symbol string) (decimal.Decimal, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var latest *model.KLine
+	for _, k := range m.klines {
+		if k.Symbol != symbol {
+			continue
+		}
+		if latest == nil || k.Timestamp.After(latest.Timestamp) {
+			k := k
+			latest = &k
+		}
+	}
+	if latest == nil {
+		return decimal.Zero, ErrNotFound
+	}
+	return latest.Close, nil
+}
+
+// Alerts
+
+func (m *memoryBackend) ListActiveAlerts(ctx context.Context) ([]model.Alert, error) {
+	return m.filterAlerts(func(a model.Alert) bool { return a.IsActive }), nil
+}
+
+func (m *memoryBackend) ListAlerts(ctx context.Context, userID int64) ([]model.Alert, error) {
+	return m.filterAlerts(func(a model.Alert) bool { return a.UserID == userID }), nil
+}
+
+func (m *memoryBackend) filterAlerts(keep func(model.Alert) bool) []model.Alert {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	alerts := make([]model.Alert, 0)
+	for _, a := range m.alerts {
+		if keep(a) {
+			alerts = append(alerts, a)
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].ID < alerts[j].ID })
+	return alerts
+}
+
+func (m *memoryBackend) CreateAlert(ctx context.Context, a model.Alert) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a.ID = m.newID()
+	a.IsActive = true
+	a.CreatedAt = time.Now()
+	m.alerts[a.ID] = a
+	return a.ID, nil
+}
+
+func (m *memoryBackend) DeleteAlert(ctx context.Context, userID, alertID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.alerts[alertID]
+	if !ok || a.UserID != userID {
+		return ErrNotFound
+	}
+	delete(m.alerts, alertID)
+	return nil
+}
+
+// Paper trading
+
+func (m *memoryBackend) GetBalance(ctx context.Context, userID int64) (decimal.Decimal, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	balance, ok := m.accounts[userID]
+	if !ok {
+		return decimal.Zero, ErrNotFound
+	}
+	return balance, nil
+}
+
+func (m *memoryBackend) CreateAccount(ctx context.Context, userID int64, balance decimal.Decimal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.accounts[userID]; !ok {
+		m.accounts[userID] = balance
+	}
+	return nil
+}
+
+func (m *memoryBackend) ListOpenOrders(ctx context.Context) ([]model.PaperOrder, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	orders := make([]model.PaperOrder, 0)
+	for _, o := range m.orders {
+		if o.Status == "open" {
+			orders = append(orders, o)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+	return orders, nil
+}
+
+func (m *memoryBackend) CreateOrder(ctx context.Context, o model.PaperOrder) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o.ID = m.newID()
+	o.Status = "open"
+	m.orders[o.ID] = o
+	return o.ID, nil
+}
+
+func (m *memoryBackend) ApplyFills(ctx context.Context, fills []model.PaperOrder) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, o := range fills {
+		if stored, ok := m.orders[o.ID]; ok {
+			stored.Status = "filled"
+			stored.FilledPrice = o.FilledPrice
+			m.orders[o.ID] = stored
+		}
+
+		amount := o.Qty.Mul(o.FilledPrice)
+		if o.Side == "buy" {
+			m.accounts[o.UserID] = m.accounts[o.UserID].Sub(amount)
+		} else {
+			m.accounts[o.UserID] = m.accounts[o.UserID].Add(amount)
+		}
+
+		if m.positions[o.UserID] == nil {
+			m.positions[o.UserID] = make(map[string]model.PaperPosition)
+		}
+		pos := m.positions[o.UserID][o.Symbol]
+		pos.UserID, pos.Symbol = o.UserID, o.Symbol
+		if o.Side == "buy" {
+			newQty := pos.Qty.Add(o.Qty)
+			if !newQty.IsZero() {
+				pos.AvgPrice = pos.Qty.Mul(pos.AvgPrice).Add(o.Qty.Mul(o.FilledPrice)).Div(newQty)
+			}
+			pos.Qty = newQty
+		} else {
+			pos.Qty = pos.Qty.Sub(o.Qty)
+		}
+		m.positions[o.UserID][o.Symbol] = pos
+	}
+	return nil
+}
+
+func (m *memoryBackend) ListPositions(ctx context.Context, userID int64) ([]model.PaperPosition, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	positions := make([]model.PaperPosition, 0)
+	for _, p := range m.positions[userID] {
+		if p.Qty.GreaterThan(decimal.Zero) {
+			positions = append(positions, p)
+		}
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i].Symbol < positions[j].Symbol })
+	return positions, nil
+}
+
+func (m *memoryBackend) ListPortfolios(ctx context.Context, userID int64) ([]model.Portfolio, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	portfolios := make([]model.Portfolio, 0)
+	for _, p := range m.portfolios {
+		if p.UserID == userID {
+			portfolios = append(portfolios, p)
+		}
+	}
+	sort.Slice(portfolios, func(i, j int) bool { return portfolios[i].ID < portfolios[j].ID })
+	return portfolios, nil
+}
+
+func (m *memoryBackend) CreatePortfolio(ctx context.Context, userID int64, name string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p := model.Portfolio{ID: m.newID(), UserID: userID, Name: name, CreatedAt: time.Now()}
+	m.portfolios[p.ID] = p
+	return p.ID, nil
+}
+
+// Users
+
+func (m *memoryBackend) CreateUser(ctx context.Context, email, passwordHash string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Email == email {
+			return 0, ErrConflict
+		}
+	}
+	u := model.User{ID: m.newID(), Email: email, PasswordHash: passwordHash, CreatedAt: time.Now()}
+	m.users[u.ID] = u
+	return u.ID, nil
+}
+
+func (m *memoryBackend) GetUserByEmail(ctx context.Context, email string) (model.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return model.User{}, ErrNotFound
+}
+
+func (m *memoryBackend) GetSubscription(ctx context.Context, userID int64) (model.SubscriptionInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sub, ok := m.subscriptions[userID]
+	if !ok {
+		return model.SubscriptionInfo{}, ErrNotFound
+	}
+	return sub, nil
+}
+
+func (m *memoryBackend) SetSubscriptionTier(ctx context.Context, userID int64, tierName string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	maxSymbols, ok := m.tiers[tierName]
+	if !ok {
+		return fmt.Errorf("tier not found: %w", ErrNotFound)
+	}
+	m.subscriptions[userID] = model.SubscriptionInfo{
+		TierName:   tierName,
+		MaxSymbols: maxSymbols,
+		Status:     "active",
+		ExpiresAt:  expiresAt,
+	}
+	return nil
+}
+
+func (m *memoryBackend) ListAPIKeys(ctx context.Context, userID int64) ([]model.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]model.APIKey, 0)
+	for _, k := range m.apiKeys {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+func (m *memoryBackend) CreateAPIKey(ctx context.Context, key model.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range m.apiKeys {
+		if k.KeyID == key.KeyID {
+			return ErrConflict
+		}
+	}
+	key.ID = m.newID()
+	key.IsActive = true
+	key.CreatedAt = time.Now()
+	m.apiKeys[key.ID] = key
+	return nil
+}
+
+// Strategies
+
+func (m *memoryBackend) ListMarketItems(ctx context.Context) ([]model.MarketItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	items := make([]model.MarketItem, 0, len(m.marketItems))
+	for _, item := range m.marketItems {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items, nil
+}
+
+func (m *memoryBackend) PurchaseMarketItem(ctx context.Context, userID, itemID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.marketItems[itemID]; !ok {
+		return ErrNotFound
+	}
+	key := [2]int64{userID, itemID}
+	if _, ok := m.purchases[key]; !ok {
+		m.purchases[key] = time.Now()
+	}
+	return nil
+}
+
+func (m *memoryBackend) CreateStrategy(ctx context.Context, strat model.Strategy, version model.StrategyVersion, wasm []byte) (model.Strategy, model.StrategyVersion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	strat.ID = m.newID()
+	strat.CreatedAt = time.Now()
+	m.strategies[strat.ID] = strat
+	version.StrategyID = strat.ID
+	version = m.addVersion(version, wasm)
+	return m.strategies[strat.ID], version, nil
+}
+
+// addVersion numbers version, stores it and makes it the strategy's latest
+func (m *memoryBackend) addVersion(version model.StrategyVersion, wasm []byte) model.StrategyVersion {
+	strat := m.strategies[version.StrategyID]
+	strat.LatestVersion++
+	strat.Type, strat.Config = version.Type, version.Config
+	strat.UpdatedAt = time.Now()
+	m.strategies[strat.ID] = strat
+
+	version.ID = m.newID()
+	version.Version = strat.LatestVersion
+	version.CreatedAt = strat.UpdatedAt
+	if version.WasmHash != "" {
+		if _, ok := m.blobs[version.WasmHash]; !ok {
+			m.blobs[version.WasmHash] = append([]byte(nil), wasm...)
+		}
+		version.WasmSize = len(m.blobs[version.WasmHash])
+	}
+	m.versions[version.ID] = version
+	return version
+}
+
+func (m *memoryBackend) ListStrategies(ctx context.Context, userID int64) ([]model.Strategy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]model.Strategy, 0)
+	for id, strat := range m.strategies {
+		if strat.UserID == userID && !m.deleted[id] {
+			list = append(list, strat)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID > list[j].ID })
+	return list, nil
+}
+
+func (m *memoryBackend) GetStrategy(ctx context.Context, userID, strategyID int64) (model.Strategy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.strategy(userID, strategyID)
+}
+
+func (m *memoryBackend) strategy(userID, strategyID int64) (model.Strategy, error) {
+	strat, ok := m.strategies[strategyID]
+	if !ok || strat.UserID != userID || m.deleted[strategyID] {
+		return model.Strategy{}, ErrNotFound
+	}
+	return strat, nil
+}
+
+func (m *memoryBackend) UpdateStrategy(ctx context.Context, strat model.Strategy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, err := m.strategy(strat.UserID, strat.ID)
+	if err != nil {
+		return err
+	}
+	stored.Name, stored.Description = strat.Name, strat.Description
+	stored.UpdatedAt = time.Now()
+	m.strategies[stored.ID] = stored
+	return nil
+}
+
+func (m *memoryBackend) DeleteStrategy(ctx context.Context, userID, strategyID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.strategy(userID, strategyID); err != nil {
+		return err
+	}
+	m.deleted[strategyID] = true
+	return nil
+}
+
+func (m *memoryBackend) CreateStrategyVersion(ctx context.Context, userID int64, version model.StrategyVersion, wasm []byte) (model.StrategyVersion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.strategy(userID, version.StrategyID); err != nil {
+		return model.StrategyVersion{}, err
+	}
+	return m.addVersion(version, wasm), nil
+}
+
+func (m *memoryBackend) ListStrategyVersions(ctx context.Context, userID, strategyID int64) ([]model.StrategyVersion, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if _, err := m.strategy(userID, strategyID); err != nil {
+		return nil, err
+	}
+	list := make([]model.StrategyVersion, 0)
+	for _, v := range m.versions {
+		if v.StrategyID == strategyID {
+			list = append(list, v)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version > list[j].Version })
+	return list, nil
+}
+
+func (m *memoryBackend) GetStrategyVersion(ctx context.Context, userID, versionID int64) (model.StrategyVersion, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v, ok := m.versions[versionID]
+	if !ok {
+		return model.StrategyVersion{}, ErrNotFound
+	}
+	if _, err := m.strategy(userID, v.StrategyID); err != nil {
+		return model.StrategyVersion{}, err
+	}
+	return v, nil
+}
+
+func (m *memoryBackend) GetStrategyBlob(ctx context.Context, hash string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, ok := m.blobs[hash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// Backtests
+
+func (m *memoryBackend) CreateBacktestRun(ctx context.Context, run model.BacktestRun) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run.ID = m.newID()
+	if run.Status == "" {
+		run.Status = model.BacktestQueued
+	}
+	run.CreatedAt = time.Now()
+	m.backtests[run.ID] = run
+	return run.ID, nil
+}
+
+func (m *memoryBackend) GetBacktestRun(ctx context.Context, userID, runID int64) (model.BacktestRun, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	run, ok := m.backtests[runID]
+	if !ok || run.UserID != userID {
+		return model.BacktestRun{}, ErrNotFound
+	}
+	return run, nil
+}
+
+func (m *memoryBackend) ListBacktestRuns(ctx context.Context, userID int64, limit int) ([]model.BacktestRun, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	runs := make([]model.BacktestRun, 0)
+	for _, run := range m.backtests {
+		if run.UserID == userID {
+			run.Report = nil
+			runs = append(runs, run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].ID > runs[j].ID })
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+func (m *memoryBackend) ClaimBacktestRun(ctx context.Context, runID int64) (model.BacktestRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run, ok := m.backtests[runID]
+	if !ok {
+		return model.BacktestRun{}, ErrNotFound
+	}
+	if run.Status != model.BacktestQueued && run.Status != model.BacktestRunning {
+		return model.BacktestRun{}, ErrConflict
+	}
+	now := time.Now()
+	run.Status = model.BacktestRunning
+	run.StartedAt = &now
+	m.backtests[runID] = run
+	return run, nil
+}
+
+func (m *memoryBackend) FinishBacktestRun(ctx context.Context, runID int64, status model.BacktestStatus, report *model.BacktestReport, errMsg string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run, ok := m.backtests[runID]
+	if !ok {
+		return ErrNotFound
+	}
+	if run.Status != model.BacktestRunning {
+		return ErrConflict
+	}
+	now := time.Now()
+	run.Status, run.Report, run.Error, run.FinishedAt = status, report, errMsg, &now
+	m.backtests[runID] = run
+	return nil
+}
+
+func (m *memoryBackend) CancelBacktestRun(ctx context.Context, userID, runID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run, ok := m.backtests[runID]
+	if !ok || run.UserID != userID {
+		return ErrNotFound
+	}
+	if run.Status != model.BacktestQueued && run.Status != model.BacktestRunning {
+		return ErrConflict
+	}
+	now := time.Now()
+	run.Status, run.FinishedAt = model.BacktestCancelled, &now
+	m.backtests[runID] = run
+	return nil
+}
+
+// Deployments
+
+func (m *memoryBackend) CreateDeployment(ctx context.Context, d model.Deployment) (model.Deployment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d.ID = m.newID()
+	if d.Status == "" {
+		d.Status = model.DeploymentRunning
+	}
+	d.CreatedAt = time.Now()
+	d.UpdatedAt = d.CreatedAt
+	m.deployments[d.ID] = d
+	return d, nil
+}
+
+func (m *memoryBackend) GetDeployment(ctx context.Context, userID, deploymentID int64) (model.Deployment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	d, ok := m.deployments[deploymentID]
+	if !ok || d.UserID != userID {
+		return model.Deployment{}, ErrNotFound
+	}
+	return d, nil
+}
+
+func (m *memoryBackend) ListDeployments(ctx context.Context, userID int64) ([]model.Deployment, error) {
+	list := m.filterDeployments(func(d model.Deployment) bool { return d.UserID == userID })
+	sort.Slice(list, func(i, j int) bool { return list[i].ID > list[j].ID })
+	return list, nil
+}
+
+func (m *memoryBackend) ListActiveDeployments(ctx context.Context) ([]model.Deployment, error) {
+	list := m.filterDeployments(func(d model.Deployment) bool { return d.Status != model.DeploymentStopped })
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (m *memoryBackend) filterDeployments(keep func(model.Deployment) bool) []model.Deployment {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]model.Deployment, 0)
+	for _, d := range m.deployments {
+		if keep(d) {
+			list = append(list, d)
+		}
+	}
+	return list
+}
+
+func (m *memoryBackend) SetDeploymentStatus(ctx context.Context, userID, deploymentID int64, status model.DeploymentStatus) (model.Deployment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.deployments[deploymentID]
+	if !ok || d.UserID != userID {
+		return model.Deployment{}, ErrNotFound
+	}
+	if d.Status == model.DeploymentStopped {
+		return model.Deployment{}, ErrConflict
+	}
+	d.Status, d.UpdatedAt = status, time.Now()
+	m.deployments[deploymentID] = d
+	return d, nil
+}
+
+func (m *memoryBackend) SetDeploymentPosition(ctx context.Context, deploymentID int64, qty decimal.Decimal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.deployments[deploymentID]
+	if !ok {
+		return ErrNotFound
+	}
+	d.PositionQty, d.UpdatedAt = qty, time.Now()
+	m.deployments[deploymentID] = d
+	return nil
+}
+
+// Strategy states
+
+func (m *memoryBackend) SaveStrategyState(ctx context.Context, key string, state []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[key] = slices.Clone(state)
+	return nil
+}
+
+func (m *memoryBackend) GetStrategyState(ctx context.Context, key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	state, ok := m.states[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return slices.Clone(state), nil
+}
+
+func (m *memoryBackend) DeploymentFills(ctx context.Context, deploymentID int64) (decimal.Decimal, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	held, open := decimal.Zero, 0
+	for _, e := range m.executions {
+		o, ok := m.orders[e.OrderID]
+		if e.DeploymentID != deploymentID || !ok {
+			continue
+		}
+		switch {
+		case o.Status == "open":
+			open++
+		case o.Status == "filled" && o.Side == "buy":
+			held = held.Add(o.Qty)
+		case o.Status == "filled":
+			held = held.Sub(o.Qty)
+		}
+	}
+	return held, open, nil
+}
+
+// Leases
+
+// AcquireLease always succeeds: a memory store lives in a single process
+func (m *memoryBackend) AcquireLease(ctx context.Context, name string) (bool, error) {
+	return true, nil
+}
+
+func (m *memoryBackend) ReleaseLease(ctx context.Context, name string) error {
+	return nil
+}
+
+// Signal executions
+
+func (m *memoryBackend) RecordExecution(ctx context.Context, e model.SignalExecution) (model.SignalExecution, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.ID = m.newID()
+	e.OrderStatus, e.FilledPrice = "", decimal.Zero
+	e.CreatedAt = time.Now()
+	m.executions[e.ID] = e
+	return e, nil
+}
+
+func (m *memoryBackend) ListExecutions(ctx context.Context, userID, deploymentID int64, limit int) ([]model.SignalExecution, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]model.SignalExecution, 0)
+	for _, e := range m.executions {
+		if e.UserID != userID || e.DeploymentID != deploymentID {
+			continue
+		}
+		if o, ok := m.orders[e.OrderID]; ok {
+			e.OrderStatus, e.FilledPrice = o.Status, o.FilledPrice
+		}
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID > list[j].ID })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, nil
+}
@@ -0,0 +1,323 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"quant-trader/internal/model"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_Klines(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var klines []model.KLine
+	for i := 0; i < 5; i++ {
+		klines = append(klines, model.KLine{
+			Symbol:    "BTCUSDT",
+			Exchange:  "binance",
+			Period:    "1m",
+			Close:     decimal.NewFromInt(int64(100 + i)),
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	require.NoError(t, store.Market.UpsertKlines(ctx, klines))
+
+	// Upsert replaces the candle with the same key
+	updated := klines[4]
+	updated.Close = decimal.NewFromInt(200)
+	require.NoError(t, store.Market.UpsertKlines(ctx, []model.KLine{updated}))
+
+	got, err := store.Market.QueryKlines(ctx, KlineQuery{Symbol: "BTCUSDT", Period: "1m", Start: base.Add(time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, got, 4)
+	assert.True(t, got[0].Timestamp.Equal(base.Add(time.Minute)))
+
+	got, err = store.Market.QueryKlines(ctx, KlineQuery{Symbol: "BTCUSDT", Period: "1m", Limit: 2, Descending: true})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.True(t, got[0].Close.Equal(decimal.NewFromInt(200)))
+
+	got, err = store.Market.QueryKlines(ctx, KlineQuery{Symbol: "BTCUSDT", Exchange: "okx", Period: "1m"})
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	price, err := store.Market.LatestClose(ctx, "BTCUSDT")
+	require.NoError(t, err)
+	assert.True(t, price.Equal(decimal.NewFromInt(200)))
+
+	_, err = store.Market.LatestClose(ctx, "ETHUSDT")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryStore_Ticks(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	trades := []model.Trade{
+		{ID: "2", Symbol: "BTCUSDT", Exchange: "binance", Price: decimal.NewFromInt(101), Timestamp: base.Add(time.Second)},
+		{ID: "1", Symbol: "BTCUSDT", Exchange: "binance", Price: decimal.NewFromInt(100), Timestamp: base},
+		{ID: "3", Symbol: "BTCUSDT", Exchange: "binance", Price: decimal.NewFromInt(102), Timestamp: base.Add(time.Minute)},
+		{ID: "1", Symbol: "BTCUSDT", Exchange: "okx", Price: decimal.NewFromInt(99), Timestamp: base},
+	}
+	require.NoError(t, store.Market.InsertTrades(ctx, trades))
+
+	// The end of the range is exclusive
+	it, err := store.Market.IterateTrades(ctx, TickQuery{Symbol: "BTCUSDT", Exchange: "binance", Start: base, End: base.Add(time.Minute)})
+	require.NoError(t, err)
+	var ids []string
+	for it.Next() {
+		ids = append(ids, it.Trade().ID)
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, []string{"1", "2"}, ids)
+
+	books := []model.OrderBook{
+		{Symbol: "BTCUSDT", Exchange: "binance", Timestamp: base, Bids: [][2]string{{"99", "1"}}, Asks: [][2]string{{"101", "2"}}},
+		{Symbol: "BTCUSDT", Exchange: "binance", Timestamp: base.Add(time.Second), Bids: [][2]string{{"100", "1"}}},
+	}
+	require.NoError(t, store.Market.InsertOrderBooks(ctx, books))
+	bit, err := store.Market.IterateOrderBooks(ctx, TickQuery{Symbol: "BTCUSDT", Start: base, End: base.Add(time.Hour)})
+	require.NoError(t, err)
+	var got []model.OrderBook
+	for bit.Next() {
+		got = append(got, bit.OrderBook())
+	}
+	assert.Equal(t, books, got)
+}
+
+func TestMemoryStore_PaperFills(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	require.NoError(t, store.Paper.CreateAccount(ctx, 1, decimal.NewFromInt(1000)))
+	buy, err := store.Paper.CreateOrder(ctx, model.PaperOrder{UserID: 1, Symbol: "BTCUSDT", Side: "buy", Type: "market", Qty: decimal.NewFromInt(2)})
+	require.NoError(t, err)
+	buy2, err := store.Paper.CreateOrder(ctx, model.PaperOrder{UserID: 1, Symbol: "BTCUSDT", Side: "buy", Type: "market", Qty: decimal.NewFromInt(2)})
+	require.NoError(t, err)
+
+	open, err := store.Paper.ListOpenOrders(ctx)
+	require.NoError(t, err)
+	assert.Len(t, open, 2)
+
+	require.NoError(t, store.Paper.ApplyFills(ctx, []model.PaperOrder{
+		{ID: buy, UserID: 1, Symbol: "BTCUSDT", Side: "buy", Qty: decimal.NewFromInt(2), FilledPrice: decimal.NewFromInt(100)},
+		{ID: buy2, UserID: 1, Symbol: "BTCUSDT", Side: "buy", Qty: decimal.NewFromInt(2), FilledPrice: decimal.NewFromInt(200)},
+	}))
+
+	open, err = store.Paper.ListOpenOrders(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, open)
+
+	balance, err := store.Paper.GetBalance(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, balance.Equal(decimal.NewFromInt(400)))
+
+	positions, err := store.Paper.ListPositions(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	assert.True(t, positions[0].Qty.Equal(decimal.NewFromInt(4)))
+	assert.True(t, positions[0].AvgPrice.Equal(decimal.NewFromInt(150)))
+
+	// Selling everything removes the position from the listing
+	require.NoError(t, store.Paper.ApplyFills(ctx, []model.PaperOrder{
+		{UserID: 1, Symbol: "BTCUSDT", Side: "sell", Qty: decimal.NewFromInt(4), FilledPrice: decimal.NewFromInt(150)},
+	}))
+	positions, err = store.Paper.ListPositions(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, positions)
+}
+
+func TestMemoryStore_Users(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	id, err := store.Users.CreateUser(ctx, "a@example.com", "hash")
+	require.NoError(t, err)
+	_, err = store.Users.CreateUser(ctx, "a@example.com", "other")
+	assert.ErrorIs(t, err, ErrConflict)
+
+	u, err := store.Users.GetUserByEmail(ctx, "a@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, id, u.ID)
+
+	_, err = store.Users.GetSubscription(ctx, id)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, store.Users.SetSubscriptionTier(ctx, id, "Pro", time.Now().AddDate(0, 0, 30)))
+	sub, err := store.Users.GetSubscription(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "Pro", sub.TierName)
+	assert.Equal(t, 10, sub.MaxSymbols)
+	assert.Equal(t, "active", sub.Status)
+
+	assert.ErrorIs(t, store.Users.SetSubscriptionTier(ctx, id, "Gold", time.Now()), ErrNotFound)
+
+	alertID, err := store.Alerts.CreateAlert(ctx, model.Alert{UserID: id, Symbol: "BTCUSDT", ConditionType: "price_above"})
+	require.NoError(t, err)
+	assert.ErrorIs(t, store.Alerts.DeleteAlert(ctx, id+1, alertID), ErrNotFound)
+	require.NoError(t, store.Alerts.DeleteAlert(ctx, id, alertID))
+}
+
+func TestMemoryStore_BacktestRuns(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	repo := store.Backtests
+
+	first, err := repo.CreateBacktestRun(ctx, model.BacktestRun{UserID: 1, Symbols: []string{"BTCUSDT"}})
+	require.NoError(t, err)
+	second, err := repo.CreateBacktestRun(ctx, model.BacktestRun{UserID: 1, Symbols: []string{"ETHUSDT"}})
+	require.NoError(t, err)
+
+	_, err = repo.GetBacktestRun(ctx, 2, first)
+	assert.ErrorIs(t, err, ErrNotFound, "other user's run")
+
+	run, err := repo.ClaimBacktestRun(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, model.BacktestRunning, run.Status)
+	require.NoError(t, repo.FinishBacktestRun(ctx, first, model.BacktestDone, &model.BacktestReport{TotalTrades: 3}, ""))
+	_, err = repo.ClaimBacktestRun(ctx, first)
+	assert.ErrorIs(t, err, ErrConflict, "finished runs are not rerun")
+
+	run, err = repo.GetBacktestRun(ctx, 1, first)
+	require.NoError(t, err)
+	require.NotNil(t, run.Report)
+	assert.Equal(t, 3, run.Report.TotalTrades)
+	assert.NotNil(t, run.FinishedAt)
+
+	// A cancelled run cannot be finished by the worker still holding it
+	_, err = repo.ClaimBacktestRun(ctx, second)
+	require.NoError(t, err)
+	assert.ErrorIs(t, repo.CancelBacktestRun(ctx, 2, second), ErrNotFound)
+	require.NoError(t, repo.CancelBacktestRun(ctx, 1, second))
+	assert.ErrorIs(t, repo.FinishBacktestRun(ctx, second, model.BacktestDone, &model.BacktestReport{}, ""), ErrConflict)
+	assert.ErrorIs(t, repo.CancelBacktestRun(ctx, 1, second), ErrConflict)
+
+	runs, err := repo.ListBacktestRuns(ctx, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, second, runs[0].ID)
+	assert.Equal(t, model.BacktestCancelled, runs[0].Status)
+	assert.Nil(t, runs[1].Report, "list leaves reports out")
+
+	runs, err = repo.ListBacktestRuns(ctx, 1, 1)
+	require.NoError(t, err)
+	assert.Len(t, runs, 1)
+}
+
+func TestMemoryStore_StrategyVersions(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryStore().Strategies
+
+	strat, v1, err := repo.CreateStrategy(ctx, model.Strategy{UserID: 1, Name: "cross"},
+		model.StrategyVersion{Type: "ma_cross", Config: json.RawMessage(`{"short_period":2}`)}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strat.LatestVersion)
+	assert.Equal(t, 1, v1.Version)
+	assert.Equal(t, strat.ID, v1.StrategyID)
+
+	v2, err := repo.CreateStrategyVersion(ctx, 1, model.StrategyVersion{StrategyID: strat.ID, Type: "wasm", Config: json.RawMessage(`{}`), WasmHash: "abc"}, []byte{1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, 2, v2.Version)
+	assert.Equal(t, 3, v2.WasmSize)
+	_, err = repo.CreateStrategyVersion(ctx, 2, model.StrategyVersion{StrategyID: strat.ID, Type: "ma_cross"}, nil)
+	assert.ErrorIs(t, err, ErrNotFound, "other user's strategy")
+
+	got, err := repo.GetStrategy(ctx, 1, strat.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "wasm", got.Type, "the latest version's type")
+	assert.Equal(t, 2, got.LatestVersion)
+	versions, err := repo.ListStrategyVersions(ctx, 1, strat.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, v2.ID, versions[0].ID)
+	blob, err := repo.GetStrategyBlob(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3}, blob)
+
+	require.NoError(t, repo.UpdateStrategy(ctx, model.Strategy{ID: strat.ID, UserID: 1, Name: "renamed"}))
+	assert.ErrorIs(t, repo.UpdateStrategy(ctx, model.Strategy{ID: strat.ID, UserID: 2, Name: "stolen"}), ErrNotFound)
+	got, err = repo.GetStrategy(ctx, 1, strat.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", got.Name)
+	assert.Equal(t, "wasm", got.Type)
+
+	_, err = repo.GetStrategyVersion(ctx, 2, v1.ID)
+	assert.ErrorIs(t, err, ErrNotFound, "other user's version")
+	require.NoError(t, repo.DeleteStrategy(ctx, 1, strat.ID))
+	_, err = repo.GetStrategyVersion(ctx, 1, v1.ID)
+	assert.ErrorIs(t, err, ErrNotFound, "deleted strategy")
+	list, err := repo.ListStrategies(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestMemoryStore_Deployments(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryStore().Deployments
+
+	first, err := repo.CreateDeployment(ctx, model.Deployment{UserID: 1, StrategyVersionID: 7, Symbol: "BTCUSDT", Period: "1m"})
+	require.NoError(t, err)
+	assert.Equal(t, model.DeploymentRunning, first.Status)
+	second, err := repo.CreateDeployment(ctx, model.Deployment{UserID: 1, StrategyVersionID: 7, Symbol: "ETHUSDT", Period: "5m"})
+	require.NoError(t, err)
+	other, err := repo.CreateDeployment(ctx, model.Deployment{UserID: 2, StrategyVersionID: 8, Symbol: "BTCUSDT", Period: "1m"})
+	require.NoError(t, err)
+
+	_, err = repo.GetDeployment(ctx, 2, first.ID)
+	assert.ErrorIs(t, err, ErrNotFound, "other user's deployment")
+	_, err = repo.SetDeploymentStatus(ctx, 2, first.ID, model.DeploymentStopped)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	paused, err := repo.SetDeploymentStatus(ctx, 1, first.ID, model.DeploymentPaused)
+	require.NoError(t, err)
+	assert.Equal(t, model.DeploymentPaused, paused.Status)
+	_, err = repo.SetDeploymentStatus(ctx, 1, second.ID, model.DeploymentStopped)
+	require.NoError(t, err)
+	_, err = repo.SetDeploymentStatus(ctx, 1, second.ID, model.DeploymentRunning)
+	assert.ErrorIs(t, err, ErrConflict, "stopped deployments stay stopped")
+
+	require.NoError(t, repo.SetDeploymentPosition(ctx, first.ID, decimal.NewFromFloat(0.5)))
+	got, err := repo.GetDeployment(ctx, 1, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "0.5", got.PositionQty.String())
+
+	list, err := repo.ListDeployments(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, second.ID, list[0].ID)
+
+	active, err := repo.ListActiveDeployments(ctx)
+	require.NoError(t, err)
+	require.Len(t, active, 2)
+	assert.Equal(t, first.ID, active[0].ID)
+	assert.Equal(t, other.ID, active[1].ID)
+}
+
+func TestMemoryStore_StrategyStates(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryStore().States
+
+	_, err := repo.GetStrategyState(ctx, "deployment:1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	state := []byte(`{"count":1}`)
+	require.NoError(t, repo.SaveStrategyState(ctx, "deployment:1", state))
+	state[2] = 'x' // the store keeps its own copy
+	require.NoError(t, repo.SaveStrategyState(ctx, "deployment:2", []byte(`{}`)))
+	got, err := repo.GetStrategyState(ctx, "deployment:1")
+	require.NoError(t, err)
+	assert.Equal(t, `{"count":1}`, string(got))
+
+	require.NoError(t, repo.SaveStrategyState(ctx, "deployment:1", []byte(`{"count":2}`)))
+	got, err = repo.GetStrategyState(ctx, "deployment:1")
+	require.NoError(t, err)
+	assert.Equal(t, `{"count":2}`, string(got))
+}
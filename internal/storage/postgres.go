@@ -0,0 +1,1125 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"quant-trader/internal/model"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+)
+
+// NewPostgresStore creates repositories backed by the shared pgx pool.
+// source decides whether candles above 1m are read from the continuous aggregates.
+func NewPostgresStore(db *pgxpool.Pool, source KlineSource) *Store {
+	return &Store{
+		Market:     &pgMarketRepository{db: db, source: source},
+		Alerts:     &pgAlertRepository{db: db},
+		Paper:      &pgPaperRepository{db: db},
+		Users:      &pgUserRepository{db: db},
+		Strategies: &pgStrategyRepository{db: db},
+		Backtests:  &pgBacktestRepository{db: db},
+
+		Deployments: &pgDeploymentRepository{db: db},
+		States:      &pgStateRepository{db: db},
+		Executions:  &pgExecutionRepository{db: db},
+		Leases:      &pgLeaseRepository{db: db, held: make(map[string]bool)},
+	}
+}
+
+// mapError translates pgx errors into the repository sentinel errors
+func mapError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrConflict
+	}
+	return err
+}
+
+type pgMarketRepository struct {
+	db     *pgxpool.Pool
+	source KlineSource
+}
+
+func (r *pgMarketRepository) InsertTrades(ctx context.Context, trades []model.Trade) error {
+	batch := &pgx.Batch{}
+	for _, t := range trades {
+		batch.Queue(`INSERT INTO trades (time, symbol, exchange, price, amount, side, trade_id)
+                     VALUES ($1, $2, $3, $4, $5, $6, $7)
+                     ON CONFLICT (symbol, exchange, trade_id, time) DO NOTHING`,
+			t.Timestamp, t.Symbol, t.Exchange, t.Price, t.Amount, t.Side, t.ID)
+	}
+	return r.sendBatch(ctx, batch)
+}
+
+func (r *pgMarketRepository) IterateTrades(ctx context.Context, q TickQuery) (TradeIterator, error) {
+	where, args := tickConditions(q)
+	rows, err := r.db.Query(ctx, `SELECT time, symbol, exchange, price, amount, COALESCE(side, ''), trade_id
+		FROM trades WHERE `+where+` ORDER BY time, trade_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &pgTradeIterator{rows: rows}, nil
+}
+
+func (r *pgMarketRepository) InsertOrderBooks(ctx context.Context, books []model.OrderBook) error {
+	batch := &pgx.Batch{}
+	for _, b := range books {
+		bids, err := json.Marshal(b.Bids)
+		if err != nil {
+			return err
+		}
+		asks, err := json.Marshal(b.Asks)
+		if err != nil {
+			return err
+		}
+		batch.Queue(`INSERT INTO order_books (time, symbol, exchange, bids, asks)
+                     VALUES ($1, $2, $3, $4, $5)
+                     ON CONFLICT (symbol, exchange, time) DO UPDATE SET
+                     bids = EXCLUDED.bids,
+                     asks = EXCLUDED.asks`,
+			b.Timestamp, b.Symbol, b.Exchange, bids, asks)
+	}
+	return r.sendBatch(ctx, batch)
+}
+
+func (r *pgMarketRepository) IterateOrderBooks(ctx context.Context, q TickQuery) (OrderBookIterator, error) {
+	where, args := tickConditions(q)
+	rows, err := r.db.Query(ctx, `SELECT time, symbol, exchange, bids, asks
+		FROM order_books WHERE `+where+` ORDER BY time`, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &pgOrderBookIterator{rows: rows}, nil
+}
+
+// tickConditions renders the WHERE clause of a TickQuery
+func tickConditions(q TickQuery) (string, []any) {
+	conds := []string{"symbol = $1", "time >= $2", "time < $3"}
+	args := []any{q.Symbol, q.Start, q.End}
+	if q.Exchange != "" {
+		args = append(args, q.Exchange)
+		conds = append(conds, fmt.Sprintf("exchange = $%d", len(args)))
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// pgTradeIterator scans one row per Next call, like pgKlineIterator
+type pgTradeIterator struct {
+	rows pgx.Rows
+	cur  model.Trade
+	err  error
+}
+
+func (it *pgTradeIterator) Next() bool {
+	if it.err != nil || !it.rows.Next() {
+		return false
+	}
+	var t model.Trade
+	if err := it.rows.Scan(&t.Timestamp, &t.Symbol, &t.Exchange, &t.Price, &t.Amount, &t.Side, &t.ID); err != nil {
+		it.err = err
+		return false
+	}
+	it.cur = t
+	return true
+}
+
+func (it *pgTradeIterator) Trade() model.Trade { return it.cur }
+
+func (it *pgTradeIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.rows.Err()
+}
+
+func (it *pgTradeIterator) Close() { it.rows.Close() }
+
+type pgOrderBookIterator struct {
+	rows pgx.Rows
+	cur  model.OrderBook
+	err  error
+}
+
+func (it *pgOrderBookIterator) Next() bool {
+	if it.err != nil || !it.rows.Next() {
+		return false
+	}
+	var (
+		b          model.OrderBook
+		bids, asks []byte
+	)
+	if err := it.rows.Scan(&b.Timestamp, &b.Symbol, &b.Exchange, &bids, &asks); err != nil {
+		it.err = err
+		return false
+	}
+	if err := json.Unmarshal(bids, &b.Bids); err != nil {
+		it.err = err
+		return false
+	}
+	if err := json.Unmarshal(asks, &b.Asks); err != nil {
+		it.err = err
+		return false
+	}
+	it.cur = b
+	return true
+}
+
+func (it *pgOrderBookIterator) OrderBook() model.OrderBook { return it.cur }
+
+func (it *pgOrderBookIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.rows.Err()
+}
+
+func (it *pgOrderBookIterator) Close() { it.rows.Close() }
+
+func (r *pgMarketRepository) UpsertKlines(ctx context.Context, klines []model.KLine) error {
+	batch := &pgx.Batch{}
+	for _, k := range klines {
+		batch.Queue(`INSERT INTO klines (time, symbol, exchange, period, open, high, low, close, volume)
+                     VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+                     ON CONFLICT (symbol, exchange, period, time) DO UPDATE SET
+                     open = EXCLUDED.open,
+                     high = EXCLUDED.high,
+                     low = EXCLUDED.low,
+                     close = EXCLUDED.close,
+                     volume = EXCLUDED.volume`,
+			k.Timestamp, k.Symbol, k.Exchange, k.Period, k.Open, k.High, k.Low, k.Close, k.Volume)
+	}
+	return r.sendBatch(ctx, batch)
+}
+
+func (r *pgMarketRepository) sendBatch(ctx context.Context, batch *pgx.Batch) error {
+	if batch.Len() == 0 {
+		return nil
+	}
+	br := r.db.SendBatch(ctx, batch)
+	defer br.Close()
+
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := br.Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *pgMarketRepository) QueryKlines(ctx context.Context, q KlineQuery) ([]model.KLine, error) {
+	sql, args := buildKlineQuery(r.source.Relation(q.Period), q)
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	klines := make([]model.KLine, 0)
+	for rows.Next() {
+		var k model.KLine
+		if err := rows.Scan(&k.Timestamp, &k.Symbol, &k.Exchange, &k.Period, &k.Open, &k.High, &k.Low, &k.Close, &k.Volume); err != nil {
+			return nil, err
+		}
+		klines = append(klines, k)
+	}
+	return klines, rows.Err()
+}
+
+func (r *pgMarketRepository) IterateKlines(ctx context.Context, q KlineQuery) (KlineIterator, error) {
+	sql, args := buildKlineQuery(r.source.Relation(q.Period), q)
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &pgKlineIterator{rows: rows}, nil
+}
+
+// pgKlineIterator scans one row per Next call so large ranges stream from the server
+type pgKlineIterator struct {
+	rows pgx.Rows
+	cur  model.KLine
+	err  error
+}
+
+func (it *pgKlineIterator) Next() bool {
+	if it.err != nil || !it.rows.Next() {
+		return false
+	}
+	var k model.KLine
+	if err := it.rows.Scan(&k.Timestamp, &k.Symbol, &k.Exchange, &k.Period, &k.Open, &k.High, &k.Low, &k.Close, &k.Volume); err != nil {
+		it.err = err
+		return false
+	}
+	it.cur = k
+	return true
+}
+
+func (it *pgKlineIterator) KLine() model.KLine { return it.cur }
+
+func (it *pgKlineIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.rows.Err()
+}
+
+func (it *pgKlineIterator) Close() { it.rows.Close() }
+
+// buildKlineQuery renders the SELECT for q against relation with positional args
+func buildKlineQuery(relation string, q KlineQuery) (string, []any) {
+	conds := []string{"symbol = $1", "period = $2"}
+	args := []any{q.Symbol, q.Period}
+	if q.Exchange != "" {
+		args = append(args, q.Exchange)
+		conds = append(conds, fmt.Sprintf("exchange = $%d", len(args)))
+	}
+	if !q.Start.IsZero() {
+		args = append(args, q.Start)
+		conds = append(conds, fmt.Sprintf("time >= $%d", len(args)))
+	}
+	if !q.End.IsZero() {
+		args = append(args, q.End)
+		conds = append(conds, fmt.Sprintf("time <= $%d", len(args)))
+	}
+
+	order := "ASC"
+	if q.Descending {
+		order = "DESC"
+	}
+	sql := fmt.Sprintf(`SELECT time, symbol, exchange, period, open, high, low, close, volume
+		FROM %s WHERE %s ORDER BY time %s`, relation, strings.Join(conds, " AND "), order)
+	if q.Limit > 0 {
+		sql += fmt.Sprintf(" LIMIT %d", q.Limit)
+	}
+	return sql, args
+}
+
+func (r *pgMarketRepository) LatestClose(ctx context.Context, symbol string) (decimal.Decimal, error) {
+	var price decimal.Decimal
+	err := r.db.QueryRow(ctx, "SELECT close FROM klines WHERE symbol = $1 ORDER BY time DESC LIMIT 1", symbol).Scan(&price)
+	if err != nil {
+		return decimal.Zero, mapError(err)
+	}
+	return price, nil
+}
+
+type pgAlertRepository struct {
+	db *pgxpool.Pool
+}
+
+func (r *pgAlertRepository) ListActiveAlerts(ctx context.Context) ([]model.Alert, error) {
+	return r.list(ctx, "SELECT id, user_id, symbol, condition_type, target_value, is_active, created_at FROM alerts WHERE is_active = TRUE")
+}
+
+func (r *pgAlertRepository) ListAlerts(ctx context.Context, userID int64) ([]model.Alert, error) {
+	return r.list(ctx, "SELECT id, user_id, symbol, condition_type, target_value, is_active, created_at FROM alerts WHERE user_id = $1", userID)
+}
+
+func (r *pgAlertRepository) list(ctx context.Context, sql string, args ...any) ([]model.Alert, error) {
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := make([]model.Alert, 0)
+	for rows.Next() {
+		var a model.Alert
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Symbol, &a.ConditionType, &a.TargetValue, &a.IsActive, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+func (r *pgAlertRepository) CreateAlert(ctx context.Context, a model.Alert) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(ctx,
+		"INSERT INTO alerts (user_id, symbol, condition_type, target_value) VALUES ($1, $2, $3, $4) RETURNING id",
+		a.UserID, a.Symbol, a.ConditionType, a.TargetValue).Scan(&id)
+	return id, mapError(err)
+}
+
+func (r *pgAlertRepository) DeleteAlert(ctx context.Context, userID, alertID int64) error {
+	result, err := r.db.Exec(ctx, "DELETE FROM alerts WHERE id = $1 AND user_id = $2", alertID, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type pgPaperRepository struct {
+	db *pgxpool.Pool
+}
+
+func (r *pgPaperRepository) GetBalance(ctx context.Context, userID int64) (decimal.Decimal, error) {
+	var balance decimal.Decimal
+	err := r.db.QueryRow(ctx, "SELECT balance FROM paper_accounts WHERE user_id = $1", userID).Scan(&balance)
+	return balance, mapError(err)
+}
+
+func (r *pgPaperRepository) CreateAccount(ctx context.Context, userID int64, balance decimal.Decimal) error {
+	_, err := r.db.Exec(ctx, "INSERT INTO paper_accounts (user_id, balance) VALUES ($1, $2) ON CONFLICT DO NOTHING", userID, balance)
+	return err
+}
+
+func (r *pgPaperRepository) ListOpenOrders(ctx context.Context) ([]model.PaperOrder, error) {
+	rows, err := r.db.Query(ctx, "SELECT id, user_id, symbol, side, type, COALESCE(price, 0), qty, status FROM paper_orders WHERE status = 'open' ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := make([]model.PaperOrder, 0)
+	for rows.Next() {
+		var o model.PaperOrder
+		if err := rows.Scan(&o.ID, &o.UserID, &o.Symbol, &o.Side, &o.Type, &o.Price, &o.Qty, &o.Status); err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+func (r *pgPaperRepository) CreateOrder(ctx context.Context, o model.PaperOrder) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(ctx,
+		"INSERT INTO paper_orders (user_id, symbol, side, type, price, qty) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		o.UserID, o.Symbol, o.Side, o.Type, o.Price, o.Qty).Scan(&id)
+	return id, mapError(err)
+}
+
+func (r *pgPaperRepository) ApplyFills(ctx context.Context, fills []model.PaperOrder) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, o := range fills {
+		// 1. Update order status
+		if _, err := tx.Exec(ctx, "UPDATE paper_orders SET status = 'filled', filled_price = $1, filled_time = NOW() WHERE id = $2",
+			o.FilledPrice, o.ID); err != nil {
+			return err
+		}
+
+		// 2. Update balance
+		amount := o.Qty.Mul(o.FilledPrice)
+		if o.Side == "buy" {
+			_, err = tx.Exec(ctx, "UPDATE paper_accounts SET balance = balance - $1 WHERE user_id = $2", amount, o.UserID)
+		} else {
+			_, err = tx.Exec(ctx, "UPDATE paper_accounts SET balance = balance + $1 WHERE user_id = $2", amount, o.UserID)
+		}
+		if err != nil {
+			return err
+		}
+
+		// 3. Update positions
+		if o.Side == "buy" {
+			_, err = tx.Exec(ctx, `
+				INSERT INTO paper_positions (user_id, symbol, qty, avg_price)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT (user_id, symbol) DO UPDATE SET
+					avg_price = (paper_positions.qty * paper_positions.avg_price + $3 * $4) / (paper_positions.qty + $3),
+					qty = paper_positions.qty + $3`,
+				o.UserID, o.Symbol, o.Qty, o.FilledPrice)
+		} else {
+			_, err = tx.Exec(ctx, "UPDATE paper_positions SET qty = qty - $1 WHERE user_id = $2 AND symbol = $3",
+				o.Qty, o.UserID, o.Symbol)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *pgPaperRepository) ListPositions(ctx context.Context, userID int64) ([]model.PaperPosition, error) {
+	rows, err := r.db.Query(ctx, "SELECT user_id, symbol, qty, avg_price FROM paper_positions WHERE user_id = $1 AND qty > 0", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	positions := make([]model.PaperPosition, 0)
+	for rows.Next() {
+		var p model.PaperPosition
+		if err := rows.Scan(&p.UserID, &p.Symbol, &p.Qty, &p.AvgPrice); err != nil {
+			return nil, err
+		}
+		positions = append(positions, p)
+	}
+	return positions, rows.Err()
+}
+
+func (r *pgPaperRepository) ListPortfolios(ctx context.Context, userID int64) ([]model.Portfolio, error) {
+	rows, err := r.db.Query(ctx, "SELECT id, user_id, name, created_at FROM portfolios WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	portfolios := make([]model.Portfolio, 0)
+	for rows.Next() {
+		var p model.Portfolio
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Name, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		portfolios = append(portfolios, p)
+	}
+	return portfolios, rows.Err()
+}
+
+func (r *pgPaperRepository) CreatePortfolio(ctx context.Context, userID int64, name string) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(ctx, "INSERT INTO portfolios (user_id, name) VALUES ($1, $2) RETURNING id", userID, name).Scan(&id)
+	return id, mapError(err)
+}
+
+type pgUserRepository struct {
+	db *pgxpool.Pool
+}
+
+func (r *pgUserRepository) CreateUser(ctx context.Context, email, passwordHash string) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(ctx,
+		"INSERT INTO users (email, password_hash) VALUES ($1, $2) RETURNING id",
+		email, passwordHash).Scan(&id)
+	return id, mapError(err)
+}
+
+func (r *pgUserRepository) GetUserByEmail(ctx context.Context, email string) (model.User, error) {
+	var u model.User
+	err := r.db.QueryRow(ctx,
+		"SELECT id, email, password_hash, created_at FROM users WHERE email = $1", email).
+		Scan(&u.ID, &u.Email, &u.PasswordHash, &u.CreatedAt)
+	return u, mapError(err)
+}
+
+func (r *pgUserRepository) GetSubscription(ctx context.Context, userID int64) (model.SubscriptionInfo, error) {
+	var sub model.SubscriptionInfo
+	err := r.db.QueryRow(ctx,
+		`SELECT t.name, t.max_symbols, s.status, s.expires_at
+		 FROM user_subscriptions s
+		 JOIN subscription_tiers t ON s.tier_id = t.id
+		 WHERE s.user_id = $1`, userID).Scan(&sub.TierName, &sub.MaxSymbols, &sub.Status, &sub.ExpiresAt)
+	return sub, mapError(err)
+}
+
+func (r *pgUserRepository) SetSubscriptionTier(ctx context.Context, userID int64, tierName string, expiresAt time.Time) error {
+	var tierID int64
+	if err := r.db.QueryRow(ctx, "SELECT id FROM subscription_tiers WHERE name = $1", tierName).Scan(&tierID); err != nil {
+		return fmt.Errorf("tier not found: %w", mapError(err))
+	}
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO user_subscriptions (user_id, tier_id, status, expires_at)
+		VALUES ($1, $2, 'active', $3)
+		ON CONFLICT (user_id) DO UPDATE SET
+			tier_id = $2,
+			status = 'active',
+			expires_at = $3`,
+		userID, tierID, expiresAt)
+	return err
+}
+
+func (r *pgUserRepository) ListAPIKeys(ctx context.Context, userID int64) ([]model.APIKey, error) {
+	rows, err := r.db.Query(ctx,
+		"SELECT id, user_id, key_id, name, is_active, created_at FROM api_keys WHERE user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]model.APIKey, 0)
+	for rows.Next() {
+		var k model.APIKey
+		if err := rows.Scan(&k.ID, &k.UserID, &k.KeyID, &k.Name, &k.IsActive, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (r *pgUserRepository) CreateAPIKey(ctx context.Context, key model.APIKey) error {
+	_, err := r.db.Exec(ctx,
+		"INSERT INTO api_keys (user_id, key_id, key_secret, name) VALUES ($1, $2, $3, $4)",
+		key.UserID, key.KeyID, key.KeySecret, key.Name)
+	return mapError(err)
+}
+
+type pgStrategyRepository struct {
+	db *pgxpool.Pool
+}
+
+func (r *pgStrategyRepository) ListMarketItems(ctx context.Context) ([]model.MarketItem, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT m.id, m.price, COALESCE(m.description, ''), m.performance_metrics, s.name, u.email as author
+		 FROM strategy_market m
+		 JOIN strategies s ON m.strategy_id = s.id
+		 JOIN users u ON m.owner_id = u.id
+		 WHERE m.is_public = TRUE`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]model.MarketItem, 0)
+	for rows.Next() {
+		var item model.MarketItem
+		var metrics []byte
+		if err := rows.Scan(&item.ID, &item.Price, &item.Description, &metrics, &item.Name, &item.Author); err != nil {
+			return nil, err
+		}
+		item.Metrics = metrics
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *pgStrategyRepository) PurchaseMarketItem(ctx context.Context, userID, itemID int64) error {
+	_, err := r.db.Exec(ctx,
+		"INSERT INTO strategy_purchases (user_id, market_item_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		userID, itemID)
+	return err
+}
+
+const strategyColumns = "id, COALESCE(user_id, 0), name, description, type, config, latest_version, created_at, COALESCE(updated_at, created_at)"
+
+func scanStrategy(row pgx.Row) (model.Strategy, error) {
+	var s model.Strategy
+	err := row.Scan(&s.ID, &s.UserID, &s.Name, &s.Description, &s.Type, &s.Config, &s.LatestVersion, &s.CreatedAt, &s.UpdatedAt)
+	return s, mapError(err)
+}
+
+// strategyVersionQuery selects versions with the size of their module
+const strategyVersionQuery = `SELECT v.id, v.strategy_id, v.version, v.type, v.config, COALESCE(v.wasm_hash, ''),
+	COALESCE(octet_length(b.data), 0), v.note, v.created_at
+	FROM strategy_versions v
+	JOIN strategies s ON s.id = v.strategy_id
+	LEFT JOIN strategy_blobs b ON b.hash = v.wasm_hash`
+
+func scanStrategyVersion(row pgx.Row) (model.StrategyVersion, error) {
+	var v model.StrategyVersion
+	err := row.Scan(&v.ID, &v.StrategyID, &v.Version, &v.Type, &v.Config, &v.WasmHash, &v.WasmSize, &v.Note, &v.CreatedAt)
+	return v, mapError(err)
+}
+
+func (r *pgStrategyRepository) CreateStrategy(ctx context.Context, strat model.Strategy, version model.StrategyVersion, wasm []byte) (model.Strategy, model.StrategyVersion, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return strat, version, err
+	}
+	defer tx.Rollback(ctx)
+
+	created, err := scanStrategy(tx.QueryRow(ctx,
+		`INSERT INTO strategies (user_id, name, description, type, config) VALUES ($1, $2, $3, $4, $5)
+		 RETURNING `+strategyColumns,
+		strat.UserID, strat.Name, strat.Description, version.Type, version.Config))
+	if err != nil {
+		return strat, version, err
+	}
+	version.StrategyID = created.ID
+	if version, err = insertStrategyVersion(ctx, tx, strat.UserID, version, wasm); err != nil {
+		return strat, version, err
+	}
+	created.LatestVersion = version.Version
+	return created, version, tx.Commit(ctx)
+}
+
+// insertStrategyVersion stores the module and the next version of a strategy
+// of userID. Bumping latest_version first locks the strategy row, so
+// concurrent versions are numbered one after the other.
+func insertStrategyVersion(ctx context.Context, tx pgx.Tx, userID int64, version model.StrategyVersion, wasm []byte) (model.StrategyVersion, error) {
+	err := tx.QueryRow(ctx,
+		`UPDATE strategies SET latest_version = latest_version + 1, type = $3, config = $4, updated_at = NOW()
+		 WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL RETURNING latest_version`,
+		version.StrategyID, userID, version.Type, version.Config).Scan(&version.Version)
+	if err != nil {
+		return version, mapError(err)
+	}
+	if version.WasmHash != "" {
+		_, err := tx.Exec(ctx,
+			"INSERT INTO strategy_blobs (hash, data) VALUES ($1, $2) ON CONFLICT (hash) DO NOTHING",
+			version.WasmHash, wasm)
+		if err != nil {
+			return version, err
+		}
+		version.WasmSize = len(wasm)
+	}
+	err = tx.QueryRow(ctx,
+		`INSERT INTO strategy_versions (strategy_id, version, type, config, wasm_hash, note)
+		 VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6) RETURNING id, created_at`,
+		version.StrategyID, version.Version, version.Type, version.Config, version.WasmHash, version.Note).Scan(&version.ID, &version.CreatedAt)
+	return version, mapError(err)
+}
+
+func (r *pgStrategyRepository) ListStrategies(ctx context.Context, userID int64) ([]model.Strategy, error) {
+	rows, err := r.db.Query(ctx,
+		"SELECT "+strategyColumns+" FROM strategies WHERE user_id = $1 AND deleted_at IS NULL ORDER BY id DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]model.Strategy, 0)
+	for rows.Next() {
+		s, err := scanStrategy(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+func (r *pgStrategyRepository) GetStrategy(ctx context.Context, userID, strategyID int64) (model.Strategy, error) {
+	return scanStrategy(r.db.QueryRow(ctx,
+		"SELECT "+strategyColumns+" FROM strategies WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL", strategyID, userID))
+}
+
+func (r *pgStrategyRepository) UpdateStrategy(ctx context.Context, strat model.Strategy) error {
+	result, err := r.db.Exec(ctx,
+		`UPDATE strategies SET name = $3, description = $4, updated_at = NOW()
+		 WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`,
+		strat.ID, strat.UserID, strat.Name, strat.Description)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgStrategyRepository) DeleteStrategy(ctx context.Context, userID, strategyID int64) error {
+	result, err := r.db.Exec(ctx,
+		"UPDATE strategies SET deleted_at = NOW() WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL",
+		strategyID, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgStrategyRepository) CreateStrategyVersion(ctx context.Context, userID int64, version model.StrategyVersion, wasm []byte) (model.StrategyVersion, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return version, err
+	}
+	defer tx.Rollback(ctx)
+	if version, err = insertStrategyVersion(ctx, tx, userID, version, wasm); err != nil {
+		return version, err
+	}
+	return version, tx.Commit(ctx)
+}
+
+func (r *pgStrategyRepository) ListStrategyVersions(ctx context.Context, userID, strategyID int64) ([]model.StrategyVersion, error) {
+	if _, err := r.GetStrategy(ctx, userID, strategyID); err != nil {
+		return nil, err
+	}
+	rows, err := r.db.Query(ctx, strategyVersionQuery+" WHERE v.strategy_id = $1 ORDER BY v.version DESC", strategyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]model.StrategyVersion, 0)
+	for rows.Next() {
+		v, err := scanStrategyVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, rows.Err()
+}
+
+func (r *pgStrategyRepository) GetStrategyVersion(ctx context.Context, userID, versionID int64) (model.StrategyVersion, error) {
+	return scanStrategyVersion(r.db.QueryRow(ctx,
+		strategyVersionQuery+" WHERE v.id = $1 AND s.user_id = $2 AND s.deleted_at IS NULL", versionID, userID))
+}
+
+func (r *pgStrategyRepository) GetStrategyBlob(ctx context.Context, hash string) ([]byte, error) {
+	var data []byte
+	err := r.db.QueryRow(ctx, "SELECT data FROM strategy_blobs WHERE hash = $1", hash).Scan(&data)
+	return data, mapError(err)
+}
+
+type pgBacktestRepository struct {
+	db *pgxpool.Pool
+}
+
+const backtestRunColumns = `id, COALESCE(user_id, 0), status, strategy_type, config, COALESCE(strategy_version_id, 0), symbols, exchange, period,
+	start_time, end_time, spec, COALESCE(error, ''), created_at, started_at, finished_at`
+
+// scanBacktestRun scans backtestRunColumns, followed by the report if withReport
+func scanBacktestRun(row pgx.Row, withReport bool) (model.BacktestRun, error) {
+	var run model.BacktestRun
+	var report []byte
+	dest := []any{&run.ID, &run.UserID, &run.Status, &run.StrategyType, &run.Config, &run.StrategyVersionID, &run.Symbols, &run.Exchange, &run.Period,
+		&run.StartTime, &run.EndTime, &run.Spec, &run.Error, &run.CreatedAt, &run.StartedAt, &run.FinishedAt}
+	if withReport {
+		dest = append(dest, &report)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return run, mapError(err)
+	}
+	if len(report) > 0 {
+		run.Report = new(model.BacktestReport)
+		if err := json.Unmarshal(report, run.Report); err != nil {
+			return run, fmt.Errorf("failed to decode backtest report %d: %w", run.ID, err)
+		}
+	}
+	return run, nil
+}
+
+func (r *pgBacktestRepository) CreateBacktestRun(ctx context.Context, run model.BacktestRun) (int64, error) {
+	if run.Status == "" {
+		run.Status = model.BacktestQueued
+	}
+	var symbol string
+	if len(run.Symbols) > 0 {
+		symbol = run.Symbols[0]
+	}
+	var id int64
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO backtest_runs (user_id, status, strategy_type, config, strategy_version_id, symbol, symbols, exchange, period, start_time, end_time, spec)
+		 VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6, $7, $8, $9, $10, $11, $12) RETURNING id`,
+		run.UserID, run.Status, run.StrategyType, run.Config, run.StrategyVersionID, symbol, run.Symbols, run.Exchange, run.Period,
+		run.StartTime, run.EndTime, run.Spec).Scan(&id)
+	return id, mapError(err)
+}
+
+func (r *pgBacktestRepository) GetBacktestRun(ctx context.Context, userID, runID int64) (model.BacktestRun, error) {
+	return scanBacktestRun(r.db.QueryRow(ctx,
+		"SELECT "+backtestRunColumns+", report FROM backtest_runs WHERE id = $1 AND user_id = $2", runID, userID), true)
+}
+
+func (r *pgBacktestRepository) ListBacktestRuns(ctx context.Context, userID int64, limit int) ([]model.BacktestRun, error) {
+	sql := "SELECT " + backtestRunColumns + " FROM backtest_runs WHERE user_id = $1 ORDER BY created_at DESC, id DESC"
+	args := []any{userID}
+	if limit > 0 {
+		sql += " LIMIT $2"
+		args = append(args, limit)
+	}
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make([]model.BacktestRun, 0)
+	for rows.Next() {
+		run, err := scanBacktestRun(rows, false)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+func (r *pgBacktestRepository) ClaimBacktestRun(ctx context.Context, runID int64) (model.BacktestRun, error) {
+	run, err := scanBacktestRun(r.db.QueryRow(ctx,
+		`UPDATE backtest_runs SET status = 'running', started_at = NOW()
+		 WHERE id = $1 AND status IN ('queued', 'running')
+		 RETURNING `+backtestRunColumns, runID), false)
+	if errors.Is(err, ErrNotFound) {
+		return run, r.missingOrConflict(ctx, runID)
+	}
+	return run, err
+}
+
+func (r *pgBacktestRepository) FinishBacktestRun(ctx context.Context, runID int64, status model.BacktestStatus, report *model.BacktestReport, errMsg string) error {
+	var data []byte
+	if report != nil {
+		var err error
+		if data, err = json.Marshal(report); err != nil {
+			return fmt.Errorf("failed to encode backtest report: %w", err)
+		}
+	}
+	result, err := r.db.Exec(ctx,
+		`UPDATE backtest_runs SET status = $2, report = $3, error = NULLIF($4, ''), finished_at = NOW()
+		 WHERE id = $1 AND status = 'running'`,
+		runID, status, data, errMsg)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return r.missingOrConflict(ctx, runID)
+	}
+	return nil
+}
+
+func (r *pgBacktestRepository) CancelBacktestRun(ctx context.Context, userID, runID int64) error {
+	result, err := r.db.Exec(ctx,
+		`UPDATE backtest_runs SET status = 'cancelled', finished_at = NOW()
+		 WHERE id = $1 AND user_id = $2 AND status IN ('queued', 'running')`,
+		runID, userID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		var exists bool
+		if err := r.db.QueryRow(ctx,
+			"SELECT EXISTS (SELECT 1 FROM backtest_runs WHERE id = $1 AND user_id = $2)", runID, userID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotFound
+		}
+		return ErrConflict
+	}
+	return nil
+}
+
+// missingOrConflict tells why a conditional update of a run matched nothing
+func (r *pgBacktestRepository) missingOrConflict(ctx context.Context, runID int64) error {
+	var exists bool
+	if err := r.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM backtest_runs WHERE id = $1)", runID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrConflict
+}
+
+type pgDeploymentRepository struct {
+	db *pgxpool.Pool
+}
+
+const deploymentColumns = "id, user_id, strategy_version_id, symbol, period, capital, paper, sizing, status, position_qty, created_at, updated_at"
+
+func scanDeployment(row pgx.Row) (model.Deployment, error) {
+	var d model.Deployment
+	err := row.Scan(&d.ID, &d.UserID, &d.StrategyVersionID, &d.Symbol, &d.Period, &d.Capital, &d.Paper, &d.Sizing, &d.Status, &d.PositionQty, &d.CreatedAt, &d.UpdatedAt)
+	return d, mapError(err)
+}
+
+func (r *pgDeploymentRepository) list(ctx context.Context, sql string, args ...any) ([]model.Deployment, error) {
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]model.Deployment, 0)
+	for rows.Next() {
+		d, err := scanDeployment(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+func (r *pgDeploymentRepository) CreateDeployment(ctx context.Context, d model.Deployment) (model.Deployment, error) {
+	if d.Status == "" {
+		d.Status = model.DeploymentRunning
+	}
+	return scanDeployment(r.db.QueryRow(ctx,
+		`INSERT INTO strategy_deployments (user_id, strategy_version_id, symbol, period, capital, paper, sizing, status, position_qty)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING `+deploymentColumns,
+		d.UserID, d.StrategyVersionID, d.Symbol, d.Period, d.Capital, d.Paper, d.Sizing, d.Status, d.PositionQty))
+}
+
+func (r *pgDeploymentRepository) GetDeployment(ctx context.Context, userID, deploymentID int64) (model.Deployment, error) {
+	return scanDeployment(r.db.QueryRow(ctx,
+		"SELECT "+deploymentColumns+" FROM strategy_deployments WHERE id = $1 AND user_id = $2", deploymentID, userID))
+}
+
+func (r *pgDeploymentRepository) ListDeployments(ctx context.Context, userID int64) ([]model.Deployment, error) {
+	return r.list(ctx, "SELECT "+deploymentColumns+" FROM strategy_deployments WHERE user_id = $1 ORDER BY created_at DESC, id DESC", userID)
+}
+
+func (r *pgDeploymentRepository) ListActiveDeployments(ctx context.Context) ([]model.Deployment, error) {
+	return r.list(ctx, "SELECT "+deploymentColumns+" FROM strategy_deployments WHERE status <> 'stopped' ORDER BY id")
+}
+
+func (r *pgDeploymentRepository) SetDeploymentStatus(ctx context.Context, userID, deploymentID int64, status model.DeploymentStatus) (model.Deployment, error) {
+	d, err := scanDeployment(r.db.QueryRow(ctx,
+		`UPDATE strategy_deployments SET status = $3, updated_at = NOW()
+		 WHERE id = $1 AND user_id = $2 AND status <> 'stopped'
+		 RETURNING `+deploymentColumns, deploymentID, userID, status))
+	if errors.Is(err, ErrNotFound) {
+		if _, err := r.GetDeployment(ctx, userID, deploymentID); err != nil {
+			return d, err
+		}
+		return d, ErrConflict
+	}
+	return d, err
+}
+
+func (r *pgDeploymentRepository) SetDeploymentPosition(ctx context.Context, deploymentID int64, qty decimal.Decimal) error {
+	result, err := r.db.Exec(ctx,
+		"UPDATE strategy_deployments SET position_qty = $2, updated_at = NOW() WHERE id = $1", deploymentID, qty)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type pgExecutionRepository struct {
+	db *pgxpool.Pool
+}
+
+func (r *pgExecutionRepository) RecordExecution(ctx context.Context, e model.SignalExecution) (model.SignalExecution, error) {
+	var orderID *int64
+	if e.OrderID != 0 {
+		orderID = &e.OrderID
+	}
+	e.OrderStatus, e.FilledPrice = "", decimal.Zero
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO signal_executions (deployment_id, user_id, strategy, symbol, action, signal_price, signal_time, sizing, qty, status, reason, order_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id, created_at`,
+		e.DeploymentID, e.UserID, e.Strategy, e.Symbol, e.Action, e.SignalPrice, e.SignalTime, e.Sizing, e.Qty, e.Status, e.Reason, orderID).Scan(&e.ID, &e.CreatedAt)
+	return e, mapError(err)
+}
+
+func (r *pgExecutionRepository) ListExecutions(ctx context.Context, userID, deploymentID int64, limit int) ([]model.SignalExecution, error) {
+	sql := `SELECT e.id, e.deployment_id, e.user_id, e.strategy, e.symbol, e.action, e.signal_price, e.signal_time, e.sizing, e.qty,
+	               e.status, e.reason, COALESCE(e.order_id, 0), COALESCE(o.status, ''), COALESCE(o.filled_price, 0), e.created_at
+	        FROM signal_executions e LEFT JOIN paper_orders o ON o.id = e.order_id
+	        WHERE e.user_id = $1 AND e.deployment_id = $2 ORDER BY e.id DESC`
+	args := []any{userID, deploymentID}
+	if limit > 0 {
+		sql += " LIMIT $3"
+		args = append(args, limit)
+	}
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]model.SignalExecution, 0)
+	for rows.Next() {
+		var e model.SignalExecution
+		if err := rows.Scan(&e.ID, &e.DeploymentID, &e.UserID, &e.Strategy, &e.Symbol, &e.Action, &e.SignalPrice, &e.SignalTime, &e.Sizing, &e.Qty,
+			&e.Status, &e.Reason, &e.OrderID, &e.OrderStatus, &e.FilledPrice, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+func (r *pgExecutionRepository) DeploymentFills(ctx context.Context, deploymentID int64) (decimal.Decimal, int, error) {
+	var held decimal.Decimal
+	var open int
+	err := r.db.QueryRow(ctx,
+		`SELECT COALESCE(SUM(CASE WHEN o.side = 'buy' THEN o.qty ELSE -o.qty END) FILTER (WHERE o.status = 'filled'), 0),
+		        COUNT(*) FILTER (WHERE o.status = 'open')
+		 FROM signal_executions e JOIN paper_orders o ON o.id = e.order_id
+		 WHERE e.deployment_id = $1`, deploymentID).Scan(&held, &open)
+	return held, open, err
+}
+
+type pgStateRepository struct {
+	db *pgxpool.Pool
+}
+
+func (r *pgStateRepository) SaveStrategyState(ctx context.Context, key string, state []byte) error {
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO strategy_states (key, state, updated_at) VALUES ($1, $2, NOW())
+		 ON CONFLICT (key) DO UPDATE SET state = EXCLUDED.state, updated_at = EXCLUDED.updated_at`,
+		key, state)
+	return err
+}
+
+func (r *pgStateRepository) GetStrategyState(ctx context.Context, key string) ([]byte, error) {
+	var state []byte
+	err := r.db.QueryRow(ctx, "SELECT state FROM strategy_states WHERE key = $1", key).Scan(&state)
+	return state, mapError(err)
+}
+
+// pgLeaseRepository holds its leases as session-level advisory locks on one
+// dedicated connection, so that they go with the session if the process dies
+type pgLeaseRepository struct {
+	db *pgxpool.Pool
+
+	mu   sync.Mutex
+	conn *pgxpool.Conn // while any lease is held
+	held map[string]bool
+}
+
+func (r *pgLeaseRepository) AcquireLease(ctx context.Context, name string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn != nil && r.conn.Ping(ctx) != nil {
+		// The locks are gone with the session, or soon will be
+		r.drop(ctx)
+	}
+	if r.held[name] {
+		return true, nil
+	}
+	if r.conn == nil {
+		conn, err := r.db.Acquire(ctx)
+		if err != nil {
+			return false, fmt.Errorf("failed to acquire connection: %w", err)
+		}
+		r.conn = conn
+	}
+	var ok bool
+	if err := r.conn.QueryRow(ctx, "SELECT pg_try_advisory_lock(hashtextextended($1, 0))", name).Scan(&ok); err != nil {
+		r.drop(ctx)
+		return false, err
+	}
+	if ok {
+		r.held[name] = true
+	} else if len(r.held) == 0 {
+		r.conn.Release()
+		r.conn = nil
+	}
+	return ok, nil
+}
+
+func (r *pgLeaseRepository) ReleaseLease(ctx context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.held[name] {
+		return nil
+	}
+	delete(r.held, name)
+	if len(r.held) == 0 {
+		// Closing the session releases the lock whether or not unlocking works
+		r.drop(ctx)
+		return nil
+	}
+	_, err := r.conn.Exec(ctx, "SELECT pg_advisory_unlock(hashtextextended($1, 0))", name)
+	return err
+}
+
+// drop closes the lease connection, and with it every lease held
+func (r *pgLeaseRepository) drop(ctx context.Context) {
+	r.conn.Conn().Close(ctx)
+	r.conn.Release()
+	r.conn = nil
+	clear(r.held)
+}
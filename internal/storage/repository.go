@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"quant-trader/internal/model"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict is returned when a unique constraint would be violated
+	ErrConflict = errors.New("record already exists")
+)
+
+// KlineQuery 描述一次历史 K 线查询
+type KlineQuery struct {
+	Symbol     string
+	Exchange   string // optional, empty matches all exchanges
+	Period     string
+	Start      time.Time // optional, zero means unbounded
+	End        time.Time // optional, zero means unbounded
+	Limit      int       // optional, 0 means no limit
+	Descending bool
+}
+
+// TickQuery 描述一次逐笔成交或深度快照查询，时间范围为 [Start, End)
+type TickQuery struct {
+	Symbol   string
+	Exchange string // optional, empty matches all exchanges
+	Start    time.Time
+	End      time.Time
+}
+
+// MarketDataRepository stores trades, order book snapshots and candles
+type MarketDataRepository interface {
+	InsertTrades(ctx context.Context, trades []model.Trade) error
+	// IterateTrades streams the trades matching q in time order
+	IterateTrades(ctx context.Context, q TickQuery) (TradeIterator, error)
+	InsertOrderBooks(ctx context.Context, books []model.OrderBook) error
+	// IterateOrderBooks streams the depth snapshots matching q in time order
+	IterateOrderBooks(ctx context.Context, q TickQuery) (OrderBookIterator, error)
+	UpsertKlines(ctx context.Context, klines []model.KLine) error
+	QueryKlines(ctx context.Context, q KlineQuery) ([]model.KLine, error)
+	// IterateKlines streams the candles matching q without loading them all into memory
+	IterateKlines(ctx context.Context, q KlineQuery) (KlineIterator, error)
+	// LatestClose returns the most recent close of symbol across periods, or ErrNotFound
+	LatestClose(ctx context.Context, symbol string) (decimal.Decimal, error)
+}
+
+// AlertRepository stores user alert rules
+type AlertRepository interface {
+	ListActiveAlerts(ctx context.Context) ([]model.Alert, error)
+	ListAlerts(ctx context.Context, userID int64) ([]model.Alert, error)
+	CreateAlert(ctx context.Context, a model.Alert) (int64, error)
+	// DeleteAlert returns ErrNotFound if the alert does not belong to userID
+	DeleteAlert(ctx context.Context, userID, alertID int64) error
+}
+
+// PaperRepository stores paper trading accounts, orders, positions and portfolios
+type PaperRepository interface {
+	// GetBalance returns ErrNotFound if the user has no paper account yet
+	GetBalance(ctx context.Context, userID int64) (decimal.Decimal, error)
+	// CreateAccount opens a paper account, leaving an existing one untouched
+	CreateAccount(ctx context.Context, userID int64, balance decimal.Decimal) error
+	ListOpenOrders(ctx context.Context) ([]model.PaperOrder, error)
+	CreateOrder(ctx context.Context, o model.PaperOrder) (int64, error)
+	// ApplyFills atomically marks orders filled and updates balances and positions
+	ApplyFills(ctx context.Context, fills []model.PaperOrder) error
+	// ListPositions returns the user's positions with a positive quantity
+	ListPositions(ctx context.Context, userID int64) ([]model.PaperPosition, error)
+	ListPortfolios(ctx context.Context, userID int64) ([]model.Portfolio, error)
+	CreatePortfolio(ctx context.Context, userID int64, name string) (int64, error)
+}
+
+// UserRepository stores users, their subscriptions and API keys
+type UserRepository interface {
+	// CreateUser returns ErrConflict if the email is taken
+	CreateUser(ctx context.Context, email, passwordHash string) (int64, error)
+	GetUserByEmail(ctx context.Context, email string) (model.User, error)
+	// GetSubscription returns ErrNotFound if the user never subscribed
+	GetSubscription(ctx context.Context, userID int64) (model.SubscriptionInfo, error)
+	SetSubscriptionTier(ctx context.Context, userID int64, tierName string, expiresAt time.Time) error
+	ListAPIKeys(ctx context.Context, userID int64) ([]model.APIKey, error)
+	CreateAPIKey(ctx context.Context, key model.APIKey) error
+}
+
+// StrategyRepository stores strategies and the marketplace listings
+type StrategyRepository interface {
+	ListMarketItems(ctx context.Context) ([]model.MarketItem, error)
+	PurchaseMarketItem(ctx context.Context, userID, itemID int64) error
+
+	// CreateStrategy stores a strategy with version as its version 1 and the
+	// WASM module, if any, under version.WasmHash
+	CreateStrategy(ctx context.Context, strat model.Strategy, version model.StrategyVersion, wasm []byte) (model.Strategy, model.StrategyVersion, error)
+	ListStrategies(ctx context.Context, userID int64) ([]model.Strategy, error)
+	// GetStrategy returns ErrNotFound unless the strategy belongs to userID
+	GetStrategy(ctx context.Context, userID, strategyID int64) (model.Strategy, error)
+	// UpdateStrategy renames or redescribes a strategy of strat.UserID; the
+	// type and config only change with a new version
+	UpdateStrategy(ctx context.Context, strat model.Strategy) error
+	// DeleteStrategy hides a strategy; its versions stay for the runs using them
+	DeleteStrategy(ctx context.Context, userID, strategyID int64) error
+	// CreateStrategyVersion appends the next version to a strategy of userID
+	CreateStrategyVersion(ctx context.Context, userID int64, version model.StrategyVersion, wasm []byte) (model.StrategyVersion, error)
+	// ListStrategyVersions returns the versions of a strategy of userID, newest first
+	ListStrategyVersions(ctx context.Context, userID, strategyID int64) ([]model.StrategyVersion, error)
+	// GetStrategyVersion returns ErrNotFound unless the version belongs to a
+	// strategy of userID that is not deleted
+	GetStrategyVersion(ctx context.Context, userID, versionID int64) (model.StrategyVersion, error)
+	// GetStrategyBlob returns the WASM module stored under hash, unverified
+	GetStrategyBlob(ctx context.Context, hash string) ([]byte, error)
+}
+
+// BacktestRepository stores backtest jobs and their reports
+type BacktestRepository interface {
+	CreateBacktestRun(ctx context.Context, run model.BacktestRun) (int64, error)
+	// GetBacktestRun returns ErrNotFound unless the run belongs to userID
+	GetBacktestRun(ctx context.Context, userID, runID int64) (model.BacktestRun, error)
+	// ListBacktestRuns returns the user's latest runs, newest first, without reports
+	ListBacktestRuns(ctx context.Context, userID int64, limit int) ([]model.BacktestRun, error)
+	// ClaimBacktestRun marks a queued run, or one left running by a worker that
+	// died, as running and returns it; ErrConflict once it is cancelled or finished
+	ClaimBacktestRun(ctx context.Context, runID int64) (model.BacktestRun, error)
+	// FinishBacktestRun records the outcome of a running run; ErrConflict if it
+	// is no longer running, e.g. because it was cancelled
+	FinishBacktestRun(ctx context.Context, runID int64, status model.BacktestStatus, report *model.BacktestReport, errMsg string) error
+	// CancelBacktestRun cancels a queued or running run; ErrNotFound unless it
+	// belongs to userID, ErrConflict if it already finished
+	CancelBacktestRun(ctx context.Context, userID, runID int64) error
+}
+
+// DeploymentRepository stores users' live strategy deployments
+type DeploymentRepository interface {
+	CreateDeployment(ctx context.Context, d model.Deployment) (model.Deployment, error)
+	// GetDeployment returns ErrNotFound unless the deployment belongs to userID
+	GetDeployment(ctx context.Context, userID, deploymentID int64) (model.Deployment, error)
+	// ListDeployments returns the user's deployments, newest first
+	ListDeployments(ctx context.Context, userID int64) ([]model.Deployment, error)
+	// ListActiveDeployments returns every deployment that is not stopped, oldest first
+	ListActiveDeployments(ctx context.Context) ([]model.Deployment, error)
+	// SetDeploymentStatus changes the status of a deployment of userID;
+	// ErrNotFound unless it belongs to userID, ErrConflict once it is stopped
+	SetDeploymentStatus(ctx context.Context, userID, deploymentID int64, status model.DeploymentStatus) (model.Deployment, error)
+	// SetDeploymentPosition records the quantity a deployment holds
+	SetDeploymentPosition(ctx context.Context, deploymentID int64, qty decimal.Decimal) error
+}
+
+// ExecutionRepository records what came of the signals of paper deployments
+type ExecutionRepository interface {
+	RecordExecution(ctx context.Context, e model.SignalExecution) (model.SignalExecution, error)
+	// ListExecutions returns the latest executions of a deployment of userID,
+	// newest first, with the status and fill of their orders
+	ListExecutions(ctx context.Context, userID, deploymentID int64, limit int) ([]model.SignalExecution, error)
+	// DeploymentFills returns what the filled orders of a deployment hold and
+	// how many of its orders are still open
+	DeploymentFills(ctx context.Context, deploymentID int64) (decimal.Decimal, int, error)
+}
+
+// StateRepository keeps the latest checkpoint of each live strategy, keyed by
+// the runner, e.g. "deployment:42"
+type StateRepository interface {
+	SaveStrategyState(ctx context.Context, key string, state []byte) error
+	// GetStrategyState returns ErrNotFound if nothing was saved under key
+	GetStrategyState(ctx context.Context, key string) ([]byte, error)
+}
+
+// LeaseRepository hands out named leases that one process holds at a time. A
+// lease is held until released or until its holder loses the database, e.g.
+// because it died.
+type LeaseRepository interface {
+	// AcquireLease takes the lease, or confirms this process still holds it;
+	// false while another process holds it
+	AcquireLease(ctx context.Context, name string) (bool, error)
+	ReleaseLease(ctx context.Context, name string) error
+}
+
+// Store 聚合所有仓储接口，由 Postgres 或内存实现提供
+type Store struct {
+	Market     MarketDataRepository
+	Alerts     AlertRepository
+	Paper      PaperRepository
+	Users      UserRepository
+	Strategies StrategyRepository
+	Backtests  BacktestRepository
+
+	Deployments DeploymentRepository
+	States      StateRepository
+	Executions  ExecutionRepository
+	Leases      LeaseRepository
+}
@@ -2,28 +2,31 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"quant-trader/internal/infrastructure"
 	"quant-trader/internal/model"
 	"sync"
 	"time"
 
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 )
 
 type BatchSaver struct {
-	pool      *pgxpool.Pool
+	repo      MarketDataRepository
+	spill     *SpillLog // optional, nil disables spilling
 	logger    *zap.Logger
 	buffer    []model.Trade
 	mu        sync.Mutex
 	flushIntv time.Duration
 	batchSize int
+	// replayAfter delays the next replay attempt after the DB was seen failing
+	replayAfter time.Time
 }
 
-func NewBatchSaver(pool *pgxpool.Pool, logger *zap.Logger, flushIntv time.Duration, batchSize int) *BatchSaver {
+func NewBatchSaver(repo MarketDataRepository, spill *SpillLog, logger *zap.Logger, flushIntv time.Duration, batchSize int) *BatchSaver {
 	saver := &BatchSaver{
-		pool:      pool,
+		repo:      repo,
+		spill:     spill,
 		logger:    logger,
 		buffer:    make([]model.Trade, 0, batchSize),
 		flushIntv: flushIntv,
@@ -36,9 +39,10 @@ func NewBatchSaver(pool *pgxpool.Pool, logger *zap.Logger, flushIntv time.Durati
 func (s *BatchSaver) Add(trade model.Trade) {
 	s.mu.Lock()
 	s.buffer = append(s.buffer, trade)
+	full := len(s.buffer) >= s.batchSize
 	s.mu.Unlock()
 
-	if len(s.buffer) >= s.batchSize {
+	if full {
 		s.Flush()
 	}
 }
@@ -49,6 +53,7 @@ func (s *BatchSaver) run() {
 
 	for range ticker.C {
 		s.Flush()
+		s.replay()
 	}
 }
 
@@ -62,25 +67,88 @@ func (s *BatchSaver) Flush() {
 	s.buffer = make([]model.Trade, 0, s.batchSize)
 	s.mu.Unlock()
 
+	if err := s.insert(trades); err != nil {
+		s.logger.Error("failed to execute batch insert", zap.Error(err))
+		s.spillTrades(trades, err)
+		return
+	}
+	s.deferReplay(0)
+	infrastructure.DBInsertRate.WithLabelValues("trades").Add(float64(len(trades)))
+}
+
+func (s *BatchSaver) insert(trades []model.Trade) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	batch := &pgx.Batch{}
+	return s.repo.InsertTrades(ctx, trades)
+}
+
+func (s *BatchSaver) spillTrades(trades []model.Trade, cause error) {
+	if s.spill == nil || !shouldSpill(cause) {
+		return
+	}
+	s.deferReplay(spillRetryInterval)
+
+	records := make([][]byte, 0, len(trades))
 	for _, t := range trades {
-		batch.Queue(`INSERT INTO trades (time, symbol, exchange, price, amount, side, trade_id) 
-                     VALUES ($1, $2, $3, $4, $5, $6, $7)
-                     ON CONFLICT (symbol, exchange, trade_id, time) DO NOTHING`,
-			t.Timestamp, t.Symbol, t.Exchange, t.Price, t.Amount, t.Side, t.ID)
+		data, err := json.Marshal(t)
+		if err != nil {
+			continue
+		}
+		records = append(records, data)
 	}
+	if err := s.spill.Append(records); err != nil {
+		s.logger.Error("failed to spill trades, dropping batch", zap.Int("count", len(trades)), zap.Error(err))
+		return
+	}
+	s.logger.Warn("database unavailable, spilled trades to disk", zap.Int("count", len(records)))
+}
 
-	br := s.pool.SendBatch(ctx, batch)
-	defer br.Close()
+// replay pushes spilled trades back into the database once inserts succeed again
+func (s *BatchSaver) replay() {
+	if s.spill == nil || !s.replayDue() || !s.spill.Pending() {
+		return
+	}
 
-	for i := 0; i < len(trades); i++ {
-		_, err := br.Exec()
-		if err != nil {
-			s.logger.Error("failed to execute batch insert", zap.Error(err))
+	n, err := s.spill.Replay(s.batchSize, func(records [][]byte) error {
+		trades := make([]model.Trade, 0, len(records))
+		for _, r := range records {
+			var t model.Trade
+			if err := json.Unmarshal(r, &t); err != nil {
+				s.logger.Warn("skipping corrupt spilled trade", zap.Error(err))
+				continue
+			}
+			trades = append(trades, t)
+		}
+		if err := s.insert(trades); err != nil {
+			if shouldSpill(err) {
+				return err
+			}
+			// The server rejected the rows; retrying will not help.
+			s.logger.Error("dropping spilled trades rejected by database", zap.Int("count", len(trades)), zap.Error(err))
+			return nil
 		}
+		infrastructure.DBInsertRate.WithLabelValues("trades").Add(float64(len(trades)))
+		return nil
+	})
+	if err != nil {
+		s.deferReplay(spillRetryInterval)
+		s.logger.Warn("failed to replay spilled trades", zap.Int("replayed", n), zap.Error(err))
+		return
 	}
-	infrastructure.DBInsertRate.WithLabelValues("trades").Add(float64(len(trades)))
+	if n > 0 {
+		s.logger.Info("replayed spilled trades", zap.Int("count", n))
+	}
+}
+
+func (s *BatchSaver) deferReplay(d time.Duration) {
+	s.mu.Lock()
+	s.replayAfter = time.Now().Add(d)
+	s.mu.Unlock()
+}
+
+func (s *BatchSaver) replayDue() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !time.Now().Before(s.replayAfter)
 }
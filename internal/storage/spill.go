@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"quant-trader/internal/infrastructure"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+const (
+	spillSegmentExt  = ".seg"
+	spillSegmentSize = 16 << 20 // rotate segments at 16MB
+
+	// spillRetryInterval is how long savers wait before retrying a replay after a DB failure
+	spillRetryInterval = 10 * time.Second
+)
+
+// ErrSpillFull is returned when appending would exceed the spill disk budget
+var ErrSpillFull = errors.New("spill disk budget exceeded")
+
+// SpillLog 是一个本地追加写日志，在数据库不可用时暂存未落库的记录，恢复后按顺序回放。
+// Records are stored one JSON document per line in numbered segment files.
+type SpillLog struct {
+	dir      string
+	table    string
+	maxBytes int64
+	logger   *zap.Logger
+
+	mu         sync.Mutex
+	active     *os.File
+	activeSeq  uint64
+	activeSize int64
+	nextSeq    uint64
+	totalBytes int64
+}
+
+// NewSpillLog opens (or creates) the spill directory and picks up any segments
+// left over from a previous run so they are replayed once the database is back.
+func NewSpillLog(dir, table string, maxBytes int64, logger *zap.Logger) (*SpillLog, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spill dir: %w", err)
+	}
+
+	l := &SpillLog{
+		dir:      dir,
+		table:    table,
+		maxBytes: maxBytes,
+		logger:   logger,
+		nextSeq:  1,
+	}
+
+	segments, err := l.segments()
+	if err != nil {
+		return nil, err
+	}
+	for _, seq := range segments {
+		info, err := os.Stat(l.segmentPath(seq))
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat spill segment: %w", err)
+		}
+		l.totalBytes += info.Size()
+		l.nextSeq = seq + 1
+	}
+	infrastructure.SpillBytes.WithLabelValues(table).Set(float64(l.totalBytes))
+
+	if len(segments) > 0 {
+		logger.Warn("found unreplayed spill segments",
+			zap.String("table", table),
+			zap.Int("segments", len(segments)),
+			zap.Int64("bytes", l.totalBytes))
+	}
+	return l, nil
+}
+
+// Append writes records to the active segment and syncs it to disk.
+// The whole batch is rejected with ErrSpillFull if it does not fit in the budget.
+func (l *SpillLog) Append(records [][]byte) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var size int64
+	for _, r := range records {
+		size += int64(len(r)) + 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxBytes > 0 && l.totalBytes+size > l.maxBytes {
+		infrastructure.SpillDropped.WithLabelValues(l.table).Add(float64(len(records)))
+		return ErrSpillFull
+	}
+
+	if l.active == nil {
+		f, err := os.OpenFile(l.segmentPath(l.nextSeq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open spill segment: %w", err)
+		}
+		l.active = f
+		l.activeSeq = l.nextSeq
+		l.activeSize = 0
+		l.nextSeq++
+	}
+
+	w := bufio.NewWriter(l.active)
+	for _, r := range records {
+		w.Write(r)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write spill segment: %w", err)
+	}
+	if err := l.active.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spill segment: %w", err)
+	}
+
+	l.activeSize += size
+	l.totalBytes += size
+	infrastructure.SpilledRecords.WithLabelValues(l.table).Add(float64(len(records)))
+	infrastructure.SpillBytes.WithLabelValues(l.table).Set(float64(l.totalBytes))
+
+	if l.activeSize >= spillSegmentSize {
+		l.sealLocked()
+	}
+	return nil
+}
+
+// Pending reports whether there are spilled records waiting to be replayed
+func (l *SpillLog) Pending() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.totalBytes > 0
+}
+
+// Replay feeds spilled records to fn in chunks of at most batchSize, oldest first.
+// A segment is deleted only after all of its records were accepted, so a failed
+// replay is retried from the start of that segment; fn must therefore be idempotent.
+func (l *SpillLog) Replay(batchSize int, fn func(records [][]byte) error) (int, error) {
+	// Seal the active segment so new spills go to a fresh file while we read.
+	l.mu.Lock()
+	l.sealLocked()
+	l.mu.Unlock()
+
+	segments, err := l.segments()
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for _, seq := range segments {
+		n, err := l.replaySegment(seq, batchSize, fn)
+		replayed += n
+		if err != nil {
+			return replayed, err
+		}
+	}
+	return replayed, nil
+}
+
+// Close releases the active segment file
+func (l *SpillLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active == nil {
+		return nil
+	}
+	err := l.active.Close()
+	l.active = nil
+	return err
+}
+
+func (l *SpillLog) replaySegment(seq uint64, batchSize int, fn func(records [][]byte) error) (int, error) {
+	path := l.segmentPath(seq)
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open spill segment: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return 0, fmt.Errorf("failed to stat spill segment: %w", err)
+	}
+
+	replayed := 0
+	chunk := make([][]byte, 0, batchSize)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		chunk = append(chunk, append([]byte(nil), line...))
+		if len(chunk) >= batchSize {
+			if err := fn(chunk); err != nil {
+				f.Close()
+				return replayed, err
+			}
+			replayed += len(chunk)
+			chunk = chunk[:0]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return replayed, fmt.Errorf("failed to read spill segment: %w", err)
+	}
+	if len(chunk) > 0 {
+		if err := fn(chunk); err != nil {
+			f.Close()
+			return replayed, err
+		}
+		replayed += len(chunk)
+	}
+	f.Close()
+
+	if err := os.Remove(path); err != nil {
+		return replayed, fmt.Errorf("failed to remove spill segment: %w", err)
+	}
+
+	l.mu.Lock()
+	l.totalBytes -= info.Size()
+	if l.totalBytes < 0 {
+		l.totalBytes = 0
+	}
+	infrastructure.SpillBytes.WithLabelValues(l.table).Set(float64(l.totalBytes))
+	l.mu.Unlock()
+
+	infrastructure.ReplayedRecords.WithLabelValues(l.table).Add(float64(replayed))
+	return replayed, nil
+}
+
+func (l *SpillLog) sealLocked() {
+	if l.active == nil {
+		return
+	}
+	if err := l.active.Close(); err != nil {
+		l.logger.Warn("failed to close spill segment", zap.String("table", l.table), zap.Error(err))
+	}
+	l.active = nil
+}
+
+// segments returns the sequence numbers of sealed segments in replay order
+func (l *SpillLog) segments() ([]uint64, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list spill dir: %w", err)
+	}
+
+	l.mu.Lock()
+	active, activeSeq := l.active != nil, l.activeSeq
+	l.mu.Unlock()
+
+	var seqs []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, spillSegmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spillSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		if active && seq == activeSeq {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+func (l *SpillLog) segmentPath(seq uint64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%020d%s", seq, spillSegmentExt))
+}
+
+// shouldSpill reports whether a failed insert is worth retrying later.
+// Errors reported by the server (constraint, type errors) will fail again on
+// replay, so only connection-level failures are spilled.
+func shouldSpill(err error) bool {
+	var pgErr *pgconn.PgError
+	return !errors.As(err, &pgErr)
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSpillLog_AppendAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewSpillLog(dir, "test_append", 0, zap.NewNop())
+	require.NoError(t, err)
+
+	require.NoError(t, l.Append([][]byte{[]byte(`{"id":"1"}`), []byte(`{"id":"2"}`)}))
+	require.NoError(t, l.Append([][]byte{[]byte(`{"id":"3"}`)}))
+	assert.True(t, l.Pending())
+
+	// First replay fails: nothing is removed
+	boom := errors.New("db down")
+	_, err = l.Replay(10, func(records [][]byte) error { return boom })
+	assert.ErrorIs(t, err, boom)
+	assert.True(t, l.Pending())
+
+	var got []string
+	n, err := l.Replay(2, func(records [][]byte) error {
+		for _, r := range records {
+			got = append(got, string(r))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []string{`{"id":"1"}`, `{"id":"2"}`, `{"id":"3"}`}, got)
+	assert.False(t, l.Pending())
+	require.NoError(t, l.Close())
+}
+
+func TestSpillLog_Budget(t *testing.T) {
+	l, err := NewSpillLog(t.TempDir(), "test_budget", 16, zap.NewNop())
+	require.NoError(t, err)
+
+	require.NoError(t, l.Append([][]byte{[]byte("0123456789")}))
+	assert.ErrorIs(t, l.Append([][]byte{[]byte("0123456789")}), ErrSpillFull)
+}
+
+func TestSpillLog_RecoversSegmentsOnRestart(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewSpillLog(dir, "test_restart", 0, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, l.Append([][]byte{[]byte("a")}))
+	require.NoError(t, l.Close())
+
+	l2, err := NewSpillLog(dir, "test_restart", 0, zap.NewNop())
+	require.NoError(t, err)
+	assert.True(t, l2.Pending())
+
+	// New appends must not reuse the old segment
+	require.NoError(t, l2.Append([][]byte{[]byte("b")}))
+
+	var got []string
+	_, err = l2.Replay(10, func(records [][]byte) error {
+		for _, r := range records {
+			got = append(got, string(r))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, got)
+}
+
+func TestShouldSpill(t *testing.T) {
+	assert.True(t, shouldSpill(errors.New("connection refused")))
+	assert.False(t, shouldSpill(&pgconn.PgError{Code: "23505"}))
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"quant-trader/internal/model"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// KlineSource 决定高周期 K 线的读取来源
+type KlineSource string
+
+const (
+	// KlineSourceTable reads every period from rows written to klines by KlineSaver
+	KlineSourceTable KlineSource = "table"
+	// KlineSourceAggregate reads periods above 1m from the klines_<period> continuous aggregates
+	KlineSourceAggregate KlineSource = "aggregate"
+)
+
+// ParseKlineSource maps a config value to a KlineSource, defaulting to the raw table
+func ParseKlineSource(s string) (KlineSource, error) {
+	switch KlineSource(s) {
+	case "", KlineSourceTable:
+		return KlineSourceTable, nil
+	case KlineSourceAggregate:
+		return KlineSourceAggregate, nil
+	default:
+		return "", fmt.Errorf("unknown kline source: %s", s)
+	}
+}
+
+// Relation returns the table or view holding candles of the given period.
+// The result is always one of a fixed set of names and is safe to interpolate into SQL.
+func (s KlineSource) Relation(period string) string {
+	if s != KlineSourceAggregate || period == "1m" {
+		return "klines"
+	}
+	for _, p := range model.SupportedPeriods {
+		if p == period {
+			return "klines_" + p
+		}
+	}
+	return "klines"
+}
+
+// Persists reports whether KlineSaver should store candles of the given period
+func (s KlineSource) Persists(period string) bool {
+	return s != KlineSourceAggregate || period == "1m"
+}
+
+// RetentionPolicy drops chunks of a hypertable or continuous aggregate older than DropAfter.
+// DropAfter is a Postgres interval such as "30 days"; empty removes the policy.
+type RetentionPolicy struct {
+	Relation  string
+	DropAfter string
+}
+
+// KlineAggregateViews lists the continuous aggregates created by migration 008
+func KlineAggregateViews() []string {
+	views := make([]string, 0, len(model.SupportedPeriods)-1)
+	for _, p := range model.SupportedPeriods {
+		if p != "1m" {
+			views = append(views, "klines_"+p)
+		}
+	}
+	return views
+}
+
+// ApplyRetentionPolicies replaces the TimescaleDB retention policy of each relation,
+// so changing the configured interval takes effect on the next start.
+func ApplyRetentionPolicies(ctx context.Context, db *pgxpool.Pool, logger *zap.Logger, policies []RetentionPolicy) error {
+	for _, p := range policies {
+		if _, err := db.Exec(ctx, "SELECT remove_retention_policy($1::regclass, if_exists => TRUE)", p.Relation); err != nil {
+			return fmt.Errorf("failed to remove retention policy on %s: %w", p.Relation, err)
+		}
+		if p.DropAfter == "" {
+			continue
+		}
+		if _, err := db.Exec(ctx, "SELECT add_retention_policy($1::regclass, drop_after => $2::interval)", p.Relation, p.DropAfter); err != nil {
+			return fmt.Errorf("failed to add retention policy on %s: %w", p.Relation, err)
+		}
+		logger.Info("retention policy applied", zap.String("relation", p.Relation), zap.String("drop_after", p.DropAfter))
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKlineSource_Relation(t *testing.T) {
+	assert.Equal(t, "klines", KlineSourceTable.Relation("1h"))
+	assert.Equal(t, "klines", KlineSourceAggregate.Relation("1m"))
+	assert.Equal(t, "klines_1h", KlineSourceAggregate.Relation("1h"))
+	assert.Equal(t, "klines", KlineSourceAggregate.Relation("1h; DROP TABLE klines"))
+
+	assert.True(t, KlineSourceTable.Persists("5m"))
+	assert.True(t, KlineSourceAggregate.Persists("1m"))
+	assert.False(t, KlineSourceAggregate.Persists("5m"))
+
+	_, err := ParseKlineSource("bogus")
+	assert.Error(t, err)
+}
@@ -0,0 +1,98 @@
+package strategy
+
+import (
+	"quant-trader/internal/model"
+	"sync"
+)
+
+// ATRTrailingSchema are the parameters of atr_trailing
+var ATRTrailingSchema = Schema{
+	Params: []Param{
+		IntParam("trend_period", "buy when the close crosses above the EMA of this many candles", 50, 2, 500),
+		IntParam("atr_period", "ATR period in candles", 14, 2, 200),
+		FloatParam("multiplier", "the stop trails the highest close by this many ATRs", 3, 0.5, 10),
+	},
+}
+
+// ATRTrailingStrategy ATR 跟踪止损趋势策略: buys when the close crosses above
+// its EMA and sells when it falls below a stop that trails the highest close
+// since entry by multiplier ATRs. The stop only moves up.
+type ATRTrailingStrategy struct {
+	mu          sync.Mutex
+	candles     candleWindow
+	trendPeriod int
+	atrPeriod   int
+	multiplier  float64
+	long        bool
+	stop        float64
+}
+
+func NewATRTrailingStrategy(trendPeriod, atrPeriod int, multiplier float64) *ATRTrailingStrategy {
+	return &ATRTrailingStrategy{
+		candles:     candleWindow{n: 4*max(trendPeriod, atrPeriod) + 2},
+		trendPeriod: trendPeriod,
+		atrPeriod:   atrPeriod,
+		multiplier:  multiplier,
+	}
+}
+
+func (s *ATRTrailingStrategy) Name() string {
+	return "ATR_Trailing"
+}
+
+func (s *ATRTrailingStrategy) OnCandle(candle model.KLine) Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	candles := s.candles.add(candle)
+	if len(candles) < 2 {
+		return ActionHold
+	}
+	before, after := prevAndLast(candles)
+	atr, ok := after.ATR(s.atrPeriod)
+	if !ok {
+		return ActionHold
+	}
+	price := candle.Close.InexactFloat64()
+
+	if s.long {
+		if price < s.stop {
+			s.long = false
+			return ActionSell
+		}
+		s.stop = max(s.stop, price-s.multiplier*atr)
+		return ActionHold
+	}
+
+	prevEMA, ok1 := before.EMA(s.trendPeriod)
+	ema, ok2 := after.EMA(s.trendPeriod)
+	prev := candles[len(candles)-2].Close
+	if ok1 && ok2 && prev.LessThanOrEqual(prevEMA) && candle.Close.GreaterThan(ema) {
+		s.long = true
+		s.stop = price - s.multiplier*atr
+		return ActionBuy
+	}
+	return ActionHold
+}
+
+func (s *ATRTrailingStrategy) WarmUp() int {
+	return s.candles.n
+}
+
+func (s *ATRTrailingStrategy) Snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return windowState{Candles: s.candles.candles, Long: s.long, Stop: s.stop}.encode()
+}
+
+func (s *ATRTrailingStrategy) Restore(state []byte) error {
+	st, err := decodeState(s.Name(), state)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.candles.restore(st.Candles)
+	s.long, s.stop = st.Long, st.Stop
+	return nil
+}
@@ -0,0 +1,17 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestATRTrailingStrategy(t *testing.T) {
+	// Enters on the rise above the EMA and rides it until the fall breaks the stop
+	assert.Equal(t, map[int]Action{12: ActionBuy, 29: ActionSell}, signals(NewATRTrailingStrategy(5, 3, 2), wave()...))
+
+	// A wider stop holds on longer
+	wide := signals(NewATRTrailingStrategy(5, 3, 4), wave()...)
+	assert.Equal(t, ActionBuy, wide[12])
+	assert.Empty(t, wide[29])
+}
@@ -0,0 +1,98 @@
+package strategy
+
+import (
+	"quant-trader/internal/model"
+	"sync"
+)
+
+// BollingerSchema are the parameters of bollinger
+var BollingerSchema = Schema{
+	Params: []Param{
+		IntParam("period", "moving average period in candles", 20, 2, 500),
+		FloatParam("k", "band width in standard deviations", 2, 0.5, 5),
+		ChoiceParam("mode", "breakout buys a close above the upper band, reversion a close below the lower band", "reversion", "breakout", "reversion"),
+	},
+}
+
+// BollingerStrategy 布林带策略. In breakout mode it buys when the close breaks
+// above the upper band; in reversion mode when it drops below the lower band.
+// Both sell when the close crosses the middle band back.
+type BollingerStrategy struct {
+	mu       sync.Mutex
+	candles  candleWindow
+	period   int
+	k        float64
+	breakout bool
+}
+
+func NewBollingerStrategy(period int, k float64, mode string) *BollingerStrategy {
+	return &BollingerStrategy{
+		candles:  candleWindow{n: period + 1},
+		period:   period,
+		k:        k,
+		breakout: mode == "breakout",
+	}
+}
+
+func (s *BollingerStrategy) Name() string {
+	if s.breakout {
+		return "Bollinger_Breakout"
+	}
+	return "Bollinger_Reversion"
+}
+
+func (s *BollingerStrategy) OnCandle(candle model.KLine) Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	candles := s.candles.add(candle)
+	if len(candles) < 2 {
+		return ActionHold
+	}
+	before, after := prevAndLast(candles)
+	prevMiddle, prevUpper, prevLower, ok1 := before.Bollinger(s.period, s.k)
+	middle, upper, lower, ok2 := after.Bollinger(s.period, s.k)
+	if !ok1 || !ok2 {
+		return ActionHold
+	}
+
+	prev := candles[len(candles)-2].Close.InexactFloat64()
+	price := candle.Close.InexactFloat64()
+	if s.breakout {
+		switch {
+		case prev <= prevUpper && price > upper:
+			return ActionBuy
+		case prev >= prevMiddle && price < middle:
+			return ActionSell
+		}
+		return ActionHold
+	}
+	switch {
+	case prev >= prevLower && price < lower:
+		return ActionBuy
+	case prev <= prevMiddle && price > middle:
+		return ActionSell
+	}
+	return ActionHold
+}
+
+func (s *BollingerStrategy) WarmUp() int {
+	return s.candles.n
+}
+
+func (s *BollingerStrategy) Snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return windowState{Candles: s.candles.candles}.encode()
+}
+
+func (s *BollingerStrategy) Restore(state []byte) error {
+	st, err := decodeState(s.Name(), state)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.candles.restore(st.Candles)
+	return nil
+}
@@ -0,0 +1,18 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBollingerStrategy(t *testing.T) {
+	flat := []float64{100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100, 100}
+
+	reversion := NewBollingerStrategy(10, 2, "reversion")
+	assert.Equal(t, "Bollinger_Reversion", reversion.Name())
+	assert.Equal(t, map[int]Action{12: ActionBuy, 14: ActionSell}, signals(reversion, append(flat, 90, 92, 101, 100)...))
+
+	breakout := NewBollingerStrategy(10, 2, "breakout")
+	assert.Equal(t, map[int]Action{12: ActionBuy, 15: ActionSell}, signals(breakout, append(flat, 110, 112, 111, 95)...))
+}
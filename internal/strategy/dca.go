@@ -0,0 +1,133 @@
+package strategy
+
+import (
+	"quant-trader/internal/model"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// DCASchema are the parameters of dca
+var DCASchema = Schema{
+	Params: []Param{
+		IntParam("interval", "candles between buys", 24, 1, 100000),
+		FloatParam("order_pct", "fraction of equity each buy spends", 0.05, 0.001, 1),
+		IntParam("max_orders", "most buys before a take profit, 0 for no limit", 0, 0, 10000),
+		FloatParam("take_profit", "sell everything this fraction above the average entry, 0 to never sell", 0, 0, 10),
+	},
+}
+
+// DCAStrategy 定投: buys every interval candles and optionally sells the
+// whole position once the price is take_profit above the average entry. As
+// an action strategy the average is of the signal closes, each buy being the
+// same amount; NewStrategyV2 buys order_pct of equity and uses the
+// position's entry price.
+type DCAStrategy struct {
+	mu         sync.Mutex
+	interval   int
+	orderPct   float64
+	maxOrders  int
+	takeProfit decimal.Decimal
+	count      int
+	buys       int
+	units      decimal.Decimal // sum of 1/price of the buys
+}
+
+func NewDCAStrategy(interval int, orderPct float64, maxOrders int, takeProfit float64) *DCAStrategy {
+	return &DCAStrategy{
+		interval:   interval,
+		orderPct:   orderPct,
+		maxOrders:  maxOrders,
+		takeProfit: decimal.NewFromFloat(takeProfit),
+	}
+}
+
+func (s *DCAStrategy) Name() string {
+	return "DCA"
+}
+
+// due reports whether this candle is a buy candle and buys are left
+func (s *DCAStrategy) due() bool {
+	due := s.count%s.interval == 0
+	s.count++
+	return due && (s.maxOrders == 0 || s.buys < s.maxOrders)
+}
+
+// target is the price that takes profit over entry, zero when disabled
+func (s *DCAStrategy) target(entry decimal.Decimal) decimal.Decimal {
+	if s.takeProfit.IsZero() {
+		return decimal.Zero
+	}
+	return entry.Mul(decimal.NewFromInt(1).Add(s.takeProfit))
+}
+
+func (s *DCAStrategy) OnCandle(candle model.KLine) Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := s.due()
+	if s.buys > 0 {
+		entry := decimal.NewFromInt(int64(s.buys)).Div(s.units)
+		if target := s.target(entry); target.IsPositive() && candle.Close.GreaterThanOrEqual(target) {
+			s.buys, s.units = 0, decimal.Zero
+			return ActionSell
+		}
+	}
+	if due && candle.Close.IsPositive() {
+		s.buys++
+		s.units = s.units.Add(decimal.NewFromInt(1).Div(candle.Close))
+		return ActionBuy
+	}
+	return ActionHold
+}
+
+// V2 runs the plan on the event-driven runtime, buying order_pct of equity
+func (s *DCAStrategy) V2() StrategyV2 {
+	return &dcaEvents{plan: NewDCAStrategy(s.interval, s.orderPct, s.maxOrders, s.takeProfit.InexactFloat64())}
+}
+
+// dcaEvents is the StrategyV2 of a DCA plan
+type dcaEvents struct {
+	plan *DCAStrategy
+}
+
+func (s *dcaEvents) Name() string                       { return "DCA" }
+func (s *dcaEvents) Init(Context) error                 { return nil }
+func (s *dcaEvents) OnTrade(Context, model.Trade)       {}
+func (s *dcaEvents) OnOrderUpdate(Context, model.Order) {}
+func (s *dcaEvents) OnTimer(Context, time.Time)         {}
+
+func (s *dcaEvents) OnCandle(ctx Context, candle model.KLine) {
+	due := s.plan.due()
+	if len(ctx.OpenOrders(candle.Symbol)) > 0 {
+		return
+	}
+	pos := ctx.Position(candle.Symbol)
+	if target := s.plan.target(pos.EntryPrice); pos.Qty.IsPositive() && target.IsPositive() && candle.Close.GreaterThanOrEqual(target) {
+		ctx.Submit(CloseIntent(pos))
+		s.plan.buys = 0
+		return
+	}
+	if due {
+		ctx.Submit(OrderIntent{Symbol: candle.Symbol, Side: model.SideBuy, EquityPct: s.plan.orderPct, Tag: "dca"})
+		s.plan.buys++
+	}
+}
+
+func (s *DCAStrategy) Snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return windowState{Count: s.count, Buys: s.buys, Units: s.units}.encode()
+}
+
+func (s *DCAStrategy) Restore(state []byte) error {
+	st, err := decodeState(s.Name(), state)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.count, s.buys, s.units = st.Count, st.Buys, st.Units
+	return nil
+}
@@ -0,0 +1,37 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDCAStrategy(t *testing.T) {
+	// Buys at 100 and 90 average 94.74; 10% above is 104.21
+	closes := []float64{100, 100, 90, 90, 103, 110, 110}
+	assert.Equal(t, map[int]Action{0: ActionBuy, 2: ActionBuy, 4: ActionBuy, 5: ActionSell, 6: ActionBuy},
+		signals(NewDCAStrategy(2, 0.1, 0, 0.1), closes...))
+
+	// At most two buys, and no take profit
+	assert.Equal(t, map[int]Action{0: ActionBuy, 1: ActionBuy}, signals(NewDCAStrategy(1, 0.1, 2, 0), 100, 90, 80, 200))
+}
+
+func TestDCAStrategy_V2(t *testing.T) {
+	s, err := NewStrategyV2("dca", map[string]interface{}{"interval": 2.0, "order_pct": 0.1, "take_profit": 0.1})
+	require.NoError(t, err)
+	candles := minuteCandles(0, 100, 100, 120)
+
+	ctx := &fakeContext{}
+	s.OnCandle(ctx, candles[0])
+	s.OnCandle(ctx, candles[1])
+	require.Len(t, ctx.submitted, 1)
+	assert.Equal(t, 0.1, ctx.submitted[0].EquityPct)
+
+	// Take profit is against the position's entry price
+	ctx = &fakeContext{pos: PositionInfo{Symbol: "BTCUSDT", Qty: decimal.NewFromInt(1), EntryPrice: decimal.NewFromInt(100)}}
+	s.OnCandle(ctx, candles[2])
+	require.Len(t, ctx.submitted, 1)
+	assert.Equal(t, CloseIntent(ctx.pos), ctx.submitted[0])
+}
@@ -0,0 +1,88 @@
+package strategy
+
+import (
+	"errors"
+	"quant-trader/internal/model"
+	"sync"
+)
+
+// DonchianSchema are the parameters of donchian_breakout
+var DonchianSchema = Schema{
+	Params: []Param{
+		IntParam("entry_period", "buy when the close breaks the highest high of this many candles", 20, 2, 500),
+		IntParam("exit_period", "sell when the close breaks the lowest low of this many candles", 10, 2, 500),
+	},
+	Check: func(p Params) error {
+		if p.Int("exit_period") > p.Int("entry_period") {
+			return errors.New("exit_period must not be above entry_period")
+		}
+		return nil
+	},
+}
+
+// DonchianStrategy 唐奇安通道突破 (turtle): buys a close above the previous
+// entry_period highs and sells a close below the previous exit_period lows
+type DonchianStrategy struct {
+	mu          sync.Mutex
+	candles     candleWindow
+	entryPeriod int
+	exitPeriod  int
+	long        bool
+}
+
+func NewDonchianStrategy(entryPeriod, exitPeriod int) *DonchianStrategy {
+	return &DonchianStrategy{
+		candles:     candleWindow{n: entryPeriod + 1},
+		entryPeriod: entryPeriod,
+		exitPeriod:  exitPeriod,
+	}
+}
+
+func (s *DonchianStrategy) Name() string {
+	return "Donchian_Breakout"
+}
+
+func (s *DonchianStrategy) OnCandle(candle model.KLine) Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	candles := s.candles.add(candle)
+	// The channel is made of the candles before this one
+	channel := NewIndicators(candles[:len(candles)-1])
+	if !s.long {
+		high, ok := channel.Highest(s.entryPeriod)
+		if ok && candle.Close.GreaterThan(high) {
+			s.long = true
+			return ActionBuy
+		}
+		return ActionHold
+	}
+	low, ok := channel.Lowest(s.exitPeriod)
+	if ok && candle.Close.LessThan(low) {
+		s.long = false
+		return ActionSell
+	}
+	return ActionHold
+}
+
+func (s *DonchianStrategy) WarmUp() int {
+	return s.candles.n
+}
+
+func (s *DonchianStrategy) Snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return windowState{Candles: s.candles.candles, Long: s.long}.encode()
+}
+
+func (s *DonchianStrategy) Restore(state []byte) error {
+	st, err := decodeState(s.Name(), state)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.candles.restore(st.Candles)
+	s.long = st.Long
+	return nil
+}
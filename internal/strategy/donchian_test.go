@@ -0,0 +1,14 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDonchianStrategy(t *testing.T) {
+	// minuteCandles span close±1: the entry needs a close above the previous
+	// highs, the exit one below the previous lows
+	closes := []float64{100, 101, 100, 101, 100, 103, 104, 103, 100, 96, 104, 107}
+	assert.Equal(t, map[int]Action{5: ActionBuy, 8: ActionSell, 11: ActionBuy}, signals(NewDonchianStrategy(4, 3), closes...))
+}
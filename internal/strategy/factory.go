@@ -1,26 +1,105 @@
 package strategy
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"time"
 )
 
+// ErrWasmConstructor refuses to build "wasm" strategies by type: their module
+// and limits are checked by the caller of NewWasmFromConfig
+var ErrWasmConstructor = errors.New("wasm strategies only run from a stored strategy version")
+
+// NewStrategy builds a strategy of a registered type, see Types
 func NewStrategy(strategyType string, config map[string]interface{}) (Strategy, error) {
-	switch strategyType {
-	case "ma_cross":
-		short, ok1 := config["short_period"].(float64)
-		long, ok2 := config["long_period"].(float64)
-		if !ok1 || !ok2 {
-			return nil, fmt.Errorf("invalid config for ma_cross: need short_period and long_period")
+	r, ok := registry[strategyType]
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy type: %s", strategyType)
+	}
+	return r.build(config)
+}
+
+// NewStrategyV2 builds a strategy for the event-driven runtime; action-based
+// types are wrapped with Adapt unless they size their own orders
+func NewStrategyV2(strategyType string, config map[string]interface{}) (StrategyV2, error) {
+	s, err := NewStrategy(strategyType, config)
+	if err != nil {
+		return nil, err
+	}
+	if native, ok := s.(interface{ V2() StrategyV2 }); ok {
+		return native.V2(), nil
+	}
+	return Adapt(s), nil
+}
+
+// Legacy returns the action-based strategy s adapts, or nil for a native
+// StrategyV2 and for one that reads other timeframes through its Context
+func Legacy(s StrategyV2) Strategy {
+	if strat := Unadapt(s); strat != nil {
+		if _, ok := strat.(MultiTimeframeStrategy); !ok {
+			return strat
 		}
-		return NewMAStrategy(int(short), int(long)), nil
-	case "ma_cross_v2":
-		short, ok1 := config["short_period"].(float64)
-		long, ok2 := config["long_period"].(float64)
-		if !ok1 || !ok2 {
-			return nil, fmt.Errorf("invalid config for ma_cross_v2: need short_period and long_period")
+	}
+	return nil
+}
+
+// Unadapt returns the action-based strategy s adapts, nil for a native StrategyV2
+func Unadapt(s StrategyV2) Strategy {
+	if a, ok := s.(*actionAdapter); ok {
+		return a.strat
+	}
+	return nil
+}
+
+// NewWasmFromConfig reads a wasm strategy config: module is the binary, or
+// base64 encoded, params go to qt_init, and lookback, memory_pages, fuel and
+// timeout_ms override the defaults. It does not bound them, so only call it
+// for modules whose limits were checked.
+func NewWasmFromConfig(config map[string]interface{}) (*WasmStrategy, error) {
+	var code []byte
+	switch m := config["module"].(type) {
+	case []byte:
+		code = m
+	case string:
+		decoded, err := base64.StdEncoding.DecodeString(m)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config for wasm: module is not base64: %w", err)
 		}
-		return NewMACrossStrategy(int(short), int(long)), nil
+		code = decoded
 	default:
-		return nil, fmt.Errorf("unknown strategy type: %s", strategyType)
+		return nil, fmt.Errorf("invalid config for wasm: need module")
+	}
+
+	cfg := WasmConfig{}
+	cfg.Name, _ = config["name"].(string)
+	if params, ok := config["params"]; ok {
+		if cfg.Params, ok = params.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("invalid config for wasm: params must be an object")
+		}
+	}
+	number := func(key string) (float64, error) {
+		v, ok := config[key]
+		if !ok {
+			return 0, nil
+		}
+		f, ok := v.(float64)
+		if !ok || f < 0 {
+			return 0, fmt.Errorf("invalid config for wasm: %s must be a positive number", key)
+		}
+		return f, nil
+	}
+	for key, set := range map[string]func(float64){
+		"lookback":     func(f float64) { cfg.Lookback = int(f) },
+		"memory_pages": func(f float64) { cfg.Limits.MemoryPages = uint32(f) },
+		"fuel":         func(f float64) { cfg.Limits.Fuel = int64(f) },
+		"timeout_ms":   func(f float64) { cfg.Limits.Timeout = time.Duration(f) * time.Millisecond },
+	} {
+		f, err := number(key)
+		if err != nil {
+			return nil, err
+		}
+		set(f)
 	}
+	return NewWasmStrategy(code, cfg)
 }
@@ -0,0 +1,152 @@
+package strategy
+
+import (
+	"errors"
+	"math"
+	"quant-trader/internal/model"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// GridSchema are the parameters of grid
+var GridSchema = Schema{
+	Params: []Param{
+		FloatParam("lower", "lowest grid line", 0, 0, 1e12).Required(),
+		FloatParam("upper", "highest grid line", 0, 0, 1e12).Required(),
+		IntParam("levels", "number of equal steps between lower and upper", 10, 2, 200),
+		FloatParam("allocation", "fraction of equity the whole grid may hold", 1, 0.01, 1),
+	},
+	Check: func(p Params) error {
+		if p.Float("lower") <= 0 || p.Float("lower") >= p.Float("upper") {
+			return errors.New("lower must be above 0 and below upper")
+		}
+		return nil
+	},
+}
+
+// gridLevels tracks which step of the grid the price is in and how many
+// steps were bought and not yet sold
+type gridLevels struct {
+	lower   float64
+	step    float64
+	levels  int
+	cell    int
+	started bool
+	held    int
+}
+
+// cross moves to the step of price and returns how many grid lines it
+// crossed, negative when the price fell
+func (g *gridLevels) cross(price float64) int {
+	cell := int(math.Floor((price - g.lower) / g.step))
+	cell = min(max(cell, 0), g.levels)
+	if !g.started {
+		g.cell, g.started = cell, true
+		return 0
+	}
+	n := cell - g.cell
+	g.cell = cell
+	return n
+}
+
+// GridStrategy 网格交易: buys a step each time the price falls through a grid
+// line and sells a bought step each time it rises through one. As an action
+// strategy every crossing is a signal; NewStrategyV2 sizes each step at
+// allocation/levels of equity.
+type GridStrategy struct {
+	mu         sync.Mutex
+	grid       gridLevels
+	allocation float64
+}
+
+func NewGridStrategy(lower, upper float64, levels int, allocation float64) *GridStrategy {
+	return &GridStrategy{
+		grid:       gridLevels{lower: lower, step: (upper - lower) / float64(levels), levels: levels},
+		allocation: allocation,
+	}
+}
+
+func (s *GridStrategy) Name() string {
+	return "Grid"
+}
+
+func (s *GridStrategy) OnCandle(candle model.KLine) Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.grid.cross(candle.Close.InexactFloat64())
+	switch {
+	case n < 0:
+		s.grid.held -= n
+		return ActionBuy
+	case n > 0 && s.grid.held > 0:
+		s.grid.held -= min(n, s.grid.held)
+		return ActionSell
+	}
+	return ActionHold
+}
+
+// V2 runs the grid on the event-driven runtime with a sized order per step
+func (s *GridStrategy) V2() StrategyV2 {
+	return &gridEvents{grid: s.grid, allocation: s.allocation}
+}
+
+// gridEvents is the StrategyV2 of a grid
+type gridEvents struct {
+	grid       gridLevels
+	allocation float64
+}
+
+func (s *gridEvents) Name() string                       { return "Grid" }
+func (s *gridEvents) Init(Context) error                 { return nil }
+func (s *gridEvents) OnTrade(Context, model.Trade)       {}
+func (s *gridEvents) OnOrderUpdate(Context, model.Order) {}
+func (s *gridEvents) OnTimer(Context, time.Time)         {}
+
+func (s *gridEvents) OnCandle(ctx Context, candle model.KLine) {
+	n := s.grid.cross(candle.Close.InexactFloat64())
+	if len(ctx.OpenOrders(candle.Symbol)) > 0 {
+		return
+	}
+	pos := ctx.Position(candle.Symbol)
+	if !pos.Qty.IsPositive() {
+		// Nothing is held, e.g. a buy was rejected
+		s.grid.held = 0
+	}
+
+	switch {
+	case n < 0:
+		steps := -n
+		ctx.Submit(OrderIntent{
+			Symbol:    candle.Symbol,
+			Side:      model.SideBuy,
+			EquityPct: s.allocation / float64(s.grid.levels) * float64(steps),
+			Tag:       "grid",
+		})
+		s.grid.held += steps
+	case n > 0 && s.grid.held > 0 && pos.Qty.IsPositive():
+		steps := min(n, s.grid.held)
+		qty := pos.Qty.Mul(decimal.NewFromInt(int64(steps))).Div(decimal.NewFromInt(int64(s.grid.held)))
+		ctx.Submit(OrderIntent{Symbol: candle.Symbol, Side: model.SideSell, Qty: qty, Tag: "grid"})
+		s.grid.held -= steps
+	}
+}
+
+func (s *GridStrategy) Snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return windowState{Cell: s.grid.cell, Started: s.grid.started, Held: s.grid.held}.encode()
+}
+
+func (s *GridStrategy) Restore(state []byte) error {
+	st, err := decodeState(s.Name(), state)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.grid.cell, s.grid.started, s.grid.held = min(max(st.Cell, 0), s.grid.levels), st.Started, max(st.Held, 0)
+	return nil
+}
@@ -0,0 +1,37 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGridStrategy(t *testing.T) {
+	// Lines every 5 from 90 to 110; 100 starts in the third step. Each sell
+	// is of a step bought, so the rise above 110 sells nothing.
+	closes := []float64{100, 94, 96, 106, 108, 112, 85}
+	assert.Equal(t, map[int]Action{1: ActionBuy, 2: ActionSell, 3: ActionSell, 6: ActionBuy}, signals(NewGridStrategy(90, 110, 4, 1), closes...))
+}
+
+func TestGridStrategy_V2(t *testing.T) {
+	s, err := NewStrategyV2("grid", map[string]interface{}{"lower": 90.0, "upper": 110.0, "levels": 4.0, "allocation": 0.8})
+	require.NoError(t, err)
+	candles := minuteCandles(0, 100, 94, 106)
+
+	ctx := &fakeContext{}
+	s.OnCandle(ctx, candles[0])
+	assert.Empty(t, ctx.submitted)
+
+	// Two lines down buys two steps of 0.8/4 of equity
+	s.OnCandle(ctx, candles[1])
+	require.Len(t, ctx.submitted, 1)
+	assert.InDelta(t, 0.4, ctx.submitted[0].EquityPct, 1e-9)
+
+	// Three lines up sells the two steps held
+	ctx = &fakeContext{pos: PositionInfo{Symbol: "BTCUSDT", Qty: decimal.NewFromInt(4)}}
+	s.OnCandle(ctx, candles[2])
+	require.Len(t, ctx.submitted, 1)
+	assert.Equal(t, "4", ctx.submitted[0].Qty.String())
+}
@@ -0,0 +1,227 @@
+package strategy
+
+import (
+	"quant-trader/internal/indicators"
+	"quant-trader/internal/model"
+	"slices"
+
+	"github.com/shopspring/decimal"
+)
+
+// Resample aggregates candles into bars of period. Only complete bars are
+// returned: a first bar the candles start inside of is dropped, and so is the
+// last one until its final source candle has closed. Candles already at period
+// or longer are returned as they are.
+func Resample(candles []model.KLine, period string) []model.KLine {
+	if len(candles) == 0 {
+		return nil
+	}
+	step := model.PeriodToDuration(period)
+	base := model.PeriodToDuration(candles[0].Period)
+	if step <= base {
+		return candles
+	}
+
+	var (
+		out []model.KLine
+		cur *model.KLine
+	)
+	for _, c := range candles {
+		start := c.Timestamp.Truncate(step)
+		if cur == nil || !cur.Timestamp.Equal(start) {
+			out = append(out, model.KLine{
+				Symbol: c.Symbol, Exchange: c.Exchange, Period: period, Timestamp: start,
+				Open: c.Open, High: c.High, Low: c.Low,
+			})
+			cur = &out[len(out)-1]
+		}
+		cur.High = decimal.Max(cur.High, c.High)
+		cur.Low = decimal.Min(cur.Low, c.Low)
+		cur.Close = c.Close
+		cur.Volume = cur.Volume.Add(c.Volume)
+	}
+	last := candles[len(candles)-1]
+	if last.Timestamp.Add(base).Before(cur.Timestamp.Add(step)) {
+		out = out[:len(out)-1]
+	}
+	if len(out) > 0 && !out[0].Timestamp.Equal(candles[0].Timestamp) {
+		out = out[1:]
+	}
+	return out
+}
+
+// lastN returns the last n items, all of them when n <= 0
+func lastN[T any](items []T, n int) []T {
+	if n > 0 && len(items) > n {
+		return items[len(items)-n:]
+	}
+	return items
+}
+
+// candleWindow keeps the last n candles of a single-symbol strategy
+type candleWindow struct {
+	n       int
+	candles []model.KLine
+}
+
+// add appends candle and returns the window, oldest first
+func (w *candleWindow) add(candle model.KLine) []model.KLine {
+	w.candles = append(w.candles, candle)
+	// Trim in batches so the buffer is not copied on every candle
+	if len(w.candles) > 2*w.n {
+		w.candles = append(w.candles[:0], w.candles[len(w.candles)-w.n:]...)
+	}
+	return lastN(w.candles, w.n)
+}
+
+// prevAndLast wraps the candles before and up to the last one, each the same
+// length so indicators smoothed over all history stay comparable
+func prevAndLast(candles []model.KLine) (prev, last Indicators) {
+	n := len(candles) - 1
+	return NewIndicators(candles[:n]), NewIndicators(candles[1:])
+}
+
+// Indicators computes common indicators over a candle history and returns
+// their latest value. Each reports false until the history is long enough.
+type Indicators struct {
+	candles []model.KLine
+}
+
+// NewIndicators wraps candles, oldest first
+func NewIndicators(candles []model.KLine) Indicators {
+	return Indicators{candles: candles}
+}
+
+// Len is the number of candles the indicators see
+func (in Indicators) Len() int {
+	return len(in.candles)
+}
+
+func (in Indicators) closes() []decimal.Decimal {
+	closes := make([]decimal.Decimal, len(in.candles))
+	for i, c := range in.candles {
+		closes[i] = c.Close
+	}
+	return closes
+}
+
+func (in Indicators) SMA(period int) (decimal.Decimal, bool) {
+	if period <= 0 || len(in.candles) < period {
+		return decimal.Zero, false
+	}
+	sma := indicators.CalculateSMA(in.closes()[len(in.candles)-period:], period)
+	return sma[period-1], true
+}
+
+func (in Indicators) EMA(period int) (decimal.Decimal, bool) {
+	if period <= 0 || len(in.candles) < period {
+		return decimal.Zero, false
+	}
+	ema := indicators.CalculateEMA(in.closes(), period)
+	return ema[len(ema)-1], true
+}
+
+func (in Indicators) RSI(period int) (decimal.Decimal, bool) {
+	if period <= 0 || len(in.candles) < period+1 {
+		return decimal.Zero, false
+	}
+	rsi := indicators.CalculateRSI(in.closes(), period)
+	return rsi[len(rsi)-1], true
+}
+
+// MACD returns the MACD line, its signal line and the histogram
+func (in Indicators) MACD(fast, slow, signal int) (macd, sig, hist decimal.Decimal, ok bool) {
+	if fast <= 0 || slow <= fast || signal <= 0 || len(in.candles) < slow+signal {
+		return decimal.Zero, decimal.Zero, decimal.Zero, false
+	}
+	m, s, h := indicators.CalculateMACD(in.closes(), fast, slow, signal)
+	n := len(m) - 1
+	return m[n], s[n], h[n], true
+}
+
+// Bollinger returns the middle, upper and lower bands at k standard deviations
+func (in Indicators) Bollinger(period int, k float64) (middle, upper, lower float64, ok bool) {
+	if period <= 0 || len(in.candles) < period {
+		return 0, 0, 0, false
+	}
+	m, u, l := indicators.CalculateBollingerBands(in.candles[len(in.candles)-period:], period, k)
+	return m[period-1], u[period-1], l[period-1], true
+}
+
+func (in Indicators) ATR(period int) (float64, bool) {
+	if period <= 0 || len(in.candles) < period+1 {
+		return 0, false
+	}
+	atr := indicators.CalculateATR(in.candles, period)
+	return atr[len(atr)-1], true
+}
+
+// Highest is the highest high of the last period candles
+func (in Indicators) Highest(period int) (decimal.Decimal, bool) {
+	if period <= 0 || len(in.candles) < period {
+		return decimal.Zero, false
+	}
+	high := in.candles[len(in.candles)-period].High
+	for _, c := range in.candles[len(in.candles)-period:] {
+		high = decimal.Max(high, c.High)
+	}
+	return high, true
+}
+
+// Lowest is the lowest low of the last period candles
+func (in Indicators) Lowest(period int) (decimal.Decimal, bool) {
+	if period <= 0 || len(in.candles) < period {
+		return decimal.Zero, false
+	}
+	low := in.candles[len(in.candles)-period].Low
+	for _, c := range in.candles[len(in.candles)-period:] {
+		low = decimal.Min(low, c.Low)
+	}
+	return low, true
+}
+
+// HistoryBuffer keeps the most recent candles of each symbol for a Context
+type HistoryBuffer struct {
+	limit   int
+	candles map[string][]model.KLine
+}
+
+// NewHistoryBuffer keeps up to limit candles per symbol
+func NewHistoryBuffer(limit int) *HistoryBuffer {
+	return &HistoryBuffer{limit: limit, candles: make(map[string][]model.KLine)}
+}
+
+// Add appends a closed candle of its symbol
+func (h *HistoryBuffer) Add(candle model.KLine) {
+	candles := append(h.candles[candle.Symbol], candle)
+	// Trim in batches so the buffer is not copied on every candle
+	if len(candles) > 2*h.limit {
+		candles = append(candles[:0], candles[len(candles)-h.limit:]...)
+	}
+	h.candles[candle.Symbol] = candles
+}
+
+// Grow raises the number of candles kept per symbol to at least limit
+func (h *HistoryBuffer) Grow(limit int) {
+	h.limit = max(h.limit, limit)
+}
+
+// History implements Context.History over the buffered candles
+func (h *HistoryBuffer) History(symbol, period string, n int) []model.KLine {
+	candles := lastN(h.candles[symbol], h.limit)
+	if len(candles) == 0 {
+		return nil
+	}
+	if period != "" && period != candles[0].Period {
+		step, base := model.PeriodToDuration(period), model.PeriodToDuration(candles[0].Period)
+		if step < base {
+			return nil
+		}
+		if n > 0 {
+			// Only resample the tail: n bars, the unfinished one and one cut short
+			candles = lastN(candles, (n+2)*int(step/base))
+		}
+		candles = Resample(candles, period)
+	}
+	return slices.Clone(lastN(candles, n))
+}
@@ -0,0 +1,97 @@
+package strategy
+
+import (
+	"quant-trader/internal/model"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func minuteCandles(from int, closes ...float64) []model.KLine {
+	candles := make([]model.KLine, len(closes))
+	for i, c := range closes {
+		price := decimal.NewFromFloat(c)
+		candles[i] = model.KLine{
+			Symbol: "BTCUSDT", Period: "1m",
+			Open: price, High: price.Add(decimal.NewFromInt(1)), Low: price.Sub(decimal.NewFromInt(1)), Close: price,
+			Volume:    decimal.NewFromInt(1),
+			Timestamp: time.Date(2024, 1, 1, 0, from+i, 0, 0, time.UTC),
+		}
+	}
+	return candles
+}
+
+func TestResample(t *testing.T) {
+	// 00:03..00:16: the 00:00 bar starts before the data and 00:15 is unfinished
+	candles := minuteCandles(3, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14)
+
+	bars := Resample(candles, "5m")
+	require.Len(t, bars, 2)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 5, 0, 0, time.UTC), bars[0].Timestamp)
+	assert.Equal(t, "5m", bars[0].Period)
+	assert.Equal(t, "3", bars[0].Open.String())
+	assert.Equal(t, "7", bars[0].Close.String())
+	assert.Equal(t, "8", bars[0].High.String())
+	assert.Equal(t, "2", bars[0].Low.String())
+	assert.Equal(t, "5", bars[0].Volume.String())
+	assert.Equal(t, "12", bars[1].Close.String())
+
+	assert.Equal(t, candles, Resample(candles, "1m"))
+}
+
+func TestHistoryBuffer(t *testing.T) {
+	h := NewHistoryBuffer(10)
+	for _, c := range minuteCandles(0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25) {
+		h.Add(c)
+	}
+
+	last := h.History("BTCUSDT", "1m", 0)
+	require.Len(t, last, 10)
+	assert.Equal(t, "16", last[0].Close.String())
+	assert.Len(t, h.History("BTCUSDT", "1m", 3), 3)
+	assert.Len(t, h.History("BTCUSDT", "5m", 0), 2, "00:15 and 00:20")
+	assert.Nil(t, h.History("ETHUSDT", "1m", 0))
+
+	// Returned candles are copies
+	last[0].Close = decimal.Zero
+	assert.Equal(t, "16", h.History("BTCUSDT", "1m", 0)[0].Close.String())
+}
+
+func TestIndicators(t *testing.T) {
+	in := NewIndicators(minuteCandles(0, 1, 2, 3, 4, 5, 6))
+
+	sma, ok := in.SMA(3)
+	require.True(t, ok)
+	assert.Equal(t, "5", sma.String())
+	_, ok = in.SMA(7)
+	assert.False(t, ok)
+
+	rsi, ok := in.RSI(5)
+	require.True(t, ok)
+	assert.Equal(t, "100", rsi.String())
+
+	high, ok := in.Highest(2)
+	require.True(t, ok)
+	assert.Equal(t, "7", high.String())
+	low, ok := in.Lowest(2)
+	require.True(t, ok)
+	assert.Equal(t, "4", low.String())
+
+	atr, ok := in.ATR(3)
+	require.True(t, ok)
+	assert.InDelta(t, 2, atr, 1e-9)
+
+	mid, upper, lower, ok := in.Bollinger(3, 2)
+	require.True(t, ok)
+	assert.InDelta(t, 5, mid, 1e-9)
+	assert.Greater(t, upper, mid)
+	assert.Less(t, lower, mid)
+
+	_, _, _, ok = in.MACD(2, 3, 2)
+	assert.True(t, ok)
+	_, _, _, ok = in.MACD(2, 3, 4)
+	assert.False(t, ok)
+}
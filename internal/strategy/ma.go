@@ -59,3 +59,20 @@ func (s *MAStrategy) calculateMA(period int) decimal.Decimal {
 	}
 	return sum.Div(decimal.NewFromInt(int64(period)))
 }
+
+func (s *MAStrategy) WarmUp() int {
+	return s.longPeriod
+}
+
+func (s *MAStrategy) Snapshot() ([]byte, error) {
+	return windowState{Prices: s.prices}.encode()
+}
+
+func (s *MAStrategy) Restore(state []byte) error {
+	st, err := decodeState(s.Name(), state)
+	if err != nil {
+		return err
+	}
+	s.prices = append(make([]decimal.Decimal, 0), lastN(st.Prices, s.longPeriod+1)...)
+	return nil
+}
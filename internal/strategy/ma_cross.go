@@ -70,3 +70,27 @@ func (s *MACrossStrategy) calculateMA(period int, offset int) decimal.Decimal {
 	}
 	return sum.Div(decimal.NewFromInt(int64(period)))
 }
+
+func (s *MACrossStrategy) WarmUp() int {
+	return s.longPeriod + 1
+}
+
+func (s *MACrossStrategy) Snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return windowState{Candles: s.candles, LastAction: s.lastAction}.encode()
+}
+
+func (s *MACrossStrategy) Restore(state []byte) error {
+	st, err := decodeState(s.Name(), state)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.candles = append(make([]model.KLine, 0), lastN(st.Candles, s.longPeriod+1)...)
+	if st.LastAction != "" {
+		s.lastAction = st.LastAction
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package strategy
+
+import (
+	"errors"
+	"quant-trader/internal/model"
+	"sync"
+)
+
+// MACDSchema are the parameters of macd_trend
+var MACDSchema = Schema{
+	Params: []Param{
+		IntParam("fast", "fast EMA period", 12, 2, 200),
+		IntParam("slow", "slow EMA period", 26, 3, 400),
+		IntParam("signal", "signal line EMA period", 9, 2, 100),
+	},
+	Check: func(p Params) error {
+		if p.Int("fast") >= p.Int("slow") {
+			return errors.New("fast must be below slow")
+		}
+		return nil
+	},
+}
+
+// MACDStrategy MACD 趋势策略: buys when the MACD line crosses above its
+// signal line and sells when it crosses below
+type MACDStrategy struct {
+	mu      sync.Mutex
+	candles candleWindow
+	fast    int
+	slow    int
+	signal  int
+}
+
+func NewMACDStrategy(fast, slow, signal int) *MACDStrategy {
+	return &MACDStrategy{
+		candles: candleWindow{n: 4*slow + signal + 1},
+		fast:    fast,
+		slow:    slow,
+		signal:  signal,
+	}
+}
+
+func (s *MACDStrategy) Name() string {
+	return "MACD_Trend"
+}
+
+func (s *MACDStrategy) OnCandle(candle model.KLine) Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	candles := s.candles.add(candle)
+	if len(candles) < 2 {
+		return ActionHold
+	}
+	before, after := prevAndLast(candles)
+	_, _, prev, ok1 := before.MACD(s.fast, s.slow, s.signal)
+	_, _, hist, ok2 := after.MACD(s.fast, s.slow, s.signal)
+	if !ok1 || !ok2 {
+		return ActionHold
+	}
+
+	switch {
+	case !prev.IsPositive() && hist.IsPositive():
+		return ActionBuy
+	case !prev.IsNegative() && hist.IsNegative():
+		return ActionSell
+	}
+	return ActionHold
+}
+
+func (s *MACDStrategy) WarmUp() int {
+	return s.candles.n
+}
+
+func (s *MACDStrategy) Snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return windowState{Candles: s.candles.candles}.encode()
+}
+
+func (s *MACDStrategy) Restore(state []byte) error {
+	st, err := decodeState(s.Name(), state)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.candles.restore(st.Candles)
+	return nil
+}
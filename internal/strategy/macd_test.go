@@ -0,0 +1,11 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMACDStrategy(t *testing.T) {
+	assert.Equal(t, map[int]Action{11: ActionBuy, 26: ActionSell}, signals(NewMACDStrategy(3, 6, 3), wave()...))
+}
@@ -0,0 +1,91 @@
+package strategy
+
+import (
+	"errors"
+	"quant-trader/internal/model"
+	"sync"
+)
+
+// MTFTrendSchema are the parameters of mtf_trend
+var MTFTrendSchema = Schema{
+	Params: []Param{
+		ChoiceParam("trend_timeframe", "timeframe of the trend filter", "1h", "15m", "1h", "4h", "1d"),
+		IntParam("trend_period", "EMA period of the trend filter, in trend_timeframe bars", 50, 2, 200),
+		IntParam("fast", "fast EMA period of the entry timeframe", 9, 2, 200),
+		IntParam("slow", "slow EMA period of the entry timeframe", 21, 3, 400),
+	},
+	Check: func(p Params) error {
+		if p.Int("fast") >= p.Int("slow") {
+			return errors.New("fast must be below slow")
+		}
+		return nil
+	},
+}
+
+// MTFTrendStrategy 多周期趋势策略: on its own timeframe it buys a fast EMA
+// crossing above the slow one while the close is above the trend EMA of a
+// longer timeframe, and sells the cross back down
+type MTFTrendStrategy struct {
+	mu          sync.Mutex
+	timeframe   string
+	trendPeriod int
+	fast        int
+	slow        int
+	own         *LiveTimeframes // the view OnCandle keeps without a runtime's
+}
+
+func NewMTFTrendStrategy(timeframe string, trendPeriod, fast, slow int) *MTFTrendStrategy {
+	return &MTFTrendStrategy{timeframe: timeframe, trendPeriod: trendPeriod, fast: fast, slow: slow}
+}
+
+func (s *MTFTrendStrategy) Name() string {
+	return "MTF_Trend"
+}
+
+func (s *MTFTrendStrategy) ContextPeriods() ([]string, int) {
+	return []string{s.timeframe}, 4*s.trendPeriod + 1
+}
+
+// OnCandle keeps a view of its own for runtimes without one
+func (s *MTFTrendStrategy) OnCandle(candle model.KLine) Action {
+	s.mu.Lock()
+	if s.own == nil {
+		periods, lookback := s.ContextPeriods()
+		s.own = NewTimeframes(Subscription{Period: candle.Period, Context: periods, Lookback: lookback})
+	}
+	s.own.Add(candle)
+	s.mu.Unlock()
+	return s.OnCandleWith(s.own, candle)
+}
+
+func (s *MTFTrendStrategy) OnCandleWith(view Timeframes, candle model.KLine) Action {
+	candles := view.History(candle.Symbol, candle.Period, 4*s.slow+2)
+	if len(candles) < 2 {
+		return ActionHold
+	}
+	before, after := prevAndLast(candles)
+	prevFast, ok1 := before.EMA(s.fast)
+	prevSlow, ok2 := before.EMA(s.slow)
+	fast, ok3 := after.EMA(s.fast)
+	slow, ok4 := after.EMA(s.slow)
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return ActionHold
+	}
+
+	switch {
+	case prevFast.LessThanOrEqual(prevSlow) && fast.GreaterThan(slow):
+		_, lookback := s.ContextPeriods()
+		trend, ok := NewIndicators(view.History(candle.Symbol, s.timeframe, lookback)).EMA(s.trendPeriod)
+		if ok && candle.Close.GreaterThan(trend) {
+			return ActionBuy
+		}
+	case prevFast.GreaterThanOrEqual(prevSlow) && fast.LessThan(slow):
+		return ActionSell
+	}
+	return ActionHold
+}
+
+// WarmUp covers the entry EMAs; runtimes with a view size it for the trend
+func (s *MTFTrendStrategy) WarmUp() int {
+	return 4*s.slow + 2
+}
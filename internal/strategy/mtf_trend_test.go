@@ -0,0 +1,41 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMTFTrend(t *testing.T) {
+	// A dip and recovery in an uptrend: the cross back up is bought
+	up := append(ramp(100, 200, 200), ramp(200, 190, 6)...)
+	up = append(up, ramp(190, 206, 6)...)
+	assert.Equal(t, map[int]Action{200: ActionSell, 207: ActionBuy}, signals(NewMTFTrendStrategy("15m", 2, 2, 3), up...))
+
+	// A bounce in a downtrend crosses up below the 15m trend: only the plain
+	// cross buys it
+	down := append(ramp(200, 100, 200), ramp(100, 103, 6)...)
+	down = append(down, ramp(103, 95, 6)...)
+	assert.Equal(t, map[int]Action{201: ActionBuy, 206: ActionSell}, signals(NewMACrossStrategy(2, 3), down...))
+	assert.Equal(t, map[int]Action{206: ActionSell}, signals(NewMTFTrendStrategy("15m", 2, 2, 3), down...))
+}
+
+func TestMTFTrend_RuntimeView(t *testing.T) {
+	s := NewMTFTrendStrategy("15m", 2, 2, 3)
+	periods, lookback := s.ContextPeriods()
+	assert.Equal(t, []string{"15m"}, periods)
+	assert.Equal(t, 9, lookback)
+
+	// Through a runtime's view it signals like through its own
+	closes := append(ramp(100, 200, 200), ramp(200, 190, 6)...)
+	closes = append(closes, ramp(190, 206, 6)...)
+	view := NewTimeframes(Subscription{Symbols: []string{"BTCUSDT"}, Period: "1m", Context: periods, Lookback: lookback})
+	got := make(map[int]Action)
+	for i, c := range minuteCandles(0, closes...) {
+		view.Add(c)
+		if action := s.OnCandleWith(view, c); action != ActionHold {
+			got[i] = action
+		}
+	}
+	assert.Equal(t, signals(NewMTFTrendStrategy("15m", 2, 2, 3), closes...), got)
+}
@@ -0,0 +1,166 @@
+package strategy
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ParamType is the kind of value a strategy parameter takes
+type ParamType string
+
+const (
+	ParamInt    ParamType = "int"
+	ParamFloat  ParamType = "float"
+	ParamChoice ParamType = "choice"
+)
+
+// Param describes one parameter of a strategy type
+type Param struct {
+	Name        string    `json:"name"`
+	Type        ParamType `json:"type"`
+	Description string    `json:"description"`
+	// Default is used when the parameter is not set; nil makes it required
+	Default interface{} `json:"default,omitempty"`
+	// Min and Max bound numeric parameters
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+	// Options are the values of a choice parameter
+	Options []string `json:"options,omitempty"`
+}
+
+// IntParam is an integer parameter in [min, max]
+func IntParam(name, description string, def, min, max int) Param {
+	lo, hi := float64(min), float64(max)
+	return Param{Name: name, Type: ParamInt, Description: description, Default: def, Min: &lo, Max: &hi}
+}
+
+// FloatParam is a number in [min, max]
+func FloatParam(name, description string, def, min, max float64) Param {
+	return Param{Name: name, Type: ParamFloat, Description: description, Default: def, Min: &min, Max: &max}
+}
+
+// ChoiceParam is one of options
+func ChoiceParam(name, description, def string, options ...string) Param {
+	return Param{Name: name, Type: ParamChoice, Description: description, Default: def, Options: options}
+}
+
+// Required drops the default of p
+func (p Param) Required() Param {
+	p.Default = nil
+	return p
+}
+
+// Schema lists the parameters of a strategy type
+type Schema struct {
+	Params []Param `json:"params"`
+	// Check validates combinations of parameters, after each one was checked
+	Check func(Params) error `json:"-"`
+}
+
+// Params are the checked parameters of a strategy, defaults filled in
+type Params map[string]interface{}
+
+func (p Params) Int(name string) int {
+	return int(p.Float(name))
+}
+
+func (p Params) Float(name string) float64 {
+	f, _ := toFloat(p[name])
+	return f
+}
+
+func (p Params) String(name string) string {
+	s, _ := p[name].(string)
+	return s
+}
+
+// Param returns the parameter called name
+func (s Schema) Param(name string) (Param, bool) {
+	i := slices.IndexFunc(s.Params, func(p Param) bool { return p.Name == name })
+	if i < 0 {
+		return Param{}, false
+	}
+	return s.Params[i], true
+}
+
+// Parse checks config of a strategyType against the schema
+func (s Schema) Parse(strategyType string, config map[string]interface{}) (Params, error) {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("invalid config for %s: %s", strategyType, fmt.Sprintf(format, args...))
+	}
+	for _, key := range slices.Sorted(maps.Keys(config)) {
+		if _, ok := s.Param(key); !ok {
+			return nil, invalid("unknown parameter %s", key)
+		}
+	}
+
+	params := make(Params, len(s.Params))
+	for _, p := range s.Params {
+		v, ok := config[p.Name]
+		if !ok || v == nil {
+			if p.Default == nil {
+				return nil, invalid("%s is required", p.Name)
+			}
+			params[p.Name] = p.Default
+			continue
+		}
+
+		value, err := p.check(v)
+		if err != nil {
+			return nil, invalid("%s", err)
+		}
+		params[p.Name] = value
+	}
+
+	if s.Check != nil {
+		if err := s.Check(params); err != nil {
+			return nil, invalid("%s", err)
+		}
+	}
+	return params, nil
+}
+
+// check returns v as the parameter's value: a string for a choice, else a
+// float64 within its bounds
+func (p Param) check(v interface{}) (interface{}, error) {
+	if p.Type == ParamChoice {
+		choice, _ := v.(string)
+		if !slices.Contains(p.Options, choice) {
+			return nil, fmt.Errorf("%s must be one of %s", p.Name, strings.Join(p.Options, ", "))
+		}
+		return choice, nil
+	}
+	f, ok := toFloat(v)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a number", p.Name)
+	}
+	if p.Type == ParamInt && f != float64(int(f)) {
+		return nil, fmt.Errorf("%s must be a whole number", p.Name)
+	}
+	if p.Min != nil && p.Max != nil && (f < *p.Min || f > *p.Max) {
+		return nil, fmt.Errorf("%s must be between %g and %g", p.Name, *p.Min, *p.Max)
+	}
+	return f, nil
+}
+
+// Check reports whether v is a valid value of the parameter
+func (p Param) Check(v interface{}) error {
+	_, err := p.check(v)
+	return err
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,89 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ramp steps from just after from to to in n closes
+func ramp(from, to float64, n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = from + (to-from)*float64(i+1)/float64(n)
+	}
+	return out
+}
+
+// wave is 100, a fall to 80 by candle 10, a rise to 110 by candle 25 and a
+// fall to 90 by candle 35
+func wave() []float64 {
+	closes := []float64{100}
+	closes = append(closes, ramp(100, 80, 10)...)
+	closes = append(closes, ramp(80, 110, 15)...)
+	return append(closes, ramp(110, 90, 10)...)
+}
+
+// signals feeds closes to s and returns its non-hold actions by candle
+func signals(s Strategy, closes ...float64) map[int]Action {
+	out := make(map[int]Action)
+	for i, c := range minuteCandles(0, closes...) {
+		if action := s.OnCandle(c); action != ActionHold {
+			out[i] = action
+		}
+	}
+	return out
+}
+
+func TestSchema_Parse(t *testing.T) {
+	schema := Schema{Params: []Param{
+		IntParam("period", "", 14, 2, 100),
+		FloatParam("k", "", 2, 0.5, 5),
+		ChoiceParam("mode", "", "a", "a", "b"),
+		FloatParam("level", "", 0, 0, 10).Required(),
+	}}
+
+	p, err := schema.Parse("test", map[string]interface{}{"level": 3.0, "k": 1.5})
+	require.NoError(t, err)
+	assert.Equal(t, 14, p.Int("period"))
+	assert.Equal(t, 1.5, p.Float("k"))
+	assert.Equal(t, "a", p.String("mode"))
+	assert.Equal(t, 3.0, p.Float("level"))
+
+	invalid := map[string]map[string]interface{}{
+		"level is required":                   {},
+		"unknown parameter foo":               {"level": 1.0, "foo": 1.0},
+		"period must be a whole number":       {"level": 1.0, "period": 2.5},
+		"period must be between 2 and 100":    {"level": 1.0, "period": 1.0},
+		"k must be a number":                  {"level": 1.0, "k": "wide"},
+		"mode must be one of a, b":            {"level": 1.0, "mode": "c"},
+		"invalid config for test: level must": {"level": 11.0},
+	}
+	for msg, config := range invalid {
+		_, err := schema.Parse("test", config)
+		assert.ErrorContains(t, err, msg)
+	}
+}
+
+func TestBuiltins_Validation(t *testing.T) {
+	invalid := map[string]map[string]interface{}{
+		"rsi_reversion":     {"oversold": 70.0, "overbought": 30.0},
+		"bollinger":         {"mode": "momentum"},
+		"macd_trend":        {"fast": 26.0, "slow": 12.0},
+		"donchian_breakout": {"entry_period": 10.0, "exit_period": 20.0},
+		"atr_trailing":      {"multiplier": 0.0},
+		"grid":              {"lower": 110.0, "upper": 90.0},
+		"dca":               {"interval": 0.0},
+	}
+	for strategyType, config := range invalid {
+		_, err := NewStrategy(strategyType, config)
+		assert.ErrorContains(t, err, "invalid config for "+strategyType, strategyType)
+
+		// Defaults alone are valid, except for the grid's bounds
+		if strategyType != "grid" {
+			_, err = NewStrategy(strategyType, map[string]interface{}{})
+			assert.NoError(t, err, strategyType)
+		}
+	}
+}
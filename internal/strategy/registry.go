@@ -0,0 +1,118 @@
+package strategy
+
+import (
+	"errors"
+	"maps"
+	"slices"
+)
+
+// TypeInfo describes a strategy type, e.g. for generating forms and
+// optimizer ranges
+type TypeInfo struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	// Schema lists the parameters; nil for types whose config is free-form
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type registration struct {
+	TypeInfo
+	build func(config map[string]interface{}) (Strategy, error)
+}
+
+var registry = make(map[string]registration)
+
+// Register adds a strategy type whose config is checked against schema
+// before build sees it. It is meant to be called from init and panics on a
+// type registered twice.
+func Register(strategyType, description string, schema Schema, build func(Params) Strategy) {
+	registerType(TypeInfo{Type: strategyType, Description: description, Schema: &schema}, func(config map[string]interface{}) (Strategy, error) {
+		p, err := schema.Parse(strategyType, config)
+		if err != nil {
+			return nil, err
+		}
+		return build(p), nil
+	})
+}
+
+func registerType(info TypeInfo, build func(map[string]interface{}) (Strategy, error)) {
+	if _, ok := registry[info.Type]; ok {
+		panic("strategy type registered twice: " + info.Type)
+	}
+	registry[info.Type] = registration{TypeInfo: info, build: build}
+}
+
+// Types lists the registered strategy types by name
+func Types() []TypeInfo {
+	types := make([]TypeInfo, 0, len(registry))
+	for _, name := range slices.Sorted(maps.Keys(registry)) {
+		types = append(types, registry[name].TypeInfo)
+	}
+	return types
+}
+
+// LookupType returns a registered strategy type
+func LookupType(strategyType string) (TypeInfo, bool) {
+	r, ok := registry[strategyType]
+	return r.TypeInfo, ok
+}
+
+// MACrossSchema are the parameters of ma_cross and ma_cross_v2
+var MACrossSchema = Schema{
+	Params: []Param{
+		IntParam("short_period", "fast moving average period in candles", 10, 1, 500).Required(),
+		IntParam("long_period", "slow moving average period in candles", 30, 2, 1000).Required(),
+	},
+	Check: func(p Params) error {
+		if p.Int("short_period") >= p.Int("long_period") {
+			return errors.New("short_period must be below long_period")
+		}
+		return nil
+	},
+}
+
+func init() {
+	Register("ma_cross", "moving average cross on the closes it has seen", MACrossSchema, func(p Params) Strategy {
+		return NewMAStrategy(p.Int("short_period"), p.Int("long_period"))
+	})
+	Register("ma_cross_v2", "moving average cross signalled once per cross", MACrossSchema, func(p Params) Strategy {
+		return NewMACrossStrategy(p.Int("short_period"), p.Int("long_period"))
+	})
+	Register("rsi_reversion", "buys RSI recovering from oversold, sells it turning down from overbought", RSISchema, func(p Params) Strategy {
+		return NewRSIStrategy(p.Int("period"), p.Float("oversold"), p.Float("overbought"))
+	})
+	Register("bollinger", "Bollinger band breakout or mean reversion, exiting at the middle band", BollingerSchema, func(p Params) Strategy {
+		return NewBollingerStrategy(p.Int("period"), p.Float("k"), p.String("mode"))
+	})
+	Register("macd_trend", "follows MACD crossing its signal line", MACDSchema, func(p Params) Strategy {
+		return NewMACDStrategy(p.Int("fast"), p.Int("slow"), p.Int("signal"))
+	})
+	Register("donchian_breakout", "turtle style channel breakout", DonchianSchema, func(p Params) Strategy {
+		return NewDonchianStrategy(p.Int("entry_period"), p.Int("exit_period"))
+	})
+	Register("atr_trailing", "trend entries above an EMA with an ATR trailing stop", ATRTrailingSchema, func(p Params) Strategy {
+		return NewATRTrailingStrategy(p.Int("trend_period"), p.Int("atr_period"), p.Float("multiplier"))
+	})
+	Register("grid", "buys each grid line the price falls through and sells each it rises through", GridSchema, func(p Params) Strategy {
+		return NewGridStrategy(p.Float("lower"), p.Float("upper"), p.Int("levels"), p.Float("allocation"))
+	})
+	Register("dca", "buys on a fixed schedule with an optional take profit", DCASchema, func(p Params) Strategy {
+		return NewDCAStrategy(p.Int("interval"), p.Float("order_pct"), p.Int("max_orders"), p.Float("take_profit"))
+	})
+	Register("mtf_trend", "EMA cross entries filtered by the trend of a longer timeframe", MTFTrendSchema, func(p Params) Strategy {
+		return NewMTFTrendStrategy(p.String("trend_timeframe"), p.Int("trend_period"), p.Int("fast"), p.Int("slow"))
+	})
+
+	registerType(TypeInfo{Type: "rules", Description: "declarative entry and exit rules in JSON or YAML, see ParseRules"},
+		func(config map[string]interface{}) (Strategy, error) {
+			rules, err := newRulesFromConfig(config)
+			if err != nil {
+				return nil, err
+			}
+			return newRuleStrategy(rules), nil
+		})
+	registerType(TypeInfo{Type: "wasm", Description: "a WebAssembly module, see examples/wasm"},
+		func(config map[string]interface{}) (Strategy, error) {
+			return nil, ErrWasmConstructor
+		})
+}
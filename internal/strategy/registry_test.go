@@ -0,0 +1,49 @@
+package strategy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypes(t *testing.T) {
+	var names []string
+	for _, info := range Types() {
+		names = append(names, info.Type)
+	}
+	assert.Equal(t, []string{"atr_trailing", "bollinger", "dca", "donchian_breakout", "grid", "ma_cross", "ma_cross_v2", "macd_trend", "mtf_trend", "rsi_reversion", "rules", "wasm"}, names)
+
+	info, ok := LookupType("bollinger")
+	require.True(t, ok)
+	data, err := json.Marshal(info)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "bollinger",
+		"description": "Bollinger band breakout or mean reversion, exiting at the middle band",
+		"schema": {"params": [
+			{"name": "period", "type": "int", "description": "moving average period in candles", "default": 20, "min": 2, "max": 500},
+			{"name": "k", "type": "float", "description": "band width in standard deviations", "default": 2, "min": 0.5, "max": 5},
+			{"name": "mode", "type": "choice", "description": "breakout buys a close above the upper band, reversion a close below the lower band", "default": "reversion", "options": ["breakout", "reversion"]}
+		]}
+	}`, string(data))
+
+	rules, _ := LookupType("rules")
+	assert.Nil(t, rules.Schema)
+	_, ok = LookupType("unknown")
+	assert.False(t, ok)
+}
+
+func TestNewStrategy_Schema(t *testing.T) {
+	_, err := NewStrategy("ma_cross", map[string]interface{}{"short_period": 30.0, "long_period": 10.0})
+	assert.EqualError(t, err, "invalid config for ma_cross: short_period must be below long_period")
+	_, err = NewStrategy("ma_cross_v2", map[string]interface{}{"short_period": 5.0})
+	assert.EqualError(t, err, "invalid config for ma_cross_v2: long_period is required")
+	_, err = NewStrategy("unknown", nil)
+	assert.EqualError(t, err, "unknown strategy type: unknown")
+
+	s, err := NewStrategy("ma_cross", map[string]interface{}{"short_period": 5.0, "long_period": 20.0})
+	require.NoError(t, err)
+	assert.Equal(t, "Moving Average Crossover", s.Name())
+}
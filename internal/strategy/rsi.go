@@ -0,0 +1,92 @@
+package strategy
+
+import (
+	"errors"
+	"quant-trader/internal/model"
+	"sync"
+)
+
+// RSISchema are the parameters of rsi_reversion
+var RSISchema = Schema{
+	Params: []Param{
+		IntParam("period", "RSI period in candles", 14, 2, 200),
+		FloatParam("oversold", "buy when RSI climbs back above this level", 30, 1, 99),
+		FloatParam("overbought", "sell when RSI falls back below this level", 70, 1, 99),
+	},
+	Check: func(p Params) error {
+		if p.Float("oversold") >= p.Float("overbought") {
+			return errors.New("oversold must be below overbought")
+		}
+		return nil
+	},
+}
+
+// RSIStrategy RSI 均值回归: buys when RSI recovers from oversold and sells
+// when it turns down from overbought
+type RSIStrategy struct {
+	mu         sync.Mutex
+	candles    candleWindow
+	period     int
+	oversold   float64
+	overbought float64
+}
+
+func NewRSIStrategy(period int, oversold, overbought float64) *RSIStrategy {
+	// RSI is smoothed over all history; four periods is where the seed stops mattering
+	return &RSIStrategy{
+		candles:    candleWindow{n: 4*period + 2},
+		period:     period,
+		oversold:   oversold,
+		overbought: overbought,
+	}
+}
+
+func (s *RSIStrategy) Name() string {
+	return "RSI_Reversion"
+}
+
+func (s *RSIStrategy) OnCandle(candle model.KLine) Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	candles := s.candles.add(candle)
+	if len(candles) < 2 {
+		return ActionHold
+	}
+	before, after := prevAndLast(candles)
+	prev, ok1 := before.RSI(s.period)
+	rsi, ok2 := after.RSI(s.period)
+	if !ok1 || !ok2 {
+		return ActionHold
+	}
+
+	p, r := prev.InexactFloat64(), rsi.InexactFloat64()
+	switch {
+	case p < s.oversold && r >= s.oversold:
+		return ActionBuy
+	case p > s.overbought && r <= s.overbought:
+		return ActionSell
+	}
+	return ActionHold
+}
+
+func (s *RSIStrategy) WarmUp() int {
+	return s.candles.n
+}
+
+func (s *RSIStrategy) Snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return windowState{Candles: s.candles.candles}.encode()
+}
+
+func (s *RSIStrategy) Restore(state []byte) error {
+	st, err := decodeState(s.Name(), state)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.candles.restore(st.Candles)
+	return nil
+}
@@ -0,0 +1,15 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRSIStrategy(t *testing.T) {
+	// Buys as the rise lifts RSI out of oversold, sells as the fall turns it down from overbought
+	assert.Equal(t, map[int]Action{12: ActionBuy, 27: ActionSell}, signals(NewRSIStrategy(5, 30, 70), wave()...))
+
+	// A steady fall never recovers
+	assert.Empty(t, signals(NewRSIStrategy(5, 30, 70), ramp(100, 50, 30)...))
+}
@@ -0,0 +1,700 @@
+package strategy
+
+import (
+	"fmt"
+	"maps"
+	"quant-trader/internal/model"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+)
+
+// A rule definition describes a strategy declaratively, as JSON or YAML:
+//
+//	name: rsi_dip
+//	side: long                  # or short
+//	entry:
+//	  all:
+//	    - lt: [{rsi: 14}, 30]
+//	    - gt: [close, {sma: 200}]
+//	exit:
+//	  any:
+//	    - gt: [{rsi: 14}, 70]
+//	    - cross_below: [{ema: 10}, {ema: 30}]
+//	stop_loss: 0.05             # fraction of the entry price
+//	take_profit: 0.1
+//	sizing: {equity_pct: 0.5}   # or qty, notional, buying_power_pct
+//
+// Conditions are gt, gte, lt, lte, cross_above and cross_below over two
+// operands, all and any over a list of conditions and not over one. Operands
+// are numbers, the candle fields open, high, low, close and volume, the
+// indicators sma, ema, rsi, atr, highest and lowest with a period, macd
+// {fast, slow, signal, line: macd|signal|hist}, bollinger {period, k, band:
+// upper|middle|lower}, and add, sub, mul and div over two operands.
+//
+// Entries are only taken when flat and exits only checked in a position.
+// Stops and targets trigger on the candle's low and high, the stop first.
+
+// ruleKeys are the top-level keys of a definition
+var ruleKeys = []string{"name", "side", "entry", "exit", "stop_loss", "take_profit", "sizing"}
+
+// ruleSet is a compiled definition, shared by RuleStrategy and its StrategyV2
+type ruleSet struct {
+	name       string
+	side       model.OrderSide
+	entry      ruleCond
+	exit       ruleCond // nil without an exit rule
+	stopLoss   decimal.Decimal
+	takeProfit decimal.Decimal
+	sizing     OrderIntent // only the size fields are set
+	window     int         // candles the rules look at
+}
+
+// ParseRules reads a definition from JSON or YAML
+func ParseRules(data []byte) (*RuleStrategy, error) {
+	var def map[string]interface{}
+	if err := yaml.Unmarshal(data, &def); err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+	return CompileRules(def)
+}
+
+// CompileRules builds a strategy from a decoded definition
+func CompileRules(def map[string]interface{}) (*RuleStrategy, error) {
+	rules, err := compileRuleSet(def)
+	if err != nil {
+		return nil, err
+	}
+	return newRuleStrategy(rules), nil
+}
+
+// newRulesFromConfig reads a "rules" config: the definition inline, or as
+// JSON or YAML text under "definition"
+func newRulesFromConfig(config map[string]interface{}) (*ruleSet, error) {
+	if text, ok := config["definition"]; ok {
+		s, ok := text.(string)
+		if !ok || len(config) > 1 {
+			return nil, fmt.Errorf("invalid config for rules: definition must be the only key and a string")
+		}
+		var def map[string]interface{}
+		if err := yaml.Unmarshal([]byte(s), &def); err != nil {
+			return nil, fmt.Errorf("invalid rules: %w", err)
+		}
+		config = def
+	}
+	return compileRuleSet(config)
+}
+
+func compileRuleSet(def map[string]interface{}) (*ruleSet, error) {
+	for _, key := range slices.Sorted(maps.Keys(def)) {
+		if !slices.Contains(ruleKeys, key) {
+			return nil, ruleError(key, "unknown key, use one of %s", strings.Join(ruleKeys, ", "))
+		}
+	}
+
+	r := &ruleSet{name: "rules", side: model.SideBuy, sizing: OrderIntent{BuyingPowerPct: 1}}
+	if name, ok := def["name"]; ok {
+		s, ok := name.(string)
+		if !ok || s == "" {
+			return nil, ruleError("name", "must be a non-empty string")
+		}
+		r.name = s
+	}
+	switch def["side"] {
+	case nil, "long":
+	case "short":
+		r.side = model.SideSell
+	default:
+		return nil, ruleError("side", "must be long or short")
+	}
+
+	if def["entry"] == nil {
+		return nil, ruleError("entry", "is required")
+	}
+	var err error
+	if r.entry, err = compileCond("entry", def["entry"]); err != nil {
+		return nil, err
+	}
+	if def["exit"] != nil {
+		if r.exit, err = compileCond("exit", def["exit"]); err != nil {
+			return nil, err
+		}
+	}
+	for key, dst := range map[string]*decimal.Decimal{"stop_loss": &r.stopLoss, "take_profit": &r.takeProfit} {
+		v, ok := def[key]
+		if !ok {
+			continue
+		}
+		f, ok := toFloat(v)
+		if !ok || f <= 0 || key == "stop_loss" && f >= 1 {
+			return nil, ruleError(key, "must be a fraction of the entry price above 0")
+		}
+		*dst = decimal.NewFromFloat(f)
+	}
+	if sizing, ok := def["sizing"]; ok {
+		if r.sizing, err = compileSizing(sizing); err != nil {
+			return nil, err
+		}
+	}
+
+	r.window = r.entry.lookback()
+	if r.exit != nil {
+		r.window = max(r.window, r.exit.lookback())
+	}
+	return r, nil
+}
+
+func compileSizing(v interface{}) (OrderIntent, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return OrderIntent{}, ruleError("sizing", "must have exactly one of qty, notional, equity_pct, buying_power_pct")
+	}
+	var intent OrderIntent
+	for key, raw := range m {
+		f, ok := toFloat(raw)
+		if !ok || f <= 0 {
+			return OrderIntent{}, ruleError("sizing."+key, "must be a positive number")
+		}
+		switch key {
+		case "qty":
+			intent.Qty = decimal.NewFromFloat(f)
+		case "notional":
+			intent.Notional = decimal.NewFromFloat(f)
+		case "equity_pct":
+			intent.EquityPct = f
+		case "buying_power_pct":
+			intent.BuyingPowerPct = f
+		default:
+			return OrderIntent{}, ruleError("sizing."+key, "unknown sizing, use qty, notional, equity_pct or buying_power_pct")
+		}
+	}
+	return intent, nil
+}
+
+// enter reports whether the entry rule holds on the last candle
+func (r *ruleSet) enter(candles []model.KLine) bool {
+	return r.entry.eval(candles)
+}
+
+// leave reports whether a position entered at entry should be closed: its
+// stop or target was touched by the last candle, or the exit rule holds
+func (r *ruleSet) leave(candles []model.KLine, entry decimal.Decimal) bool {
+	last := candles[len(candles)-1]
+	one := decimal.NewFromInt(1)
+	long := r.side == model.SideBuy
+	if !r.stopLoss.IsZero() && entry.IsPositive() {
+		if long && last.Low.LessThanOrEqual(entry.Mul(one.Sub(r.stopLoss))) ||
+			!long && last.High.GreaterThanOrEqual(entry.Mul(one.Add(r.stopLoss))) {
+			return true
+		}
+	}
+	if !r.takeProfit.IsZero() && entry.IsPositive() {
+		if long && last.High.GreaterThanOrEqual(entry.Mul(one.Add(r.takeProfit))) ||
+			!long && last.Low.LessThanOrEqual(entry.Mul(one.Sub(r.takeProfit))) {
+			return true
+		}
+	}
+	return r.exit != nil && r.exit.eval(candles)
+}
+
+// RuleStrategy runs a rule definition as an action-based strategy. It keeps
+// a virtual position of its own for stops and targets; sizing only applies
+// in the event-driven runtime, see NewStrategyV2.
+type RuleStrategy struct {
+	mu      sync.Mutex
+	rules   *ruleSet
+	candles candleWindow
+	entry   decimal.Decimal // zero when flat
+}
+
+func newRuleStrategy(rules *ruleSet) *RuleStrategy {
+	return &RuleStrategy{rules: rules, candles: candleWindow{n: rules.window}}
+}
+
+func (s *RuleStrategy) Name() string {
+	return s.rules.name
+}
+
+func (s *RuleStrategy) OnCandle(candle model.KLine) Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	candles := s.candles.add(candle)
+
+	enter, exit := ActionBuy, ActionSell
+	if s.rules.side == model.SideSell {
+		enter, exit = ActionSell, ActionBuy
+	}
+	if s.entry.IsZero() {
+		if s.rules.enter(candles) {
+			s.entry = candle.Close
+			return enter
+		}
+		return ActionHold
+	}
+	if s.rules.leave(candles, s.entry) {
+		s.entry = decimal.Zero
+		return exit
+	}
+	return ActionHold
+}
+
+// V2 runs the same definition on the event-driven runtime, sized by its
+// sizing and with stops and targets against the broker's position
+func (s *RuleStrategy) V2() StrategyV2 {
+	return &ruleEvents{rules: s.rules, history: NewHistoryBuffer(s.rules.window)}
+}
+
+// ruleEvents is the StrategyV2 of a rule definition
+type ruleEvents struct {
+	rules   *ruleSet
+	history *HistoryBuffer
+}
+
+func (s *ruleEvents) Name() string                       { return s.rules.name }
+func (s *ruleEvents) Init(Context) error                 { return nil }
+func (s *ruleEvents) OnTrade(Context, model.Trade)       {}
+func (s *ruleEvents) OnOrderUpdate(Context, model.Order) {}
+func (s *ruleEvents) OnTimer(Context, time.Time)         {}
+
+func (s *ruleEvents) OnCandle(ctx Context, candle model.KLine) {
+	s.history.Add(candle)
+	if len(ctx.OpenOrders(candle.Symbol)) > 0 {
+		return
+	}
+	candles := s.history.History(candle.Symbol, "", s.rules.window)
+	pos := ctx.Position(candle.Symbol)
+	long := s.rules.side == model.SideBuy
+	switch {
+	case pos.Qty.IsZero():
+		if s.rules.enter(candles) {
+			intent := s.rules.sizing
+			intent.Symbol, intent.Side = candle.Symbol, s.rules.side
+			ctx.Submit(intent)
+		}
+	case pos.Qty.IsPositive() == long:
+		if s.rules.leave(candles, pos.EntryPrice) {
+			ctx.Submit(CloseIntent(pos))
+		}
+	}
+}
+
+func ruleError(path, format string, args ...interface{}) error {
+	return fmt.Errorf("invalid rules at %s: %s", path, fmt.Sprintf(format, args...))
+}
+
+// ruleCond is a compiled condition over candles, oldest first
+type ruleCond interface {
+	eval(candles []model.KLine) bool
+	// lookback is how many candles eval needs to see
+	lookback() int
+}
+
+// ruleValue is a compiled operand; ok is false until there is enough history
+type ruleValue interface {
+	value(candles []model.KLine) (v float64, ok bool)
+	lookback() int
+}
+
+// single returns the only key of a node such as {gt: [a, b]}
+func single(path string, node interface{}) (string, interface{}, error) {
+	m, ok := node.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return "", nil, ruleError(path, "must be an object with exactly one key")
+	}
+	for k, v := range m {
+		return k, v, nil
+	}
+	return "", nil, nil
+}
+
+func compileCond(path string, node interface{}) (ruleCond, error) {
+	op, arg, err := single(path, node)
+	if err != nil {
+		return nil, err
+	}
+	path += "." + op
+	switch op {
+	case "all", "any":
+		list, ok := arg.([]interface{})
+		if !ok || len(list) == 0 {
+			return nil, ruleError(path, "must be a non-empty list of conditions")
+		}
+		conds := make([]ruleCond, len(list))
+		for i, item := range list {
+			if conds[i], err = compileCond(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return nil, err
+			}
+		}
+		return &ruleLogic{any: op == "any", conds: conds}, nil
+	case "not":
+		cond, err := compileCond(path, arg)
+		if err != nil {
+			return nil, err
+		}
+		return &ruleNot{cond: cond}, nil
+	case "gt", "gte", "lt", "lte", "cross_above", "cross_below":
+		a, b, err := compilePair(path, arg)
+		if err != nil {
+			return nil, err
+		}
+		return &ruleCompare{op: op, a: a, b: b}, nil
+	}
+	return nil, ruleError(path, "unknown condition, use all, any, not, gt, gte, lt, lte, cross_above or cross_below")
+}
+
+func compilePair(path string, arg interface{}) (ruleValue, ruleValue, error) {
+	list, ok := arg.([]interface{})
+	if !ok || len(list) != 2 {
+		return nil, nil, ruleError(path, "must be a list of two operands")
+	}
+	a, err := compileValue(path+"[0]", list[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := compileValue(path+"[1]", list[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, b, nil
+}
+
+func compileValue(path string, node interface{}) (ruleValue, error) {
+	if f, ok := toFloat(node); ok {
+		return ruleConst(f), nil
+	}
+	if field, ok := node.(string); ok {
+		switch field {
+		case "open", "high", "low", "close", "volume":
+			return ruleField(field), nil
+		}
+		return nil, ruleError(path, "unknown field %q, use open, high, low, close or volume", field)
+	}
+
+	name, arg, err := single(path, node)
+	if err != nil {
+		return nil, ruleError(path, "must be a number, a candle field or an indicator")
+	}
+	path += "." + name
+	switch name {
+	case "add", "sub", "mul", "div":
+		a, b, err := compilePair(path, arg)
+		if err != nil {
+			return nil, err
+		}
+		return &ruleArith{op: name, a: a, b: b}, nil
+	case "sma", "ema", "rsi", "atr", "highest", "lowest":
+		params, err := ruleParams(path, arg, "period")
+		if err != nil {
+			return nil, err
+		}
+		period, err := params.period("period", 0)
+		if err != nil {
+			return nil, err
+		}
+		return &ruleIndicator{kind: name, period: period}, nil
+	case "macd":
+		params, err := ruleParams(path, arg, "")
+		if err != nil {
+			return nil, err
+		}
+		in := &ruleIndicator{kind: name, line: "macd"}
+		if in.fast, err = params.period("fast", 12); err != nil {
+			return nil, err
+		}
+		if in.slow, err = params.period("slow", 26); err != nil {
+			return nil, err
+		}
+		if in.period, err = params.period("signal", 9); err != nil {
+			return nil, err
+		}
+		if in.slow <= in.fast {
+			return nil, ruleError(path, "slow must be above fast")
+		}
+		if in.line, err = params.choice("line", "macd", "macd", "signal", "hist"); err != nil {
+			return nil, err
+		}
+		return in, params.done()
+	case "bollinger":
+		params, err := ruleParams(path, arg, "period")
+		if err != nil {
+			return nil, err
+		}
+		in := &ruleIndicator{kind: name, k: 2}
+		if in.period, err = params.period("period", 20); err != nil {
+			return nil, err
+		}
+		if k, ok := params.values["k"]; ok {
+			if in.k, ok = toFloat(k); !ok || in.k <= 0 {
+				return nil, ruleError(path+".k", "must be a positive number")
+			}
+			delete(params.values, "k")
+		}
+		if in.line, err = params.choice("band", "middle", "upper", "middle", "lower"); err != nil {
+			return nil, err
+		}
+		return in, params.done()
+	}
+	return nil, ruleError(path, "unknown operand, use a number, open, high, low, close, volume, sma, ema, rsi, atr, highest, lowest, macd, bollinger, add, sub, mul or div")
+}
+
+// ruleArgs are the parameters of an indicator, consumed as they are read
+type ruleArgs struct {
+	path   string
+	values map[string]interface{}
+}
+
+// ruleParams reads {period: 14, ...}, or a bare number as the value of short
+func ruleParams(path string, arg interface{}, short string) (*ruleArgs, error) {
+	if _, ok := toFloat(arg); ok && short != "" {
+		return &ruleArgs{path: path, values: map[string]interface{}{short: arg}}, nil
+	}
+	m, ok := arg.(map[string]interface{})
+	if !ok {
+		return nil, ruleError(path, "must be a period or an object of parameters")
+	}
+	return &ruleArgs{path: path, values: maps.Clone(m)}, nil
+}
+
+// period reads a positive whole number, def when unset; 0 makes it required
+func (a *ruleArgs) period(key string, def int) (int, error) {
+	v, ok := a.values[key]
+	if !ok {
+		if def == 0 {
+			return 0, ruleError(a.path, "needs %s", key)
+		}
+		return def, nil
+	}
+	delete(a.values, key)
+	f, ok := toFloat(v)
+	if !ok || f < 1 || f != float64(int(f)) {
+		return 0, ruleError(a.path+"."+key, "must be a whole number of candles")
+	}
+	return int(f), nil
+}
+
+func (a *ruleArgs) choice(key, def string, options ...string) (string, error) {
+	v, ok := a.values[key]
+	if !ok {
+		return def, nil
+	}
+	delete(a.values, key)
+	s, _ := v.(string)
+	if !slices.Contains(options, s) {
+		return "", ruleError(a.path+"."+key, "must be one of %s", strings.Join(options, ", "))
+	}
+	return s, nil
+}
+
+// done rejects parameters nothing read
+func (a *ruleArgs) done() error {
+	for _, key := range slices.Sorted(maps.Keys(a.values)) {
+		return ruleError(a.path+"."+key, "unknown parameter")
+	}
+	return nil
+}
+
+type ruleLogic struct {
+	any   bool
+	conds []ruleCond
+}
+
+func (c *ruleLogic) eval(candles []model.KLine) bool {
+	for _, cond := range c.conds {
+		if cond.eval(candles) == c.any {
+			return c.any
+		}
+	}
+	return !c.any
+}
+
+func (c *ruleLogic) lookback() int {
+	n := 0
+	for _, cond := range c.conds {
+		n = max(n, cond.lookback())
+	}
+	return n
+}
+
+type ruleNot struct {
+	cond ruleCond
+}
+
+func (c *ruleNot) eval(candles []model.KLine) bool { return !c.cond.eval(candles) }
+func (c *ruleNot) lookback() int                   { return c.cond.lookback() }
+
+// ruleCompare is false while either operand is unavailable
+type ruleCompare struct {
+	op   string
+	a, b ruleValue
+}
+
+func (c *ruleCompare) eval(candles []model.KLine) bool {
+	a, ok1 := c.a.value(candles)
+	b, ok2 := c.b.value(candles)
+	if !ok1 || !ok2 {
+		return false
+	}
+	switch c.op {
+	case "gt":
+		return a > b
+	case "gte":
+		return a >= b
+	case "lt":
+		return a < b
+	case "lte":
+		return a <= b
+	}
+
+	// Crosses compare with the previous candle
+	if len(candles) < 2 {
+		return false
+	}
+	prevA, ok1 := c.a.value(candles[:len(candles)-1])
+	prevB, ok2 := c.b.value(candles[:len(candles)-1])
+	if !ok1 || !ok2 {
+		return false
+	}
+	if c.op == "cross_above" {
+		return prevA <= prevB && a > b
+	}
+	return prevA >= prevB && a < b
+}
+
+func (c *ruleCompare) lookback() int {
+	n := max(c.a.lookback(), c.b.lookback())
+	if strings.HasPrefix(c.op, "cross_") {
+		n++
+	}
+	return n
+}
+
+type ruleConst float64
+
+func (v ruleConst) value([]model.KLine) (float64, bool) { return float64(v), true }
+func (v ruleConst) lookback() int                       { return 1 }
+
+type ruleField string
+
+func (f ruleField) value(candles []model.KLine) (float64, bool) {
+	if len(candles) == 0 {
+		return 0, false
+	}
+	c := candles[len(candles)-1]
+	switch f {
+	case "open":
+		return c.Open.InexactFloat64(), true
+	case "high":
+		return c.High.InexactFloat64(), true
+	case "low":
+		return c.Low.InexactFloat64(), true
+	case "volume":
+		return c.Volume.InexactFloat64(), true
+	}
+	return c.Close.InexactFloat64(), true
+}
+
+func (f ruleField) lookback() int { return 1 }
+
+type ruleArith struct {
+	op   string
+	a, b ruleValue
+}
+
+func (v *ruleArith) value(candles []model.KLine) (float64, bool) {
+	a, ok1 := v.a.value(candles)
+	b, ok2 := v.b.value(candles)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	switch v.op {
+	case "add":
+		return a + b, true
+	case "sub":
+		return a - b, true
+	case "mul":
+		return a * b, true
+	}
+	if b == 0 {
+		return 0, false
+	}
+	return a / b, true
+}
+
+func (v *ruleArith) lookback() int { return max(v.a.lookback(), v.b.lookback()) }
+
+// ruleIndicator evaluates one of the Indicators. Recursive indicators (EMA,
+// RSI, ATR, MACD) see four times their period, which is where the seed stops
+// mattering.
+type ruleIndicator struct {
+	kind   string
+	period int // the signal period of macd
+	fast   int
+	slow   int
+	k      float64
+	line   string // of macd and bollinger
+}
+
+func (v *ruleIndicator) value(candles []model.KLine) (float64, bool) {
+	in := NewIndicators(lastN(candles, v.lookback()))
+	var d decimal.Decimal
+	var ok bool
+	switch v.kind {
+	case "sma":
+		d, ok = in.SMA(v.period)
+	case "ema":
+		d, ok = in.EMA(v.period)
+	case "rsi":
+		d, ok = in.RSI(v.period)
+	case "highest":
+		d, ok = in.Highest(v.period)
+	case "lowest":
+		d, ok = in.Lowest(v.period)
+	case "atr":
+		return in.ATR(v.period)
+	case "macd":
+		macd, signal, hist, ok := in.MACD(v.fast, v.slow, v.period)
+		d = map[string]decimal.Decimal{"macd": macd, "signal": signal, "hist": hist}[v.line]
+		return d.InexactFloat64(), ok
+	case "bollinger":
+		middle, upper, lower, ok := in.Bollinger(v.period, v.k)
+		return map[string]float64{"middle": middle, "upper": upper, "lower": lower}[v.line], ok
+	}
+	return d.InexactFloat64(), ok
+}
+
+func (v *ruleIndicator) lookback() int {
+	switch v.kind {
+	case "ema", "rsi", "atr":
+		return 4*v.period + 1
+	case "macd":
+		return 4*v.slow + v.period
+	}
+	return v.period
+}
+
+func (s *RuleStrategy) WarmUp() int {
+	return s.candles.n
+}
+
+func (s *RuleStrategy) Snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return windowState{Candles: s.candles.candles, Entry: s.entry}.encode()
+}
+
+func (s *RuleStrategy) Restore(state []byte) error {
+	st, err := decodeState(s.Name(), state)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.candles.restore(st.Candles)
+	s.entry = st.Entry
+	return nil
+}
@@ -0,0 +1,140 @@
+package strategy
+
+import (
+	"quant-trader/internal/model"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ruleCandle is a candle closing at close whose range reaches low and high
+func ruleCandle(close, low, high int64) model.KLine {
+	return model.KLine{
+		Symbol: "BTCUSDT",
+		Period: "1m",
+		Open:   decimal.NewFromInt(close),
+		Close:  decimal.NewFromInt(close),
+		Low:    decimal.NewFromInt(low),
+		High:   decimal.NewFromInt(high),
+	}
+}
+
+func TestRules_YAMLCross(t *testing.T) {
+	s, err := NewStrategy("rules", map[string]interface{}{"definition": `
+name: sma_cross
+entry:
+  cross_above: [{sma: 2}, {sma: 3}]
+exit:
+  cross_below: [{sma: 2}, {sma: {period: 3}}]
+`})
+	require.NoError(t, err)
+	assert.Equal(t, "sma_cross", s.Name())
+
+	want := map[int]Action{4: ActionBuy, 7: ActionSell}
+	for i, price := range []int64{10, 10, 10, 10, 12, 12, 12, 8, 8} {
+		action, ok := want[i]
+		if !ok {
+			action = ActionHold
+		}
+		assert.Equal(t, action, s.OnCandle(ruleCandle(price, price, price)), "candle %d", i)
+	}
+}
+
+func TestRules_StopLossAndTakeProfit(t *testing.T) {
+	// Decoded from JSON, so numbers are float64
+	s, err := NewStrategy("rules", map[string]interface{}{
+		"entry":       map[string]interface{}{"gt": []interface{}{"close", 0.0}},
+		"stop_loss":   0.1,
+		"take_profit": 0.2,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, ActionBuy, s.OnCandle(ruleCandle(100, 100, 100)))
+	assert.Equal(t, ActionHold, s.OnCandle(ruleCandle(95, 95, 110)))
+	assert.Equal(t, ActionSell, s.OnCandle(ruleCandle(92, 89, 100)), "stop at 90")
+	assert.Equal(t, ActionBuy, s.OnCandle(ruleCandle(100, 100, 100)))
+	assert.Equal(t, ActionSell, s.OnCandle(ruleCandle(110, 100, 121)), "target at 120")
+
+	short, err := ParseRules([]byte(`{"side": "short", "entry": {"gt": ["close", 0]}, "stop_loss": 0.1}`))
+	require.NoError(t, err)
+	assert.Equal(t, ActionSell, short.OnCandle(ruleCandle(100, 100, 100)))
+	assert.Equal(t, ActionBuy, short.OnCandle(ruleCandle(105, 100, 111)))
+}
+
+func TestRules_Indicators(t *testing.T) {
+	s, err := ParseRules([]byte(`
+entry:
+  all:
+    - gt: [{macd: {fast: 3, slow: 6, signal: 2, line: hist}}, 0]
+    - lt: [close, {mul: [{bollinger: {period: 5, band: upper}}, 1.5]}]
+    - not: {lt: [{rsi: 3}, 50]}
+    - gte: [{highest: 3}, {lowest: 3}]
+    - gt: [{ema: 3}, {sub: [{atr: 3}, 1000]}]
+exit:
+  lt: [close, {div: [{sma: 3}, 2]}]
+`))
+	require.NoError(t, err)
+
+	var actions []Action
+	for i := int64(0); i < 40; i++ {
+		actions = append(actions, s.OnCandle(ruleCandle(100+i, 99+i, 101+i)))
+	}
+	assert.Equal(t, ActionHold, actions[0], "indicators need history first")
+	assert.Contains(t, actions, ActionBuy, "a steady rise turns every entry condition true")
+	assert.NotContains(t, actions, ActionSell)
+}
+
+func TestRules_Invalid(t *testing.T) {
+	cases := map[string]string{
+		`{}`:                                "entry: is required",
+		`{entry: {gt: [close, 1]}, foo: 1}`: "at foo: unknown key",
+		`{entry: {gt: [close]}}`:            "at entry.gt: must be a list of two operands",
+		`{entry: {all: [{gt: [close, 1]}, {gt: [{smaa: 3}, 1]}]}}`: "at entry.all[1].gt[0].smaa: unknown operand",
+		`{entry: {gt: [closing, 1]}}`:                              `at entry.gt[0]: unknown field "closing"`,
+		`{entry: {gt: [{sma: 2.5}, 1]}}`:                           "at entry.gt[0].sma.period: must be a whole number",
+		`{entry: {gt: [{macd: {fast: 26, slow: 12}}, 0]}}`:         "slow must be above fast",
+		`{entry: {gt: [{bollinger: {band: top}}, 0]}}`:             "at entry.gt[0].bollinger.band: must be one of upper, middle, lower",
+		`{entry: {gt: [{bollinger: {width: 2}}, 0]}}`:              "at entry.gt[0].bollinger.width: unknown parameter",
+		`{entry: {between: [close, 1]}}`:                           "at entry.between: unknown condition",
+		`{entry: {gt: [close, 1]}, stop_loss: 1.5}`:                "at stop_loss",
+		`{entry: {gt: [close, 1]}, sizing: {qty: 1, notional: 5}}`: "at sizing: must have exactly one",
+		`{entry: {gt: [close, 1]}, sizing: {lots: 1}}`:             "at sizing.lots: unknown sizing",
+		`{entry: {gt: [close, 1]}, side: flat}`:                    "at side: must be long or short",
+		`entry: [`:                                                 "invalid rules",
+	}
+	for def, msg := range cases {
+		_, err := NewStrategy("rules", map[string]interface{}{"definition": def})
+		assert.ErrorContains(t, err, msg, def)
+	}
+
+	_, err := NewStrategy("rules", map[string]interface{}{"definition": 1.0})
+	assert.ErrorContains(t, err, "definition must be the only key and a string")
+}
+
+func TestRules_V2(t *testing.T) {
+	s, err := NewStrategyV2("rules", map[string]interface{}{"definition": `
+entry: {gt: [close, 0]}
+stop_loss: 0.1
+sizing: {equity_pct: 0.5}
+`})
+	require.NoError(t, err)
+	assert.Nil(t, Legacy(s), "rules run natively")
+
+	ctx := &fakeContext{}
+	s.OnCandle(ctx, ruleCandle(100, 100, 100))
+	require.Len(t, ctx.submitted, 1)
+	assert.Equal(t, OrderIntent{Symbol: "BTCUSDT", Side: model.SideBuy, EquityPct: 0.5}, ctx.submitted[0])
+
+	// Stops follow the broker's entry price, not the signal's close
+	ctx = &fakeContext{pos: PositionInfo{Symbol: "BTCUSDT", Qty: decimal.NewFromInt(2), EntryPrice: decimal.NewFromInt(110)}}
+	s.OnCandle(ctx, ruleCandle(100, 98, 100))
+	require.Len(t, ctx.submitted, 1)
+	assert.Equal(t, CloseIntent(ctx.pos), ctx.submitted[0])
+
+	ctx.submitted = nil
+	ctx.open = []model.Order{{ID: 1}}
+	s.OnCandle(ctx, ruleCandle(50, 50, 50))
+	assert.Empty(t, ctx.submitted, "waits for open orders")
+}
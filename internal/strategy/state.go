@@ -0,0 +1,72 @@
+package strategy
+
+import (
+	"encoding/json"
+	"fmt"
+	"quant-trader/internal/model"
+	"slices"
+
+	"github.com/shopspring/decimal"
+)
+
+// WarmUpStrategy reads history before its signals mean anything. Runtimes
+// feed it the last WarmUp candles of its period before going live.
+type WarmUpStrategy interface {
+	Strategy
+	WarmUp() int
+}
+
+// StatefulStrategy checkpoints what it keeps between candles, so a restart
+// picks up where it left off. Restore is called before the first candle.
+type StatefulStrategy interface {
+	Strategy
+	Snapshot() ([]byte, error)
+	Restore(state []byte) error
+}
+
+// windowState is the snapshot of the built-in strategies; each sets only
+// the fields it keeps
+type windowState struct {
+	Candles    []model.KLine     `json:"candles,omitempty"`
+	Prices     []decimal.Decimal `json:"prices,omitempty"`
+	LastAction Action            `json:"last_action,omitempty"`
+	Long       bool              `json:"long,omitempty"`
+	Stop       float64           `json:"stop,omitempty"`
+	Entry      decimal.Decimal   `json:"entry,omitempty"`
+	Cell       int               `json:"cell,omitempty"`
+	Started    bool              `json:"started,omitempty"`
+	Held       int               `json:"held,omitempty"`
+	Count      int               `json:"count,omitempty"`
+	Buys       int               `json:"buys,omitempty"`
+	Units      decimal.Decimal   `json:"units,omitempty"`
+}
+
+func (st windowState) encode() ([]byte, error) {
+	return json.Marshal(st)
+}
+
+func decodeState(name string, data []byte) (windowState, error) {
+	var st windowState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("invalid %s state: %w", name, err)
+	}
+	return st, nil
+}
+
+// restore refills the window from a snapshot
+func (w *candleWindow) restore(candles []model.KLine) {
+	w.candles = slices.Clone(lastN(candles, w.n))
+}
+
+// WarmUpWindow is how many candles of sub.Period s needs before it goes live:
+// its WarmUp, or for a MultiTimeframeStrategy all its view keeps
+func WarmUpWindow(s Strategy, sub Subscription) int {
+	n := 0
+	if w, ok := s.(WarmUpStrategy); ok {
+		n = w.WarmUp()
+	}
+	if _, ok := s.(MultiTimeframeStrategy); ok {
+		n = max(n, sub.window())
+	}
+	return n
+}
@@ -0,0 +1,66 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatefulStrategies_Resume(t *testing.T) {
+	configs := map[string]map[string]interface{}{
+		"ma_cross":          {"short_period": 3.0, "long_period": 8.0},
+		"ma_cross_v2":       {"short_period": 3.0, "long_period": 8.0},
+		"rsi_reversion":     {"period": 5.0, "oversold": 30.0, "overbought": 70.0},
+		"bollinger":         {"period": 8.0, "k": 1.5},
+		"macd_trend":        {"fast": 3.0, "slow": 6.0, "signal": 3.0},
+		"donchian_breakout": {"entry_period": 5.0, "exit_period": 3.0},
+		"atr_trailing":      {"trend_period": 5.0, "atr_period": 3.0, "multiplier": 1.0},
+		"grid":              {"lower": 80.0, "upper": 110.0, "levels": 6.0},
+		"dca":               {"interval": 4.0, "take_profit": 0.1},
+		"rules":             {"definition": `{"entry": {"cross_above": [{"sma": 2}, {"sma": 5}]}, "exit": {"cross_below": [{"sma": 2}, {"sma": 5}]}}`},
+	}
+	candles := minuteCandles(0, append(wave(), wave()...)...)
+	split := 30
+
+	for typ, config := range configs {
+		t.Run(typ, func(t *testing.T) {
+			before, err := NewStrategy(typ, config)
+			require.NoError(t, err)
+			for _, c := range candles[:split] {
+				before.OnCandle(c)
+			}
+			state, err := before.(StatefulStrategy).Snapshot()
+			require.NoError(t, err)
+
+			// A fresh instance restored from the snapshot carries on the same
+			after, err := NewStrategy(typ, config)
+			require.NoError(t, err)
+			require.NoError(t, after.(StatefulStrategy).Restore(state))
+			var want, got []Action
+			for _, c := range candles[split:] {
+				want = append(want, before.OnCandle(c))
+				got = append(got, after.OnCandle(c))
+			}
+			assert.Contains(t, want, ActionBuy, "the rest of the data should trade")
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestStatefulStrategies_InvalidState(t *testing.T) {
+	s := NewRSIStrategy(5, 30, 70)
+	assert.ErrorContains(t, s.Restore([]byte("{")), "invalid RSI_Reversion state")
+}
+
+func TestWarmUpWindow(t *testing.T) {
+	sub := Subscription{Symbols: []string{"BTCUSDT"}, Period: "1m"}
+	assert.Equal(t, 9, WarmUpWindow(NewMACrossStrategy(3, 8), sub))
+	assert.Equal(t, 0, WarmUpWindow(NewGridStrategy(80, 110, 6, 1), sub))
+
+	// A multi-timeframe view needs every 1m candle behind its 15m lookback
+	mtf := NewMTFTrendStrategy("15m", 2, 2, 3)
+	periods, lookback := mtf.ContextPeriods()
+	sub.Context, sub.Lookback = periods, lookback
+	assert.Equal(t, (lookback+1)*15, WarmUpWindow(mtf, sub))
+}
@@ -0,0 +1,115 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"quant-trader/internal/model"
+	"slices"
+)
+
+// DefaultTimeframeLookback is how many bars of its longest period a
+// subscription keeps when it does not say
+const DefaultTimeframeLookback = 200
+
+// MaxTimeframeWindow bounds the candles a subscription keeps per symbol
+const MaxTimeframeWindow = 100_000
+
+// Subscription is what a live strategy consumes: the candles of Period for
+// each of Symbols. Context periods are longer timeframes the strategy reads
+// through its Timeframes view; they are resampled from Period, so they are in
+// sync with the candle being handled.
+type Subscription struct {
+	Symbols []string `json:"symbols"`
+	Period  string   `json:"period"`
+	Context []string `json:"context,omitempty"`
+	// Lookback is how many bars of the longest period the view keeps
+	Lookback int `json:"lookback,omitempty"`
+}
+
+func (s Subscription) Validate() error {
+	if len(s.Symbols) == 0 {
+		return errors.New("subscription needs at least one symbol")
+	}
+	if !slices.Contains(model.SupportedPeriods, s.Period) {
+		return fmt.Errorf("unsupported subscription period: %q", s.Period)
+	}
+	step := model.PeriodToDuration(s.Period)
+	for _, period := range s.Context {
+		if !slices.Contains(model.SupportedPeriods, period) {
+			return fmt.Errorf("unsupported context period: %q", period)
+		}
+		if d := model.PeriodToDuration(period); d <= step || d%step != 0 {
+			return fmt.Errorf("context period %s must be a multiple of %s", period, s.Period)
+		}
+	}
+	if s.Lookback < 0 || s.window() > MaxTimeframeWindow {
+		return fmt.Errorf("subscription keeps more than %d candles of %s", MaxTimeframeWindow, s.Period)
+	}
+	return nil
+}
+
+// Matches reports whether candle is one the subscription delivers
+func (s Subscription) Matches(candle model.KLine) bool {
+	return candle.Period == s.Period && slices.Contains(s.Symbols, candle.Symbol)
+}
+
+// window is how many candles of Period serve Lookback bars of every period
+func (s Subscription) window() int {
+	lookback := s.Lookback
+	if lookback == 0 {
+		lookback = DefaultTimeframeLookback
+	}
+	step := model.PeriodToDuration(s.Period)
+	longest := step
+	for _, period := range s.Context {
+		longest = max(longest, model.PeriodToDuration(period))
+	}
+	// One more bar covers the one Resample drops when the data starts inside it
+	return (lookback + 1) * int(longest/step)
+}
+
+// Timeframes is a synchronized view of a strategy's candles at several
+// periods: while a candle is handled it holds only bars closed by then.
+// Context implements it for backtests, NewTimeframes for the live runner.
+type Timeframes interface {
+	// History returns up to n closed candles of symbol at period, oldest first
+	History(symbol, period string, n int) []model.KLine
+	// Indicators computes indicators over the History of symbol at period
+	Indicators(symbol, period string) Indicators
+}
+
+// MultiTimeframeStrategy is an action-based strategy that reads longer
+// timeframes. Runtimes that have a view call OnCandleWith instead of
+// OnCandle, after the view includes candle.
+type MultiTimeframeStrategy interface {
+	Strategy
+	// ContextPeriods are the longer periods the strategy reads and how many
+	// bars of the longest it needs
+	ContextPeriods() (periods []string, lookback int)
+	OnCandleWith(view Timeframes, candle model.KLine) Action
+}
+
+// TimeframeWindow is how many candles of period the view of s must keep, 0
+// when s reads no other timeframes
+func TimeframeWindow(s StrategyV2, period string) int {
+	mtf, ok := Unadapt(s).(MultiTimeframeStrategy)
+	if !ok {
+		return 0
+	}
+	context, lookback := mtf.ContextPeriods()
+	return Subscription{Period: period, Context: context, Lookback: lookback}.window()
+}
+
+// LiveTimeframes is the Timeframes view of a subscription in the live runner
+type LiveTimeframes struct {
+	*HistoryBuffer
+}
+
+// NewTimeframes keeps enough candles of sub for its Lookback
+func NewTimeframes(sub Subscription) *LiveTimeframes {
+	return &LiveTimeframes{HistoryBuffer: NewHistoryBuffer(sub.window())}
+}
+
+func (t *LiveTimeframes) Indicators(symbol, period string) Indicators {
+	return NewIndicators(t.History(symbol, period, 0))
+}
@@ -0,0 +1,64 @@
+package strategy
+
+import (
+	"quant-trader/internal/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscription_Validate(t *testing.T) {
+	assert.NoError(t, Subscription{Symbols: []string{"BTCUSDT"}, Period: "1m", Context: []string{"5m", "1h"}}.Validate())
+
+	for _, tc := range []struct {
+		sub  Subscription
+		want string
+	}{
+		{Subscription{Period: "1m"}, "at least one symbol"},
+		{Subscription{Symbols: []string{"BTCUSDT"}, Period: "7m"}, "unsupported subscription period"},
+		{Subscription{Symbols: []string{"BTCUSDT"}, Period: "5m", Context: []string{"1m"}}, "must be a multiple of 5m"},
+		{Subscription{Symbols: []string{"BTCUSDT"}, Period: "1m", Context: []string{"1d"}, Lookback: 200}, "more than 100000 candles"},
+	} {
+		err := tc.sub.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), tc.want)
+	}
+}
+
+func TestSubscription_Matches(t *testing.T) {
+	sub := Subscription{Symbols: []string{"BTCUSDT", "ETHUSDT"}, Period: "1m"}
+	candle := minuteCandles(0, 100)[0]
+	assert.True(t, sub.Matches(candle))
+
+	candle.Period = "5m"
+	assert.False(t, sub.Matches(candle))
+	candle.Period, candle.Symbol = "1m", "SOLUSDT"
+	assert.False(t, sub.Matches(candle))
+}
+
+func TestLiveTimeframes(t *testing.T) {
+	// Two 5m bars, plus the one a window starting mid-bar drops: 15 candles
+	view := NewTimeframes(Subscription{Symbols: []string{"BTCUSDT"}, Period: "1m", Context: []string{"5m"}, Lookback: 2})
+	closes := func(bars []model.KLine) []string {
+		out := make([]string, len(bars))
+		for i, b := range bars {
+			out[i] = b.Close.String()
+		}
+		return out
+	}
+
+	// Only 5m bars closed by the last candle are seen
+	for _, c := range minuteCandles(0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14) {
+		view.Add(c)
+	}
+	assert.Equal(t, []string{"5", "10"}, closes(view.History("BTCUSDT", "5m", 0)))
+	assert.Equal(t, 2, view.Indicators("BTCUSDT", "5m").Len())
+
+	view.Add(minuteCandles(14, 15)[0])
+	assert.Equal(t, []string{"5", "10", "15"}, closes(view.History("BTCUSDT", "5m", 0)))
+
+	// Past the window the oldest bar is partial and dropped
+	view.Add(minuteCandles(15, 16)[0])
+	assert.Equal(t, []string{"10", "15"}, closes(view.History("BTCUSDT", "5m", 0)))
+}
@@ -0,0 +1,209 @@
+package strategy
+
+import (
+	"errors"
+	"quant-trader/internal/model"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// StrategyV2 is an event-driven strategy. Unlike Strategy it sees its position
+// and account through a Context and places its own sized orders.
+type StrategyV2 interface {
+	Name() string
+	// Init is called once before the first event, e.g. to set a timer
+	Init(ctx Context) error
+	// OnCandle is called for every closed candle of the strategy's symbols
+	OnCandle(ctx Context, candle model.KLine)
+	// OnTrade is called for every public trade when the runtime replays ticks
+	OnTrade(ctx Context, trade model.Trade)
+	// OnOrderUpdate is called when an order placed through ctx fills or ends
+	OnOrderUpdate(ctx Context, order model.Order)
+	// OnTimer is called at every boundary of the interval set with SetTimer
+	OnTimer(ctx Context, now time.Time)
+}
+
+// Context is what a StrategyV2 sees of the market and its account
+type Context interface {
+	// Now is the time of the current event; zero during Init
+	Now() time.Time
+	Position(symbol string) PositionInfo
+	Account() AccountInfo
+	// History returns up to n closed candles of symbol, oldest first. Periods
+	// longer than the feed are resampled from it; shorter ones return nothing.
+	History(symbol, period string, n int) []model.KLine
+	// Indicators computes indicators over the whole History of symbol at period
+	Indicators(symbol, period string) Indicators
+	OpenOrders(symbol string) []model.Order
+	// Size resolves the quantity of intent against the account and last price
+	Size(intent OrderIntent) (model.OrderRequest, error)
+	// Submit sizes intent and places the order
+	Submit(intent OrderIntent) (int64, error)
+	Cancel(orderID int64) bool
+	// SetTimer makes OnTimer fire every interval; zero stops it
+	SetTimer(interval time.Duration)
+}
+
+// PositionInfo is the holding of one symbol; Qty is negative for shorts
+type PositionInfo struct {
+	Symbol        string          `json:"symbol"`
+	Qty           decimal.Decimal `json:"qty"`
+	EntryPrice    decimal.Decimal `json:"entry_price"`
+	UnrealizedPnL decimal.Decimal `json:"unrealized_pnl"`
+}
+
+// AccountInfo is the balance side of the account
+type AccountInfo struct {
+	Cash        decimal.Decimal `json:"cash"`
+	Equity      decimal.Decimal `json:"equity"`
+	BuyingPower decimal.Decimal `json:"buying_power"`
+	Leverage    decimal.Decimal `json:"leverage"`
+	AllowShort  bool            `json:"allow_short"`
+}
+
+// OrderIntent is an order whose quantity is still to be sized. Exactly one of
+// Qty, Notional, EquityPct and BuyingPowerPct must be set.
+type OrderIntent struct {
+	Symbol     string            `json:"symbol"`
+	Side       model.OrderSide   `json:"side"`
+	Type       model.OrderType   `json:"type"` // market when empty
+	LimitPrice decimal.Decimal   `json:"limit_price,omitempty"`
+	StopPrice  decimal.Decimal   `json:"stop_price,omitempty"`
+	TIF        model.TimeInForce `json:"tif,omitempty"`
+	ExpireAt   time.Time         `json:"expire_at,omitempty"`
+	Tag        string            `json:"tag,omitempty"`
+
+	Qty      decimal.Decimal `json:"qty,omitempty"`      // base units
+	Notional decimal.Decimal `json:"notional,omitempty"` // quote amount
+	// EquityPct and BuyingPowerPct size the order as a fraction of equity or
+	// of buying power, e.g. 0.25 for a quarter
+	EquityPct      float64 `json:"equity_pct,omitempty"`
+	BuyingPowerPct float64 `json:"buying_power_pct,omitempty"`
+}
+
+// CloseIntent flattens pos with a market order; it has no size for a flat position
+func CloseIntent(pos PositionInfo) OrderIntent {
+	side := model.SideSell
+	if pos.Qty.IsNegative() {
+		side = model.SideBuy
+	}
+	return OrderIntent{Symbol: pos.Symbol, Side: side, Qty: pos.Qty.Abs(), Tag: "close"}
+}
+
+// SizeIntent converts intent into an order request. price is the reference for
+// notional sizing: the limit or stop price when the order has one, else last.
+func SizeIntent(intent OrderIntent, last decimal.Decimal, account AccountInfo) (model.OrderRequest, error) {
+	req := model.OrderRequest{
+		Symbol:     intent.Symbol,
+		Side:       intent.Side,
+		Type:       intent.Type,
+		LimitPrice: intent.LimitPrice,
+		StopPrice:  intent.StopPrice,
+		TIF:        intent.TIF,
+		ExpireAt:   intent.ExpireAt,
+		Tag:        intent.Tag,
+	}
+	if req.Type == "" {
+		req.Type = model.OrderMarket
+	}
+
+	sizes := 0
+	for _, set := range []bool{!intent.Qty.IsZero(), !intent.Notional.IsZero(), intent.EquityPct != 0, intent.BuyingPowerPct != 0} {
+		if set {
+			sizes++
+		}
+	}
+	if sizes != 1 {
+		return req, errors.New("order intent needs exactly one of qty, notional, equity_pct and buying_power_pct")
+	}
+
+	price := last
+	switch {
+	case intent.LimitPrice.IsPositive():
+		price = intent.LimitPrice
+	case intent.StopPrice.IsPositive():
+		price = intent.StopPrice
+	}
+	notional := intent.Notional
+	switch {
+	case !intent.Qty.IsZero():
+		req.Qty = intent.Qty
+		return req, req.Validate()
+	case intent.EquityPct != 0:
+		notional = account.Equity.Mul(decimal.NewFromFloat(intent.EquityPct))
+	case intent.BuyingPowerPct != 0:
+		notional = account.BuyingPower.Mul(decimal.NewFromFloat(intent.BuyingPowerPct))
+	}
+	if !price.IsPositive() {
+		return req, errors.New("no price to size order for " + intent.Symbol)
+	}
+	req.Qty = notional.Div(price)
+	return req, req.Validate()
+}
+
+// Adapt runs an action-based Strategy as a StrategyV2: buy means "be long with
+// all buying power" and sell "be flat", or "be short" when the account allows
+// it. A reversal closes first and opens on the next signal. Like the wrapped
+// strategy, an adapted one follows a single symbol.
+func Adapt(s Strategy) StrategyV2 {
+	return &actionAdapter{strat: s}
+}
+
+// actionAdapter is the StrategyV2 returned by Adapt
+type actionAdapter struct {
+	strat Strategy
+}
+
+func (a *actionAdapter) Name() string {
+	return a.strat.Name()
+}
+
+// Unwrap returns the adapted strategy
+func (a *actionAdapter) Unwrap() Strategy {
+	return a.strat
+}
+
+func (a *actionAdapter) Init(Context) error {
+	return nil
+}
+
+func (a *actionAdapter) OnCandle(ctx Context, candle model.KLine) {
+	var action Action
+	if mtf, ok := a.strat.(MultiTimeframeStrategy); ok {
+		action = mtf.OnCandleWith(ctx, candle)
+	} else {
+		action = a.strat.OnCandle(candle)
+	}
+	// Skip while an earlier signal is still waiting for its fill
+	if len(ctx.OpenOrders(candle.Symbol)) > 0 {
+		return
+	}
+
+	pos := ctx.Position(candle.Symbol)
+	open := func(side model.OrderSide) {
+		ctx.Submit(OrderIntent{Symbol: candle.Symbol, Side: side, BuyingPowerPct: 1})
+	}
+	switch action {
+	case ActionBuy:
+		switch {
+		case pos.Qty.IsNegative():
+			ctx.Submit(CloseIntent(pos))
+		case pos.Qty.IsZero():
+			open(model.SideBuy)
+		}
+	case ActionSell:
+		switch {
+		case pos.Qty.IsPositive():
+			ctx.Submit(CloseIntent(pos))
+		case pos.Qty.IsZero() && ctx.Account().AllowShort:
+			open(model.SideSell)
+		}
+	}
+}
+
+func (a *actionAdapter) OnTrade(Context, model.Trade) {}
+
+func (a *actionAdapter) OnOrderUpdate(Context, model.Order) {}
+
+func (a *actionAdapter) OnTimer(Context, time.Time) {}
@@ -0,0 +1,104 @@
+package strategy
+
+import (
+	"quant-trader/internal/model"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSizeIntent(t *testing.T) {
+	account := AccountInfo{Equity: decimal.NewFromInt(10000), BuyingPower: decimal.NewFromInt(4000)}
+	last := decimal.NewFromInt(100)
+
+	cases := []struct {
+		name   string
+		intent OrderIntent
+		qty    string
+	}{
+		{"qty", OrderIntent{Qty: decimal.NewFromInt(3)}, "3"},
+		{"notional", OrderIntent{Notional: decimal.NewFromInt(500)}, "5"},
+		{"equity", OrderIntent{EquityPct: 0.25}, "25"},
+		{"buying power", OrderIntent{BuyingPowerPct: 0.5}, "20"},
+		{"limit price", OrderIntent{Type: model.OrderLimit, LimitPrice: decimal.NewFromInt(80), Notional: decimal.NewFromInt(400)}, "5"},
+	}
+	for _, tc := range cases {
+		tc.intent.Symbol, tc.intent.Side = "BTCUSDT", model.SideBuy
+		req, err := SizeIntent(tc.intent, last, account)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.qty, req.Qty.String(), tc.name)
+	}
+
+	req, err := SizeIntent(OrderIntent{Symbol: "BTCUSDT", Side: model.SideBuy, Qty: decimal.NewFromInt(1)}, last, account)
+	require.NoError(t, err)
+	assert.Equal(t, model.OrderMarket, req.Type)
+
+	_, err = SizeIntent(OrderIntent{Symbol: "BTCUSDT", Side: model.SideBuy, Qty: decimal.NewFromInt(1), EquityPct: 0.1}, last, account)
+	assert.Error(t, err, "two sizes")
+	_, err = SizeIntent(OrderIntent{Symbol: "BTCUSDT", Side: model.SideBuy, EquityPct: 0.1}, decimal.Zero, account)
+	assert.Error(t, err, "no price")
+}
+
+// fakeContext records what an adapted strategy submits
+type fakeContext struct {
+	pos        PositionInfo
+	allowShort bool
+	open       []model.Order
+	submitted  []OrderIntent
+}
+
+func (c *fakeContext) Now() time.Time                            { return time.Time{} }
+func (c *fakeContext) Position(string) PositionInfo              { return c.pos }
+func (c *fakeContext) Account() AccountInfo                      { return AccountInfo{AllowShort: c.allowShort} }
+func (c *fakeContext) History(string, string, int) []model.KLine { return nil }
+func (c *fakeContext) Indicators(string, string) Indicators      { return Indicators{} }
+func (c *fakeContext) OpenOrders(string) []model.Order           { return c.open }
+func (c *fakeContext) Size(OrderIntent) (model.OrderRequest, error) {
+	return model.OrderRequest{}, nil
+}
+func (c *fakeContext) Submit(intent OrderIntent) (int64, error) {
+	c.submitted = append(c.submitted, intent)
+	return int64(len(c.submitted)), nil
+}
+func (c *fakeContext) Cancel(int64) bool      { return false }
+func (c *fakeContext) SetTimer(time.Duration) {}
+
+// fixedAction always returns the same action
+type fixedAction Action
+
+func (a fixedAction) Name() string                { return "fixed" }
+func (a fixedAction) OnCandle(model.KLine) Action { return Action(a) }
+
+func TestAdapt(t *testing.T) {
+	candle := model.KLine{Symbol: "BTCUSDT", Close: decimal.NewFromInt(100)}
+	long := PositionInfo{Symbol: "BTCUSDT", Qty: decimal.NewFromInt(2)}
+	short := PositionInfo{Symbol: "BTCUSDT", Qty: decimal.NewFromInt(-2)}
+
+	cases := []struct {
+		name   string
+		action Action
+		ctx    *fakeContext
+		want   []OrderIntent
+	}{
+		{"buy when flat", ActionBuy, &fakeContext{}, []OrderIntent{{Symbol: "BTCUSDT", Side: model.SideBuy, BuyingPowerPct: 1}}},
+		{"buy when long", ActionBuy, &fakeContext{pos: long}, nil},
+		{"buy when short closes", ActionBuy, &fakeContext{pos: short}, []OrderIntent{CloseIntent(short)}},
+		{"sell when long closes", ActionSell, &fakeContext{pos: long}, []OrderIntent{CloseIntent(long)}},
+		{"sell when flat", ActionSell, &fakeContext{}, nil},
+		{"sell when flat and shorting", ActionSell, &fakeContext{allowShort: true}, []OrderIntent{{Symbol: "BTCUSDT", Side: model.SideSell, BuyingPowerPct: 1}}},
+		{"waits for open orders", ActionBuy, &fakeContext{open: []model.Order{{}}}, nil},
+		{"hold", ActionHold, &fakeContext{}, nil},
+	}
+	for _, tc := range cases {
+		s := Adapt(fixedAction(tc.action))
+		require.NoError(t, s.Init(tc.ctx))
+		s.OnCandle(tc.ctx, candle)
+		assert.Equal(t, tc.want, tc.ctx.submitted, tc.name)
+	}
+
+	assert.Equal(t, model.SideBuy, CloseIntent(short).Side)
+	assert.Equal(t, "2", CloseIntent(short).Qty.String())
+}
@@ -0,0 +1,230 @@
+package strategy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"quant-trader/internal/model"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// WASM strategy ABI, version 1. examples/wasm/README.md has the full reference.
+//
+// A module exports its linear memory as "memory" and these functions:
+//
+//	qt_abi_version() -> i32              must return WasmABIVersion
+//	qt_alloc(size i32) -> i32            a buffer the host writes the event into
+//	qt_init(params i32, len i32) -> i32  optional; params is JSON, non-zero fails
+//	qt_on_candle(frame i32, len i32) -> i64
+//	qt_on_trade(trade i32) -> i64        optional
+//	qt_on_order(order i32) -> i64        optional
+//	qt_on_timer(ts_ms i64) -> i64        optional
+//
+// Event functions return the order intents they place as ptr<<32 | count, 0
+// for none. Numbers are little endian; prices and sizes are f64.
+//
+//	frame   f64 position_qty, f64 entry_price, f64 cash, f64 equity,
+//	        f64 buying_power, u32 open_orders, u32 candle_count,
+//	        then candle_count candles, oldest first; the last is the new one
+//	candle  i64 ts_ms, f64 open, f64 high, f64 low, f64 close, f64 volume
+//	trade   i64 ts_ms, f64 price, f64 amount, u32 side, u32 reserved
+//	order   i64 id, u32 side, u32 status, u32 type, u32 reserved,
+//	        f64 qty, f64 filled_qty, f64 avg_fill_price
+//	intent  u32 side, u32 type, u32 size_mode, u32 reserved,
+//	        f64 size, f64 limit_price, f64 stop_price
+//
+// side is 1 buy, 2 sell; type 0 market, 1 limit, 2 stop, 3 stop_limit; status
+// 1 open, 2 filled, 3 cancelled, 4 expired, 5 rejected. size_mode says what
+// size is: 1 base quantity, 2 quote notional, 3 fraction of equity, 4 fraction
+// of buying power, 5 close the whole position (size is ignored).
+//
+// Host functions are imported from module "qt":
+//
+//	log(level i32, msg i32, len i32)                  0 debug, 1 info, 2 warn, 3 error
+//	indicator(kind i32, period i32, param f64) -> f64 NaN until there is enough history
+//	state_get(key i32, key_len i32, buf i32, cap i32) -> i32
+//	state_set(key i32, key_len i32, val i32, val_len i32)
+//	set_timer(interval_ms i64)
+//
+// indicator kinds are 1 sma, 2 ema, 3 rsi, 4 atr, 5 highest high, 6 lowest
+// low, 7 upper, 8 middle and 9 lower Bollinger band with param deviations,
+// all over the candles of the event's symbol. state_get returns the length of
+// the value, copying it only when it fits in cap, or -1 when the key is unset.
+const WasmABIVersion = 1
+
+const (
+	wasmFrameHeader = 48
+	wasmCandleSize  = 48
+	wasmTradeSize   = 32
+	wasmOrderSize   = 48
+	wasmIntentSize  = 40
+	// maxWasmIntents bounds the intents one event may return
+	maxWasmIntents = 64
+)
+
+const (
+	wasmSizeQty = iota + 1
+	wasmSizeNotional
+	wasmSizeEquity
+	wasmSizeBuyingPower
+	wasmSizeClose
+)
+
+var (
+	wasmTypes    = []model.OrderType{model.OrderMarket, model.OrderLimit, model.OrderStop, model.OrderStopLimit}
+	wasmStatuses = []model.OrderStatus{model.OrderOpen, model.OrderFilled, model.OrderCancelled, model.OrderExpired, model.OrderRejected}
+)
+
+// wasmIntent is an intent read back from a module; close flattens the position
+type wasmIntent struct {
+	OrderIntent
+	close bool
+}
+
+// abiWriter appends little endian fields to a buffer
+type abiWriter []byte
+
+func (w *abiWriter) u32(v uint32) {
+	*w = binary.LittleEndian.AppendUint32(*w, v)
+}
+
+func (w *abiWriter) i64(v int64) {
+	*w = binary.LittleEndian.AppendUint64(*w, uint64(v))
+}
+
+func (w *abiWriter) f64(v float64) {
+	*w = binary.LittleEndian.AppendUint64(*w, math.Float64bits(v))
+}
+
+func (w *abiWriter) dec(v decimal.Decimal) {
+	w.f64(v.InexactFloat64())
+}
+
+func wasmSide(side string) uint32 {
+	switch side {
+	case string(model.SideBuy):
+		return 1
+	case string(model.SideSell):
+		return 2
+	}
+	return 0
+}
+
+func encodeWasmFrame(pos PositionInfo, account AccountInfo, openOrders int, candles []model.KLine) []byte {
+	w := make(abiWriter, 0, wasmFrameHeader+len(candles)*wasmCandleSize)
+	w.dec(pos.Qty)
+	w.dec(pos.EntryPrice)
+	w.dec(account.Cash)
+	w.dec(account.Equity)
+	w.dec(account.BuyingPower)
+	w.u32(uint32(openOrders))
+	w.u32(uint32(len(candles)))
+	for _, c := range candles {
+		w.i64(c.Timestamp.UnixMilli())
+		w.dec(c.Open)
+		w.dec(c.High)
+		w.dec(c.Low)
+		w.dec(c.Close)
+		w.dec(c.Volume)
+	}
+	return w
+}
+
+func encodeWasmTrade(t model.Trade) []byte {
+	w := make(abiWriter, 0, wasmTradeSize)
+	w.i64(t.Timestamp.UnixMilli())
+	w.dec(t.Price)
+	w.dec(t.Amount)
+	w.u32(wasmSide(t.Side))
+	w.u32(0)
+	return w
+}
+
+func encodeWasmOrder(o model.Order) []byte {
+	w := make(abiWriter, 0, wasmOrderSize)
+	w.i64(o.ID)
+	w.u32(wasmSide(string(o.Side)))
+	var status, typ uint32
+	for i, s := range wasmStatuses {
+		if s == o.Status {
+			status = uint32(i + 1)
+		}
+	}
+	for i, t := range wasmTypes {
+		if t == o.Type {
+			typ = uint32(i)
+		}
+	}
+	w.u32(status)
+	w.u32(typ)
+	w.u32(0)
+	w.dec(o.Qty)
+	w.dec(o.FilledQty)
+	w.dec(o.AvgFillPrice)
+	return w
+}
+
+// decodeWasmIntents reads intents written by a module for symbol
+func decodeWasmIntents(symbol string, raw []byte) ([]wasmIntent, error) {
+	if len(raw)%wasmIntentSize != 0 {
+		return nil, fmt.Errorf("intent buffer of %d bytes is not a whole number of intents", len(raw))
+	}
+	f64 := func(b []byte) decimal.Decimal {
+		return decimal.NewFromFloat(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+	}
+	intents := make([]wasmIntent, 0, len(raw)/wasmIntentSize)
+	for off := 0; off < len(raw); off += wasmIntentSize {
+		b := raw[off : off+wasmIntentSize]
+		in := wasmIntent{OrderIntent: OrderIntent{Symbol: symbol}}
+
+		switch binary.LittleEndian.Uint32(b[0:]) {
+		case 1:
+			in.Side = model.SideBuy
+		case 2:
+			in.Side = model.SideSell
+		default:
+			if binary.LittleEndian.Uint32(b[8:]) != wasmSizeClose {
+				return nil, fmt.Errorf("intent %d: unknown side", off/wasmIntentSize)
+			}
+		}
+		typ := binary.LittleEndian.Uint32(b[4:])
+		if int(typ) >= len(wasmTypes) {
+			return nil, fmt.Errorf("intent %d: unknown order type %d", off/wasmIntentSize, typ)
+		}
+		in.Type = wasmTypes[typ]
+		if in.Type == model.OrderLimit || in.Type == model.OrderStopLimit {
+			in.LimitPrice = f64(b[24:])
+		}
+		if in.Type == model.OrderStop || in.Type == model.OrderStopLimit {
+			in.StopPrice = f64(b[32:])
+		}
+
+		size := math.Float64frombits(binary.LittleEndian.Uint64(b[16:]))
+		if math.IsNaN(size) || math.IsInf(size, 0) {
+			return nil, fmt.Errorf("intent %d: size is not a number", off/wasmIntentSize)
+		}
+		switch binary.LittleEndian.Uint32(b[8:]) {
+		case wasmSizeQty:
+			in.Qty = decimal.NewFromFloat(size)
+		case wasmSizeNotional:
+			in.Notional = decimal.NewFromFloat(size)
+		case wasmSizeEquity:
+			in.EquityPct = size
+		case wasmSizeBuyingPower:
+			in.BuyingPowerPct = size
+		case wasmSizeClose:
+			in.close = true
+		default:
+			return nil, fmt.Errorf("intent %d: unknown size mode", off/wasmIntentSize)
+		}
+		intents = append(intents, in)
+	}
+	return intents, nil
+}
+
+// wasmMillis converts a duration from the ABI
+func wasmMillis(ms int64) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
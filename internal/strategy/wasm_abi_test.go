@@ -0,0 +1,85 @@
+package strategy
+
+import (
+	"encoding/binary"
+	"math"
+	"quant-trader/internal/model"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeWasmFrame(t *testing.T) {
+	candles := minuteCandles(0, 10, 11)
+	frame := encodeWasmFrame(
+		PositionInfo{Qty: decimal.NewFromFloat(1.5)},
+		AccountInfo{Equity: decimal.NewFromInt(1000)},
+		2, candles)
+
+	require.Len(t, frame, wasmFrameHeader+2*wasmCandleSize)
+	f64 := func(off int) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(frame[off:])) }
+	assert.Equal(t, 1.5, f64(0))
+	assert.Equal(t, 1000.0, f64(24))
+	assert.EqualValues(t, 2, binary.LittleEndian.Uint32(frame[40:]))
+	assert.EqualValues(t, 2, binary.LittleEndian.Uint32(frame[44:]))
+
+	last := wasmFrameHeader + wasmCandleSize
+	assert.Equal(t, candles[1].Timestamp.UnixMilli(), int64(binary.LittleEndian.Uint64(frame[last:])))
+	assert.Equal(t, 11.0, f64(last+32))
+	assert.Equal(t, 12.0, f64(last+16))
+}
+
+func TestDecodeWasmIntents(t *testing.T) {
+	intent := func(side, typ, mode uint32, size, limit, stop float64) []byte {
+		w := abiWriter{}
+		w.u32(side)
+		w.u32(typ)
+		w.u32(mode)
+		w.u32(0)
+		w.f64(size)
+		w.f64(limit)
+		w.f64(stop)
+		return w
+	}
+
+	raw := append(intent(1, 1, wasmSizeNotional, 500, 99, 0), intent(0, 0, wasmSizeClose, 0, 0, 0)...)
+	intents, err := decodeWasmIntents("BTCUSDT", raw)
+	require.NoError(t, err)
+	require.Len(t, intents, 2)
+	assert.Equal(t, model.SideBuy, intents[0].Side)
+	assert.Equal(t, model.OrderLimit, intents[0].Type)
+	assert.Equal(t, "500", intents[0].Notional.String())
+	assert.Equal(t, "99", intents[0].LimitPrice.String())
+	assert.True(t, intents[0].StopPrice.IsZero())
+	assert.Equal(t, "BTCUSDT", intents[0].Symbol)
+	assert.True(t, intents[1].close)
+
+	for name, bad := range map[string][]byte{
+		"partial":   raw[:wasmIntentSize+1],
+		"side":      intent(3, 0, wasmSizeQty, 1, 0, 0),
+		"type":      intent(1, 9, wasmSizeQty, 1, 0, 0),
+		"size mode": intent(1, 0, 9, 1, 0, 0),
+		"nan":       intent(1, 0, wasmSizeQty, math.NaN(), 0, 0),
+	} {
+		_, err := decodeWasmIntents("BTCUSDT", bad)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestEncodeWasmOrder(t *testing.T) {
+	order := encodeWasmOrder(model.Order{
+		ID:           7,
+		OrderRequest: model.OrderRequest{Side: model.SideSell, Type: model.OrderStop, Qty: decimal.NewFromInt(2)},
+		Status:       model.OrderFilled,
+		FilledQty:    decimal.NewFromInt(2),
+		UpdatedAt:    time.Now(),
+	})
+	require.Len(t, order, wasmOrderSize)
+	assert.EqualValues(t, 7, binary.LittleEndian.Uint64(order[0:]))
+	assert.EqualValues(t, 2, binary.LittleEndian.Uint32(order[8:]))  // sell
+	assert.EqualValues(t, 2, binary.LittleEndian.Uint32(order[12:])) // filled
+	assert.EqualValues(t, 2, binary.LittleEndian.Uint32(order[16:])) // stop
+}
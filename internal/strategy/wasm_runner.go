@@ -2,66 +2,275 @@ package strategy
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"quant-trader/internal/model"
+	"slices"
+	"time"
 
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
 )
 
+var (
+	// ErrWasmFuel is returned when a call runs out of fuel
+	ErrWasmFuel = errors.New("wasm strategy ran out of fuel")
+	// ErrWasmTimeout is returned when a call runs past its timeout
+	ErrWasmTimeout = errors.New("wasm strategy timed out")
+)
+
+// WasmLimits bounds what one module may use. Zero fields take the defaults.
+type WasmLimits struct {
+	// MemoryPages caps linear memory in 64 KiB pages, 1024 (64 MiB) by default
+	MemoryPages uint32 `json:"memory_pages"`
+	// Fuel is how many guest function calls one event may make, 10 million by default
+	Fuel int64 `json:"fuel"`
+	// Timeout is the wall time one event may take, 500ms by default
+	Timeout time.Duration `json:"timeout"`
+}
+
+func (l WasmLimits) withDefaults() WasmLimits {
+	if l.MemoryPages == 0 {
+		l.MemoryPages = 1024
+	}
+	if l.Fuel == 0 {
+		l.Fuel = 10_000_000
+	}
+	if l.Timeout == 0 {
+		l.Timeout = 500 * time.Millisecond
+	}
+	return l
+}
+
+// wasmCache shares compiled code between runtimes, so instantiating the same
+// module again, e.g. once per symbol or optimizer trial, skips compilation
+var wasmCache = wazero.NewCompilationCache()
+
+// wasmExports are the functions of the ABI a module may export
+var wasmExports = []struct {
+	name            string
+	params, results []api.ValueType
+	required        bool
+}{
+	{"qt_abi_version", nil, []api.ValueType{api.ValueTypeI32}, true},
+	{"qt_alloc", []api.ValueType{api.ValueTypeI32}, []api.ValueType{api.ValueTypeI32}, true},
+	{"qt_init", []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, []api.ValueType{api.ValueTypeI32}, false},
+	{"qt_on_candle", []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, []api.ValueType{api.ValueTypeI64}, true},
+	{"qt_on_trade", []api.ValueType{api.ValueTypeI32}, []api.ValueType{api.ValueTypeI64}, false},
+	{"qt_on_order", []api.ValueType{api.ValueTypeI32}, []api.ValueType{api.ValueTypeI64}, false},
+	{"qt_on_timer", []api.ValueType{api.ValueTypeI64}, []api.ValueType{api.ValueTypeI64}, false},
+}
+
+// WasmHost serves the host functions a module imports from "qt"
+type WasmHost interface {
+	Log(level int32, msg string)
+	Indicator(kind, period int32, param float64) float64
+	StateGet(key string) ([]byte, bool)
+	StateSet(key string, value []byte)
+	SetTimer(interval time.Duration)
+}
+
+// WasmRunner hosts one strategy module in its own runtime. Every call is
+// bounded by the limits; a call that exceeds them closes the module, so the
+// runner is unusable afterwards.
 type WasmRunner struct {
 	runtime wazero.Runtime
 	mod     api.Module
+	limits  WasmLimits
 }
 
-func NewWasmRunner(ctx context.Context, wasmCode []byte) (*WasmRunner, error) {
-	r := wazero.NewRuntime(ctx)
+// NewWasmRunner compiles and instantiates wasmCode, checking its ABI version
+func NewWasmRunner(ctx context.Context, wasmCode []byte, limits WasmLimits, host WasmHost) (*WasmRunner, error) {
+	limits = limits.withDefaults()
+	cfg := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(limits.MemoryPages).
+		WithCloseOnContextDone(true).
+		WithCompilationCache(wasmCache)
+	r := &WasmRunner{runtime: wazero.NewRuntimeWithConfig(ctx, cfg), limits: limits}
 
-	// Compile and instantiate the module
-	mod, err := r.Instantiate(ctx, wasmCode)
+	if err := r.instantiate(ctx, wasmCode, host); err != nil {
+		r.runtime.Close(ctx)
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *WasmRunner) instantiate(ctx context.Context, wasmCode []byte, host WasmHost) error {
+	// TinyGo and Go modules target WASI; they get no files, env or real clock
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r.runtime); err != nil {
+		return fmt.Errorf("failed to instantiate wasi: %w", err)
+	}
+	if err := r.hostModule(ctx, host); err != nil {
+		return fmt.Errorf("failed to instantiate host functions: %w", err)
+	}
+
+	compiled, err := r.runtime.CompileModule(experimental.WithFunctionListenerFactory(ctx, fuelListener{}), wasmCode)
 	if err != nil {
-		r.Close(ctx)
-		return nil, fmt.Errorf("failed to instantiate wasm: %w", err)
+		return fmt.Errorf("failed to compile wasm: %w", err)
+	}
+	exports := compiled.ExportedFunctions()
+	for _, sig := range wasmExports {
+		def, ok := exports[sig.name]
+		if !ok {
+			if sig.required {
+				return fmt.Errorf("wasm module does not export %s", sig.name)
+			}
+			continue
+		}
+		if !slices.Equal(def.ParamTypes(), sig.params) || !slices.Equal(def.ResultTypes(), sig.results) {
+			return fmt.Errorf("wasm export %s has the wrong signature", sig.name)
+		}
+	}
+
+	callCtx, done := r.callContext(ctx)
+	defer done()
+	config := wazero.NewModuleConfig().WithStartFunctions("_initialize")
+	if r.mod, err = r.runtime.InstantiateModule(callCtx, compiled, config); err != nil {
+		return r.callError(callCtx, fmt.Errorf("failed to instantiate wasm: %w", err))
 	}
 
-	return &WasmRunner{
-		runtime: r,
-		mod:     mod,
-	}, nil
+	version, err := r.Call(ctx, "qt_abi_version")
+	if err != nil {
+		return err
+	}
+	if version[0] != WasmABIVersion {
+		return fmt.Errorf("wasm module implements ABI version %d, want %d", version[0], WasmABIVersion)
+	}
+	return nil
 }
 
-func (r *WasmRunner) OnCandle(ctx context.Context, candle model.KLine) (Action, error) {
-	// 1. Get the handle to the OnCandle function in Wasm
-	onCandle := r.mod.ExportedFunction("OnCandle")
-	if onCandle == nil {
-		return ActionHold, fmt.Errorf("wasm module does not export OnCandle")
+func (r *WasmRunner) hostModule(ctx context.Context, host WasmHost) error {
+	read := func(m api.Module, ptr, n uint32) string {
+		b, _ := m.Memory().Read(ptr, n)
+		return string(b)
 	}
+	_, err := r.runtime.NewHostModuleBuilder("qt").
+		NewFunctionBuilder().
+		WithFunc(func(_ context.Context, m api.Module, level int32, ptr, n uint32) {
+			host.Log(level, read(m, ptr, n))
+		}).Export("log").
+		NewFunctionBuilder().
+		WithFunc(func(_ context.Context, kind, period int32, param float64) float64 {
+			return host.Indicator(kind, period, param)
+		}).Export("indicator").
+		NewFunctionBuilder().
+		WithFunc(func(_ context.Context, m api.Module, key, keyLen, buf, capacity uint32) int32 {
+			value, ok := host.StateGet(read(m, key, keyLen))
+			if !ok {
+				return -1
+			}
+			if len(value) <= int(capacity) {
+				m.Memory().Write(buf, value)
+			}
+			return int32(len(value))
+		}).Export("state_get").
+		NewFunctionBuilder().
+		WithFunc(func(_ context.Context, m api.Module, key, keyLen, val, valLen uint32) {
+			value, _ := m.Memory().Read(val, valLen)
+			host.StateSet(read(m, key, keyLen), append([]byte(nil), value...))
+		}).Export("state_set").
+		NewFunctionBuilder().
+		WithFunc(func(_ context.Context, ms int64) {
+			host.SetTimer(wasmMillis(ms))
+		}).Export("set_timer").
+		Instantiate(ctx)
+	return err
+}
 
-	// 2. Map Go candle to Wasm memory (simplified example)
-	price, _ := candle.Close.Float64()
+// Exports reports whether the module exports function name
+func (r *WasmRunner) Exports(name string) bool {
+	return r.mod.ExportedFunction(name) != nil
+}
 
-	// 3. Call the function
-	results, err := onCandle.Call(ctx, api.EncodeF64(price))
+// Call runs an exported function within the limits
+func (r *WasmRunner) Call(ctx context.Context, name string, params ...uint64) ([]uint64, error) {
+	fn := r.mod.ExportedFunction(name)
+	if fn == nil {
+		return nil, fmt.Errorf("wasm module does not export %s", name)
+	}
+	callCtx, done := r.callContext(ctx)
+	defer done()
+	results, err := fn.Call(callCtx, params...)
 	if err != nil {
-		return ActionHold, fmt.Errorf("failed to call OnCandle: %w", err)
-	}
-
-	// 4. Map the return value back to Action
-	if len(results) > 0 {
-		// Define a mapping between Wasm integer returns and our string Actions
-		switch results[0] {
-		case 1:
-			return ActionBuy, nil
-		case 2:
-			return ActionSell, nil
-		default:
-			return ActionHold, nil
-		}
+		return nil, r.callError(callCtx, fmt.Errorf("%s: %w", name, err))
+	}
+	return results, nil
+}
+
+// Write copies data into a buffer allocated by the module
+func (r *WasmRunner) Write(ctx context.Context, data []byte) (uint32, error) {
+	res, err := r.Call(ctx, "qt_alloc", uint64(len(data)))
+	if err != nil {
+		return 0, err
 	}
+	ptr := uint32(res[0])
+	if !r.mod.Memory().Write(ptr, data) {
+		return 0, fmt.Errorf("qt_alloc returned %d bytes out of memory at %d", len(data), ptr)
+	}
+	return ptr, nil
+}
 
-	return ActionHold, nil
+// Read copies n bytes out of the module's memory
+func (r *WasmRunner) Read(ptr, n uint32) ([]byte, error) {
+	b, ok := r.mod.Memory().Read(ptr, n)
+	if !ok {
+		return nil, fmt.Errorf("read of %d bytes at %d is out of memory", n, ptr)
+	}
+	return append([]byte(nil), b...), nil
 }
 
 func (r *WasmRunner) Close(ctx context.Context) error {
 	return r.runtime.Close(ctx)
 }
+
+// fuelKey carries the *fuelTank of the current call
+type fuelKey struct{}
+
+type fuelTank struct {
+	left   int64
+	empty  bool
+	cancel context.CancelFunc
+}
+
+// callContext bounds one call by the timeout and the fuel
+func (r *WasmRunner) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, r.limits.Timeout)
+	tank := &fuelTank{left: r.limits.Fuel, cancel: cancel}
+	return context.WithValue(ctx, fuelKey{}, tank), cancel
+}
+
+// callError names the limit that stopped a call, if one did
+func (r *WasmRunner) callError(ctx context.Context, err error) error {
+	if tank, ok := ctx.Value(fuelKey{}).(*fuelTank); ok && tank.empty {
+		return fmt.Errorf("%w: %w", ErrWasmFuel, err)
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrWasmTimeout, err)
+	}
+	return err
+}
+
+// fuelListener burns one unit of fuel per guest function call and cancels the
+// call once the tank is empty. wazero has no instruction metering, so loops
+// without calls are only bounded by the timeout.
+type fuelListener struct{}
+
+func (fuelListener) NewFunctionListener(api.FunctionDefinition) experimental.FunctionListener {
+	return fuelListener{}
+}
+
+func (fuelListener) Before(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) {
+	tank, ok := ctx.Value(fuelKey{}).(*fuelTank)
+	if !ok || tank.empty {
+		return
+	}
+	if tank.left--; tank.left < 0 {
+		tank.empty = true
+		tank.cancel()
+	}
+}
+
+func (fuelListener) After(context.Context, api.Module, api.FunctionDefinition, []uint64) {}
+
+func (fuelListener) Abort(context.Context, api.Module, api.FunctionDefinition, error) {}
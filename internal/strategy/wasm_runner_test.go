@@ -0,0 +1,105 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wasmSection encodes a module section; contents are short enough for a one
+// byte length
+func wasmSection(id byte, contents ...byte) []byte {
+	return append([]byte{id, byte(len(contents))}, contents...)
+}
+
+func wasmName(name string) []byte {
+	return append([]byte{byte(len(name))}, name...)
+}
+
+// testWasmModule assembles a module with the required ABI exports: version is
+// what qt_abi_version returns, pages the initial memory and onCandle the body
+// of qt_on_candle, which may call function 3, an empty function
+func testWasmModule(version, pages byte, onCandle ...byte) []byte {
+	module := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	module = append(module, wasmSection(1,
+		0x04,
+		0x60, 0x00, 0x01, 0x7f, // () -> i32
+		0x60, 0x01, 0x7f, 0x01, 0x7f, // (i32) -> i32
+		0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e, // (i32, i32) -> i64
+		0x60, 0x00, 0x00, // () -> ()
+	)...)
+	module = append(module, wasmSection(3, 0x04, 0x00, 0x01, 0x02, 0x03)...)
+	module = append(module, wasmSection(5, 0x01, 0x00, pages)...)
+
+	exports := []byte{0x04}
+	for i, name := range []string{"memory", "qt_abi_version", "qt_alloc", "qt_on_candle"} {
+		kind, index := byte(0x00), byte(i-1)
+		if i == 0 {
+			kind, index = 0x02, 0
+		}
+		exports = append(append(exports, wasmName(name)...), kind, index)
+	}
+	module = append(module, wasmSection(7, exports...)...)
+
+	bodies := [][]byte{
+		{0x00, 0x41, version, 0x0b},                                 // i32.const version
+		{0x00, 0x41, 0x80, 0x08, 0x0b},                              // i32.const 1024
+		append(append([]byte{0x00}, onCandle...), 0x42, 0x00, 0x0b), // ...; i64.const 0
+		{0x00, 0x0b},
+	}
+	code := []byte{byte(len(bodies))}
+	for _, body := range bodies {
+		code = append(append(code, byte(len(body))), body...)
+	}
+	return append(module, wasmSection(10, code...)...)
+}
+
+var (
+	wasmLoop     = []byte{0x03, 0x40, 0x0c, 0x00, 0x0b}             // loop br 0 end
+	wasmCallLoop = []byte{0x03, 0x40, 0x10, 0x03, 0x0c, 0x00, 0x0b} // loop call 3 br 0 end
+)
+
+func TestWasmRunner_Instantiate(t *testing.T) {
+	ctx := context.Background()
+
+	r, err := NewWasmRunner(ctx, testWasmModule(WasmABIVersion, 1), WasmLimits{}, &WasmStrategy{})
+	require.NoError(t, err)
+	assert.False(t, r.Exports("qt_init"))
+	ptr, err := r.Write(ctx, []byte("frame"))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1024, ptr)
+	res, err := r.Call(ctx, "qt_on_candle", uint64(ptr), 5)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, res[0])
+	require.NoError(t, r.Close(ctx))
+
+	_, err = NewWasmRunner(ctx, testWasmModule(WasmABIVersion+1, 1), WasmLimits{}, &WasmStrategy{})
+	assert.ErrorContains(t, err, "ABI version")
+
+	_, err = NewWasmRunner(ctx, testWasmModule(WasmABIVersion, 4), WasmLimits{MemoryPages: 2}, &WasmStrategy{})
+	assert.Error(t, err, "initial memory above the limit")
+
+	_, err = NewWasmRunner(ctx, []byte("not wasm"), WasmLimits{}, &WasmStrategy{})
+	assert.Error(t, err)
+}
+
+func TestWasmRunner_Limits(t *testing.T) {
+	ctx := context.Background()
+
+	r, err := NewWasmRunner(ctx, testWasmModule(WasmABIVersion, 1, wasmCallLoop...), WasmLimits{Fuel: 1000, Timeout: 10 * time.Second}, &WasmStrategy{})
+	require.NoError(t, err)
+	_, err = r.Call(ctx, "qt_on_candle", 0, 0)
+	assert.ErrorIs(t, err, ErrWasmFuel)
+	_, err = r.Call(ctx, "qt_abi_version")
+	assert.Error(t, err, "the module is closed once a limit is hit")
+
+	r, err = NewWasmRunner(ctx, testWasmModule(WasmABIVersion, 1, wasmLoop...), WasmLimits{Timeout: 50 * time.Millisecond}, &WasmStrategy{})
+	require.NoError(t, err)
+	start := time.Now()
+	_, err = r.Call(ctx, "qt_on_candle", 0, 0)
+	assert.ErrorIs(t, err, ErrWasmTimeout)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
@@ -0,0 +1,346 @@
+package strategy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"quant-trader/internal/model"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// WasmConfig describes a WASM strategy instance
+type WasmConfig struct {
+	Name string
+	// Params is passed to qt_init as JSON
+	Params map[string]interface{}
+	// Lookback is how many candles each frame carries, 200 by default
+	Lookback int
+	Limits   WasmLimits
+}
+
+// WasmStrategy runs a module implementing the ABI in wasm_abi.go. A module
+// that traps or exceeds its limits is stopped: later events are ignored and
+// Err reports why.
+type WasmStrategy struct {
+	name     string
+	runner   *WasmRunner
+	params   []byte
+	lookback int
+	history  *HistoryBuffer
+	state    map[string][]byte
+	logger   *zap.Logger
+
+	// ctx and symbol are those of the event being handled, for the host functions
+	ctx    Context
+	symbol string
+	err    error
+}
+
+// NewWasmStrategy instantiates wasmCode; the module is started but qt_init
+// only runs on Init
+func NewWasmStrategy(wasmCode []byte, cfg WasmConfig) (*WasmStrategy, error) {
+	params, err := json.Marshal(cfg.Params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wasm params: %w", err)
+	}
+	if cfg.Name == "" {
+		cfg.Name = "wasm"
+	}
+	if cfg.Lookback <= 0 {
+		cfg.Lookback = 200
+	}
+	s := &WasmStrategy{
+		name:     cfg.Name,
+		params:   params,
+		lookback: cfg.Lookback,
+		history:  NewHistoryBuffer(cfg.Lookback),
+		state:    make(map[string][]byte),
+		logger:   zap.NewNop(),
+	}
+	if s.runner, err = NewWasmRunner(context.Background(), wasmCode, cfg.Limits, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetLogger receives the module's log calls
+func (s *WasmStrategy) SetLogger(logger *zap.Logger) {
+	s.logger = logger.With(zap.String("strategy", s.name))
+}
+
+func (s *WasmStrategy) Name() string {
+	return s.name
+}
+
+// Err is the error that stopped the module, if any
+func (s *WasmStrategy) Err() error {
+	return s.err
+}
+
+func (s *WasmStrategy) Close() error {
+	return s.runner.Close(context.Background())
+}
+
+func (s *WasmStrategy) Init(ctx Context) error {
+	s.ctx = ctx
+	if !s.runner.Exports("qt_init") {
+		return nil
+	}
+	ptr, err := s.runner.Write(context.Background(), s.params)
+	if err != nil {
+		return s.stop(err)
+	}
+	res, err := s.runner.Call(context.Background(), "qt_init", uint64(ptr), uint64(len(s.params)))
+	if err != nil {
+		return s.stop(err)
+	}
+	if code := int32(res[0]); code != 0 {
+		return fmt.Errorf("wasm strategy %s: qt_init returned %d", s.name, code)
+	}
+	return nil
+}
+
+func (s *WasmStrategy) OnCandle(ctx Context, candle model.KLine) {
+	s.history.Add(candle)
+	s.event(ctx, candle.Symbol, func() ([]uint64, error) {
+		frame := encodeWasmFrame(ctx.Position(candle.Symbol), ctx.Account(), len(ctx.OpenOrders(candle.Symbol)),
+			s.history.History(candle.Symbol, "", s.lookback))
+		ptr, err := s.runner.Write(context.Background(), frame)
+		if err != nil {
+			return nil, err
+		}
+		return s.runner.Call(context.Background(), "qt_on_candle", uint64(ptr), uint64(len(frame)))
+	})
+}
+
+func (s *WasmStrategy) OnTrade(ctx Context, trade model.Trade) {
+	s.callOptional(ctx, trade.Symbol, "qt_on_trade", encodeWasmTrade(trade))
+}
+
+func (s *WasmStrategy) OnOrderUpdate(ctx Context, order model.Order) {
+	s.callOptional(ctx, order.Symbol, "qt_on_order", encodeWasmOrder(order))
+}
+
+// OnTimer places intents for the symbol of the last candle
+func (s *WasmStrategy) OnTimer(ctx Context, now time.Time) {
+	if !s.runner.Exports("qt_on_timer") {
+		return
+	}
+	s.event(ctx, s.symbol, func() ([]uint64, error) {
+		return s.runner.Call(context.Background(), "qt_on_timer", uint64(now.UnixMilli()))
+	})
+}
+
+func (s *WasmStrategy) callOptional(ctx Context, symbol, name string, data []byte) {
+	if !s.runner.Exports(name) {
+		return
+	}
+	s.event(ctx, symbol, func() ([]uint64, error) {
+		ptr, err := s.runner.Write(context.Background(), data)
+		if err != nil {
+			return nil, err
+		}
+		return s.runner.Call(context.Background(), name, uint64(ptr))
+	})
+}
+
+// event runs call and submits the intents it returns
+func (s *WasmStrategy) event(ctx Context, symbol string, call func() ([]uint64, error)) {
+	if s.err != nil {
+		return
+	}
+	s.ctx, s.symbol = ctx, symbol
+	res, err := call()
+	if err != nil {
+		s.stop(err)
+		return
+	}
+	intents, err := s.intents(symbol, res[0])
+	if err != nil {
+		s.stop(err)
+		return
+	}
+	for _, in := range intents {
+		intent := in.OrderIntent
+		if in.close {
+			pos := ctx.Position(symbol)
+			if pos.Qty.IsZero() {
+				continue
+			}
+			intent = CloseIntent(pos)
+		}
+		if _, err := ctx.Submit(intent); err != nil {
+			s.logger.Warn("wasm strategy order rejected", zap.String("symbol", symbol), zap.Error(err))
+		}
+	}
+}
+
+// intents reads the intents described by a packed ptr<<32 | count result
+func (s *WasmStrategy) intents(symbol string, packed uint64) ([]wasmIntent, error) {
+	if packed == 0 {
+		return nil, nil
+	}
+	ptr, count := uint32(packed>>32), uint32(packed)
+	if count > maxWasmIntents {
+		return nil, fmt.Errorf("wasm strategy returned %d intents, at most %d are allowed", count, maxWasmIntents)
+	}
+	raw, err := s.runner.Read(ptr, count*wasmIntentSize)
+	if err != nil {
+		return nil, err
+	}
+	return decodeWasmIntents(symbol, raw)
+}
+
+func (s *WasmStrategy) stop(err error) error {
+	s.err = fmt.Errorf("wasm strategy %s stopped: %w", s.name, err)
+	s.logger.Error("wasm strategy stopped", zap.Error(err))
+	return s.err
+}
+
+// Log implements WasmHost
+func (s *WasmStrategy) Log(level int32, msg string) {
+	switch level {
+	case 0:
+		s.logger.Debug(msg)
+	case 1:
+		s.logger.Info(msg)
+	case 2:
+		s.logger.Warn(msg)
+	default:
+		s.logger.Error(msg)
+	}
+}
+
+// Indicator implements WasmHost over the candles of the current symbol
+func (s *WasmStrategy) Indicator(kind, period int32, param float64) float64 {
+	in := NewIndicators(s.history.History(s.symbol, "", 0))
+	n := int(period)
+	var (
+		v  float64
+		ok bool
+	)
+	switch kind {
+	case 1:
+		d, found := in.SMA(n)
+		v, ok = d.InexactFloat64(), found
+	case 2:
+		d, found := in.EMA(n)
+		v, ok = d.InexactFloat64(), found
+	case 3:
+		d, found := in.RSI(n)
+		v, ok = d.InexactFloat64(), found
+	case 4:
+		v, ok = in.ATR(n)
+	case 5:
+		d, found := in.Highest(n)
+		v, ok = d.InexactFloat64(), found
+	case 6:
+		d, found := in.Lowest(n)
+		v, ok = d.InexactFloat64(), found
+	case 7, 8, 9:
+		middle, upper, lower, found := in.Bollinger(n, param)
+		v, ok = [...]float64{upper, middle, lower}[kind-7], found
+	}
+	if !ok {
+		return math.NaN()
+	}
+	return v
+}
+
+// StateGet implements WasmHost
+func (s *WasmStrategy) StateGet(key string) ([]byte, bool) {
+	v, ok := s.state[key]
+	return v, ok
+}
+
+// StateSet implements WasmHost
+func (s *WasmStrategy) StateSet(key string, value []byte) {
+	s.state[key] = value
+}
+
+// SetTimer implements WasmHost
+func (s *WasmStrategy) SetTimer(interval time.Duration) {
+	if s.ctx != nil {
+		s.ctx.SetTimer(interval)
+	}
+}
+
+// Actions runs the module as an action-based Strategy: buy intents become
+// ActionBuy and sell intents ActionSell. The module sees a flat account, or
+// the position implied by the actions so far, so sizes are meaningless.
+func (s *WasmStrategy) Actions() Strategy {
+	return &wasmActions{wasm: s}
+}
+
+// wasmActions is the Strategy returned by WasmStrategy.Actions
+type wasmActions struct {
+	wasm    *WasmStrategy
+	long    bool
+	started bool
+	action  Action
+}
+
+func (a *wasmActions) Name() string {
+	return a.wasm.Name()
+}
+
+func (a *wasmActions) Close() error {
+	return a.wasm.Close()
+}
+
+// Unwrap returns the module as a StrategyV2
+func (a *wasmActions) Unwrap() StrategyV2 {
+	return a.wasm
+}
+
+func (a *wasmActions) OnCandle(candle model.KLine) Action {
+	if !a.started {
+		a.started = true
+		a.wasm.Init(a)
+	}
+	a.action = ActionHold
+	a.wasm.OnCandle(a, candle)
+	switch a.action {
+	case ActionBuy:
+		a.long = true
+	case ActionSell:
+		a.long = false
+	}
+	return a.action
+}
+
+// wasmActions is also the Context the module sees
+func (a *wasmActions) Now() time.Time { return time.Time{} }
+
+func (a *wasmActions) Position(symbol string) PositionInfo {
+	pos := PositionInfo{Symbol: symbol}
+	if a.long {
+		pos.Qty = decimal.NewFromInt(1)
+	}
+	return pos
+}
+
+func (a *wasmActions) Account() AccountInfo                      { return AccountInfo{} }
+func (a *wasmActions) History(string, string, int) []model.KLine { return nil }
+func (a *wasmActions) Indicators(string, string) Indicators      { return Indicators{} }
+func (a *wasmActions) OpenOrders(string) []model.Order           { return nil }
+func (a *wasmActions) Cancel(int64) bool                         { return false }
+func (a *wasmActions) SetTimer(time.Duration)                    {}
+
+func (a *wasmActions) Size(intent OrderIntent) (model.OrderRequest, error) {
+	return model.OrderRequest{Symbol: intent.Symbol, Side: intent.Side, Type: intent.Type}, nil
+}
+
+func (a *wasmActions) Submit(intent OrderIntent) (int64, error) {
+	if a.action == ActionHold {
+		a.action = ActionBuy
+		if intent.Side == model.SideSell {
+			a.action = ActionSell
+		}
+	}
+	return 0, nil
+}
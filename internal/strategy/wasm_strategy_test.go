@@ -0,0 +1,123 @@
+package strategy
+
+import (
+	"encoding/base64"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"quant-trader/internal/model"
+	"sync"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var (
+	exampleOnce sync.Once
+	exampleWasm []byte
+	exampleErr  error
+)
+
+// exampleModule builds examples/wasm/tinygo with the Go toolchain, which
+// targets wasip1 as TinyGo does
+func exampleModule(t *testing.T) []byte {
+	if testing.Short() {
+		t.Skip("builds a wasm module")
+	}
+	exampleOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "wasm-example")
+		if err != nil {
+			exampleErr = err
+			return
+		}
+		defer os.RemoveAll(dir)
+		out := filepath.Join(dir, "ma_cross.wasm")
+		cmd := exec.Command("go", "build", "-buildmode=c-shared", "-o", out, ".")
+		cmd.Dir = filepath.Join("..", "..", "examples", "wasm", "tinygo")
+		cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm", "GOWORK=off")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Log(string(output))
+			exampleErr = err
+			return
+		}
+		exampleWasm, exampleErr = os.ReadFile(out)
+	})
+	require.NoError(t, exampleErr)
+	return exampleWasm
+}
+
+func TestWasmStrategy_Example(t *testing.T) {
+	s, err := NewWasmStrategy(exampleModule(t), WasmConfig{
+		Name:   "ma_cross_wasm",
+		Params: map[string]interface{}{"fast": 2, "slow": 3, "fraction": 0.5},
+	})
+	require.NoError(t, err)
+	defer s.Close()
+	core, logs := observer.New(zap.InfoLevel)
+	s.SetLogger(zap.New(core))
+
+	ctx := &fakeContext{}
+	require.NoError(t, s.Init(ctx))
+	for _, c := range minuteCandles(0, 5, 4, 3, 6) {
+		s.OnCandle(ctx, c)
+	}
+	require.NoError(t, s.Err())
+	require.Len(t, ctx.submitted, 1)
+	assert.Equal(t, OrderIntent{Symbol: "BTCUSDT", Side: model.SideBuy, Type: model.OrderMarket, EquityPct: 0.5}, ctx.submitted[0])
+	assert.Equal(t, 1, logs.FilterMessage("signal 1").Len())
+	assert.Len(t, s.state["signals"], 8)
+
+	// A close intent flattens the position the context reports
+	ctx.pos = PositionInfo{Symbol: "BTCUSDT", Qty: decimal.NewFromInt(2)}
+	for _, c := range minuteCandles(4, 7, 8, 9, 1) {
+		s.OnCandle(ctx, c)
+	}
+	require.Len(t, ctx.submitted, 2)
+	assert.Equal(t, CloseIntent(ctx.pos), ctx.submitted[1])
+}
+
+func TestWasmStrategy_InitParams(t *testing.T) {
+	s, err := NewWasmStrategy(exampleModule(t), WasmConfig{Params: map[string]interface{}{"fast": 30, "slow": 10}})
+	require.NoError(t, err)
+	defer s.Close()
+	assert.ErrorContains(t, s.Init(&fakeContext{}), "qt_init returned 1")
+}
+
+func TestNewWasmFromConfig(t *testing.T) {
+	config := map[string]interface{}{
+		"module": base64.StdEncoding.EncodeToString(exampleModule(t)),
+		"params": map[string]interface{}{"fast": float64(2), "slow": float64(3)},
+		"fuel":   float64(1e8),
+	}
+
+	wasm, err := NewWasmFromConfig(config)
+	require.NoError(t, err)
+	s := wasm.Actions()
+	defer s.(io.Closer).Close()
+	var actions []Action
+	for _, c := range minuteCandles(0, 5, 4, 3, 6, 7, 8, 9, 1) {
+		actions = append(actions, s.OnCandle(c))
+	}
+	assert.Equal(t, []Action{ActionHold, ActionHold, ActionHold, ActionBuy, ActionHold, ActionHold, ActionHold, ActionSell}, actions)
+
+	v2, err := NewWasmFromConfig(config)
+	require.NoError(t, err)
+	assert.Nil(t, Legacy(v2))
+	v2.Close()
+
+	_, err = NewWasmFromConfig(map[string]interface{}{"module": "%%"})
+	assert.ErrorContains(t, err, "base64")
+	_, err = NewWasmFromConfig(map[string]interface{}{"module": config["module"], "fuel": "lots"})
+	assert.ErrorContains(t, err, "fuel")
+
+	// Built by type, e.g. from a backtest request, its limits would go unchecked
+	_, err = NewStrategy("wasm", config)
+	assert.ErrorIs(t, err, ErrWasmConstructor)
+	_, err = NewStrategyV2("wasm", config)
+	assert.ErrorIs(t, err, ErrWasmConstructor)
+}
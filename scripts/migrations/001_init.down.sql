@@ -0,0 +1,11 @@
+-- Rollback: Base schema
+
+DROP TABLE IF EXISTS alerts;
+DROP TABLE IF EXISTS user_subscriptions;
+DROP TABLE IF EXISTS subscription_tiers;
+DROP TABLE IF EXISTS backtest_runs;
+DROP TABLE IF EXISTS strategies;
+DROP TABLE IF EXISTS user_exchange_keys;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS klines;
+DROP TABLE IF EXISTS trades;
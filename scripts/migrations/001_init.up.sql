@@ -1,3 +1,5 @@
+-- Migration: Base schema
+
 -- Enable TimescaleDB extension
 CREATE EXTENSION IF NOT EXISTS timescaledb;
 
@@ -0,0 +1,5 @@
+-- Rollback: Subscription system
+-- The tables are part of the base schema (001), so only the seed rows are owned here.
+
+DELETE FROM subscription_tiers WHERE name IN ('Free', 'Pro', 'Enterprise')
+    AND id NOT IN (SELECT tier_id FROM user_subscriptions WHERE tier_id IS NOT NULL);
@@ -0,0 +1,2 @@
+-- Rollback: Alert system
+-- The alerts table is part of the base schema (001); nothing else to undo.
@@ -11,5 +11,5 @@ CREATE TABLE IF NOT EXISTS alerts (
 );
 
 -- Index for performance
-CREATE INDEX idx_alerts_user_id ON alerts(user_id);
-CREATE INDEX idx_alerts_symbol_active ON alerts(symbol) WHERE is_active = TRUE;
+CREATE INDEX IF NOT EXISTS idx_alerts_user_id ON alerts(user_id);
+CREATE INDEX IF NOT EXISTS idx_alerts_symbol_active ON alerts(symbol) WHERE is_active = TRUE;
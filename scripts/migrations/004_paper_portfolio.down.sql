@@ -0,0 +1,7 @@
+-- Rollback: Paper Trading & Portfolio Management
+
+DROP TABLE IF EXISTS portfolio_assets;
+DROP TABLE IF EXISTS portfolios;
+DROP TABLE IF EXISTS paper_positions;
+DROP TABLE IF EXISTS paper_orders;
+DROP TABLE IF EXISTS paper_accounts;
@@ -0,0 +1,4 @@
+-- Rollback: Strategy Marketplace
+
+DROP TABLE IF EXISTS strategy_purchases;
+DROP TABLE IF EXISTS strategy_market;
@@ -0,0 +1,3 @@
+-- Rollback: API Key Management
+
+DROP TABLE IF EXISTS api_keys;
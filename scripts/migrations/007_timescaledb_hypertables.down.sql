@@ -0,0 +1,4 @@
+-- Rollback: TimescaleDB Hypertables Optimization
+-- Hypertables cannot be converted back to plain tables; only the chunk interval is restored.
+
+SELECT set_chunk_time_interval('klines', INTERVAL '7 days');
@@ -0,0 +1,19 @@
+-- migrate:no-transaction
+-- Rollback: Continuous aggregates, compression and retention
+
+DROP MATERIALIZED VIEW IF EXISTS klines_1d;
+DROP MATERIALIZED VIEW IF EXISTS klines_4h;
+DROP MATERIALIZED VIEW IF EXISTS klines_1h;
+DROP MATERIALIZED VIEW IF EXISTS klines_15m;
+DROP MATERIALIZED VIEW IF EXISTS klines_5m;
+
+SELECT remove_compression_policy('trades', if_exists => TRUE);
+SELECT remove_compression_policy('klines', if_exists => TRUE);
+SELECT remove_retention_policy('trades', if_exists => TRUE);
+SELECT remove_retention_policy('klines', if_exists => TRUE);
+
+SELECT decompress_chunk(c, if_compressed => TRUE) FROM show_chunks('trades') c;
+SELECT decompress_chunk(c, if_compressed => TRUE) FROM show_chunks('klines') c;
+
+ALTER TABLE trades SET (timescaledb.compress = FALSE);
+ALTER TABLE klines SET (timescaledb.compress = FALSE);
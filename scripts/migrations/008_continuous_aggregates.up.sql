@@ -0,0 +1,130 @@
+-- migrate:no-transaction
+-- Migration: Continuous aggregates, compression and retention
+
+-- 1. Continuous aggregates over 1m klines
+-- With KLINE_SOURCE=aggregate only 1m klines are written to `klines`; the
+-- higher periods are materialized here and read from klines_<period>.
+CREATE MATERIALIZED VIEW IF NOT EXISTS klines_5m
+WITH (timescaledb.continuous) AS
+SELECT time_bucket(INTERVAL '5 minutes', time) AS time,
+       symbol,
+       exchange,
+       '5m'::TEXT AS period,
+       first(open, time) AS open,
+       max(high) AS high,
+       min(low) AS low,
+       last(close, time) AS close,
+       sum(volume) AS volume
+FROM klines
+WHERE period = '1m'
+GROUP BY time_bucket(INTERVAL '5 minutes', time), symbol, exchange
+WITH NO DATA;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS klines_15m
+WITH (timescaledb.continuous) AS
+SELECT time_bucket(INTERVAL '15 minutes', time) AS time,
+       symbol,
+       exchange,
+       '15m'::TEXT AS period,
+       first(open, time) AS open,
+       max(high) AS high,
+       min(low) AS low,
+       last(close, time) AS close,
+       sum(volume) AS volume
+FROM klines
+WHERE period = '1m'
+GROUP BY time_bucket(INTERVAL '15 minutes', time), symbol, exchange
+WITH NO DATA;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS klines_1h
+WITH (timescaledb.continuous) AS
+SELECT time_bucket(INTERVAL '1 hour', time) AS time,
+       symbol,
+       exchange,
+       '1h'::TEXT AS period,
+       first(open, time) AS open,
+       max(high) AS high,
+       min(low) AS low,
+       last(close, time) AS close,
+       sum(volume) AS volume
+FROM klines
+WHERE period = '1m'
+GROUP BY time_bucket(INTERVAL '1 hour', time), symbol, exchange
+WITH NO DATA;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS klines_4h
+WITH (timescaledb.continuous) AS
+SELECT time_bucket(INTERVAL '4 hours', time) AS time,
+       symbol,
+       exchange,
+       '4h'::TEXT AS period,
+       first(open, time) AS open,
+       max(high) AS high,
+       min(low) AS low,
+       last(close, time) AS close,
+       sum(volume) AS volume
+FROM klines
+WHERE period = '1m'
+GROUP BY time_bucket(INTERVAL '4 hours', time), symbol, exchange
+WITH NO DATA;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS klines_1d
+WITH (timescaledb.continuous) AS
+SELECT time_bucket(INTERVAL '1 day', time) AS time,
+       symbol,
+       exchange,
+       '1d'::TEXT AS period,
+       first(open, time) AS open,
+       max(high) AS high,
+       min(low) AS low,
+       last(close, time) AS close,
+       sum(volume) AS volume
+FROM klines
+WHERE period = '1m'
+GROUP BY time_bucket(INTERVAL '1 day', time), symbol, exchange
+WITH NO DATA;
+
+-- Refresh policies: keep the open bucket out of the materialization and let
+-- real-time aggregation serve it from the raw 1m rows.
+SELECT add_continuous_aggregate_policy('klines_5m',
+    start_offset => INTERVAL '1 day', end_offset => INTERVAL '5 minutes',
+    schedule_interval => INTERVAL '1 minute', if_not_exists => TRUE);
+SELECT add_continuous_aggregate_policy('klines_15m',
+    start_offset => INTERVAL '1 day', end_offset => INTERVAL '15 minutes',
+    schedule_interval => INTERVAL '5 minutes', if_not_exists => TRUE);
+SELECT add_continuous_aggregate_policy('klines_1h',
+    start_offset => INTERVAL '3 days', end_offset => INTERVAL '1 hour',
+    schedule_interval => INTERVAL '15 minutes', if_not_exists => TRUE);
+SELECT add_continuous_aggregate_policy('klines_4h',
+    start_offset => INTERVAL '7 days', end_offset => INTERVAL '4 hours',
+    schedule_interval => INTERVAL '1 hour', if_not_exists => TRUE);
+SELECT add_continuous_aggregate_policy('klines_1d',
+    start_offset => INTERVAL '30 days', end_offset => INTERVAL '1 day',
+    schedule_interval => INTERVAL '1 hour', if_not_exists => TRUE);
+
+ALTER MATERIALIZED VIEW klines_5m SET (timescaledb.materialized_only = FALSE);
+ALTER MATERIALIZED VIEW klines_15m SET (timescaledb.materialized_only = FALSE);
+ALTER MATERIALIZED VIEW klines_1h SET (timescaledb.materialized_only = FALSE);
+ALTER MATERIALIZED VIEW klines_4h SET (timescaledb.materialized_only = FALSE);
+ALTER MATERIALIZED VIEW klines_1d SET (timescaledb.materialized_only = FALSE);
+
+-- 2. Compression
+-- Chunks older than the policy window are compressed. Backfills into
+-- compressed ranges need TimescaleDB 2.11+ (DML on compressed chunks).
+ALTER TABLE trades SET (
+    timescaledb.compress,
+    timescaledb.compress_segmentby = 'symbol, exchange',
+    timescaledb.compress_orderby = 'time DESC, trade_id'
+);
+SELECT add_compression_policy('trades', INTERVAL '1 day', if_not_exists => TRUE);
+
+ALTER TABLE klines SET (
+    timescaledb.compress,
+    timescaledb.compress_segmentby = 'symbol, exchange, period',
+    timescaledb.compress_orderby = 'time DESC'
+);
+SELECT add_compression_policy('klines', INTERVAL '7 days', if_not_exists => TRUE);
+
+-- 3. Retention
+-- Retention is configured per table by the application at startup
+-- (RETENTION_TRADES, RETENTION_KLINES, RETENTION_KLINE_AGGREGATES).
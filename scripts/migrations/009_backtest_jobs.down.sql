@@ -0,0 +1,18 @@
+-- Rollback: Asynchronous Backtest Jobs
+
+DROP INDEX IF EXISTS idx_backtest_runs_user;
+DELETE FROM backtest_runs WHERE report IS NULL;
+ALTER TABLE backtest_runs ALTER COLUMN report SET NOT NULL;
+
+ALTER TABLE backtest_runs
+    DROP COLUMN IF EXISTS finished_at,
+    DROP COLUMN IF EXISTS started_at,
+    DROP COLUMN IF EXISTS error,
+    DROP COLUMN IF EXISTS spec,
+    DROP COLUMN IF EXISTS period,
+    DROP COLUMN IF EXISTS exchange,
+    DROP COLUMN IF EXISTS symbols,
+    DROP COLUMN IF EXISTS config,
+    DROP COLUMN IF EXISTS strategy_type,
+    DROP COLUMN IF EXISTS status,
+    DROP COLUMN IF EXISTS user_id;
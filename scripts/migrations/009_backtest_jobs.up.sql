@@ -0,0 +1,25 @@
+-- Migration: Asynchronous Backtest Jobs
+-- Existing rows were written synchronously and always carry a report, so they
+-- are backfilled as done.
+
+ALTER TABLE backtest_runs
+    ADD COLUMN IF NOT EXISTS user_id BIGINT REFERENCES users(id),
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'done', -- queued, running, done, failed, cancelled
+    ADD COLUMN IF NOT EXISTS strategy_type VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS config JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS symbols TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS exchange VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS period VARCHAR(10) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS spec JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS error TEXT,
+    ADD COLUMN IF NOT EXISTS started_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS finished_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE backtest_runs SET symbols = ARRAY[symbol] WHERE symbols = '{}';
+
+ALTER TABLE backtest_runs ALTER COLUMN status SET DEFAULT 'queued';
+-- The report is only written once the job is done
+ALTER TABLE backtest_runs ALTER COLUMN report DROP NOT NULL;
+
+-- Index for listing a user's runs
+CREATE INDEX IF NOT EXISTS idx_backtest_runs_user ON backtest_runs(user_id, created_at DESC);
@@ -0,0 +1,3 @@
+-- Rollback: Order Book Snapshots
+
+DROP TABLE IF EXISTS order_books;
@@ -0,0 +1,16 @@
+-- Migration: Order Book Snapshots
+-- Depth snapshots let tick-level backtests walk the book for market orders and
+-- estimate the queue in front of resting limit orders. Levels are stored as
+-- [price, amount] string pairs, like model.OrderBook.
+
+CREATE TABLE IF NOT EXISTS order_books (
+    time TIMESTAMPTZ NOT NULL,
+    symbol TEXT NOT NULL,
+    exchange TEXT NOT NULL,
+    bids JSONB NOT NULL,
+    asks JSONB NOT NULL,
+    PRIMARY KEY (symbol, exchange, time)
+);
+
+SELECT create_hypertable('order_books', 'time', if_not_exists => TRUE);
+
@@ -0,0 +1,14 @@
+-- Rollback: Strategy Versions
+
+DROP INDEX IF EXISTS idx_strategies_user;
+ALTER TABLE backtest_runs DROP COLUMN IF EXISTS strategy_version_id;
+
+DROP TABLE IF EXISTS strategy_versions;
+DROP FUNCTION IF EXISTS reject_strategy_version_change ();
+DROP TABLE IF EXISTS strategy_blobs;
+
+ALTER TABLE strategies
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS latest_version,
+    DROP COLUMN IF EXISTS description;
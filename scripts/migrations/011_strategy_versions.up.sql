@@ -0,0 +1,47 @@
+-- Migration: Strategy Versions
+-- A strategy keeps the type and config of its latest version. Versions are
+-- immutable, since backtests and live runs reference them, and WASM modules
+-- are stored once per SHA-256.
+
+ALTER TABLE strategies
+    ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS latest_version INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ DEFAULT NOW (),
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS strategy_blobs (
+    hash CHAR(64) PRIMARY KEY, -- hex SHA-256 of data
+    data BYTEA NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW ()
+);
+
+CREATE TABLE IF NOT EXISTS strategy_versions (
+    id BIGSERIAL PRIMARY KEY,
+    strategy_id BIGINT NOT NULL REFERENCES strategies (id),
+    version INT NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    config JSONB NOT NULL,
+    wasm_hash CHAR(64) REFERENCES strategy_blobs (hash),
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW (),
+    UNIQUE (strategy_id, version)
+);
+
+CREATE OR REPLACE FUNCTION reject_strategy_version_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'strategy versions are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS strategy_versions_immutable ON strategy_versions;
+CREATE TRIGGER strategy_versions_immutable BEFORE UPDATE OR DELETE ON strategy_versions
+    FOR EACH ROW EXECUTE FUNCTION reject_strategy_version_change();
+
+-- Existing strategies become their version 1
+INSERT INTO strategy_versions (strategy_id, version, type, config, created_at)
+SELECT id, 1, type, config, created_at FROM strategies WHERE latest_version = 0;
+UPDATE strategies SET latest_version = 1 WHERE latest_version = 0;
+
+ALTER TABLE backtest_runs ADD COLUMN IF NOT EXISTS strategy_version_id BIGINT REFERENCES strategy_versions (id);
+
+CREATE INDEX IF NOT EXISTS idx_strategies_user ON strategies (user_id) WHERE deleted_at IS NULL;
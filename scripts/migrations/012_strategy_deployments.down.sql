@@ -0,0 +1,5 @@
+-- Rollback: Strategy Deployments
+
+DROP INDEX IF EXISTS idx_strategy_deployments_active;
+DROP INDEX IF EXISTS idx_strategy_deployments_user;
+DROP TABLE IF EXISTS strategy_deployments;
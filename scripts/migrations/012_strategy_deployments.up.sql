@@ -0,0 +1,20 @@
+-- Migration: Strategy Deployments
+-- Stored strategy versions users run live. Deployments that are not stopped
+-- are restored when the strategy runner starts.
+
+CREATE TABLE IF NOT EXISTS strategy_deployments (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id),
+    strategy_version_id BIGINT NOT NULL REFERENCES strategy_versions (id),
+    symbol VARCHAR(20) NOT NULL,
+    period VARCHAR(10) NOT NULL,
+    capital NUMERIC(20, 8) NOT NULL DEFAULT 0,
+    paper BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(20) NOT NULL DEFAULT 'running', -- running, paused, stopped
+    position_qty NUMERIC(20, 8) NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW (),
+    updated_at TIMESTAMPTZ DEFAULT NOW ()
+);
+
+CREATE INDEX IF NOT EXISTS idx_strategy_deployments_user ON strategy_deployments (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_strategy_deployments_active ON strategy_deployments (id) WHERE status <> 'stopped';
@@ -0,0 +1,3 @@
+-- Rollback: Strategy States
+
+DROP TABLE IF EXISTS strategy_states;
@@ -0,0 +1,9 @@
+-- Migration: Strategy States
+-- The latest checkpoint of each live strategy, so a restarted runner resumes
+-- it where it left off instead of from a cold start.
+
+CREATE TABLE IF NOT EXISTS strategy_states (
+    key TEXT PRIMARY KEY, -- e.g. deployment:42
+    state BYTEA NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT NOW ()
+);
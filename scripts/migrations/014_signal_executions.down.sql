@@ -0,0 +1,5 @@
+-- Rollback: Signal Executions
+
+DROP INDEX IF EXISTS idx_signal_executions_deployment;
+DROP TABLE IF EXISTS signal_executions;
+ALTER TABLE strategy_deployments DROP COLUMN IF EXISTS sizing;
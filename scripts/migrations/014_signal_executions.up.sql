@@ -0,0 +1,25 @@
+-- Migration: Signal Executions
+-- How paper deployments size their buys, and what came of each signal they
+-- traded: the order it placed, or why it placed none. The order carries the fill.
+
+ALTER TABLE strategy_deployments
+    ADD COLUMN IF NOT EXISTS sizing JSONB NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS signal_executions (
+    id BIGSERIAL PRIMARY KEY,
+    deployment_id BIGINT NOT NULL REFERENCES strategy_deployments (id),
+    user_id BIGINT NOT NULL REFERENCES users (id),
+    strategy VARCHAR(100) NOT NULL,
+    symbol VARCHAR(20) NOT NULL,
+    action VARCHAR(10) NOT NULL, -- buy, sell
+    signal_price NUMERIC(20, 8) NOT NULL,
+    signal_time TIMESTAMPTZ NOT NULL,
+    sizing VARCHAR(20) NOT NULL DEFAULT '',
+    qty NUMERIC(20, 8) NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL, -- placed, skipped, rejected, failed
+    reason TEXT NOT NULL DEFAULT '',
+    order_id BIGINT REFERENCES paper_orders (id),
+    created_at TIMESTAMPTZ DEFAULT NOW ()
+);
+
+CREATE INDEX IF NOT EXISTS idx_signal_executions_deployment ON signal_executions (deployment_id, id DESC);
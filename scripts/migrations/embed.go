@@ -0,0 +1,11 @@
+// Package migrations embeds the versioned SQL schema migrations applied by internal/migrate.
+//
+// Files are named NNN_name.up.sql / NNN_name.down.sql. A file whose first line is
+// "-- migrate:no-transaction" is executed statement by statement outside a transaction,
+// which TimescaleDB requires for continuous aggregates.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS